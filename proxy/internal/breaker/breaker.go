@@ -0,0 +1,138 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+const window = 10 * time.Second
+
+type Settings struct {
+	ErrorRate   float64
+	MinRequests int
+	OpenFor     time.Duration
+}
+
+// Breaker считает ошибки в окне фиксированной длины. когда доля ошибок превышает порог,
+// breaker открывается на OpenFor, после чего пропускает один пробный запрос.
+type Breaker struct {
+	settings Settings
+
+	mu          sync.Mutex
+	state       State
+	windowStart time.Time
+	requests    int
+	failures    int
+	openUntil   time.Time
+	probing     bool
+}
+
+func New(settings Settings) *Breaker {
+	return &Breaker{settings: settings, state: StateClosed, windowStart: time.Now()}
+}
+
+func (b *Breaker) Settings() Settings {
+	return b.settings
+}
+
+func (b *Breaker) Allow() error {
+	if b.settings.ErrorRate <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case StateOpen:
+		if now.Before(b.openUntil) {
+			return ErrOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+		return nil
+	}
+
+	if now.Sub(b.windowStart) > window {
+		b.windowStart = now
+		b.requests = 0
+		b.failures = 0
+	}
+	return nil
+}
+
+func (b *Breaker) Report(failed bool) {
+	if b.settings.ErrorRate <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.state == StateHalfOpen {
+		b.probing = false
+		if failed {
+			b.trip(now)
+			return
+		}
+		b.state = StateClosed
+		b.windowStart = now
+		b.requests = 0
+		b.failures = 0
+		return
+	}
+
+	if b.state != StateClosed {
+		return
+	}
+
+	b.requests++
+	if failed {
+		b.failures++
+	}
+
+	if b.requests >= b.settings.MinRequests && float64(b.failures)/float64(b.requests) >= b.settings.ErrorRate {
+		b.trip(now)
+	}
+}
+
+// Release завершает запрос, который не дошел до апстрима или был отменен клиентом: он не считается
+// ни успехом, ни ошибкой, а пробный запрос можно повторить.
+func (b *Breaker) Release() {
+	if b.settings.ErrorRate <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *Breaker) trip(now time.Time) {
+	b.state = StateOpen
+	b.openUntil = now.Add(b.settings.OpenFor)
+	b.requests = 0
+	b.failures = 0
+}
@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerTrips(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		reports  []bool
+		open     bool
+	}{
+		{"error rate reached", Settings{ErrorRate: 0.5, MinRequests: 4}, []bool{true, false, true, false}, true},
+		{"below error rate", Settings{ErrorRate: 0.5, MinRequests: 4}, []bool{true, false, false, false}, false},
+		// до MinRequests даже одни ошибки не открывают breaker
+		{"too few requests", Settings{ErrorRate: 0.5, MinRequests: 4}, []bool{true, true, true}, false},
+		{"disabled", Settings{ErrorRate: 0, MinRequests: 1}, []bool{true, true, true}, false},
+	}
+
+	for _, tt := range tests {
+		tt.settings.OpenFor = time.Minute
+		b := New(tt.settings)
+		for _, failed := range tt.reports {
+			if err := b.Allow(); err != nil {
+				t.Fatalf("%s: unexpected error before tripping: %v", tt.name, err)
+			}
+			b.Report(failed)
+		}
+		if err := b.Allow(); errors.Is(err, ErrOpen) != tt.open {
+			t.Errorf("%s: expected open %v, got error %v", tt.name, tt.open, err)
+		}
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(b *Breaker)
+		state  State
+		allow  bool
+	}{
+		{"successful probe closes", func(b *Breaker) { b.Report(false) }, StateClosed, true},
+		{"failed probe reopens", func(b *Breaker) { b.Report(true) }, StateOpen, false},
+		// отмененный пробный запрос не решает судьбу breaker, следующий запрос снова пробный
+		{"released probe is retried", (*Breaker).Release, StateHalfOpen, true},
+	}
+
+	for _, tt := range tests {
+		b := New(Settings{ErrorRate: 0.5, MinRequests: 1, OpenFor: 20 * time.Millisecond})
+		b.Report(true)
+		if err := b.Allow(); !errors.Is(err, ErrOpen) {
+			t.Fatalf("%s: expected open breaker, got %v", tt.name, err)
+		}
+
+		time.Sleep(30 * time.Millisecond)
+		if err := b.Allow(); err != nil {
+			t.Fatalf("%s: expected probe after OpenFor, got %v", tt.name, err)
+		}
+		// пока идет проба, остальные запросы не пропускаются
+		if err := b.Allow(); !errors.Is(err, ErrOpen) {
+			t.Errorf("%s: expected a single probe, got %v", tt.name, err)
+		}
+
+		tt.finish(b)
+		if b.state != tt.state {
+			t.Errorf("%s: expected state %s, got %s", tt.name, tt.state, b.state)
+		}
+		if err := b.Allow(); (err == nil) != tt.allow {
+			t.Errorf("%s: expected allow %v, got %v", tt.name, tt.allow, err)
+		}
+	}
+}
+
+func TestBreakerWindowReset(t *testing.T) {
+	b := New(Settings{ErrorRate: 0.5, MinRequests: 2, OpenFor: time.Minute})
+	b.Report(true)
+	// ошибка из прошлого окна не складывается с новой
+	b.windowStart = time.Now().Add(-window - time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Report(true)
+	if err := b.Allow(); err != nil {
+		t.Errorf("expected closed breaker after window reset, got %v", err)
+	}
+}
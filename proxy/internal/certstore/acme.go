@@ -0,0 +1,100 @@
+package certstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"proxy/internal/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewACMEManager настраивает выпуск сертификатов. allowHost решает, для каких имен можно запрашивать
+// сертификат, чтобы клиент с произвольным SNI не мог исчерпать лимиты удостоверяющего центра.
+func NewACMEManager(cfg config.ACME, allowHost func(host string) bool) (*autocert.Manager, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.RootCAPath != "" {
+		pem, err := os.ReadFile(cfg.RootCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read acme root ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.RootCAPath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	httpClient := &http.Client{Transport: &orderLocationTransport{next: transport}}
+
+	return &autocert.Manager{
+		Prompt: autocert.AcceptTOS,
+		Cache:  autocert.DirCache(cfg.CacheDir),
+		Email:  cfg.Email,
+		HostPolicy: func(_ context.Context, host string) error {
+			if !allowHost(host) {
+				return fmt.Errorf("host %q is not served by any resource", host)
+			}
+			return nil
+		},
+		Client: &acme.Client{DirectoryURL: cfg.DirectoryURL, HTTPClient: httpClient},
+	}, nil
+}
+
+// orderLocationTransport восстанавливает заголовок Location в ответе на finalize. acme.Client берет из него
+// адрес заказа, чтобы дождаться выпуска, а CA с асинхронной финализацией (например, pebble) его не присылают.
+// адрес заказа запоминается по ответу на создание заказа, где есть и Location, и ссылка finalize.
+type orderLocationTransport struct {
+	next   http.RoundTripper
+	mu     sync.Mutex
+	orders map[string]string
+}
+
+func (t *orderLocationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodPost {
+		return resp, err
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		t.mu.Lock()
+		if orderURL, ok := t.orders[req.URL.String()]; ok {
+			resp.Header.Set("Location", orderURL)
+		}
+		t.mu.Unlock()
+		return resp, nil
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var order struct {
+		Finalize string `json:"finalize"`
+	}
+	if json.Unmarshal(body, &order) == nil && order.Finalize != "" {
+		t.mu.Lock()
+		if t.orders == nil {
+			t.orders = make(map[string]string)
+		}
+		t.orders[order.Finalize] = location
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
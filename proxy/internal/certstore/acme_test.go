@@ -0,0 +1,57 @@
+package certstore
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderLocationTransport(t *testing.T) {
+	var ca *httptest.Server
+	ca = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/new-order":
+			w.Header().Set("Location", ca.URL+"/order/1")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"status":"pending","finalize":%q}`, ca.URL+"/finalize/1")
+		case "/finalize/1", "/finalize/2":
+			// асинхронная финализация без Location
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"status":"processing"}`)
+		}
+	}))
+	defer ca.Close()
+
+	client := &http.Client{Transport: &orderLocationTransport{next: http.DefaultTransport}}
+
+	resp, err := client.Post(ca.URL+"/new-order", "application/jose+json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// тело ответа на создание заказа остается доступно вызывающему
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) == 0 {
+		t.Error("expected order body to be preserved")
+	}
+
+	tests := []struct {
+		path     string
+		location string
+	}{
+		{"/finalize/1", ca.URL + "/order/1"},
+		{"/finalize/2", ""},
+	}
+	for _, tt := range tests {
+		resp, err := client.Post(ca.URL+tt.path, "application/jose+json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Location"); got != tt.location {
+			t.Errorf("%s: expected Location %q, got %q", tt.path, tt.location, got)
+		}
+	}
+}
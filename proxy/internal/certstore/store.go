@@ -0,0 +1,179 @@
+package certstore
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+	"proxy/internal/logger"
+	"router"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// table - сертификаты, проиндексированные по именам из SAN. ключи wildcard хранятся без "*".
+type table struct {
+	exact    map[string]*tls.Certificate
+	wildcard map[string]*tls.Certificate
+}
+
+// Store выбирает сертификат по SNI. сертификаты берутся из каталога (пары name.crt и name.key)
+// и из таблицы rules engine и периодически перечитываются без перезапуска прокси.
+// если подходящего сертификата нет, а acme включен, сертификат выпускается через acme.
+type Store struct {
+	dir     string
+	client  *rules.RulesEngineClient
+	acme    *autocert.Manager
+	current atomic.Pointer[table]
+}
+
+func New(dir string, client *rules.RulesEngineClient, acmeManager *autocert.Manager) *Store {
+	s := &Store{dir: dir, client: client, acme: acmeManager}
+	s.current.Store(&table{exact: map[string]*tls.Certificate{}, wildcard: map[string]*tls.Certificate{}})
+	return s
+}
+
+// Reload перечитывает сертификаты. битые файлы и записи пропускаются, а при недоступности rules engine
+// его сертификаты остаются от прошлой загрузки.
+func (s *Store) Reload() error {
+	l := logger.Logger()
+	next := &table{exact: map[string]*tls.Certificate{}, wildcard: map[string]*tls.Certificate{}}
+
+	if s.dir != "" {
+		files, err := filepath.Glob(filepath.Join(s.dir, "*.crt"))
+		if err != nil {
+			return err
+		}
+		for _, certFile := range files {
+			keyFile := strings.TrimSuffix(certFile, ".crt") + ".key"
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				l.Info("skip certificate file", zap.String("file", certFile), zap.Error(err))
+				continue
+			}
+			if err := next.add(&cert); err != nil {
+				l.Info("skip certificate file", zap.String("file", certFile), zap.Error(err))
+			}
+		}
+	}
+
+	var fetchErr error
+	if s.client != nil {
+		certificates, err := s.client.GetCertificates()
+		if err != nil {
+			fetchErr = err
+			next.merge(s.current.Load())
+		}
+		for _, c := range certificates {
+			cert, err := tls.X509KeyPair([]byte(c.CertPEM), []byte(c.KeyPEM))
+			if err != nil {
+				l.Info("skip certificate", zap.String("name", c.Name), zap.Error(err))
+				continue
+			}
+			if err := next.add(&cert); err != nil {
+				l.Info("skip certificate", zap.String("name", c.Name), zap.Error(err))
+			}
+		}
+	}
+
+	s.current.Store(next)
+	return fetchErr
+}
+
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				logger.Logger().Info("failed to reload certificates", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *Store) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if s.acme != nil && isACMEChallenge(hello) {
+		return s.acme.GetCertificate(hello)
+	}
+
+	name := router.NormalizeHost(hello.ServerName)
+	if cert, ok := s.current.Load().lookup(name); ok {
+		return cert, nil
+	}
+
+	if s.acme != nil && name != "" {
+		return s.acme.GetCertificate(hello)
+	}
+	return nil, fmt.Errorf("no certificate for %q", name)
+}
+
+func (t *table) lookup(name string) (*tls.Certificate, bool) {
+	if cert, ok := t.exact[name]; ok {
+		return cert, true
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		cert, ok := t.wildcard[name[i:]]
+		return cert, ok
+	}
+	return nil, false
+}
+
+// add индексирует сертификат по всем именам. при совпадении имен побеждает сертификат, действующий дольше,
+// так что новый сертификат можно выложить до удаления старого.
+func (t *table) add(cert *tls.Certificate) error {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+	cert.Leaf = leaf
+
+	names := leaf.DNSNames
+	if len(names) == 0 && leaf.Subject.CommonName != "" {
+		names = []string{leaf.Subject.CommonName}
+	}
+	for _, name := range names {
+		name = router.NormalizeHost(name)
+		index := t.exact
+		if strings.HasPrefix(name, "*.") {
+			index, name = t.wildcard, name[1:]
+		}
+		if prev, ok := index[name]; ok && prev.Leaf.NotAfter.After(leaf.NotAfter) {
+			continue
+		}
+		index[name] = cert
+	}
+	return nil
+}
+
+func (t *table) merge(other *table) {
+	for name, cert := range other.exact {
+		if _, ok := t.exact[name]; !ok {
+			t.exact[name] = cert
+		}
+	}
+	for name, cert := range other.wildcard {
+		if _, ok := t.wildcard[name]; !ok {
+			t.wildcard[name] = cert
+		}
+	}
+}
+
+func isACMEChallenge(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+}
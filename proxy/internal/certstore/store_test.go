@@ -0,0 +1,175 @@
+package certstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+
+	"golang.org/x/crypto/acme"
+)
+
+// issue выпускает самоподписанный сертификат и возвращает его и ключ в PEM.
+func issue(t *testing.T, commonName string, notAfter time.Time, names ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     names,
+		NotBefore:    notAfter.Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writePair(t *testing.T, dir, name string, certPEM, keyPEM []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedName возвращает CommonName сертификата, выбранного для SNI, или пустую строку.
+func servedName(s *Store, serverName string) string {
+	cert, err := s.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	if err != nil {
+		return ""
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestStoreLookup(t *testing.T) {
+	dir := t.TempDir()
+	soon, later := time.Now().Add(24*time.Hour), time.Now().Add(90*24*time.Hour)
+
+	cert, key := issue(t, "exact", later, "api.example.com")
+	writePair(t, dir, "exact", cert, key)
+	cert, key = issue(t, "wildcard", later, "*.example.com", "example.com")
+	writePair(t, dir, "wildcard", cert, key)
+	// из двух сертификатов на одно имя выбирается действующий дольше
+	cert, key = issue(t, "old", soon, "shop.example.org")
+	writePair(t, dir, "old", cert, key)
+	cert, key = issue(t, "new", later, "shop.example.org")
+	writePair(t, dir, "new", cert, key)
+	cert, key = issue(t, "cn-only", later)
+	writePair(t, dir, "cn", cert, key)
+	cert, key = issue(t, "expired", time.Now().Add(-time.Hour), "expired.example.net")
+	writePair(t, dir, "expired", cert, key)
+	if err := os.WriteFile(filepath.Join(dir, "broken.crt"), []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(dir, nil, nil)
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{"api.example.com", "exact"},
+		{"API.Example.com.", "exact"},
+		{"www.example.com", "wildcard"},
+		{"example.com", "wildcard"},
+		// wildcard покрывает только один уровень
+		{"a.b.example.com", ""},
+		{"shop.example.org", "new"},
+		{"cn-only", "cn-only"},
+		{"expired.example.net", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := servedName(s, tt.serverName); got != tt.want {
+			t.Errorf("GetCertificate(%q) = %q, want %q", tt.serverName, got, tt.want)
+		}
+	}
+}
+
+func TestStoreReloadFromRulesEngine(t *testing.T) {
+	var fail atomic.Bool
+	certPEM, keyPEM := issue(t, "remote", time.Now().Add(24*time.Hour), "remote.example.com")
+	rulesEngine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var resp rules.CertificatesResponse
+		resp.Data.Certificates = []rules.Certificate{
+			{Name: "remote", CertPEM: string(certPEM), KeyPEM: string(keyPEM)},
+			{Name: "invalid", CertPEM: "garbage", KeyPEM: "garbage"},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer rulesEngine.Close()
+
+	dir := t.TempDir()
+	s := New(dir, rules.NewRulesEngineClient(rulesEngine.URL, "token"), nil)
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := servedName(s, "remote.example.com"); got != "remote" {
+		t.Fatalf("expected certificate from rules engine, got %q", got)
+	}
+
+	// при недоступном rules engine его сертификаты остаются, а файлы из каталога перечитываются
+	cert, key := issue(t, "local", time.Now().Add(24*time.Hour), "local.example.com")
+	writePair(t, dir, "local", cert, key)
+	fail.Store(true)
+	if err := s.Reload(); err == nil {
+		t.Error("expected error from unavailable rules engine")
+	}
+	if got := servedName(s, "remote.example.com"); got != "remote" {
+		t.Errorf("expected previous certificate to be kept, got %q", got)
+	}
+	if got := servedName(s, "local.example.com"); got != "local" {
+		t.Errorf("expected new file to be loaded, got %q", got)
+	}
+}
+
+func TestIsACMEChallenge(t *testing.T) {
+	tests := []struct {
+		protos []string
+		want   bool
+	}{
+		{[]string{acme.ALPNProto}, true},
+		{[]string{"h2", acme.ALPNProto}, false},
+		{[]string{"h2", "http/1.1"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isACMEChallenge(&tls.ClientHelloInfo{SupportedProtos: tt.protos}); got != tt.want {
+			t.Errorf("isACMEChallenge(%v) = %v, want %v", tt.protos, got, tt.want)
+		}
+	}
+}
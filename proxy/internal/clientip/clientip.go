@@ -0,0 +1,161 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Resolver определяет адрес клиента. заголовкам X-Forwarded-For, Forwarded и X-Real-Ip верим только
+// если соединение пришло от доверенного прокси, а цепочку разбираем справа налево до первого
+// недоверенного адреса: все, что левее, мог дописать сам клиент.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, raw := range trustedProxies {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "/") {
+			addr, err := netip.ParseAddr(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", raw, err)
+			}
+			addr = addr.Unmap()
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", raw, err)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+func (r *Resolver) IsTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve возвращает нормализованный адрес клиента (v4 или v6 без порта и зоны).
+func (r *Resolver) Resolve(req *http.Request) string {
+	peer, ok := ParseAddr(req.RemoteAddr)
+	if !ok {
+		return req.RemoteAddr
+	}
+	if !r.IsTrusted(peer) {
+		return peer.String()
+	}
+
+	chain := forwardedChain(req.Header)
+	if len(chain) == 0 {
+		if realIP, ok := ParseAddr(req.Header.Get("X-Real-Ip")); ok {
+			return realIP.String()
+		}
+		return peer.String()
+	}
+
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, ok := ParseAddr(chain[i])
+		if !ok {
+			// мусор в цепочке мог записать только клиент, дальше не идем
+			break
+		}
+		client = addr
+		if !r.IsTrusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Middleware определяет адрес клиента один раз и кладет его в контекст запроса.
+func Middleware(resolver *Resolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKey{}, resolver.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromRequest возвращает адрес, найденный Middleware. без middleware используется адрес соединения.
+func FromRequest(r *http.Request) string {
+	if ip, ok := r.Context().Value(contextKey{}).(string); ok {
+		return ip
+	}
+	if addr, ok := ParseAddr(r.RemoteAddr); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+// ParseAddr разбирает адрес в любом из видов: "ip", "ip:port", "[ipv6]:port", "[ipv6]".
+func ParseAddr(raw string) (netip.Addr, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return netip.Addr{}, false
+	}
+
+	if addr, err := netip.ParseAddr(strings.Trim(raw, "[]")); err == nil {
+		return addr.Unmap().WithZone(""), true
+	}
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			return addr.Unmap().WithZone(""), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// forwardedChain возвращает цепочку адресов от клиента к последнему прокси. если есть заголовок
+// Forwarded (RFC 7239), берем его, иначе X-Forwarded-For. несколько заголовков склеиваются по порядку.
+func forwardedChain(header http.Header) []string {
+	var chain []string
+
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				chain = append(chain, forwardedFor(element))
+			}
+		}
+		return chain
+	}
+
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+	return chain
+}
+
+// forwardedFor достает параметр for из элемента Forwarded: for=192.0.2.60;proto=http;by=203.0.113.43.
+// "unknown" и обфусцированные идентификаторы вернутся как есть и не разберутся как адрес.
+func forwardedFor(element string) string {
+	for _, pair := range strings.Split(element, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestNewResolver(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8", " 192.168.1.1 ", "", "::ffff:172.16.0.0/108", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		addr    string
+		trusted bool
+	}{
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"172.16.5.5", true},
+		{"172.32.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, tt := range tests {
+		if got := r.IsTrusted(netip.MustParseAddr(tt.addr)); got != tt.trusted {
+			t.Errorf("IsTrusted(%s) = %v, want %v", tt.addr, got, tt.trusted)
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.local", "10.0.0.300"} {
+		if _, err := NewResolver([]string{invalid}); err == nil {
+			t.Errorf("NewResolver(%q): expected error", invalid)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{name: "untrusted peer ignores headers", remote: "203.0.113.5:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}, "X-Real-Ip": {"2.2.2.2"}},
+			want:    "203.0.113.5"},
+		{name: "trusted peer without headers", remote: "10.0.0.1:4000",
+			want: "10.0.0.1"},
+		{name: "x-forwarded-for from trusted peer", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "198.51.100.7"},
+		{name: "spoofed left part is skipped", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.7, 10.0.0.2"}},
+			want:    "198.51.100.7"},
+		{name: "repeated headers are joined", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1", "198.51.100.7"}},
+			want:    "198.51.100.7"},
+		{name: "chain of trusted proxies", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:    "10.0.0.3"},
+		{name: "garbage stops the walk", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7, evil, 10.0.0.2"}},
+			want:    "10.0.0.2"},
+		{name: "x-real-ip from trusted peer", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"X-Real-Ip": {"198.51.100.7"}},
+			want:    "198.51.100.7"},
+		{name: "invalid x-real-ip", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"X-Real-Ip": {"unknown"}},
+			want:    "10.0.0.1"},
+		{name: "forwarded", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"Forwarded": {`for=198.51.100.7;proto=https;by=10.0.0.1`}},
+			want:    "198.51.100.7"},
+		{name: "forwarded takes precedence over x-forwarded-for", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"Forwarded": {"for=198.51.100.7"}, "X-Forwarded-For": {"1.1.1.1"}},
+			want:    "198.51.100.7"},
+		{name: "forwarded ipv6 with port", remote: "[2001:db8::10]:443",
+			headers: map[string][]string{"Forwarded": {`for=1.1.1.1, For="[2001:db9:cafe::17]:4711", for=10.0.0.5`}},
+			want:    "2001:db9:cafe::17"},
+		{name: "forwarded spoofed element", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"Forwarded": {"for=1.1.1.1", "for=198.51.100.7"}},
+			want:    "198.51.100.7"},
+		{name: "forwarded unknown", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"Forwarded": {"for=unknown, for=10.0.0.2"}},
+			want:    "10.0.0.2"},
+		{name: "forwarded without for", remote: "10.0.0.1:4000",
+			headers: map[string][]string{"Forwarded": {"proto=https"}},
+			want:    "10.0.0.1"},
+		{name: "mapped peer address", remote: "[::ffff:10.0.0.1]:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"::ffff:198.51.100.7"}},
+			want:    "198.51.100.7"},
+		{name: "unparsable remote address", remote: "@unix",
+			want: "@unix"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		for name, values := range tt.headers {
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+		if got := r.Resolve(req); got != tt.want {
+			t.Errorf("%s: Resolve = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{" 192.0.2.1:8080 ", "192.0.2.1"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"fe80::1%eth0", "fe80::1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"", ""},
+		{"unknown", ""},
+		{"_hidden", ""},
+	}
+
+	for _, tt := range tests {
+		addr, ok := ParseAddr(tt.raw)
+		got := ""
+		if ok {
+			got = addr.String()
+		}
+		if got != tt.want {
+			t.Errorf("ParseAddr(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	handler := Middleware(r, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = FromRequest(req)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "198.51.100.7" {
+		t.Errorf("expected client from middleware, got %q", got)
+	}
+
+	// без middleware заголовкам не верим
+	if ip := FromRequest(req); ip != "10.0.0.1" {
+		t.Errorf("expected peer address without middleware, got %q", ip)
+	}
+}
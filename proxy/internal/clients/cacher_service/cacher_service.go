@@ -7,7 +7,6 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 )
@@ -29,19 +28,15 @@ func NewCacherClient(url string) *CacherClient {
 	}
 }
 
-func (cc *CacherClient) GenerateCacheKey(req *http.Request) (string, error) {
+func (cc *CacherClient) GenerateCacheKey(req *http.Request, body []byte) string {
 	encodedQuery := req.URL.Query().Encode()
-	key := fmt.Sprintf("%s:%s?%s", req.Method, req.URL.Path, encodedQuery)
+	key := fmt.Sprintf("%s:%s:%s?%s", req.Method, req.Host, req.URL.Path, encodedQuery)
 
 	if req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch {
-		bodyHash, err := hashRequestBody(req)
-		if err != nil {
-			return "", err
-		}
-		key += fmt.Sprintf(":%s", bodyHash)
+		key += fmt.Sprintf(":%s", hashRequestBody(body))
 	}
 
-	return key, nil
+	return key
 }
 
 func (cc *CacherClient) GetCache(ctx context.Context, key string) (string, error) {
@@ -95,13 +90,7 @@ func (cc *CacherClient) SetCache(ctx context.Context, key, value string) error {
 	return nil
 }
 
-func hashRequestBody(req *http.Request) (string, error) {
-	body, err := io.ReadAll(req.Body)
-	if err != nil {
-		return "", err
-	}
-	defer req.Body.Close()
-
+func hashRequestBody(body []byte) string {
 	hash := sha256.Sum256(body)
-	return hex.EncodeToString(hash[:]), nil
+	return hex.EncodeToString(hash[:])
 }
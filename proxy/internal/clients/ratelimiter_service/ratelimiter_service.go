@@ -3,6 +3,7 @@ package ratelimiterservice
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -15,8 +16,8 @@ func NewRateLimiterClient(url string) *RateLimiterClient {
 }
 
 func (rl *RateLimiterClient) CheckLimit(ip string) (bool, error) {
-	url := fmt.Sprintf("%s?ip=%s", rl.rateLimiterURL, ip)
-	request, err := http.NewRequest(http.MethodGet, url, nil)
+	requestURL := fmt.Sprintf("%s?ip=%s", rl.rateLimiterURL, url.QueryEscape(ip))
+	request, err := http.NewRequest(http.MethodGet, requestURL, nil)
 	if err != nil {
 		return false, fmt.Errorf("error creating request: %w", err)
 	}
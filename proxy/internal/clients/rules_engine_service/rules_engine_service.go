@@ -8,11 +8,65 @@ import (
 	"time"
 )
 
+const (
+	BalancerRoundRobin     = "round_robin"
+	BalancerLeastConn      = "least_conn"
+	BalancerConsistentHash = "consistent_hash"
+)
+
+type Upstream struct {
+	ID                  string   `json:"id"`
+	Name                string   `json:"name"`
+	Balancer            string   `json:"balancer"`
+	HashKey             string   `json:"hash_key"`
+	Targets             []string `json:"targets"`
+	HealthCheckPath     string   `json:"health_check_path"`
+	HealthCheckInterval int      `json:"health_check_interval"`
+	MaxFails            int      `json:"max_fails"`
+	FailTimeout         int      `json:"fail_timeout"`
+}
+
+type Policy struct {
+	ConnectTimeoutMs   int     `json:"connect_timeout_ms"`
+	ReadTimeoutMs      int     `json:"read_timeout_ms"`
+	Retries            int     `json:"retries"`
+	RetryBackoffMs     int     `json:"retry_backoff_ms"`
+	BreakerErrorRate   float64 `json:"breaker_error_rate"`
+	BreakerMinRequests int     `json:"breaker_min_requests"`
+	BreakerOpenMs      int     `json:"breaker_open_ms"`
+}
+
 type Resource struct {
-	Name   string `json:"name"`
-	URL    string `json:"url"`
-	Host   string `json:"host"`
-	Method string `json:"http_method"`
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	URL             string    `json:"url"`
+	Host            string    `json:"host"`
+	Hostname        string    `json:"hostname"`
+	Method          string    `json:"http_method"`
+	IsActive        *bool     `json:"is_active"`
+	Upstream        *Upstream `json:"upstream"`
+	Policy          Policy    `json:"policy"`
+	MaxBodyBytes    int64     `json:"max_body_bytes"`
+	AllowUpgrade    bool      `json:"allow_upgrade"`
+	InspectMessages bool      `json:"inspect_messages"`
+	HTTPSRedirect   bool      `json:"https_redirect"`
+	LogOnly         bool      `json:"log_only"`
+}
+
+type Certificate struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Hostnames []string `json:"hostnames"`
+	CertPEM   string   `json:"cert_pem"`
+	KeyPEM    string   `json:"key_pem"`
+}
+
+type certificatesWrapper struct {
+	Certificates []Certificate `json:"certificates"`
+}
+
+type CertificatesResponse struct {
+	Data certificatesWrapper `json:"data"`
 }
 
 type resourcesWrapper struct {
@@ -25,29 +79,55 @@ type ResourcesResponse struct {
 
 type RulesEngineClient struct {
 	rulesEngineURL string
+	internalToken  string
 }
 
 type AnalyzerRequest struct {
 	IP      string            `json:"ip"`
+	Host    string            `json:"host"`
 	Method  string            `json:"method"`
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
 	Body    string            `json:"body"`
 }
 
+type RuleMatch struct {
+	RuleID     string `json:"rule_id"`
+	RuleName   string `json:"rule_name"`
+	AttackType string `json:"attack_type"`
+	Action     string `json:"action"`
+	Reason     string `json:"reason"`
+	Variable   string `json:"variable,omitempty"`
+	LogOnly    bool   `json:"log_only"`
+	Score      int    `json:"score,omitempty"`
+}
+
 type AnalyzerResult struct {
-	Action       string `json:"action"`
-	ModifiedURL  string `json:"modified_url,omitempty"`
-	ModifiedBody string `json:"modified_body,omitempty"`
-	Reason       string `json:"reason"`
+	Action       string      `json:"action"`
+	ModifiedURL  string      `json:"modified_url,omitempty"`
+	ModifiedBody string      `json:"modified_body,omitempty"`
+	BodyModified bool        `json:"body_modified,omitempty"`
+	Reason       string      `json:"reason"`
+	AnomalyScore int         `json:"anomaly_score,omitempty"`
+	ScoringRules []string    `json:"scoring_rule_ids,omitempty"`
+	Matches      []RuleMatch `json:"matches,omitempty"`
+	CSRFToken    *CSRFToken  `json:"csrf_token,omitempty"`
+}
+
+// CSRFToken - токен, который rules engine выдал на безопасный запрос. прокси отдает его клиенту в cookie и заголовке.
+type CSRFToken struct {
+	Value      string `json:"value"`
+	CookieName string `json:"cookie_name"`
+	HeaderName string `json:"header_name"`
+	MaxAge     int    `json:"max_age"`
 }
 
 type AnalyzerResponse struct {
 	Data AnalyzerResult `json:"data"`
 }
 
-func NewRulesEngineClient(url string) *RulesEngineClient {
-	return &RulesEngineClient{rulesEngineURL: url}
+func NewRulesEngineClient(url, internalToken string) *RulesEngineClient {
+	return &RulesEngineClient{rulesEngineURL: url, internalToken: internalToken}
 }
 
 func (re *RulesEngineClient) GetResources() ([]Resource, error) {
@@ -64,6 +144,10 @@ func (re *RulesEngineClient) GetResources() ([]Resource, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from rules engine: %d", resp.StatusCode)
+	}
+
 	var resourcesResp ResourcesResponse
 	if err := json.NewDecoder(resp.Body).Decode(&resourcesResp); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -72,8 +156,36 @@ func (re *RulesEngineClient) GetResources() ([]Resource, error) {
 	return resourcesResp.Data.Resources, nil
 }
 
-func (re *RulesEngineClient) AnalyzeRequest(ip, method, url, body string, headers map[string]string) (*AnalyzerResult, error) {
-	respBody, err := json.Marshal(AnalyzerRequest{IP: ip, Method: method, URL: url, Body: body, Headers: headers})
+// GetCertificates загружает сертификаты с приватными ключами через внутренний api rules engine.
+func (re *RulesEngineClient) GetCertificates() ([]Certificate, error) {
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/internal/certificates", re.rulesEngineURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header.Set("X-Internal-Token", re.internalToken)
+
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting certificate list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from rules engine: %d", resp.StatusCode)
+	}
+
+	var certificatesResp CertificatesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&certificatesResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return certificatesResp.Data.Certificates, nil
+}
+
+func (re *RulesEngineClient) AnalyzeRequest(ip, host, method, url, body string, headers map[string]string) (*AnalyzerResult, error) {
+	respBody, err := json.Marshal(AnalyzerRequest{IP: ip, Host: host, Method: method, URL: url, Body: body, Headers: headers})
 	if err != nil {
 		return nil, err
 	}
@@ -9,12 +9,42 @@ import (
 )
 
 type Config struct {
-	Env            string `env:"ENV"               env-required:"true" yaml:"env"`
-	HTTPServer     `yaml:"http_server"`
-	IpsFilePath    string `env:"IPS_PATH"`
-	RateLimiterURL string `yaml:"ratelimiter_url"`
-	CacherURL      string `yaml:"cacher_url"`
-	RulesEngineURL string `yaml:"rules_engine_url"`
+	Env                      string `env:"ENV"               env-required:"true" yaml:"env"`
+	HTTPServer               `yaml:"http_server"`
+	IpsFilePath              string        `env:"IPS_PATH"`
+	RateLimiterURL           string        `yaml:"ratelimiter_url"`
+	CacherURL                string        `yaml:"cacher_url"`
+	RulesEngineURL           string        `yaml:"rules_engine_url"`
+	RulesEngineToken         string        `env:"INTERNAL_API_TOKEN" yaml:"rules_engine_token"`
+	ResourcesRefreshInterval time.Duration `env-default:"10s" yaml:"resources_refresh_interval"`
+	AdminServer              AdminServer   `yaml:"admin_server"`
+	InspectBodyBytes         int64         `env-default:"65536" yaml:"inspect_body_bytes"`
+	CacheMaxBytes            int64         `env-default:"1048576" yaml:"cache_max_bytes"`
+	TLSServer                TLSServer     `yaml:"tls_server"`
+	TrustedProxies           []string      `env:"TRUSTED_PROXIES" env-separator:"," yaml:"trusted_proxies"`
+	ProxyProtocol            bool          `env:"PROXY_PROTOCOL" yaml:"proxy_protocol"`
+}
+
+// TLSServer - https-листенер. пустой адрес отключает tls.
+type TLSServer struct {
+	Address              string        `yaml:"address"`
+	CertDir              string        `yaml:"cert_dir"`
+	CertsRefreshInterval time.Duration `env-default:"1m" yaml:"certs_refresh_interval"`
+	ACME                 ACME          `yaml:"acme"`
+}
+
+// ACME - выпуск сертификатов по протоколу acme. для локальной проверки directory_url указывает на pebble,
+// а root_ca_path - на его корневой сертификат.
+type ACME struct {
+	Enabled      bool   `env:"ACME_ENABLED" yaml:"enabled"`
+	DirectoryURL string `env:"ACME_DIRECTORY_URL" env-default:"https://acme-v02.api.letsencrypt.org/directory" yaml:"directory_url"`
+	RootCAPath   string `env:"ACME_ROOT_CA_PATH" yaml:"root_ca_path"`
+	Email        string `yaml:"email"`
+	CacheDir     string `env-default:"acme" yaml:"cache_dir"`
+}
+
+type AdminServer struct {
+	Address string `env-default:"localhost:8090" yaml:"address"`
 }
 
 type HTTPServer struct {
@@ -41,6 +71,13 @@ func LoadConfig() (*Config, error) {
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
 		return nil, fmt.Errorf("cannot read config: %w", err)
 	}
+	// time.NewTicker паникует на неположительном интервале
+	if cfg.ResourcesRefreshInterval <= 0 {
+		return nil, fmt.Errorf("resources_refresh_interval must be positive")
+	}
+	if cfg.TLSServer.Address != "" && cfg.TLSServer.CertsRefreshInterval <= 0 {
+		return nil, fmt.Errorf("tls_server.certs_refresh_interval must be positive")
+	}
 
 	return &cfg, nil
 }
@@ -0,0 +1,11 @@
+package proxy
+
+import "net/http"
+
+type UpstreamsStatusResponse struct {
+	Upstreams any `json:"upstreams"`
+}
+
+func (ph *ProxyHandler) HandleUpstreamsStatus(w http.ResponseWriter, r *http.Request) {
+	WriteJSONResponse(w, UpstreamsStatusResponse{Upstreams: ph.upstreams.Status()}, http.StatusOK)
+}
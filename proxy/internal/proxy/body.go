@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const copyBufferSize = 32 * 1024
+
+var errBodyConsumed = errors.New("streamed request body can be sent only once")
+
+// requestBody - тело запроса, из которого в память прочитано только начало (окно анализа).
+// если тело целиком поместилось в окно, оно считается полным и может быть отправлено повторно.
+type requestBody struct {
+	head     []byte
+	rest     io.Reader
+	length   int64
+	complete bool
+	consumed bool
+}
+
+func readRequestBody(r *http.Request, window int64) (*requestBody, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return &requestBody{complete: true}, nil
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r.Body, window+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(head)) <= window {
+		return &requestBody{head: head, length: int64(len(head)), complete: true}, nil
+	}
+
+	return &requestBody{
+		head:   head[:window],
+		rest:   io.MultiReader(bytes.NewReader(head[window:]), r.Body),
+		length: r.ContentLength,
+	}, nil
+}
+
+// replaceHead подменяет проанализированное начало тела, например после sanitize.
+func (b *requestBody) replaceHead(head []byte) {
+	if b.length >= 0 {
+		b.length += int64(len(head) - len(b.head))
+	}
+	b.head = head
+}
+
+func (b *requestBody) replayable() bool {
+	return b.complete
+}
+
+func (b *requestBody) reader() (io.Reader, int64, error) {
+	if b.complete {
+		return bytes.NewReader(b.head), int64(len(b.head)), nil
+	}
+	if b.consumed {
+		return nil, 0, errBodyConsumed
+	}
+	b.consumed = true
+	return io.MultiReader(bytes.NewReader(b.head), b.rest), b.length, nil
+}
+
+// copyResponse отдает ответ клиенту по мере чтения из апстрима. если ответ не больше cacheLimit,
+// он дополнительно собирается в буфер и возвращается для кэша.
+func copyResponse(w http.ResponseWriter, resp *http.Response, cacheLimit int64) ([]byte, bool, error) {
+	for k, v := range resp.Header {
+		// cookie, выставленные прокси, например csrf-токен, дополняют cookie апстрима
+		if k == "Set-Cookie" {
+			w.Header()[k] = append(w.Header()[k], v...)
+			continue
+		}
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	stream := isEventStream(resp)
+	flush := stream || resp.ContentLength < 0
+	cacheable := !stream && cacheLimit > 0 && resp.ContentLength <= cacheLimit
+	rc := http.NewResponseController(w)
+
+	var cached bytes.Buffer
+	buf := make([]byte, copyBufferSize)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return nil, false, err
+			}
+			if cacheable {
+				if int64(cached.Len()+n) > cacheLimit {
+					cacheable = false
+					cached.Reset()
+				} else {
+					cached.Write(buf[:n])
+				}
+			}
+			if flush {
+				_ = rc.Flush()
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return nil, false, readErr
+		}
+	}
+
+	return cached.Bytes(), cacheable, nil
+}
+
+func isEventStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
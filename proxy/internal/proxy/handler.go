@@ -2,116 +2,202 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
+	"sync"
 
+	"proxy/internal/breaker"
 	cacher "proxy/internal/clients/cacher_service"
 	ratelimiter "proxy/internal/clients/ratelimiter_service"
 	rules "proxy/internal/clients/rules_engine_service"
 	"proxy/internal/config"
 	"proxy/internal/logger"
-)
+	"proxy/internal/upstream"
+	"router"
 
-type ResourceMap map[string]map[string]rules.Resource
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
 
 type ProxyHandler struct {
-	resources         ResourceMap
-	transport         http.RoundTripper
+	resources         *ResourceStore
+	upstreams         *upstream.Manager
+	transports        sync.Map
+	breakers          sync.Map
 	rateLimiterClient *ratelimiter.RateLimiterClient
 	cacherClient      *cacher.CacherClient
 	rulesEngineClient *rules.RulesEngineClient
+	inspectBodyBytes  int64
+	cacheMaxBytes     int64
+	httpsPort         string
 }
 
 func NewProxyHandler(cfg *config.Config) (*ProxyHandler, error) {
-	rulesClient := rules.NewRulesEngineClient(cfg.RulesEngineURL)
+	rulesClient := rules.NewRulesEngineClient(cfg.RulesEngineURL, cfg.RulesEngineToken)
 
-	resources, err := rulesClient.GetResources()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load resources from rules engine: %w", err)
+	upstreams := upstream.NewManager()
+	resources := NewResourceStore(rulesClient, upstreams, cfg.ResourcesRefreshInterval)
+	if err := resources.Refresh(); err != nil {
+		// rules engine может подняться позже прокси, таблица подтянется при следующем обновлении
+		logger.Logger().Info("initial resources load failed", zap.Error(err))
 	}
 
-	// TODO: доступные ресурсы должны обновляться постоянно, а не только при инициализации хэндлера, т.е. при деплое
-	resourcesMap := make(ResourceMap)
-	for _, res := range resources {
-		if _, exists := resourcesMap[res.URL]; !exists {
-			resourcesMap[res.URL] = make(map[string]rules.Resource)
+	var httpsPort string
+	if cfg.TLSServer.Address != "" {
+		_, port, err := net.SplitHostPort(cfg.TLSServer.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls server address: %w", err)
 		}
-		resourcesMap[res.URL][res.Method] = res
+		httpsPort = port
 	}
 
 	return &ProxyHandler{
-		resources: resourcesMap,
-		transport: &http.Transport{
-			DisableKeepAlives: true,
-		},
+		resources:         resources,
+		upstreams:         upstreams,
 		rateLimiterClient: ratelimiter.NewRateLimiterClient(cfg.RateLimiterURL),
 		cacherClient:      cacher.NewCacherClient(cfg.CacherURL),
 		rulesEngineClient: rulesClient,
+		inspectBodyBytes:  cfg.InspectBodyBytes,
+		cacheMaxBytes:     cfg.CacheMaxBytes,
+		httpsPort:         httpsPort,
 	}, nil
 }
 
+// ServesHost сообщает, есть ли ресурс с таким hostname. используется, чтобы выпускать сертификаты только для своих сайтов.
+func (ph *ProxyHandler) ServesHost(host string) bool {
+	return ph.resources.Load().HasExactHost(host)
+}
+
+func (ph *ProxyHandler) WatchResources(ctx context.Context) {
+	go ph.upstreams.RunHealthChecks(ctx)
+	ph.resources.Watch(ctx)
+}
+
 func (ph *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	requestID := uuid.NewString()
 	ctx = context.WithValue(ctx, "request-id", requestID)
 	l := logger.Logger()
 
-	resourceMethods, pathExists := ph.resources[r.URL.Path]
-	if !pathExists {
-		WriteJSONResponse(w, NewErrorResponse("endpoint not found", http.StatusNotFound, requestID), http.StatusNotFound)
+	if hasDotSegment(r.URL.Path) {
+		WriteJSONResponse(w, NewErrorResponse("invalid request path", http.StatusBadRequest, requestID), http.StatusBadRequest)
 		return
 	}
 
-	resource, methodExists := resourceMethods[r.Method]
-	if !methodExists {
+	resource, match, err := ph.resources.Load().Lookup(RequestHost(r), r.URL.Path, r.Method)
+	if errors.Is(err, router.ErrNotFound) {
+		WriteJSONResponse(w, NewErrorResponse("endpoint not found", http.StatusNotFound, requestID), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, router.ErrMethodNotAllowed) {
 		WriteJSONResponse(w, NewErrorResponse("method not allowed", http.StatusMethodNotAllowed, requestID), http.StatusMethodNotAllowed)
 		return
 	}
 
-	if code, err := ph.validateRequest(r); err != nil {
-		WriteJSONResponse(w, NewErrorResponse(err.Error(), code, requestID), code)
+	if resource.HTTPSRedirect && r.TLS == nil && ph.httpsPort != "" {
+		redirectToHTTPS(w, r, ph.httpsPort)
 		return
 	}
-	cacheKey, _ := ph.cacherClient.GenerateCacheKey(r)
-	cachedData, err := ph.cacherClient.GetCache(ctx, cacheKey)
-	if err == nil {
-		l.Info("request was cached", zap.String("request_id", requestID))
 
-		w.Write([]byte(cachedData))
+	if isUpgradeRequest(r) {
+		ph.serveUpgrade(w, r, resource, match, requestID)
 		return
-	} else {
-		l.Info("error while getting cache by key", zap.String("key", cacheKey), zap.Error(err))
 	}
 
-	req, err := ph.modifyRequest(ctx, r, resource)
+	if resource.MaxBodyBytes > 0 {
+		if r.ContentLength > resource.MaxBodyBytes {
+			WriteJSONResponse(w, NewErrorResponse("request body too large", http.StatusRequestEntityTooLarge, requestID), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, resource.MaxBodyBytes)
+	}
+
+	body, err := readRequestBody(r, ph.inspectBodyBytes)
 	if err != nil {
-		WriteJSONResponse(w, NewErrorResponse("internal server error", http.StatusInternalServerError, requestID), http.StatusInternalServerError)
+		code, msg := http.StatusBadRequest, "failed to read request body"
+		if isBodyTooLarge(err) {
+			code, msg = http.StatusRequestEntityTooLarge, "request body too large"
+		}
+		WriteJSONResponse(w, NewErrorResponse(msg, code, requestID), code)
+		return
+	}
+
+	if code, err := ph.validateRequest(w, r, body); err != nil {
+		WriteJSONResponse(w, NewErrorResponse(err.Error(), code, requestID), code)
 		return
 	}
 
-	resp, err := ph.forwardRequest(ctx, req)
+	// тело, не поместившееся в окно анализа, не участвует в ключе, поэтому такие запросы не кэшируем
+	cacheKey := ""
+	if body.complete {
+		cacheKey = ph.cacherClient.GenerateCacheKey(r, body.head)
+		cachedData, err := ph.cacherClient.GetCache(ctx, cacheKey)
+		if err == nil {
+			l.Info("request was cached", zap.String("request_id", requestID))
+
+			w.Write([]byte(cachedData))
+			return
+		} else {
+			l.Info("error while getting cache by key", zap.String("key", cacheKey), zap.Error(err))
+		}
+	}
+
+	resp, err := ph.roundTrip(ctx, r, resource, match, body)
 	if err != nil {
-		l.Info("error while proxing request", zap.String("key", cacheKey), zap.Error(err))
+		ph.writeRoundTripError(w, err, resource, requestID)
+		return
+	}
+	defer resp.Body.Close()
+
+	// 4xx апстрима отдаем клиенту как есть, а детали 5xx не раскрываем
+	if resp.StatusCode >= http.StatusInternalServerError {
+		l.Info("upstream returned error", zap.String("resource", resource.Name), zap.Int("status", resp.StatusCode))
+
+		WriteJSONResponse(w, NewErrorResponse("proxy error", http.StatusBadGateway, requestID), http.StatusBadGateway)
+		return
+	}
+
+	cacheLimit := ph.cacheMaxBytes
+	if cacheKey == "" || resp.StatusCode >= http.StatusMultipleChoices {
+		cacheLimit = 0
+	}
 
-		WriteJSONResponse(w, NewErrorResponse("proxy error", http.StatusInternalServerError, requestID), http.StatusInternalServerError)
+	respBody, cacheable, err := copyResponse(w, resp, cacheLimit)
+	if err != nil {
+		l.Info("error while streaming response", zap.String("resource", resource.Name), zap.Error(err))
 		return
 	}
-	respBody, _ := io.ReadAll(resp.Body)
 
 	// TODO: раскомментить и пофиксить когда-нибудь
 	// go func() {
-	err = ph.cacherClient.SetCache(ctx, cacheKey, string(respBody))
-	if err != nil {
-		l.Info("failed to cache response", zap.String("key", cacheKey), zap.Error(err))
+	if cacheable {
+		err = ph.cacherClient.SetCache(ctx, cacheKey, string(respBody))
+		if err != nil {
+			l.Info("failed to cache response", zap.String("key", cacheKey), zap.Error(err))
+		}
 	}
 	// }()
+}
 
-	for k, v := range resp.Header {
-		w.Header()[k] = v
-	}
+func (ph *ProxyHandler) writeRoundTripError(w http.ResponseWriter, err error, resource rules.Resource, requestID string) {
+	l := logger.Logger()
+
+	switch {
+	case isBodyTooLarge(err):
+		WriteJSONResponse(w, NewErrorResponse("request body too large", http.StatusRequestEntityTooLarge, requestID), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, breaker.ErrOpen):
+		l.Info("circuit breaker is open", zap.String("resource", resource.Name))
 
-	w.WriteHeader(resp.StatusCode)
+		WriteJSONResponse(w, NewErrorResponse("upstream temporarily unavailable", http.StatusServiceUnavailable, requestID), http.StatusServiceUnavailable)
+	case errors.Is(err, upstream.ErrNoAvailableTargets):
+		l.Info("no upstream target available", zap.String("resource", resource.Name), zap.Error(err))
 
-	w.Write(respBody)
+		WriteJSONResponse(w, NewErrorResponse("no upstream available", http.StatusServiceUnavailable, requestID), http.StatusServiceUnavailable)
+	default:
+		l.Info("error while proxing request", zap.String("resource", resource.Name), zap.Error(err))
+
+		WriteJSONResponse(w, NewErrorResponse("proxy error", http.StatusBadGateway, requestID), http.StatusBadGateway)
+	}
 }
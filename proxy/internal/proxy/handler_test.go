@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+	"proxy/internal/upstream"
+)
+
+func TestHasDotSegment(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api/../admin", true},
+		{"/api/./admin", true},
+		{"/api/..", true},
+		{"/..", true},
+		{`/api/..\admin`, true},
+		{"/api/admin", false},
+		{"/api/..admin", false},
+		{"/api/.well-known/x", false},
+		{"/files/a.b", false},
+		{"/", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasDotSegment(tt.path); got != tt.want {
+			t.Errorf("hasDotSegment(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestServeHTTPRejectsDotSegments(t *testing.T) {
+	store := NewResourceStore(nil, upstream.NewManager(), time.Minute)
+	store.current.Store(buildResourceMap([]rules.Resource{{ID: "api", URL: "/api/*", Method: "GET", Host: "http://upstream"}}))
+	ph := &ProxyHandler{resources: store}
+
+	for _, target := range []string{"/api/../admin", "/api/%2e%2e/admin", "/api/..%2Fadmin", "/api/%2E/x"} {
+		rec := httptest.NewRecorder()
+		ph.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
@@ -1,19 +1,59 @@
 package proxy
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"unicode/utf8"
 
+	"proxy/internal/clientip"
 	rules "proxy/internal/clients/rules_engine_service"
 	"proxy/internal/logger"
+	"proxy/internal/upstream"
+	"router"
+
+	"go.uber.org/zap"
 )
 
-func (ph *ProxyHandler) modifyRequest(ctx context.Context, r *http.Request, resource rules.Resource) (*http.Request, error) {
-	rawUrl, err := url.Parse(resource.Host)
+// upstreamTarget выбирает адрес апстрима. если у ресурса есть пул, host ресурса трактуется как путь внутри таргета,
+// а при пустом host проксируется исходный путь запроса. done сообщает пулу результат запроса.
+func (ph *ProxyHandler) upstreamTarget(r *http.Request, resource rules.Resource, match *router.Match) (string, func(failed bool), error) {
+	if resource.Upstream == nil {
+		return expandTarget(resource.Host, match), func(bool) {}, nil
+	}
+
+	pool, ok := ph.upstreams.Get(resource.Upstream.ID)
+	if !ok {
+		return "", nil, upstream.ErrNoAvailableTargets
+	}
+
+	header, byIP := pool.HashKeySource()
+	hashKey := r.Header.Get(header)
+	if byIP {
+		hashKey = ReadUserIP(r)
+	}
+
+	target, err := pool.Pick(hashKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	path := r.URL.Path
+	if resource.Host != "" {
+		path = expandTarget(resource.Host, match)
+	}
+
+	return strings.TrimSuffix(target.URL.String(), "/") + path, func(failed bool) { pool.Done(target, failed) }, nil
+}
+
+func (ph *ProxyHandler) modifyRequest(ctx context.Context, r *http.Request, targetURL string, body io.Reader, length int64) (*http.Request, error) {
+	rawUrl, err := url.Parse(targetURL)
 	if err != nil {
 		return nil, err
 	}
@@ -21,35 +61,56 @@ func (ph *ProxyHandler) modifyRequest(ctx context.Context, r *http.Request, reso
 	queryParams := r.URL.Query().Encode()
 	url := fmt.Sprintf("%s?%s", rawUrl, queryParams)
 
-	req, err := http.NewRequestWithContext(ctx, r.Method, url, r.Body)
+	req, err := http.NewRequestWithContext(ctx, r.Method, url, body)
 	if err != nil {
 		return nil, err
 	}
+	req.ContentLength = length
+	if length == 0 {
+		req.Body = http.NoBody
+	}
 
 	for k, v := range r.Header {
 		req.Header[k] = v
 	}
 
-	req.Header.Set("X-Forwarded-Host", r.Header.Get("Host"))
-	req.Header.Set("X-Forwarded-Proto", r.Proto)
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	req.Header.Set("X-Forwarded-Proto", requestScheme(r))
 
 	return req, nil
 }
 
-func (ph *ProxyHandler) forwardRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
-	resp, err := ph.transport.RoundTrip(req)
-	if err != nil {
-		return nil, fmt.Errorf("proxy request failed: %v", err)
+// hasDotSegment сообщает, есть ли в декодированном пути сегмент . или ... путь не нормализуется, поэтому
+// /api/../admin совпал бы с ресурсом /api/*, а апстрим получил бы /admin в обход политики этого ресурса.
+// обратная косая черта считается разделителем, как на части серверов.
+func hasDotSegment(path string) bool {
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' })
+	for _, segment := range segments {
+		if segment == "." || segment == ".." {
+			return true
+		}
 	}
+	return false
+}
 
-	if resp.StatusCode >= http.StatusBadRequest {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+// expandTarget подставляет параметры шаблона в адрес апстрима, а для префиксных ресурсов дописывает оставшийся путь.
+func expandTarget(host string, match *router.Match) string {
+	if match == nil {
+		return host
 	}
 
-	return resp, nil
+	for name, value := range match.Params {
+		host = strings.ReplaceAll(host, "{"+name+"}", url.PathEscape(value))
+	}
+
+	if match.Rest != "" {
+		host = strings.TrimSuffix(host, "/") + match.Rest
+	}
+
+	return host
 }
 
-func (ph *ProxyHandler) validateRequest(r *http.Request) (int, error) {
+func (ph *ProxyHandler) validateRequest(w http.ResponseWriter, r *http.Request, body *requestBody) (int, error) {
 	ip := ReadUserIP(r)
 	l := logger.Logger()
 
@@ -58,37 +119,41 @@ func (ph *ProxyHandler) validateRequest(r *http.Request) (int, error) {
 		return http.StatusTooManyRequests, fmt.Errorf("too many requests")
 	}
 
-	var bodyBytes []byte
-	if r.Body != nil {
-		bodyBytes, _ = io.ReadAll(r.Body)
-	}
-
-	headers := make(map[string]string)
-	for k, v := range r.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
-		}
-	}
-
+	analyzed := analyzedHead(body)
 	analysisResp, err := ph.rulesEngineClient.AnalyzeRequest(
 		ip,
+		RequestHost(r),
 		r.Method,
 		r.URL.String(),
-		string(bodyBytes),
-		headers,
+		string(analyzed),
+		requestHeaders(r),
 	)
 	if err != nil {
 		l.Info("error analyzing request", zap.Error(err))
 		return http.StatusInternalServerError, fmt.Errorf("error analyzing request")
 	}
+	logWouldBlock(r, ip, analysisResp.Matches)
 
 	switch analysisResp.Action {
 	case "block":
-		l.Info("blocker request from ip", zap.String("ip", ip), zap.String("reason", analysisResp.Reason))
+		l.Info(
+			"blocker request from ip",
+			zap.String("ip", ip),
+			zap.String("reason", analysisResp.Reason),
+			zap.Int("anomaly_score", analysisResp.AnomalyScore),
+			zap.Strings("scoring_rule_ids", analysisResp.ScoringRules),
+		)
 		return http.StatusForbidden, fmt.Errorf("request blocked: %s", analysisResp.Reason)
 	case "allow":
-		if analysisResp.ModifiedBody != "" {
-			r.Body = io.NopCloser(bytes.NewBufferString(analysisResp.ModifiedBody))
+		setCSRFToken(w, r, analysisResp.CSRFToken)
+		// анализатор возвращает тело строкой json, поэтому неизмененное тело не подменяется: бинарные
+		// данные в нем испорчены. тело не текстового типа нельзя безопасно переписать, и запрос с ним блокируется
+		if analysisResp.BodyModified {
+			if !isTextContent(r.Header.Get("Content-Type")) {
+				l.Info("blocked request with modified non-text body", zap.String("ip", ip), zap.String("reason", analysisResp.Reason))
+				return http.StatusForbidden, fmt.Errorf("request blocked: %s", analysisResp.Reason)
+			}
+			body.replaceHead(append([]byte(analysisResp.ModifiedBody), body.head[len(analyzed):]...))
 		}
 		if analysisResp.ModifiedURL != "" {
 			modifiedURL, err := url.Parse(analysisResp.ModifiedURL)
@@ -100,13 +165,133 @@ func (ph *ProxyHandler) validateRequest(r *http.Request) (int, error) {
 	return http.StatusOK, nil
 }
 
-func ReadUserIP(r *http.Request) string {
-	IPAddress := r.Header.Get("X-Real-Ip")
-	if IPAddress == "" {
-		IPAddress = r.Header.Get("X-Forwarded-For")
+// analyzedHead возвращает часть окна тела, которую получает анализатор: окно может разрезать
+// многобайтовый символ, и его начало остается в теле как есть.
+func analyzedHead(body *requestBody) []byte {
+	head := body.head
+	if body.complete {
+		return head
+	}
+	for i := 1; i <= utf8.UTFMax && i <= len(head); i++ {
+		if utf8.RuneStart(head[len(head)-i]) {
+			if !utf8.FullRune(head[len(head)-i:]) {
+				return head[:len(head)-i]
+			}
+			break
+		}
+	}
+	return head
+}
+
+// isTextContent сообщает, текстовое ли тело: только его анализатор может вернуть измененным без потерь.
+func isTextContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded", "application/graphql":
+		return true
 	}
-	if IPAddress == "" {
-		IPAddress = r.RemoteAddr
+	return false
+}
+
+// setCSRFToken выставляет выданный rules engine токен в cookie для double submit и в заголовок ответа.
+// cookie не HttpOnly: клиентский код читает из нее токен и отправляет его в заголовке.
+func setCSRFToken(w http.ResponseWriter, r *http.Request, token *rules.CSRFToken) {
+	if token == nil {
+		return
 	}
-	return IPAddress
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     token.CookieName,
+		Value:    token.Value,
+		Path:     "/",
+		MaxAge:   token.MaxAge,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set(token.HeaderName, token.Value)
+}
+
+// logWouldBlock пишет событие для каждого правила в режиме log_only, которое сработало бы в боевом режиме.
+func logWouldBlock(r *http.Request, ip string, matches []rules.RuleMatch) {
+	for _, match := range matches {
+		if !match.LogOnly {
+			continue
+		}
+		event := "would-block"
+		if match.Action != "block" {
+			event = "would-modify"
+		}
+		logger.Logger().Info(
+			event,
+			zap.String("rule_id", match.RuleID),
+			zap.String("rule_name", match.RuleName),
+			zap.String("attack_type", match.AttackType),
+			zap.String("action", match.Action),
+			zap.String("reason", match.Reason),
+			zap.String("variable", match.Variable),
+			zap.String("ip", ip),
+			zap.String("host", RequestHost(r)),
+			zap.String("method", r.Method),
+			zap.String("url", r.URL.String()),
+		)
+	}
+}
+
+func requestHeaders(r *http.Request) map[string]string {
+	headers := make(map[string]string)
+	for k, v := range r.Header {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+	return headers
+}
+
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// redirectToHTTPS отправляет клиента на https-листенер. GET и HEAD получают 301, остальные методы - 308,
+// чтобы клиент повторил запрос с тем же методом и телом.
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request, port string) {
+	host := RequestHost(r)
+	if port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	code := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+}
+
+// RequestHost возвращает имя сайта, к которому обратился клиент: SNI для TLS, иначе заголовок Host.
+func RequestHost(r *http.Request) string {
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		return router.NormalizeHost(r.TLS.ServerName)
+	}
+	return router.NormalizeHost(r.Host)
+}
+
+// ReadUserIP возвращает адрес клиента, найденный с учетом доверенных прокси.
+func ReadUserIP(r *http.Request) string {
+	return clientip.FromRequest(r)
 }
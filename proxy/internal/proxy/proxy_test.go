@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+	"proxy/internal/config"
+)
+
+// newTestProxy поднимает прокси с фейковыми rules engine, rate limiter и cacher. analyze отвечает
+// на запрос анализа вместо rules engine, по умолчанию пропуская запрос.
+func newTestProxy(t *testing.T, resources []rules.Resource, inspectBodyBytes int64, analyze func(rules.AnalyzerRequest) rules.AnalyzerResult) *httptest.Server {
+	t.Helper()
+	if analyze == nil {
+		analyze = func(req rules.AnalyzerRequest) rules.AnalyzerResult {
+			return rules.AnalyzerResult{Action: "allow", ModifiedBody: req.Body}
+		}
+	}
+
+	rulesEngine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/resources" {
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"resources": resources}})
+			return
+		}
+		var req rules.AnalyzerRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]any{"data": analyze(req)})
+	}))
+	t.Cleanup(rulesEngine.Close)
+	rateLimiter := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(rateLimiter.Close)
+	cacher := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(cacher.Close)
+
+	ph, err := NewProxyHandler(&config.Config{
+		RulesEngineURL:           rulesEngine.URL,
+		RateLimiterURL:           rateLimiter.URL,
+		CacherURL:                cacher.URL,
+		ResourcesRefreshInterval: time.Minute,
+		InspectBodyBytes:         inspectBodyBytes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(ph)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newRecordingUpstream возвращает апстрим, который запоминает тело последнего запроса.
+func newRecordingUpstream(t *testing.T) (*httptest.Server, func() []byte) {
+	t.Helper()
+	bodies := make(chan []byte, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		select {
+		case <-bodies:
+		default:
+		}
+		bodies <- body
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream, func() []byte {
+		select {
+		case body := <-bodies:
+			return body
+		default:
+			return nil
+		}
+	}
+}
+
+func TestBinaryUploadPassesThroughUnchanged(t *testing.T) {
+	upstream, received := newRecordingUpstream(t)
+	active := true
+	resources := []rules.Resource{{ID: "upload", URL: "/upload", Host: upstream.URL, Method: http.MethodPost, IsActive: &active}}
+
+	var payload bytes.Buffer
+	form := multipart.NewWriter(&payload)
+	file, _ := form.CreateFormFile("file", "image.bin")
+	binary := make([]byte, 512)
+	for i := range binary {
+		binary[i] = byte(i * 7)
+	}
+	file.Write(binary)
+	form.Close()
+
+	// окно анализа меньше тела и режет его посреди бинарных данных
+	for _, window := range []int64{100, 4096} {
+		srv := newTestProxy(t, resources, window, nil)
+		resp, err := http.Post(srv.URL+"/upload", form.FormDataContentType(), bytes.NewReader(payload.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("window %d: expected 200, got %d", window, resp.StatusCode)
+		}
+		if !bytes.Equal(received(), payload.Bytes()) {
+			t.Errorf("window %d: upstream received a different body", window)
+		}
+	}
+}
+
+func TestModifiedBody(t *testing.T) {
+	upstream, received := newRecordingUpstream(t)
+	active := true
+	resources := []rules.Resource{{ID: "form", URL: "/form", Host: upstream.URL, Method: http.MethodPost, IsActive: &active}}
+	sanitize := func(req rules.AnalyzerRequest) rules.AnalyzerResult {
+		return rules.AnalyzerResult{Action: "allow", ModifiedBody: strings.ReplaceAll(req.Body, "<script>", ""), BodyModified: true}
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		window      int64
+		status      int
+		want        string
+	}{
+		{"json", "application/json", `{"a": "<script>x"}`, 4096, http.StatusOK, `{"a": "x"}`},
+		{"text cut inside a rune", "text/plain", "<script>ééé", 9, http.StatusOK, "ééé"},
+		{"binary", "application/octet-stream", "<script>\xff\xfe", 4096, http.StatusForbidden, ""},
+		{"multipart", "multipart/form-data; boundary=x", "--x\r\n<script>\r\n--x--", 4096, http.StatusForbidden, ""},
+		{"no content type", "", "<script>x", 4096, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestProxy(t, resources, tt.window, sanitize)
+			req, _ := http.NewRequest(http.MethodPost, srv.URL+"/form", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, resp.StatusCode)
+			}
+			if got := string(received()); tt.status == http.StatusOK && got != tt.want {
+				t.Errorf("expected upstream body %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+	"proxy/internal/logger"
+	"proxy/internal/upstream"
+	"router"
+
+	"go.uber.org/zap"
+)
+
+type ResourceMap = router.Router[rules.Resource]
+
+// ResourceStore хранит актуальную таблицу ресурсов и периодически перечитывает ее из rules engine.
+type ResourceStore struct {
+	client    *rules.RulesEngineClient
+	upstreams *upstream.Manager
+	interval  time.Duration
+	current   atomic.Pointer[ResourceMap]
+}
+
+func NewResourceStore(client *rules.RulesEngineClient, upstreams *upstream.Manager, interval time.Duration) *ResourceStore {
+	store := &ResourceStore{
+		client:    client,
+		upstreams: upstreams,
+		interval:  interval,
+	}
+	store.current.Store(router.New[rules.Resource]())
+	return store
+}
+
+func (s *ResourceStore) Load() *ResourceMap {
+	return s.current.Load()
+}
+
+func (s *ResourceStore) Refresh() error {
+	resources, err := s.client.GetResources()
+	if err != nil {
+		return fmt.Errorf("failed to load resources from rules engine: %w", err)
+	}
+
+	s.upstreams.Sync(collectUpstreams(resources))
+	s.current.Store(buildResourceMap(resources))
+	return nil
+}
+
+// Watch обновляет таблицу до отмены контекста. при ошибке остается последняя успешно загруженная таблица.
+func (s *ResourceStore) Watch(ctx context.Context) {
+	l := logger.Logger()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				l.Info("failed to refresh resources, keeping previous table", zap.Error(err))
+			}
+		}
+	}
+}
+
+func buildResourceMap(resources []rules.Resource) *ResourceMap {
+	resourcesMap := router.New[rules.Resource]()
+	for _, res := range resources {
+		if res.IsActive != nil && !*res.IsActive {
+			continue
+		}
+		if err := resourcesMap.Add(res.Hostname, res.URL, res.Method, res); err != nil {
+			logger.Logger().Info(
+				"skipping resource with invalid pattern",
+				zap.String("hostname", res.Hostname),
+				zap.String("url", res.URL),
+				zap.Error(err),
+			)
+		}
+	}
+	return resourcesMap
+}
+
+func collectUpstreams(resources []rules.Resource) []rules.Upstream {
+	seen := make(map[string]struct{})
+	var upstreams []rules.Upstream
+	for _, res := range resources {
+		if res.Upstream == nil || (res.IsActive != nil && !*res.IsActive) {
+			continue
+		}
+		if _, exists := seen[res.Upstream.ID]; exists {
+			continue
+		}
+		seen[res.Upstream.ID] = struct{}{}
+		upstreams = append(upstreams, *res.Upstream)
+	}
+	return upstreams
+}
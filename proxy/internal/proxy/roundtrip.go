@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"proxy/internal/breaker"
+	rules "proxy/internal/clients/rules_engine_service"
+	"router"
+)
+
+var errBodyNotWritable = errors.New("response body is not writable")
+
+// roundTrip отправляет запрос в апстрим с учетом политики ресурса: таймаутов, ретраев идемпотентных
+// запросов с экспоненциальной задержкой и circuit breaker'а. ответы 5xx и сбои соединения считаются ошибкой апстрима,
+// отмена запроса клиентом - нет.
+func (ph *ProxyHandler) roundTrip(
+	ctx context.Context,
+	r *http.Request,
+	resource rules.Resource,
+	match *router.Match,
+	body *requestBody,
+) (*http.Response, error) {
+	cb := ph.breakerFor(resource)
+	if err := cb.Allow(); err != nil {
+		return nil, err
+	}
+
+	attempts := 1
+	if isIdempotent(r.Method) && body.replayable() {
+		attempts += resource.Policy.Retries
+	}
+	transport := ph.transportFor(resource.Policy)
+
+	// upstreamFailed - была ли ошибка самого апстрима: отмена клиентом и пустой пул ею не считаются
+	var lastErr error
+	upstreamFailed := false
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepBackoff(ctx, resource.Policy.RetryBackoffMs, attempt); err != nil {
+				lastErr = err
+				break
+			}
+		}
+
+		targetURL, done, err := ph.upstreamTarget(r, resource, match)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		reader, length, err := body.reader()
+		if err != nil {
+			done(false)
+			cb.Release()
+			return nil, err
+		}
+
+		req, err := ph.modifyRequest(ctx, r, targetURL, reader, length)
+		if err != nil {
+			done(false)
+			cb.Release()
+			return nil, err
+		}
+
+		resp, err := transport.RoundTrip(req)
+		// отмена клиентом и тело больше лимита ресурса - ошибки клиента, а не апстрима
+		if err != nil && (ctx.Err() != nil || isBodyTooLarge(err)) {
+			done(false)
+			lastErr = err
+			break
+		}
+		if err != nil {
+			done(true)
+			upstreamFailed = true
+			lastErr = fmt.Errorf("proxy request failed: %w", err)
+			continue
+		}
+
+		failed := resp.StatusCode >= http.StatusInternalServerError
+		if failed && attempt < attempts-1 && isRetryableStatus(resp.StatusCode) {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			done(true)
+			upstreamFailed = true
+			lastErr = fmt.Errorf("server returned status %d", resp.StatusCode)
+			continue
+		}
+
+		cb.Report(failed)
+		resp.Body = &reportingBody{ReadCloser: resp.Body, done: func() { done(failed) }}
+		return resp, nil
+	}
+
+	if upstreamFailed {
+		cb.Report(true)
+	} else {
+		cb.Release()
+	}
+	return nil, lastErr
+}
+
+func (ph *ProxyHandler) transportFor(policy rules.Policy) http.RoundTripper {
+	key := fmt.Sprintf("%d:%d", policy.ConnectTimeoutMs, policy.ReadTimeoutMs)
+	if transport, ok := ph.transports.Load(key); ok {
+		return transport.(http.RoundTripper)
+	}
+
+	transport, _ := ph.transports.LoadOrStore(key, &http.Transport{
+		DisableKeepAlives:     true,
+		DialContext:           (&net.Dialer{Timeout: milliseconds(policy.ConnectTimeoutMs)}).DialContext,
+		ResponseHeaderTimeout: milliseconds(policy.ReadTimeoutMs),
+	})
+	return transport.(http.RoundTripper)
+}
+
+// breakerFor возвращает breaker ресурса. при изменении настроек в rules engine состояние сбрасывается.
+func (ph *ProxyHandler) breakerFor(resource rules.Resource) *breaker.Breaker {
+	settings := breaker.Settings{
+		ErrorRate:   resource.Policy.BreakerErrorRate,
+		MinRequests: resource.Policy.BreakerMinRequests,
+		OpenFor:     milliseconds(resource.Policy.BreakerOpenMs),
+	}
+
+	if cb, ok := ph.breakers.Load(resource.ID); ok && cb.(*breaker.Breaker).Settings() == settings {
+		return cb.(*breaker.Breaker)
+	}
+
+	cb := breaker.New(settings)
+	ph.breakers.Store(resource.ID, cb)
+	return cb
+}
+
+func sleepBackoff(ctx context.Context, backoffMs, attempt int) error {
+	delay := milliseconds(backoffMs) << (attempt - 1)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+func milliseconds(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// reportingBody сообщает пулу о завершении запроса только после того, как тело ответа прочитано.
+type reportingBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *reportingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.done != nil {
+		b.done()
+		b.done = nil
+	}
+	return err
+}
+
+// Write нужен для ответов 101 Switching Protocols: их тело - это само соединение с апстримом.
+func (b *reportingBody) Write(p []byte) (int, error) {
+	w, ok := b.ReadCloser.(io.Writer)
+	if !ok {
+		return 0, errBodyNotWritable
+	}
+	return w.Write(p)
+}
@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	rules "proxy/internal/clients/rules_engine_service"
+)
+
+func TestOversizedBodyIsNotAnUpstreamFailure(t *testing.T) {
+	upstream, _ := newRecordingUpstream(t)
+	active := true
+	resources := []rules.Resource{{
+		ID:           "upload",
+		URL:          "/upload",
+		Host:         upstream.URL,
+		Method:       http.MethodPost,
+		IsActive:     &active,
+		MaxBodyBytes: 100,
+		// одна ошибка открыла бы breaker
+		Policy: rules.Policy{BreakerErrorRate: 0.5, BreakerMinRequests: 1, BreakerOpenMs: 60000},
+	}}
+	srv := newTestProxy(t, resources, 16, nil)
+
+	post := func(body string) int {
+		// без Content-Length лимит срабатывает, только когда тело дочитывается при отправке в апстрим
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/upload", io.MultiReader(strings.NewReader(body)))
+		req.Header.Set("Content-Type", "text/plain")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for range 3 {
+		if code := post(strings.Repeat("x", 1000)); code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected 413 for oversized body, got %d", code)
+		}
+	}
+	if code := post("small"); code != http.StatusOK {
+		t.Fatalf("expected 200 after oversized bodies, got %d", code)
+	}
+}
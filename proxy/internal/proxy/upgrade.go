@@ -0,0 +1,298 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	rules "proxy/internal/clients/rules_engine_service"
+	"proxy/internal/logger"
+	"proxy/internal/websocket"
+	"router"
+
+	"go.uber.org/zap"
+)
+
+var errMessageBlocked = errors.New("websocket message blocked")
+
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveUpgrade проксирует запросы с Connection: Upgrade. после 101 от апстрима соединение клиента
+// перехватывается и байты гоняются в обе стороны, а текстовые сообщения websocket при включенной
+// инспекции проверяются в rules engine.
+func (ph *ProxyHandler) serveUpgrade(w http.ResponseWriter, r *http.Request, resource rules.Resource, match *router.Match, requestID string) {
+	l := logger.Logger()
+
+	if !resource.AllowUpgrade {
+		WriteJSONResponse(w, NewErrorResponse("upgrade not allowed", http.StatusForbidden, requestID), http.StatusForbidden)
+		return
+	}
+
+	body := &requestBody{complete: true}
+	if code, err := ph.validateRequest(w, r, body); err != nil {
+		WriteJSONResponse(w, NewErrorResponse(err.Error(), code, requestID), code)
+		return
+	}
+
+	inspect := resource.InspectMessages && strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+	if inspect {
+		// сжатые кадры (permessage-deflate) проверить нельзя, поэтому не даем договориться о расширениях
+		r.Header.Del("Sec-WebSocket-Extensions")
+	}
+
+	resp, err := ph.roundTrip(r.Context(), r, resource, match, body)
+	if err != nil {
+		ph.writeRoundTripError(w, err, resource, requestID)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		if resp.StatusCode >= http.StatusInternalServerError {
+			WriteJSONResponse(w, NewErrorResponse("proxy error", http.StatusBadGateway, requestID), http.StatusBadGateway)
+			return
+		}
+		copyResponse(w, resp, 0)
+		return
+	}
+
+	backend, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		WriteJSONResponse(w, NewErrorResponse("proxy error", http.StatusBadGateway, requestID), http.StatusBadGateway)
+		return
+	}
+
+	clientConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		l.Info("failed to hijack connection", zap.String("resource", resource.Name), zap.Error(err))
+
+		WriteJSONResponse(w, NewErrorResponse("upgrade not supported", http.StatusInternalServerError, requestID), http.StatusInternalServerError)
+		return
+	}
+	defer clientConn.Close()
+
+	fmt.Fprintf(brw, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		return
+	}
+
+	errc := make(chan error, 2)
+	if inspect {
+		toClient := &clientWriter{w: clientConn}
+		go func() {
+			errc <- toClient.relay(bufio.NewReader(backend))
+		}()
+		go func() {
+			errc <- ph.inspectMessages(r, resource, brw.Reader, backend, toClient)
+		}()
+	} else {
+		go func() {
+			_, err := io.Copy(clientConn, backend)
+			errc <- err
+		}()
+		go func() {
+			_, err := io.Copy(backend, brw.Reader)
+			errc <- err
+		}()
+	}
+
+	err = <-errc
+	clientConn.Close()
+	backend.Close()
+	<-errc
+
+	if errors.Is(err, errMessageBlocked) {
+		l.Info("websocket connection closed by policy", zap.String("resource", resource.Name), zap.String("request_id", requestID))
+	}
+}
+
+// inspectMessages пересылает кадры клиента в апстрим. текстовые сообщения собираются целиком
+// (с учетом фрагментации) и отправляются только после проверки, остальные кадры идут без задержки.
+func (ph *ProxyHandler) inspectMessages(r *http.Request, resource rules.Resource, client *bufio.Reader, backend io.Writer, toClient *clientWriter) error {
+	limit := ph.inspectBodyBytes
+	if resource.MaxBodyBytes > 0 {
+		limit = resource.MaxBodyBytes
+	}
+
+	closeBoth := func(code int, reason string) error {
+		toClient.close(code, reason)
+		websocket.WriteClose(backend, code, reason, true)
+		return errMessageBlocked
+	}
+
+	var (
+		pending   []byte
+		message   []byte
+		inText    bool
+		inMessage bool
+	)
+	for {
+		h, err := websocket.ReadHeader(client)
+		if err != nil {
+			if errors.Is(err, websocket.ErrControlFrameTooLong) {
+				return closeBoth(websocket.CloseProtocolError, "control frame too long")
+			}
+			return err
+		}
+		if !h.Masked || h.Rsv != 0 {
+			return closeBoth(websocket.CloseProtocolError, "invalid frame")
+		}
+
+		if h.IsControl() {
+			if _, err := backend.Write(h.Raw); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(backend, client, h.Length); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch h.Opcode {
+		case websocket.OpText, websocket.OpBinary:
+			if inMessage {
+				return closeBoth(websocket.CloseProtocolError, "unexpected data frame")
+			}
+			inMessage, inText = true, h.Opcode == websocket.OpText
+		case websocket.OpContinuation:
+			if !inMessage {
+				return closeBoth(websocket.CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return closeBoth(websocket.CloseProtocolError, "unknown opcode")
+		}
+		if h.Fin {
+			inMessage = false
+		}
+
+		if !inText {
+			if _, err := backend.Write(h.Raw); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(backend, client, h.Length); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if int64(len(message))+h.Length > limit {
+			return closeBoth(websocket.CloseMessageTooBig, "message too big")
+		}
+
+		payload := make([]byte, h.Length)
+		if _, err := io.ReadFull(client, payload); err != nil {
+			return err
+		}
+		pending = append(append(pending, h.Raw...), payload...)
+		websocket.Unmask(h.Mask, 0, payload)
+		message = append(message, payload...)
+
+		if !h.Fin {
+			continue
+		}
+
+		modified, err := ph.analyzeMessage(r, string(message))
+		if err != nil {
+			if errors.Is(err, errMessageBlocked) {
+				return closeBoth(websocket.ClosePolicyViolation, "message blocked")
+			}
+			return closeBoth(websocket.CloseInternalError, "message analysis failed")
+		}
+
+		if modified != "" && modified != string(message) {
+			err = websocket.WriteFrame(backend, websocket.OpText, []byte(modified), true)
+		} else {
+			_, err = backend.Write(pending)
+		}
+		if err != nil {
+			return err
+		}
+		pending, message, inText = pending[:0], message[:0], false
+	}
+}
+
+// clientWriter пишет в соединение клиента при инспекции. кадры апстрима пересылаются целиком под мьютексом,
+// чтобы close-кадр прокси не попал в середину кадра апстрима, а после close-кадра отбрасываются.
+type clientWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closed bool
+}
+
+// relay пересылает кадры апстрима клиенту до ошибки чтения.
+func (c *clientWriter) relay(backend io.Reader) error {
+	for {
+		h, err := websocket.ReadHeader(backend)
+		if err != nil {
+			return err
+		}
+		if err := c.forward(h, backend); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *clientWriter) forward(h *websocket.Header, payload io.Reader) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dst := c.w
+	if c.closed {
+		dst = io.Discard
+	}
+	if _, err := dst.Write(h.Raw); err != nil {
+		return err
+	}
+	_, err := io.CopyN(dst, payload, h.Length)
+	return err
+}
+
+func (c *clientWriter) close(code int, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return websocket.WriteClose(c.w, code, reason, false)
+}
+
+func (ph *ProxyHandler) analyzeMessage(r *http.Request, message string) (string, error) {
+	ip := ReadUserIP(r)
+	l := logger.Logger()
+
+	analysisResp, err := ph.rulesEngineClient.AnalyzeRequest(ip, RequestHost(r), r.Method, r.URL.String(), message, requestHeaders(r))
+	if err != nil {
+		l.Info("error analyzing websocket message", zap.Error(err))
+		return "", err
+	}
+	logWouldBlock(r, ip, analysisResp.Matches)
+
+	if analysisResp.Action == "block" {
+		l.Info("blocked websocket message from ip", zap.String("ip", ip), zap.String("reason", analysisResp.Reason))
+		return "", errMessageBlocked
+	}
+	if !analysisResp.BodyModified {
+		return "", nil
+	}
+	return analysisResp.ModifiedBody, nil
+}
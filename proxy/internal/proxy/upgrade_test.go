@@ -0,0 +1,200 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+	"proxy/internal/websocket"
+)
+
+// newEchoUpstream принимает websocket и отвечает на каждое текстовое сообщение тем же текстом,
+// собирая фрагментированные сообщения целиком.
+func newEchoUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+
+		var message []byte
+		for {
+			h, err := websocket.ReadHeader(brw)
+			if err != nil {
+				return
+			}
+			payload := make([]byte, h.Length)
+			if _, err := io.ReadFull(brw, payload); err != nil {
+				return
+			}
+			websocket.Unmask(h.Mask, 0, payload)
+			if h.Opcode == websocket.OpClose {
+				return
+			}
+			message = append(message, payload...)
+			if h.Fin {
+				websocket.WriteFrame(conn, websocket.OpText, message, false)
+				message = message[:0]
+			}
+		}
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+// dialUpgrade открывает websocket через прокси и возвращает соединение и статус ответа на upgrade.
+func dialUpgrade(t *testing.T, srv *httptest.Server, path string) (net.Conn, *bufio.Reader, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, reader, resp.StatusCode
+}
+
+// readMessage читает кадр сервера и возвращает его опкод и payload.
+func readMessage(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+	h, err := websocket.ReadHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, h.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return h.Opcode, payload
+}
+
+func TestUpgradeNotAllowed(t *testing.T) {
+	upstream := newEchoUpstream(t)
+	active := true
+	resources := []rules.Resource{{ID: "ws", URL: "/ws", Host: upstream.URL, Method: http.MethodGet, IsActive: &active}}
+	srv := newTestProxy(t, resources, 4096, nil)
+
+	if _, _, status := dialUpgrade(t, srv, "/ws"); status != http.StatusForbidden {
+		t.Errorf("expected 403 for a resource without allow_upgrade, got %d", status)
+	}
+}
+
+func TestWebSocketMessageInspection(t *testing.T) {
+	upstream := newEchoUpstream(t)
+	active := true
+	resources := []rules.Resource{{
+		ID: "ws", URL: "/ws", Host: upstream.URL, Method: http.MethodGet, IsActive: &active,
+		AllowUpgrade: true, InspectMessages: true, MaxBodyBytes: 64,
+	}}
+	analyze := func(req rules.AnalyzerRequest) rules.AnalyzerResult {
+		switch {
+		case strings.Contains(req.Body, "evil"):
+			return rules.AnalyzerResult{Action: "block", Reason: "evil message"}
+		case strings.Contains(req.Body, "<b>"):
+			return rules.AnalyzerResult{Action: "allow", ModifiedBody: strings.ReplaceAll(req.Body, "<b>", ""), BodyModified: true}
+		}
+		return rules.AnalyzerResult{Action: "allow"}
+	}
+	srv := newTestProxy(t, resources, 4096, analyze)
+
+	tests := []struct {
+		name   string
+		frames []string
+		opcode byte
+		want   string
+		code   uint16
+	}{
+		{name: "allowed message", frames: []string{"hello"}, opcode: websocket.OpText, want: "hello"},
+		{name: "fragmented message", frames: []string{"hel", "lo ", "world"}, opcode: websocket.OpText, want: "hello world"},
+		{name: "sanitized message", frames: []string{"<b>bold"}, opcode: websocket.OpText, want: "bold"},
+		{name: "blocked message", frames: []string{"so evil"}, opcode: websocket.OpClose, code: websocket.ClosePolicyViolation},
+		{name: "blocked fragmented message", frames: []string{"ev", "il"}, opcode: websocket.OpClose, code: websocket.ClosePolicyViolation},
+		{name: "message over the limit", frames: []string{strings.Repeat("a", 40), strings.Repeat("a", 40)},
+			opcode: websocket.OpClose, code: websocket.CloseMessageTooBig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, reader, status := dialUpgrade(t, srv, "/ws")
+			if status != http.StatusSwitchingProtocols {
+				t.Fatalf("expected 101, got %d", status)
+			}
+
+			for i, frame := range tt.frames {
+				opcode := websocket.OpText
+				if i > 0 {
+					opcode = websocket.OpContinuation
+				}
+				header := []byte{opcode, 0x80 | byte(len(frame)), 0, 0, 0, 0}
+				if i == len(tt.frames)-1 {
+					header[0] |= 0x80
+				}
+				if _, err := conn.Write(append(header, frame...)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opcode, payload := readMessage(t, reader)
+			if opcode != tt.opcode {
+				t.Fatalf("expected opcode %d, got %d (%q)", tt.opcode, opcode, payload)
+			}
+			if opcode == websocket.OpClose {
+				if code := binary.BigEndian.Uint16(payload); code != tt.code {
+					t.Errorf("expected close code %d, got %d (%s)", tt.code, code, payload[2:])
+				}
+				return
+			}
+			if string(payload) != tt.want {
+				t.Errorf("expected echo %q, got %q", tt.want, payload)
+			}
+		})
+	}
+}
+
+func TestWebSocketRejectsUnmaskedFrames(t *testing.T) {
+	upstream := newEchoUpstream(t)
+	active := true
+	resources := []rules.Resource{{
+		ID: "ws", URL: "/ws", Host: upstream.URL, Method: http.MethodGet, IsActive: &active,
+		AllowUpgrade: true, InspectMessages: true,
+	}}
+	srv := newTestProxy(t, resources, 4096, nil)
+
+	conn, reader, status := dialUpgrade(t, srv, "/ws")
+	if status != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", status)
+	}
+	if err := websocket.WriteFrame(conn, websocket.OpText, []byte("hello"), false); err != nil {
+		t.Fatal(err)
+	}
+	opcode, payload := readMessage(t, reader)
+	if opcode != websocket.OpClose || binary.BigEndian.Uint16(payload) != websocket.CloseProtocolError {
+		t.Errorf("expected protocol error close, got opcode %d with %q", opcode, payload)
+	}
+}
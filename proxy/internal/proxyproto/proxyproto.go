@@ -0,0 +1,184 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxV1HeaderLen = 107
+	headerTimeout  = 5 * time.Second
+)
+
+var (
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrInvalidHeader = errors.New("invalid proxy protocol header")
+	ErrUntrustedPeer = errors.New("proxy protocol header from untrusted peer")
+)
+
+// Listener принимает соединения от балансировщика, который передает адрес клиента заголовком
+// PROXY protocol v1 или v2 (https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt).
+// заголовок обязателен. trusted ограничивает, от кого он принимается; при nil - от любого.
+type Listener struct {
+	net.Listener
+	trusted func(netip.Addr) bool
+}
+
+func NewListener(inner net.Listener, trusted func(netip.Addr) bool) *Listener {
+	return &Listener{Listener: inner, trusted: trusted}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), trusted: l.trusted}, nil
+}
+
+// Conn читает заголовок лениво, при первом Read или RemoteAddr, чтобы медленный клиент
+// не задерживал Accept остальных соединений.
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	trusted func(netip.Addr) bool
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	if c.trusted != nil {
+		peer, ok := netip.AddrFromSlice(tcpIP(c.Conn.RemoteAddr()))
+		if !ok || !c.trusted(peer.Unmap()) {
+			c.err = ErrUntrustedPeer
+			return
+		}
+	}
+
+	c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	signature, err := c.reader.Peek(len(v2Signature))
+	if err == nil && bytes.Equal(signature, v2Signature) {
+		c.remoteAddr, c.err = readV2(c.reader)
+		return
+	}
+	c.remoteAddr, c.err = readV1(c.reader)
+}
+
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < maxV1HeaderLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, ErrInvalidHeader
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil || addr.Is4() != (fields[1] == "TCP4") {
+		return nil, ErrInvalidHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	version, command := header[12]>>4, header[12]&0x0f
+	if version != 2 {
+		return nil, ErrInvalidHeader
+	}
+	family := header[13]
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	switch command {
+	case 0x0:
+		// LOCAL: соединение открыл сам балансировщик (например, health check)
+		return nil, nil
+	case 0x1:
+	default:
+		return nil, ErrInvalidHeader
+	}
+
+	switch family {
+	case 0x11, 0x12: // TCP/UDP over IPv4
+		if len(payload) < 12 {
+			return nil, ErrInvalidHeader
+		}
+		addr, _ := netip.AddrFromSlice(payload[0:4])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(payload[8:10]))), nil
+	case 0x21, 0x22: // TCP/UDP over IPv6
+		if len(payload) < 36 {
+			return nil, ErrInvalidHeader
+		}
+		addr, _ := netip.AddrFromSlice(payload[0:16])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(payload[32:34]))), nil
+	default:
+		// AF_UNIX и UNSPEC: адреса клиента нет, остается адрес соединения
+		return nil, nil
+	}
+}
+
+func tcpIP(addr net.Addr) net.IP {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestReadV1(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+		err    error
+	}{
+		{"tcp4", "PROXY TCP4 198.51.100.7 10.0.0.1 51000 443\r\n", "198.51.100.7:51000", nil},
+		{"tcp6", "PROXY TCP6 2001:db8::7 2001:db8::1 51000 443\r\n", "[2001:db8::7]:51000", nil},
+		{"unknown", "PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n", "", nil},
+		{"unknown without addresses", "PROXY UNKNOWN\r\n", "", nil},
+		{"missing cr", "PROXY TCP4 198.51.100.7 10.0.0.1 51000 443\n", "", ErrInvalidHeader},
+		{"not proxy", "GET / HTTP/1.1\r\n", "", ErrInvalidHeader},
+		{"family mismatch", "PROXY TCP4 2001:db8::7 2001:db8::1 51000 443\r\n", "", ErrInvalidHeader},
+		{"mapped address in tcp4", "PROXY TCP4 ::ffff:198.51.100.7 10.0.0.1 51000 443\r\n", "", ErrInvalidHeader},
+		{"udp", "PROXY UDP4 198.51.100.7 10.0.0.1 51000 443\r\n", "", ErrInvalidHeader},
+		{"missing port", "PROXY TCP4 198.51.100.7 10.0.0.1 51000\r\n", "", ErrInvalidHeader},
+		{"port out of range", "PROXY TCP4 198.51.100.7 10.0.0.1 70000 443\r\n", "", ErrInvalidHeader},
+		{"invalid address", "PROXY TCP4 198.51.100 10.0.0.1 51000 443\r\n", "", ErrInvalidHeader},
+		{"too long", "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n", "", ErrInvalidHeader},
+		{"truncated", "PROXY TCP4 198.51", "", io.EOF},
+	}
+
+	for _, tt := range tests {
+		addr, err := readV1(bufio.NewReader(strings.NewReader(tt.header)))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+			continue
+		}
+		if got := addrString(addr); got != tt.want {
+			t.Errorf("%s: expected address %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+// v2Header собирает заголовок v2 из байта версии и команды, семейства и адресной части.
+func v2Header(versionCommand, family byte, payload []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, versionCommand, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	return append(header, payload...)
+}
+
+func v2Addresses(src, dst string, srcPort, dstPort uint16) []byte {
+	var payload []byte
+	payload = append(payload, netip.MustParseAddr(src).AsSlice()...)
+	payload = append(payload, netip.MustParseAddr(dst).AsSlice()...)
+	payload = binary.BigEndian.AppendUint16(payload, srcPort)
+	return binary.BigEndian.AppendUint16(payload, dstPort)
+}
+
+func TestReadV2(t *testing.T) {
+	tlv := []byte{0x04, 0x00, 0x01, 0xff}
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+		err    error
+	}{
+		{"tcp over ipv4", v2Header(0x21, 0x11, v2Addresses("198.51.100.7", "10.0.0.1", 51000, 443)), "198.51.100.7:51000", nil},
+		{"udp over ipv4", v2Header(0x21, 0x12, v2Addresses("198.51.100.7", "10.0.0.1", 53, 53)), "198.51.100.7:53", nil},
+		{"tcp over ipv6", v2Header(0x21, 0x21, v2Addresses("2001:db8::7", "2001:db8::1", 51000, 443)), "[2001:db8::7]:51000", nil},
+		{"tlv after addresses", v2Header(0x21, 0x11, append(v2Addresses("198.51.100.7", "10.0.0.1", 1, 2), tlv...)), "198.51.100.7:1", nil},
+		{"local command", v2Header(0x20, 0x00, nil), "", nil},
+		{"local command with addresses", v2Header(0x20, 0x11, v2Addresses("198.51.100.7", "10.0.0.1", 1, 2)), "", nil},
+		{"unix family", v2Header(0x21, 0x31, make([]byte, 216)), "", nil},
+		{"unspec family", v2Header(0x21, 0x00, nil), "", nil},
+		{"version 1", v2Header(0x11, 0x11, v2Addresses("198.51.100.7", "10.0.0.1", 1, 2)), "", ErrInvalidHeader},
+		{"unknown command", v2Header(0x22, 0x11, v2Addresses("198.51.100.7", "10.0.0.1", 1, 2)), "", ErrInvalidHeader},
+		{"short ipv4 payload", v2Header(0x21, 0x11, make([]byte, 8)), "", ErrInvalidHeader},
+		{"short ipv6 payload", v2Header(0x21, 0x21, v2Addresses("198.51.100.7", "10.0.0.1", 1, 2)), "", ErrInvalidHeader},
+		{"truncated payload", v2Header(0x21, 0x11, v2Addresses("198.51.100.7", "10.0.0.1", 1, 2))[:20], "", io.ErrUnexpectedEOF},
+		{"truncated header", v2Signature, "", io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		addr, err := readV2(bufio.NewReader(bytes.NewReader(tt.header)))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+			continue
+		}
+		if got := addrString(addr); got != tt.want {
+			t.Errorf("%s: expected address %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	v2 := v2Header(0x21, 0x11, v2Addresses("198.51.100.8", "10.0.0.1", 52000, 443))
+	tests := []struct {
+		name    string
+		trusted func(netip.Addr) bool
+		header  []byte
+		remote  string
+		err     error
+	}{
+		{"v1", nil, []byte("PROXY TCP4 198.51.100.7 10.0.0.1 51000 443\r\n"), "198.51.100.7:51000", nil},
+		{"v2", func(addr netip.Addr) bool { return addr.IsLoopback() }, v2, "198.51.100.8:52000", nil},
+		{"unknown keeps peer address", nil, []byte("PROXY UNKNOWN\r\n"), "127.0.0.1", nil},
+		{"untrusted peer", func(netip.Addr) bool { return false }, v2, "127.0.0.1", ErrUntrustedPeer},
+		{"missing header", nil, []byte("GET / HTTP/1.1\r\n"), "127.0.0.1", ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		inner, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ln := NewListener(inner, tt.trusted)
+
+		client, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Write(append(tt.header, "payload"...)); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(io.LimitReader(conn, int64(len("payload"))))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected read error %v, got %v", tt.name, tt.err, err)
+		}
+		if tt.err == nil && string(body) != "payload" {
+			t.Errorf("%s: expected data after the header, got %q", tt.name, body)
+		}
+		// без адреса из заголовка остается адрес соединения
+		if remote := conn.RemoteAddr().String(); !strings.HasPrefix(remote, tt.remote) {
+			t.Errorf("%s: expected remote address %s, got %s", tt.name, tt.remote, remote)
+		}
+
+		client.Close()
+		conn.Close()
+		ln.Close()
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
@@ -2,22 +2,39 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"proxy/internal/certstore"
+	"proxy/internal/clientip"
+	rules "proxy/internal/clients/rules_engine_service"
 	"proxy/internal/config"
 	"proxy/internal/logger"
 	"proxy/internal/proxy"
+	"proxy/internal/proxyproto"
 	"syscall"
 	"time"
 
+	"go.uber.org/zap"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 type Server struct {
-	addr    string
-	handler http.Handler
-	srv     *http.Server
+	addr          string
+	handler       http.Handler
+	proxy         *proxy.ProxyHandler
+	srv           *http.Server
+	adminSrv      *http.Server
+	tlsSrv        *http.Server
+	certs         *certstore.Store
+	certsInterval time.Duration
+	resolver      *clientip.Resolver
+	proxyProtocol bool
 }
 
 func NewServer(cfg *config.Config) (*Server, error) {
@@ -26,25 +43,107 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to create proxy handler: %v", err)
 	}
 
-	handler := loggingMiddleware(proxyHandler)
+	resolver, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted proxies: %v", err)
+	}
+
+	if cfg.ProxyProtocol && len(cfg.TrustedProxies) == 0 {
+		return nil, fmt.Errorf("proxy_protocol requires trusted_proxies")
+	}
+
+	handler := clientip.Middleware(resolver, loggingMiddleware(proxyHandler))
+
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /upstreams", proxyHandler.HandleUpstreamsStatus)
+
+	s := &Server{
+		addr:          cfg.HTTPServer.Address,
+		handler:       handler,
+		proxy:         proxyHandler,
+		srv:           &http.Server{Addr: cfg.HTTPServer.Address, Handler: handler},
+		adminSrv:      &http.Server{Addr: cfg.AdminServer.Address, Handler: basicAuthMiddleware(cfg.HTTPServer, adminMux)},
+		certsInterval: cfg.TLSServer.CertsRefreshInterval,
+		resolver:      resolver,
+		proxyProtocol: cfg.ProxyProtocol,
+	}
+
+	if cfg.TLSServer.Address != "" {
+		if err := s.setupTLS(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Server) setupTLS(cfg *config.Config) error {
+	nextProtos := []string{"h2", "http/1.1"}
+
+	var acmeManager *autocert.Manager
+	if cfg.TLSServer.ACME.Enabled {
+		var err error
+		acmeManager, err = certstore.NewACMEManager(cfg.TLSServer.ACME, s.proxy.ServesHost)
+		if err != nil {
+			return fmt.Errorf("failed to create acme manager: %v", err)
+		}
+		// http-01 челленджи приходят на обычный http-листенер
+		s.srv.Handler = acmeManager.HTTPHandler(s.handler)
+		nextProtos = append(nextProtos, acme.ALPNProto)
+	}
+
+	rulesClient := rules.NewRulesEngineClient(cfg.RulesEngineURL, cfg.RulesEngineToken)
+	s.certs = certstore.New(cfg.TLSServer.CertDir, rulesClient, acmeManager)
+	if err := s.certs.Reload(); err != nil {
+		logger.Logger().Info("initial certificates load failed", zap.Error(err))
+	}
 
-	return &Server{
-		addr:    cfg.HTTPServer.Address,
-		handler: handler,
-		srv:     &http.Server{Addr: cfg.HTTPServer.Address, Handler: handler},
-	}, nil
+	s.tlsSrv = &http.Server{
+		Addr:    cfg.TLSServer.Address,
+		Handler: s.handler,
+		TLSConfig: &tls.Config{
+			GetCertificate: s.certs.GetCertificate,
+			NextProtos:     nextProtos,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+	return nil
 }
 
 func (s *Server) Start(ctx context.Context) error {
 	l := logger.LoggerFromContext(ctx)
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	go s.proxy.WatchResources(watchCtx)
+
 	go func() {
 		l.Info(fmt.Sprintf("Starting server on %s", s.addr))
-		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := s.serve(s.srv, false); err != nil && err != http.ErrServerClosed {
 			l.Info(fmt.Sprintf("server startup failed: %v", err))
 			os.Exit(1)
 		}
 	}()
 
+	if s.tlsSrv != nil {
+		go s.certs.Watch(watchCtx, s.certsInterval)
+
+		go func() {
+			l.Info(fmt.Sprintf("Starting tls server on %s", s.tlsSrv.Addr))
+			if err := s.serve(s.tlsSrv, true); err != nil && err != http.ErrServerClosed {
+				l.Info(fmt.Sprintf("tls server startup failed: %v", err))
+				os.Exit(1)
+			}
+		}()
+	}
+
+	go func() {
+		l.Info(fmt.Sprintf("Starting admin server on %s", s.adminSrv.Addr))
+		if err := s.adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.Info(fmt.Sprintf("admin server startup failed: %v", err))
+		}
+	}()
+
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 
@@ -57,10 +156,37 @@ func (s *Server) Start(ctx context.Context) error {
 	}
 }
 
+// serve слушает адрес сервера. при включенном PROXY protocol адрес клиента берется из заголовка балансировщика,
+// который принимается только от доверенных прокси.
+func (s *Server) serve(srv *http.Server, useTLS bool) error {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	if s.proxyProtocol {
+		ln = proxyproto.NewListener(ln, s.resolver.IsTrusted)
+	}
+
+	if useTLS {
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
+}
+
 func (s *Server) shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if err := s.adminSrv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("admin server shutdown failed: %v", err)
+	}
+
+	if s.tlsSrv != nil {
+		if err := s.tlsSrv.Shutdown(ctx); err != nil {
+			return fmt.Errorf("tls server shutdown failed: %v", err)
+		}
+	}
+
 	err := s.srv.Shutdown(ctx)
 	if err != nil {
 		return fmt.Errorf("server shutdown failed: %v", err)
@@ -68,6 +194,20 @@ func (s *Server) shutdown() error {
 	return nil
 }
 
+func basicAuthMiddleware(cfg config.HTTPServer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(cfg.User)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="proxy admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Logger().Info(
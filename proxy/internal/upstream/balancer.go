@@ -0,0 +1,91 @@
+package upstream
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+const hashReplicas = 100
+
+type balancer interface {
+	pick(targets []*Target, available []bool, key string) *Target
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobin) pick(targets []*Target, available []bool, _ string) *Target {
+	n := uint64(len(targets))
+	start := b.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		idx := (start + i) % n
+		if available[idx] {
+			return targets[idx]
+		}
+	}
+	return nil
+}
+
+type leastConn struct {
+	roundRobin
+}
+
+func (b *leastConn) pick(targets []*Target, available []bool, _ string) *Target {
+	// обход начинаем со сдвигом, чтобы при равной нагрузке запросы не уходили всегда в первый таргет
+	n := len(targets)
+	start := int(b.next.Add(1)-1) % n
+
+	var best *Target
+	for i := 0; i < n; i++ {
+		idx := (start + i) % n
+		if !available[idx] {
+			continue
+		}
+		if best == nil || targets[idx].active.Load() < best.active.Load() {
+			best = targets[idx]
+		}
+	}
+	return best
+}
+
+// consistentHash раскладывает таргеты по кольцу, чтобы один и тот же ключ попадал на один и тот же таргет,
+// а при выпадении таргета переезжали только его ключи.
+type consistentHash struct {
+	ring   []uint32
+	owners map[uint32]int
+}
+
+func newConsistentHash(targets []*Target) *consistentHash {
+	b := &consistentHash{owners: make(map[uint32]int)}
+	for i, target := range targets {
+		for r := 0; r < hashReplicas; r++ {
+			h := crc32.ChecksumIEEE([]byte(target.URL.String() + "#" + strconv.Itoa(r)))
+			if _, exists := b.owners[h]; exists {
+				continue
+			}
+			b.owners[h] = i
+			b.ring = append(b.ring, h)
+		}
+	}
+	sort.Slice(b.ring, func(i, j int) bool { return b.ring[i] < b.ring[j] })
+	return b
+}
+
+func (b *consistentHash) pick(targets []*Target, available []bool, key string) *Target {
+	if len(b.ring) == 0 {
+		return nil
+	}
+
+	h := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(b.ring), func(i int) bool { return b.ring[i] >= h })
+	for i := 0; i < len(b.ring); i++ {
+		owner := b.owners[b.ring[(start+i)%len(b.ring)]]
+		if available[owner] {
+			return targets[owner]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+	"proxy/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	healthCheckTick    = time.Second
+	healthCheckTimeout = 2 * time.Second
+)
+
+// Manager хранит пулы апстримов по id и проводит активные health check'и.
+// при обновлении таблицы ресурсов пулы с неизменной конфигурацией сохраняют свое состояние.
+type Manager struct {
+	mu    sync.RWMutex
+	pools map[string]*Pool
+
+	client *http.Client
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		pools:  make(map[string]*Pool),
+		client: &http.Client{Timeout: healthCheckTimeout},
+	}
+}
+
+func (m *Manager) Sync(upstreams []rules.Upstream) {
+	l := logger.Logger()
+	pools := make(map[string]*Pool, len(upstreams))
+
+	// имя пула меняется на месте, а Status читает его под m.mu, поэтому нужна блокировка на запись
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, config := range upstreams {
+		if _, exists := pools[config.ID]; exists {
+			continue
+		}
+		if current, exists := m.pools[config.ID]; exists && current.sameConfig(config) {
+			current.config.Name = config.Name
+			pools[config.ID] = current
+			continue
+		}
+		pool, err := newPool(config)
+		if err != nil {
+			l.Info("skipping invalid upstream", zap.String("upstream_id", config.ID), zap.Error(err))
+			continue
+		}
+		pools[config.ID] = pool
+	}
+	m.pools = pools
+}
+
+func (m *Manager) Get(id string) (*Pool, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pool, ok := m.pools[id]
+	return pool, ok
+}
+
+func (m *Manager) Status() []PoolStatus {
+	m.mu.RLock()
+	statuses := make([]PoolStatus, 0, len(m.pools))
+	for _, pool := range m.pools {
+		statuses = append(statuses, pool.Status())
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// RunHealthChecks раз в секунду проверяет, каким пулам пора делать health check, до отмены контекста.
+func (m *Manager) RunHealthChecks(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, pool := range m.duePools(now) {
+				for _, target := range pool.targets {
+					go m.checkTarget(ctx, pool, target)
+				}
+			}
+		}
+	}
+}
+
+func (m *Manager) duePools(now time.Time) []*Pool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due []*Pool
+	for _, pool := range m.pools {
+		if pool.config.HealthCheckPath == "" || now.Before(pool.nextCheck) {
+			continue
+		}
+		interval := time.Duration(pool.config.HealthCheckInterval) * time.Second
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		pool.nextCheck = now.Add(interval)
+		due = append(due, pool)
+	}
+	return due
+}
+
+func (m *Manager) checkTarget(ctx context.Context, pool *Pool, target *Target) {
+	checkURL := *target.URL
+	checkURL.Path = pool.config.HealthCheckPath
+	checkURL.RawQuery = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL.String(), nil)
+	if err != nil {
+		target.setCheckResult(err)
+		return
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		target.setCheckResult(err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		target.setCheckResult(fmt.Errorf("health check returned status %d", resp.StatusCode))
+		return
+	}
+	target.setCheckResult(nil)
+}
@@ -0,0 +1,113 @@
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	rules "proxy/internal/clients/rules_engine_service"
+)
+
+func TestManagerSync(t *testing.T) {
+	m := NewManager()
+	m.Sync([]rules.Upstream{
+		{ID: "a", Name: "api", Targets: testTargets},
+		{ID: "b", Name: "billing", Targets: testTargets[:1]},
+		{ID: "broken", Name: "broken", Targets: []string{"::"}},
+	})
+	if _, ok := m.Get("broken"); ok {
+		t.Error("invalid upstream should be skipped")
+	}
+
+	a, _ := m.Get("a")
+	b, _ := m.Get("b")
+
+	// переименование не сбрасывает состояние пула, смена таргетов - сбрасывает
+	m.Sync([]rules.Upstream{
+		{ID: "a", Name: "api-v2", Targets: testTargets},
+		{ID: "b", Name: "billing", Targets: testTargets[1:2]},
+	})
+	if current, _ := m.Get("a"); current != a {
+		t.Error("pool with the same config should be kept")
+	}
+	if current, _ := m.Get("b"); current == b {
+		t.Error("pool with changed targets should be recreated")
+	}
+
+	statuses := m.Status()
+	if len(statuses) != 2 || statuses[0].Name != "api-v2" || statuses[1].Name != "billing" {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+
+	m.Sync(nil)
+	if _, ok := m.Get("a"); ok {
+		t.Error("removed upstream should be dropped")
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("health check should drop the target query, got %q", r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	m := NewManager()
+	tests := []struct {
+		name    string
+		path    string
+		healthy bool
+	}{
+		{"error status", "/healthz", false},
+		{"unknown path", "/missing", false},
+	}
+
+	for _, tt := range tests {
+		pool := mustPool(t, rules.Upstream{ID: "u", Targets: []string{backend.URL + "/api?v=1"}, HealthCheckPath: tt.path})
+		target := pool.targets[0]
+		m.checkTarget(context.Background(), pool, target)
+
+		status := target.status(target.lastCheckAt)
+		if status.Healthy != tt.healthy || status.LastCheckAt == nil || status.LastCheckError == "" {
+			t.Errorf("%s: unexpected status %+v", tt.name, status)
+		}
+	}
+
+	// недоступный таргет помечается нездоровым, успешная проверка возвращает его в пул
+	pool := mustPool(t, rules.Upstream{ID: "u", Targets: []string{"http://127.0.0.1:1"}, HealthCheckPath: "/"})
+	m.checkTarget(context.Background(), pool, pool.targets[0])
+	if _, err := pool.Pick(""); err == nil {
+		t.Error("expected unreachable target to be unavailable")
+	}
+	pool.targets[0].setCheckResult(nil)
+	if _, err := pool.Pick(""); err != nil {
+		t.Errorf("expected target back after a passing check, got %v", err)
+	}
+}
+
+func TestDuePools(t *testing.T) {
+	m := NewManager()
+	m.Sync([]rules.Upstream{
+		{ID: "checked", Targets: testTargets, HealthCheckPath: "/healthz", HealthCheckInterval: 30},
+		{ID: "unchecked", Targets: testTargets},
+	})
+
+	pool, _ := m.Get("checked")
+	now := pool.nextCheck
+	if due := m.duePools(now); len(due) != 1 || due[0] != pool {
+		t.Fatalf("expected only the pool with a health check path, got %d pools", len(due))
+	}
+	if due := m.duePools(now); len(due) != 0 {
+		t.Error("pool should wait for its interval before the next check")
+	}
+	if due := m.duePools(pool.nextCheck); len(due) != 1 {
+		t.Error("pool should be due after its interval")
+	}
+}
@@ -0,0 +1,121 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+)
+
+var ErrNoAvailableTargets = errors.New("no available upstream targets")
+
+type Pool struct {
+	config   rules.Upstream
+	targets  []*Target
+	balancer balancer
+
+	nextCheck time.Time
+}
+
+type PoolStatus struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Balancer string         `json:"balancer"`
+	Targets  []TargetStatus `json:"targets"`
+}
+
+func newPool(config rules.Upstream) (*Pool, error) {
+	if len(config.Targets) == 0 {
+		return nil, fmt.Errorf("upstream %s has no targets", config.ID)
+	}
+
+	pool := &Pool{config: config}
+	for _, raw := range config.Targets {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid target %q in upstream %s", raw, config.ID)
+		}
+		pool.targets = append(pool.targets, newTarget(u))
+	}
+
+	switch config.Balancer {
+	case "", rules.BalancerRoundRobin:
+		pool.balancer = &roundRobin{}
+	case rules.BalancerLeastConn:
+		pool.balancer = &leastConn{}
+	case rules.BalancerConsistentHash:
+		pool.balancer = newConsistentHash(pool.targets)
+	default:
+		return nil, fmt.Errorf("unknown balancer %q in upstream %s", config.Balancer, config.ID)
+	}
+
+	return pool, nil
+}
+
+// Pick выбирает таргет для запроса. hashKey используется только consistent_hash балансировщиком.
+// после завершения запроса нужно вызвать Done.
+func (p *Pool) Pick(hashKey string) (*Target, error) {
+	now := time.Now()
+	available := make([]bool, len(p.targets))
+	hasAvailable := false
+	for i, target := range p.targets {
+		available[i] = target.available(now)
+		hasAvailable = hasAvailable || available[i]
+	}
+	if !hasAvailable {
+		return nil, ErrNoAvailableTargets
+	}
+
+	target := p.balancer.pick(p.targets, available, hashKey)
+	if target == nil {
+		return nil, ErrNoAvailableTargets
+	}
+	target.acquire()
+	return target, nil
+}
+
+func (p *Pool) Done(target *Target, failed bool) {
+	target.report(failed, p.config.MaxFails, time.Duration(p.config.FailTimeout)*time.Second)
+}
+
+// HashKeySource возвращает, откуда брать ключ для consistent_hash: "ip" или имя заголовка.
+func (p *Pool) HashKeySource() (header string, byIP bool) {
+	if p.config.HashKey == "ip" {
+		return "", true
+	}
+	return strings.TrimPrefix(p.config.HashKey, "header:"), false
+}
+
+func (p *Pool) Status() PoolStatus {
+	now := time.Now()
+	status := PoolStatus{
+		ID:       p.config.ID,
+		Name:     p.config.Name,
+		Balancer: p.config.Balancer,
+	}
+	for _, target := range p.targets {
+		status.Targets = append(status.Targets, target.status(now))
+	}
+	return status
+}
+
+func (p *Pool) sameConfig(config rules.Upstream) bool {
+	if p.config.Balancer != config.Balancer ||
+		p.config.HashKey != config.HashKey ||
+		p.config.HealthCheckPath != config.HealthCheckPath ||
+		p.config.HealthCheckInterval != config.HealthCheckInterval ||
+		p.config.MaxFails != config.MaxFails ||
+		p.config.FailTimeout != config.FailTimeout ||
+		len(p.config.Targets) != len(config.Targets) {
+		return false
+	}
+	for i := range config.Targets {
+		if p.config.Targets[i] != config.Targets[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	rules "proxy/internal/clients/rules_engine_service"
+)
+
+var testTargets = []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080"}
+
+func mustPool(t *testing.T, config rules.Upstream) *Pool {
+	t.Helper()
+	if config.Targets == nil {
+		config.Targets = testTargets
+	}
+	pool, err := newPool(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// pickHost выбирает таргет и сразу завершает запрос без ошибки.
+func pickHost(t *testing.T, pool *Pool, key string) string {
+	t.Helper()
+	target, err := pool.Pick(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Done(target, false)
+	return target.URL.Host
+}
+
+func TestNewPool(t *testing.T) {
+	tests := []struct {
+		name   string
+		config rules.Upstream
+		valid  bool
+	}{
+		{"default balancer", rules.Upstream{ID: "u", Targets: testTargets}, true},
+		{"consistent hash", rules.Upstream{ID: "u", Balancer: rules.BalancerConsistentHash, Targets: testTargets}, true},
+		{"no targets", rules.Upstream{ID: "u"}, false},
+		{"target without host", rules.Upstream{ID: "u", Targets: []string{"/relative"}}, false},
+		{"unknown balancer", rules.Upstream{ID: "u", Balancer: "random", Targets: testTargets}, false},
+	}
+
+	for _, tt := range tests {
+		if _, err := newPool(tt.config); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestRoundRobinSkipsUnavailable(t *testing.T) {
+	pool := mustPool(t, rules.Upstream{ID: "u"})
+	pool.targets[1].setCheckResult(errors.New("connection refused"))
+
+	counts := map[string]int{}
+	for i := 0; i < 6; i++ {
+		counts[pickHost(t, pool, "")]++
+	}
+	if counts["10.0.0.1:8080"] == 0 || counts["10.0.0.3:8080"] == 0 || counts["10.0.0.2:8080"] != 0 {
+		t.Errorf("unexpected distribution %v", counts)
+	}
+}
+
+func TestLeastConn(t *testing.T) {
+	pool := mustPool(t, rules.Upstream{ID: "u", Balancer: rules.BalancerLeastConn})
+
+	// два незавершенных запроса занимают два таргета, третий уходит в свободный
+	first, _ := pool.Pick("")
+	second, _ := pool.Pick("")
+	third, _ := pool.Pick("")
+	if first == second || second == third || first == third {
+		t.Fatalf("expected three different targets, got %s %s %s", first.URL.Host, second.URL.Host, third.URL.Host)
+	}
+
+	pool.Done(second, false)
+	next, _ := pool.Pick("")
+	if next != second {
+		t.Errorf("expected the idle target %s, got %s", second.URL.Host, next.URL.Host)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	pool := mustPool(t, rules.Upstream{ID: "u", Balancer: rules.BalancerConsistentHash})
+
+	owners := map[string]string{}
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("client-%d", i)
+		owners[key] = pickHost(t, pool, key)
+		if again := pickHost(t, pool, key); again != owners[key] {
+			t.Fatalf("key %s moved from %s to %s", key, owners[key], again)
+		}
+	}
+
+	// при выпадении таргета переезжают только его ключи
+	pool.targets[0].setCheckResult(errors.New("down"))
+	for key, owner := range owners {
+		got := pickHost(t, pool, key)
+		if owner != pool.targets[0].URL.Host && got != owner {
+			t.Errorf("key %s moved from %s to %s", key, owner, got)
+		}
+		if got == pool.targets[0].URL.Host {
+			t.Errorf("key %s still goes to the unhealthy target", key)
+		}
+	}
+}
+
+func TestPassiveEjection(t *testing.T) {
+	pool := mustPool(t, rules.Upstream{ID: "u", Targets: testTargets[:1], MaxFails: 2, FailTimeout: 60})
+	target := pool.targets[0]
+
+	// успешный запрос сбрасывает счетчик подряд идущих ошибок
+	for _, failed := range []bool{true, false, true} {
+		picked, err := pool.Pick("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Done(picked, failed)
+	}
+	if !target.available(time.Now()) {
+		t.Fatal("target ejected without consecutive failures")
+	}
+
+	picked, _ := pool.Pick("")
+	pool.Done(picked, true)
+	if _, err := pool.Pick(""); !errors.Is(err, ErrNoAvailableTargets) {
+		t.Errorf("expected ErrNoAvailableTargets after ejection, got %v", err)
+	}
+
+	status := pool.Status().Targets[0]
+	if !status.Ejected || status.EjectedUntil == nil || status.ActiveRequests != 0 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if !target.available(time.Now().Add(time.Minute + time.Second)) {
+		t.Error("target should return after fail_timeout")
+	}
+}
+
+func TestHashKeySource(t *testing.T) {
+	tests := []struct {
+		hashKey string
+		header  string
+		byIP    bool
+	}{
+		{"ip", "", true},
+		{"header:X-User-Id", "X-User-Id", false},
+	}
+
+	for _, tt := range tests {
+		pool := mustPool(t, rules.Upstream{ID: "u", Balancer: rules.BalancerConsistentHash, HashKey: tt.hashKey})
+		header, byIP := pool.HashKeySource()
+		if header != tt.header || byIP != tt.byIP {
+			t.Errorf("HashKeySource(%q) = %q, %v", tt.hashKey, header, byIP)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package upstream
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Target - один адрес из пула. состояние активной и пассивной проверки хранится отдельно:
+// healthy выставляет health check, ejectedUntil - подряд идущие ошибки реальных запросов.
+type Target struct {
+	URL *url.URL
+
+	healthy atomic.Bool
+	active  atomic.Int64
+
+	mu           sync.Mutex
+	fails        int
+	ejectedUntil time.Time
+	lastCheckErr string
+	lastCheckAt  time.Time
+}
+
+type TargetStatus struct {
+	URL                 string     `json:"url"`
+	Healthy             bool       `json:"healthy"`
+	Ejected             bool       `json:"ejected"`
+	EjectedUntil        *time.Time `json:"ejected_until,omitempty"`
+	ActiveRequests      int64      `json:"active_requests"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastCheckAt         *time.Time `json:"last_check_at,omitempty"`
+	LastCheckError      string     `json:"last_check_error,omitempty"`
+}
+
+func newTarget(u *url.URL) *Target {
+	t := &Target{URL: u}
+	t.healthy.Store(true)
+	return t
+}
+
+func (t *Target) available(now time.Time) bool {
+	if !t.healthy.Load() {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !now.Before(t.ejectedUntil)
+}
+
+func (t *Target) acquire() {
+	t.active.Add(1)
+}
+
+// report учитывает результат запроса для пассивного извлечения таргета из пула.
+func (t *Target) report(failed bool, maxFails int, failTimeout time.Duration) {
+	t.active.Add(-1)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !failed {
+		t.fails = 0
+		return
+	}
+
+	t.fails++
+	if maxFails > 0 && t.fails >= maxFails {
+		t.ejectedUntil = time.Now().Add(failTimeout)
+		t.fails = 0
+	}
+}
+
+func (t *Target) setCheckResult(err error) {
+	t.healthy.Store(err == nil)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastCheckAt = time.Now()
+	t.lastCheckErr = ""
+	if err != nil {
+		t.lastCheckErr = err.Error()
+	}
+}
+
+func (t *Target) status(now time.Time) TargetStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := TargetStatus{
+		URL:                 t.URL.String(),
+		Healthy:             t.healthy.Load(),
+		Ejected:             now.Before(t.ejectedUntil),
+		ActiveRequests:      t.active.Load(),
+		ConsecutiveFailures: t.fails,
+		LastCheckError:      t.lastCheckErr,
+	}
+	if status.Ejected {
+		until := t.ejectedUntil
+		status.EjectedUntil = &until
+	}
+	if !t.lastCheckAt.IsZero() {
+		checkedAt := t.lastCheckAt
+		status.LastCheckAt = &checkedAt
+	}
+	return status
+}
@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	OpContinuation byte = 0x0
+	OpText         byte = 0x1
+	OpBinary       byte = 0x2
+	OpClose        byte = 0x8
+	OpPing         byte = 0x9
+	OpPong         byte = 0xa
+)
+
+const (
+	CloseProtocolError   = 1002
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// у control-кадров payload не длиннее 125 байт, у close из них 2 занимает код
+const maxCloseReason = 123
+
+var ErrControlFrameTooLong = errors.New("control frame payload too long")
+
+// Header - заголовок кадра websocket (RFC 6455, раздел 5.2). Raw хранит байты заголовка в том виде,
+// в котором они пришли, чтобы кадр можно было переслать без изменений.
+type Header struct {
+	Fin    bool
+	Rsv    byte
+	Opcode byte
+	Masked bool
+	Mask   [4]byte
+	Length int64
+	Raw    []byte
+}
+
+func (h *Header) IsControl() bool {
+	return h.Opcode&0x8 != 0
+}
+
+func ReadHeader(r io.Reader) (*Header, error) {
+	raw := make([]byte, 2, 14)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, err
+	}
+
+	h := &Header{
+		Fin:    raw[0]&0x80 != 0,
+		Rsv:    raw[0] & 0x70,
+		Opcode: raw[0] & 0x0f,
+		Masked: raw[1]&0x80 != 0,
+		Length: int64(raw[1] & 0x7f),
+	}
+
+	var ext int
+	switch h.Length {
+	case 126:
+		ext = 2
+	case 127:
+		ext = 8
+	}
+	if ext > 0 {
+		raw = raw[:2+ext]
+		if _, err := io.ReadFull(r, raw[2:]); err != nil {
+			return nil, err
+		}
+		if ext == 2 {
+			h.Length = int64(binary.BigEndian.Uint16(raw[2:]))
+		} else {
+			h.Length = int64(binary.BigEndian.Uint64(raw[2:]) &^ (1 << 63))
+		}
+	}
+
+	if h.Masked {
+		n := len(raw)
+		raw = raw[:n+4]
+		if _, err := io.ReadFull(r, raw[n:]); err != nil {
+			return nil, err
+		}
+		copy(h.Mask[:], raw[n:])
+	}
+
+	if h.IsControl() && h.Length > 125 {
+		return nil, ErrControlFrameTooLong
+	}
+
+	h.Raw = raw
+	return h, nil
+}
+
+// Unmask снимает маску с payload. offset - позиция куска внутри payload кадра.
+func Unmask(mask [4]byte, offset int64, payload []byte) {
+	for i := range payload {
+		payload[i] ^= mask[(offset+int64(i))%4]
+	}
+}
+
+// WriteFrame пишет одиночный кадр. клиент обязан маскировать кадры, сервер - нет.
+func WriteFrame(w io.Writer, opcode byte, payload []byte, masked bool) error {
+	frame := []byte{0x80 | opcode}
+
+	var lenByte byte
+	if masked {
+		lenByte = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, lenByte|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, lenByte|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, lenByte|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	data := payload
+	if masked {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		data = append([]byte(nil), payload...)
+		Unmask(mask, 0, data)
+	}
+
+	_, err := w.Write(append(frame, data...))
+	return err
+}
+
+func WriteClose(w io.Writer, code int, reason string, masked bool) error {
+	if len(reason) > maxCloseReason {
+		reason = strings.ToValidUTF8(reason[:maxCloseReason], "")
+	}
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return WriteFrame(w, OpClose, append(payload, reason...), masked)
+}
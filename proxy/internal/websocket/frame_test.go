@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestReadHeader(t *testing.T) {
+	mask := []byte{1, 2, 3, 4}
+	tests := []struct {
+		name   string
+		raw    []byte
+		want   Header
+		err    error
+		rawLen int
+	}{
+		{name: "short text", raw: []byte{0x81, 0x05},
+			want: Header{Fin: true, Opcode: OpText, Length: 5}, rawLen: 2},
+		{name: "masked continuation", raw: append([]byte{0x00, 0x83}, mask...),
+			want: Header{Opcode: OpContinuation, Masked: true, Mask: [4]byte{1, 2, 3, 4}, Length: 3}, rawLen: 6},
+		{name: "16-bit length", raw: []byte{0x82, 126, 0x01, 0x00},
+			want: Header{Fin: true, Opcode: OpBinary, Length: 256}, rawLen: 4},
+		{name: "64-bit length masked", raw: append([]byte{0x82, 0xff, 0, 0, 0, 0, 0, 1, 0, 0}, mask...),
+			want: Header{Fin: true, Opcode: OpBinary, Masked: true, Mask: [4]byte{1, 2, 3, 4}, Length: 65536}, rawLen: 14},
+		{name: "64-bit length ignores the high bit", raw: []byte{0x82, 127, 0x80, 0, 0, 0, 0, 0, 0, 1},
+			want: Header{Fin: true, Opcode: OpBinary, Length: 1}, rawLen: 10},
+		{name: "reserved bits", raw: []byte{0xc1, 0x00},
+			want: Header{Fin: true, Rsv: 0x40, Opcode: OpText}, rawLen: 2},
+		{name: "ping", raw: []byte{0x89, 125},
+			want: Header{Fin: true, Opcode: OpPing, Length: 125}, rawLen: 2},
+		{name: "long control frame", raw: []byte{0x88, 126, 0x00, 0x7e}, err: ErrControlFrameTooLong},
+		{name: "truncated header", raw: []byte{0x81}, err: io.ErrUnexpectedEOF},
+		{name: "truncated length", raw: []byte{0x81, 126, 0x01}, err: io.ErrUnexpectedEOF},
+		{name: "truncated mask", raw: []byte{0x81, 0x81, 1, 2}, err: io.ErrUnexpectedEOF},
+		{name: "empty", raw: nil, err: io.EOF},
+	}
+
+	for _, tt := range tests {
+		h, err := ReadHeader(bytes.NewReader(tt.raw))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(h.Raw, tt.raw[:tt.rawLen]) {
+			t.Errorf("%s: expected raw header %x, got %x", tt.name, tt.raw[:tt.rawLen], h.Raw)
+		}
+		h.Raw = nil
+		if !reflect.DeepEqual(*h, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, *h)
+		}
+	}
+}
+
+func TestWriteFrameRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 0xffff, 0x10000} {
+		for _, masked := range []bool{false, true} {
+			payload := bytes.Repeat([]byte("ab"), size/2+1)[:size]
+
+			var buf bytes.Buffer
+			if err := WriteFrame(&buf, OpBinary, payload, masked); err != nil {
+				t.Fatal(err)
+			}
+			h, err := ReadHeader(&buf)
+			if err != nil {
+				t.Fatalf("size %d masked %v: %v", size, masked, err)
+			}
+			if !h.Fin || h.Opcode != OpBinary || h.Masked != masked || h.Length != int64(size) {
+				t.Errorf("size %d masked %v: unexpected header %+v", size, masked, h)
+			}
+
+			got := buf.Bytes()
+			if masked {
+				Unmask(h.Mask, 0, got)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("size %d masked %v: payload differs after round trip", size, masked)
+			}
+		}
+	}
+}
+
+func TestUnmaskInChunks(t *testing.T) {
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	payload := []byte("fragmented payload read in pieces")
+	whole := append([]byte(nil), payload...)
+	Unmask(mask, 0, whole)
+
+	// кусок, начинающийся не с кратной 4 позиции, продолжает маску с нужного байта
+	chunked := append([]byte(nil), payload...)
+	for _, bounds := range [][2]int{{0, 3}, {3, 10}, {10, 11}, {11, len(payload)}} {
+		Unmask(mask, int64(bounds[0]), chunked[bounds[0]:bounds[1]])
+	}
+	if !bytes.Equal(chunked, whole) {
+		t.Errorf("chunked unmask differs: %q vs %q", chunked, whole)
+	}
+
+	Unmask(mask, 0, whole)
+	if !bytes.Equal(whole, payload) {
+		t.Errorf("unmask twice should restore the payload")
+	}
+}
+
+func TestWriteClose(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+		want   string
+	}{
+		{"short reason", "message blocked", "message blocked"},
+		{"long reason", strings.Repeat("x", 200), strings.Repeat("x", maxCloseReason)},
+		// обрезка посреди двухбайтного символа не оставляет половину руны
+		{"multibyte reason", "x" + strings.Repeat("я", 100), "x" + strings.Repeat("я", 61)},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteClose(&buf, ClosePolicyViolation, tt.reason, false); err != nil {
+			t.Fatal(err)
+		}
+		h, err := ReadHeader(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if h.Opcode != OpClose || h.Length > 125 {
+			t.Errorf("%s: unexpected header %+v", tt.name, h)
+		}
+
+		payload := buf.Bytes()
+		if code := binary.BigEndian.Uint16(payload); code != ClosePolicyViolation {
+			t.Errorf("%s: expected code %d, got %d", tt.name, ClosePolicyViolation, code)
+		}
+		if reason := string(payload[2:]); reason != tt.want || !utf8.ValidString(reason) {
+			t.Errorf("%s: expected reason %q, got %q", tt.name, tt.want, reason)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HostPattern описывает входящий hostname ресурса:
+//
+//	""                - любой хост
+//	app.example.com   - точное совпадение
+//	*.example.com     - любой поддомен example.com
+type HostPattern struct {
+	Raw    string
+	suffix string
+}
+
+func ParseHostPattern(raw string) (*HostPattern, error) {
+	host := strings.ToLower(strings.TrimSpace(raw))
+	if host == "" {
+		return &HostPattern{}, nil
+	}
+
+	if strings.Contains(host, "/") || strings.Contains(host, ":") {
+		return nil, fmt.Errorf("hostname %q must not contain scheme, port or path", raw)
+	}
+
+	if strings.HasPrefix(host, "*.") {
+		suffix := strings.TrimPrefix(host, "*")
+		if strings.Contains(suffix, "*") || len(suffix) < 2 {
+			return nil, fmt.Errorf("invalid wildcard hostname %q", raw)
+		}
+		return &HostPattern{Raw: host, suffix: suffix}, nil
+	}
+
+	if strings.Contains(host, "*") {
+		return nil, fmt.Errorf("wildcard is allowed only as the first label of hostname %q", raw)
+	}
+
+	return &HostPattern{Raw: host}, nil
+}
+
+func (h *HostPattern) Match(host string) bool {
+	switch {
+	case h.Raw == "":
+		return true
+	case h.suffix != "":
+		return strings.HasSuffix(host, h.suffix) && len(host) > len(h.suffix)
+	default:
+		return host == h.Raw
+	}
+}
+
+// MoreSpecific: точный хост важнее wildcard, wildcard важнее пустого шаблона.
+func (h *HostPattern) MoreSpecific(other *HostPattern) bool {
+	if h.rank() != other.rank() {
+		return h.rank() > other.rank()
+	}
+	if len(h.Raw) != len(other.Raw) {
+		return len(h.Raw) > len(other.Raw)
+	}
+	return h.Raw < other.Raw
+}
+
+func (h *HostPattern) rank() int {
+	switch {
+	case h.Raw == "":
+		return 0
+	case h.suffix != "":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// NormalizeHost приводит значение заголовка Host или SNI к виду, в котором хранятся шаблоны.
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
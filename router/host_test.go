@@ -0,0 +1,73 @@
+package router
+
+import "testing"
+
+func TestHostPattern(t *testing.T) {
+	tests := []struct {
+		raw     string
+		valid   bool
+		matches []string
+		misses  []string
+	}{
+		{raw: "", valid: true, matches: []string{"", "example.com"}},
+		{raw: " App.Example.com ", valid: true, matches: []string{"app.example.com"}, misses: []string{"example.com", "x.app.example.com"}},
+		{raw: "*.example.com", valid: true,
+			matches: []string{"api.example.com", "a.b.example.com"},
+			misses:  []string{"example.com", ".example.com", "badexample.com"}},
+
+		{raw: "https://example.com", valid: false},
+		{raw: "example.com:8080", valid: false},
+		{raw: "example.com/api", valid: false},
+		{raw: "*.", valid: false},
+		{raw: "*.*.example.com", valid: false},
+		{raw: "api.*.example.com", valid: false},
+		{raw: "*example.com", valid: false},
+	}
+
+	for _, tt := range tests {
+		h, err := ParseHostPattern(tt.raw)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseHostPattern(%q): expected valid %v, got error %v", tt.raw, tt.valid, err)
+			continue
+		}
+		for _, host := range tt.matches {
+			if !h.Match(host) {
+				t.Errorf("%q should match %q", tt.raw, host)
+			}
+		}
+		for _, host := range tt.misses {
+			if h.Match(host) {
+				t.Errorf("%q should not match %q", tt.raw, host)
+			}
+		}
+	}
+}
+
+func TestHostPatternMoreSpecific(t *testing.T) {
+	ordered := []string{"api.example.com", "*.api.example.com", "*.example.com", ""}
+	for i := 0; i+1 < len(ordered); i++ {
+		a, _ := ParseHostPattern(ordered[i])
+		b, _ := ParseHostPattern(ordered[i+1])
+		if !a.MoreSpecific(b) || b.MoreSpecific(a) {
+			t.Errorf("expected %q to be more specific than %q", ordered[i], ordered[i+1])
+		}
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"Example.COM", "example.com"},
+		{"example.com.", "example.com"},
+		{"example.com:8443", "example.com"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeHost(tt.host); got != tt.want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
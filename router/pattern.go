@@ -0,0 +1,189 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kind задает тип шаблона. чем больше значение, тем специфичнее шаблон.
+type Kind int
+
+const (
+	KindRegex Kind = iota
+	KindPrefix
+	KindTemplate
+	KindExact
+)
+
+const (
+	regexPrefix    = "~"
+	wildcardSuffix = "/*"
+)
+
+// Pattern описывает url ресурса:
+//
+//	/users            - точное совпадение
+//	/users/{id}       - шаблон, {id} совпадает с одним сегментом пути
+//	/api/*            - префикс, совпадает с /api и всем, что глубже
+//	~^/files/\d+$     - регулярное выражение, именованные группы становятся параметрами
+type Pattern struct {
+	Raw      string
+	Kind     Kind
+	segments []string
+	literals int
+	regex    *regexp.Regexp
+}
+
+type Match struct {
+	Params map[string]string
+	Rest   string
+}
+
+func ParsePattern(raw string) (*Pattern, error) {
+	if raw == "" {
+		return nil, errors.New("pattern must not be empty")
+	}
+
+	if strings.HasPrefix(raw, regexPrefix) {
+		re, err := regexp.Compile(`^(?:` + strings.TrimPrefix(raw, regexPrefix) + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern %q: %w", raw, err)
+		}
+		return &Pattern{Raw: raw, Kind: KindRegex, regex: re}, nil
+	}
+
+	if !strings.HasPrefix(raw, "/") {
+		return nil, fmt.Errorf("pattern %q must start with /", raw)
+	}
+
+	if strings.HasSuffix(raw, wildcardSuffix) {
+		base := strings.TrimSuffix(raw, wildcardSuffix)
+		if strings.ContainsAny(base, "{}*") {
+			return nil, fmt.Errorf("prefix pattern %q must not contain parameters or wildcards", raw)
+		}
+		return &Pattern{Raw: raw, Kind: KindPrefix, segments: splitPath(base), literals: len(splitPath(base))}, nil
+	}
+
+	if strings.Contains(raw, "*") {
+		return nil, fmt.Errorf("wildcard is allowed only at the end of pattern %q", raw)
+	}
+
+	if !strings.ContainsAny(raw, "{}") {
+		return &Pattern{Raw: raw, Kind: KindExact, segments: splitPath(raw), literals: len(splitPath(raw))}, nil
+	}
+
+	segments := splitPath(raw)
+	names := make(map[string]struct{})
+	literals := 0
+	for _, segment := range segments {
+		name, isParam, err := parseParam(segment)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", raw, err)
+		}
+		if !isParam {
+			literals++
+			continue
+		}
+		if _, exists := names[name]; exists {
+			return nil, fmt.Errorf("invalid pattern %q: duplicate parameter %q", raw, name)
+		}
+		names[name] = struct{}{}
+	}
+
+	return &Pattern{Raw: raw, Kind: KindTemplate, segments: segments, literals: literals}, nil
+}
+
+func (p *Pattern) Match(path string) (*Match, bool) {
+	switch p.Kind {
+	case KindExact:
+		if path != p.Raw {
+			return nil, false
+		}
+		return &Match{}, true
+	case KindPrefix:
+		base := strings.TrimSuffix(p.Raw, wildcardSuffix)
+		if path != base && !strings.HasPrefix(path, base+"/") {
+			return nil, false
+		}
+		return &Match{Rest: strings.TrimPrefix(path, base)}, true
+	case KindTemplate:
+		return p.matchTemplate(path)
+	case KindRegex:
+		groups := p.regex.FindStringSubmatch(path)
+		if groups == nil {
+			return nil, false
+		}
+		params := make(map[string]string)
+		for i, name := range p.regex.SubexpNames() {
+			if i != 0 && name != "" {
+				params[name] = groups[i]
+			}
+		}
+		return &Match{Params: params}, true
+	}
+	return nil, false
+}
+
+func (p *Pattern) matchTemplate(path string) (*Match, bool) {
+	segments := splitPath(path)
+	if len(segments) != len(p.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range p.segments {
+		name, isParam, _ := parseParam(segment)
+		if !isParam {
+			if segments[i] != segment {
+				return nil, false
+			}
+			continue
+		}
+		if segments[i] == "" {
+			return nil, false
+		}
+		params[name] = segments[i]
+	}
+	return &Match{Params: params}, true
+}
+
+// MoreSpecific сообщает, должен ли p проверяться раньше other.
+func (p *Pattern) MoreSpecific(other *Pattern) bool {
+	if p.Kind != other.Kind {
+		return p.Kind > other.Kind
+	}
+	if p.literals != other.literals {
+		return p.literals > other.literals
+	}
+	if len(p.segments) != len(other.segments) {
+		return len(p.segments) > len(other.segments)
+	}
+	if len(p.Raw) != len(other.Raw) {
+		return len(p.Raw) > len(other.Raw)
+	}
+	return p.Raw < other.Raw
+}
+
+func splitPath(path string) []string {
+	trimmed := strings.TrimPrefix(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
+
+func parseParam(segment string) (string, bool, error) {
+	if !strings.ContainsAny(segment, "{}") {
+		return "", false, nil
+	}
+	if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+		return "", false, fmt.Errorf("parameter must occupy the whole segment: %q", segment)
+	}
+	name := segment[1 : len(segment)-1]
+	if name == "" || strings.ContainsAny(name, "{}") {
+		return "", false, fmt.Errorf("invalid parameter name in segment %q", segment)
+	}
+	return name, true, nil
+}
@@ -0,0 +1,113 @@
+package router
+
+import "testing"
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		raw   string
+		kind  Kind
+		valid bool
+	}{
+		{"/users", KindExact, true},
+		{"/", KindExact, true},
+		{"/users/{id}", KindTemplate, true},
+		{"/orgs/{org}/users/{id}", KindTemplate, true},
+		{"/api/*", KindPrefix, true},
+		{"/*", KindPrefix, true},
+		{`~^/files/\d+$`, KindRegex, true},
+
+		{"", 0, false},
+		{"users", 0, false},
+		{"/users/{id", 0, false},
+		{"/users/id-{id}", 0, false},
+		{"/users/{}", 0, false},
+		{"/users/{id}/{id}", 0, false},
+		{"/api/*/items", 0, false},
+		{"/api/{version}/*", 0, false},
+		{"~^/files/(", 0, false},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePattern(tt.raw)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParsePattern(%q): expected valid %v, got error %v", tt.raw, tt.valid, err)
+			continue
+		}
+		if err == nil && p.Kind != tt.kind {
+			t.Errorf("ParsePattern(%q): expected kind %d, got %d", tt.raw, tt.kind, p.Kind)
+		}
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		ok      bool
+		params  map[string]string
+		rest    string
+	}{
+		{pattern: "/users", path: "/users", ok: true},
+		{pattern: "/users", path: "/users/", ok: false},
+		{pattern: "/users/{id}", path: "/users/42", ok: true, params: map[string]string{"id": "42"}},
+		{pattern: "/users/{id}", path: "/users/", ok: false},
+		{pattern: "/users/{id}", path: "/users/42/posts", ok: false},
+		{pattern: "/orgs/{org}/users/{id}", path: "/orgs/acme/users/7", ok: true,
+			params: map[string]string{"org": "acme", "id": "7"}},
+		{pattern: "/api/*", path: "/api", ok: true, rest: ""},
+		{pattern: "/api/*", path: "/api/v1/items", ok: true, rest: "/v1/items"},
+		// префикс совпадает только по границе сегмента
+		{pattern: "/api/*", path: "/apiv2", ok: false},
+		{pattern: "/*", path: "/anything", ok: true, rest: "/anything"},
+		// регулярное выражение привязано к началу и концу пути
+		{pattern: `~/files/(?P<id>\d+)`, path: "/files/12", ok: true, params: map[string]string{"id": "12"}},
+		{pattern: `~/files/(?P<id>\d+)`, path: "/files/12/raw", ok: false},
+		{pattern: `~/a|/b`, path: "/b", ok: true},
+		{pattern: `~/a|/b`, path: "/ab", ok: false},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match, ok := p.Match(tt.path)
+		if ok != tt.ok {
+			t.Errorf("%s matching %s: expected %v", tt.pattern, tt.path, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if match.Rest != tt.rest {
+			t.Errorf("%s matching %s: expected rest %q, got %q", tt.pattern, tt.path, tt.rest, match.Rest)
+		}
+		for name, want := range tt.params {
+			if got := match.Params[name]; got != want {
+				t.Errorf("%s matching %s: expected param %s=%q, got %q", tt.pattern, tt.path, name, want, got)
+			}
+		}
+	}
+}
+
+func TestPatternMoreSpecific(t *testing.T) {
+	// каждый шаблон должен проверяться раньше следующего
+	ordered := []string{
+		"/users/me",
+		"/users",
+		"/users/{id}/posts",
+		"/users/{id}",
+		"/{a}/{b}",
+		"/api/v1/*",
+		"/api/*",
+		`~^/users/\d+$`,
+	}
+
+	for i := 0; i+1 < len(ordered); i++ {
+		a, _ := ParsePattern(ordered[i])
+		b, _ := ParsePattern(ordered[i+1])
+		if !a.MoreSpecific(b) || b.MoreSpecific(a) {
+			t.Errorf("expected %s to be more specific than %s", a.Raw, b.Raw)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package router сопоставляет запрос с ресурсом по hostname, шаблону пути и методу. общий модуль прокси
+// и rules engine: анализатор выбирает для запроса тот же ресурс, что и прокси.
+package router
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrNotFound         = errors.New("route not found")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+type route[T any] struct {
+	host    *HostPattern
+	pattern *Pattern
+	methods map[string]T
+}
+
+// Router выбирает маршрут по (host, path, method). сначала учитывается специфичность хоста,
+// затем пути, поэтому маршруты конкретного сайта перекрывают маршруты без hostname.
+type Router[T any] struct {
+	routes []*route[T]
+	byKey  map[string]*route[T]
+}
+
+func New[T any]() *Router[T] {
+	return &Router[T]{byKey: make(map[string]*route[T])}
+}
+
+func (r *Router[T]) Add(host, pattern, method string, value T) error {
+	h, err := ParseHostPattern(host)
+	if err != nil {
+		return err
+	}
+
+	key := h.Raw + " " + pattern
+	if rt, exists := r.byKey[key]; exists {
+		rt.methods[method] = value
+		return nil
+	}
+
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	rt := &route[T]{host: h, pattern: p, methods: map[string]T{method: value}}
+	r.byKey[key] = rt
+	r.routes = append(r.routes, rt)
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		a, b := r.routes[i], r.routes[j]
+		if a.host.Raw != b.host.Raw {
+			return a.host.MoreSpecific(b.host)
+		}
+		return a.pattern.MoreSpecific(b.pattern)
+	})
+	return nil
+}
+
+func (r *Router[T]) Lookup(host, path, method string) (T, *Match, error) {
+	var zero T
+	pathMatched := false
+	host = NormalizeHost(host)
+
+	for _, rt := range r.routes {
+		if !rt.host.Match(host) {
+			continue
+		}
+		match, ok := rt.pattern.Match(path)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+
+		if value, exists := rt.methods[method]; exists {
+			return value, match, nil
+		}
+	}
+
+	if pathMatched {
+		return zero, nil, ErrMethodNotAllowed
+	}
+	return zero, nil, ErrNotFound
+}
+
+// HasExactHost сообщает, есть ли маршрут с точно таким hostname (без wildcard).
+func (r *Router[T]) HasExactHost(host string) bool {
+	host = NormalizeHost(host)
+	for _, rt := range r.routes {
+		if rt.host.suffix == "" && rt.host.Raw != "" && rt.host.Raw == host {
+			return true
+		}
+	}
+	return false
+}
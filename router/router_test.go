@@ -0,0 +1,60 @@
+package router
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	r := New[string]()
+	routes := []struct{ host, pattern, method, value string }{
+		{"", "/users", "GET", "users"},
+		{"", "/users", "POST", "create user"},
+		{"", "/users/{id}", "GET", "user"},
+		{"", "/api/*", "GET", "api"},
+		{"", `~^/files/(?P<id>\d+)$`, "GET", "file"},
+		{"app.example.com", "/users", "GET", "app users"},
+		{"*.example.com", "/api/*", "GET", "example api"},
+	}
+	for _, rt := range routes {
+		if err := r.Add(rt.host, rt.pattern, rt.method, rt.value); err != nil {
+			t.Fatalf("%s %s: %v", rt.host, rt.pattern, err)
+		}
+	}
+
+	tests := []struct {
+		host, path, method string
+		value              string
+		params             map[string]string
+		err                error
+	}{
+		{"", "/users", "GET", "users", nil, nil},
+		{"", "/users", "POST", "create user", nil, nil},
+		{"", "/users", "DELETE", "", nil, ErrMethodNotAllowed},
+		{"", "/users/42", "GET", "user", map[string]string{"id": "42"}, nil},
+		{"", "/api", "GET", "api", nil, nil},
+		{"", "/api/v1/items", "GET", "api", nil, nil},
+		{"", "/files/7", "GET", "file", map[string]string{"id": "7"}, nil},
+		{"", "/files/x", "GET", "", nil, ErrNotFound},
+		{"app.example.com", "/users", "GET", "app users", nil, nil},
+		{"App.Example.com.:8080", "/users", "GET", "app users", nil, nil},
+		{"cdn.example.com", "/api/items", "GET", "example api", nil, nil},
+		{"example.org", "/api/items", "GET", "api", nil, nil},
+		{"", "/missing", "GET", "", nil, ErrNotFound},
+	}
+	for _, tt := range tests {
+		value, match, err := r.Lookup(tt.host, tt.path, tt.method)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s %s %s: expected error %v, got %v", tt.method, tt.host, tt.path, tt.err, err)
+			continue
+		}
+		if value != tt.value {
+			t.Errorf("%s %s %s: expected %q, got %q", tt.method, tt.host, tt.path, tt.value, value)
+		}
+		for name, want := range tt.params {
+			if got := match.Params[name]; got != want {
+				t.Errorf("%s %s %s: expected param %s=%q, got %q", tt.method, tt.host, tt.path, name, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// corpus прогоняет детекторы атак по корпусу известных атак и безопасных значений и печатает
+// долю пропусков (false negative) и ложных срабатываний (false positive).
+//
+//	go run ./cmd/corpus -detector sqli -v
+//
+// корпус лежит в data/<detector>/attacks.txt и benign.txt, по одному значению на строку.
+// с -max-fn и -max-fp команда завершается с ошибкой, если доли превышают порог, и годится для CI.
+package main
+
+import (
+	"bufio"
+	"embed"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"rules-engine/internal/ldap"
+	"rules-engine/internal/nosql"
+	"rules-engine/internal/sqli"
+	"rules-engine/internal/ssti"
+	"rules-engine/internal/xss"
+	"rules-engine/internal/xxe"
+	"sort"
+	"strings"
+)
+
+//go:embed data
+var data embed.FS
+
+// detector возвращает описание срабатывания (отпечаток, найденный тег) и признак атаки.
+type detector func(string) (string, bool)
+
+var detectors = map[string]detector{
+	"ldap":  ldap.Detect,
+	"nosql": nosql.Detect,
+	"sqli":  sqli.Detect,
+	"ssti":  ssti.Detect,
+	"xss":   xss.Detect,
+	"xxe":   xxe.Detect,
+}
+
+func main() {
+	name := flag.String("detector", "", "detector to run, all by default")
+	verbose := flag.Bool("v", false, "print every miss and false positive")
+	maxFN := flag.Float64("max-fn", 1, "fail if the false negative rate is above this value")
+	maxFP := flag.Float64("max-fp", 1, "fail if the false positive rate is above this value")
+	flag.Parse()
+
+	names := make([]string, 0, len(detectors))
+	for n := range detectors {
+		if *name == "" || *name == n {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		log.Fatalf("unknown detector: %s", *name)
+	}
+	sort.Strings(names)
+
+	failed := false
+	for _, n := range names {
+		fn, fp, err := run(n, detectors[n], *verbose)
+		if err != nil {
+			log.Fatalf("%s: %v", n, err)
+		}
+		if fn > *maxFN || fp > *maxFP {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func run(name string, detect detector, verbose bool) (float64, float64, error) {
+	attacks, err := load(name + "/attacks.txt")
+	if err != nil {
+		return 0, 0, err
+	}
+	benign, err := load(name + "/benign.txt")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var missed, falsePositives []string
+	for _, input := range attacks {
+		if _, ok := detect(input); !ok {
+			missed = append(missed, input)
+		}
+	}
+	for _, input := range benign {
+		if detail, ok := detect(input); ok {
+			falsePositives = append(falsePositives, fmt.Sprintf("%s\t[%s]", input, detail))
+		}
+	}
+
+	fn := rate(len(missed), len(attacks))
+	fp := rate(len(falsePositives), len(benign))
+	fmt.Printf("%s: attacks %d, missed %d (FN %.1f%%); benign %d, flagged %d (FP %.1f%%)\n",
+		name, len(attacks), len(missed), fn*100, len(benign), len(falsePositives), fp*100)
+
+	if verbose {
+		for _, input := range missed {
+			fmt.Printf("  FN  %s\n", input)
+		}
+		for _, input := range falsePositives {
+			fmt.Printf("  FP  %s\n", input)
+		}
+	}
+	return fn, fp, nil
+}
+
+func load(path string) ([]string, error) {
+	file, err := data.Open("data/" + path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+// пороги по текущему корпусу: sqli пропускает 1 and true, 1 or true и 1 xor 1, их отпечаток 1&1
+// совпадает с обычным текстом вроде 1 or 2
+var thresholds = map[string]struct{ fn, fp float64 }{
+	"ldap":  {0, 0},
+	"nosql": {0, 0},
+	"sqli":  {0.025, 0},
+	"ssti":  {0, 0},
+	"xss":   {0, 0},
+	"xxe":   {0, 0},
+}
+
+func TestCorpus(t *testing.T) {
+	for name, detect := range detectors {
+		t.Run(name, func(t *testing.T) {
+			limit, ok := thresholds[name]
+			if !ok {
+				t.Fatalf("no thresholds for detector %s", name)
+			}
+			fn, fp, err := run(name, detect, testing.Verbose())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fn > limit.fn {
+				t.Errorf("false negative rate %.3f is above %.3f", fn, limit.fn)
+			}
+			if fp > limit.fp {
+				t.Errorf("false positive rate %.3f is above %.3f", fp, limit.fp)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	authservice "rules-engine/internal/clients/auth_service"
 	"rules-engine/internal/config"
+	"rules-engine/internal/csrf"
 	"rules-engine/internal/delivery"
 	"rules-engine/internal/delivery/middleware"
 	"rules-engine/internal/logger"
@@ -42,19 +43,45 @@ func main() {
 	resourceIPListRepo := postgres.NewPostgresResourceIPListRepository(db)
 	resourceRuleRepo := postgres.NewPostgresResourceRuleRepository(db)
 	ruleRepo := postgres.NewPostgresRuleRepository(db)
+	upstreamRepo := postgres.NewPostgresUpstreamRepository(db)
+	certificateRepo := postgres.NewPostgresCertificateRepository(db)
+	exclusionRepo := postgres.NewPostgresRuleExclusionRepository(db)
+
+	resourceRoutes := usecase.NewResourceRoutes(resourceRepo, cfg.ResourcesRefreshInterval)
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go resourceRoutes.Watch(watchCtx)
 
 	ipListUseCase := usecase.NewIPListUseCase(ipListRepo)
 	ruleUseCase := usecase.NewRuleUseCase(ruleRepo)
-	resourceUseCase := usecase.NewResourceUseCase(resourceRepo, ipListUseCase, ruleUseCase, resourceIPListRepo, resourceRuleRepo)
-	analyzer := usecase.NewAnalyzerUseCase(ruleRepo, ipListRepo)
+	upstreamUseCase := usecase.NewUpstreamUseCase(upstreamRepo)
+	certificateUseCase := usecase.NewCertificateUseCase(certificateRepo)
+	resourceUseCase := usecase.NewResourceUseCase(
+		resourceRepo,
+		ipListUseCase,
+		ruleUseCase,
+		upstreamUseCase,
+		resourceIPListRepo,
+		resourceRuleRepo,
+		exclusionRepo,
+		resourceRoutes,
+	)
+	csrfSigner, err := csrf.NewSigner(cfg.CSRFSecrets)
+	if err != nil {
+		log.Fatalf("failed to create csrf signer: %v", err)
+	}
+	analyzer := usecase.NewAnalyzerUseCase(resourceRoutes, ruleRepo, ipListRepo, exclusionRepo, csrfSigner)
 
 	resourceHandler := delivery.NewResourceHandler(resourceUseCase)
 	ipListHandler := delivery.NewIPListHandler(ipListUseCase)
 	ruleHandler := delivery.NewRuleHandler(ruleUseCase)
+	upstreamHandler := delivery.NewUpstreamHandler(upstreamUseCase)
+	certificateHandler := delivery.NewCertificateHandler(certificateUseCase)
 	analyzerHandler := delivery.NewAnalyzerHandler(analyzer)
 
 	authClient := authservice.NewAuthClient(cfg.AuthURL)
 	authMiddleware := middleware.AuthMiddleware(authClient)
+	internalMiddleware := middleware.InternalMiddleware(cfg.InternalToken)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /resources", resourceHandler.HandleGetActiveResources)
@@ -65,6 +92,11 @@ func main() {
 	mux.Handle("POST /resources/{id}/attach_rule", authMiddleware(http.HandlerFunc(resourceHandler.HandleAttachRule)))
 	mux.Handle("POST /resources/{id}/detach_rule", authMiddleware(http.HandlerFunc(resourceHandler.HandleDetachRule)))
 	mux.Handle("PUT /resources/{id}", authMiddleware(http.HandlerFunc(resourceHandler.HandleUpdateResource)))
+	mux.Handle("POST /resources/import_openapi", authMiddleware(http.HandlerFunc(resourceHandler.HandleImportOpenAPI)))
+	mux.Handle("POST /resources/{id}/openapi", authMiddleware(http.HandlerFunc(resourceHandler.HandleUploadOpenAPI)))
+	mux.Handle("POST /resources/{id}/exclusions", authMiddleware(http.HandlerFunc(resourceHandler.HandleCreateExclusion)))
+	mux.Handle("PUT /resources/{id}/exclusions/{exclusion_id}", authMiddleware(http.HandlerFunc(resourceHandler.HandleUpdateExclusion)))
+	mux.Handle("DELETE /resources/{id}/exclusions/{exclusion_id}", authMiddleware(http.HandlerFunc(resourceHandler.HandleDeleteExclusion)))
 
 	mux.Handle("POST /ip_lists", authMiddleware(http.HandlerFunc(ipListHandler.HandleCreateIPList)))
 	mux.Handle("PUT /ip_lists/{id}", authMiddleware(http.HandlerFunc(ipListHandler.HandleUpdateIPList)))
@@ -72,8 +104,18 @@ func main() {
 
 	mux.Handle("POST /rules", authMiddleware(http.HandlerFunc(ruleHandler.HandleCreateRule)))
 	mux.Handle("PUT /rules/{id}", authMiddleware(http.HandlerFunc(ruleHandler.HandleUpdateRule)))
+	mux.Handle("POST /rules/import", authMiddleware(http.HandlerFunc(ruleHandler.HandleImportRules)))
 	mux.HandleFunc("GET /rules", ruleHandler.HandleGetRules)
 
+	mux.Handle("POST /upstreams", authMiddleware(http.HandlerFunc(upstreamHandler.HandleCreateUpstream)))
+	mux.Handle("PUT /upstreams/{id}", authMiddleware(http.HandlerFunc(upstreamHandler.HandleUpdateUpstream)))
+	mux.HandleFunc("GET /upstreams", upstreamHandler.HandleGetUpstreams)
+
+	mux.Handle("POST /certificates", authMiddleware(http.HandlerFunc(certificateHandler.HandleCreateCertificate)))
+	mux.Handle("PUT /certificates/{id}", authMiddleware(http.HandlerFunc(certificateHandler.HandleUpdateCertificate)))
+	mux.Handle("GET /certificates", authMiddleware(http.HandlerFunc(certificateHandler.HandleGetCertificates)))
+	mux.Handle("GET /internal/certificates", internalMiddleware(http.HandlerFunc(certificateHandler.HandleGetCertificateBundle)))
+
 	mux.HandleFunc("GET /analyze", analyzerHandler.HandleAnalyzeRequest)
 
 	srv := &http.Server{
@@ -0,0 +1 @@
+ALTER TABLE resources DROP COLUMN hostname;
\ No newline at end of file
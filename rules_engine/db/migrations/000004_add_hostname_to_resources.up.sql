@@ -0,0 +1 @@
+ALTER TABLE resources ADD COLUMN hostname TEXT NOT NULL DEFAULT '';
\ No newline at end of file
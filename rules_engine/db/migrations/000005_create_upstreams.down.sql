@@ -0,0 +1,2 @@
+ALTER TABLE resources DROP COLUMN upstream_id;
+DROP TABLE IF EXISTS upstreams;
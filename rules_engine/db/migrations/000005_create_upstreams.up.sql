@@ -0,0 +1,15 @@
+CREATE TABLE upstreams (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    balancer TEXT NOT NULL DEFAULT 'round_robin' CHECK (balancer IN ('round_robin', 'least_conn', 'consistent_hash')),
+    hash_key TEXT NOT NULL DEFAULT '',
+    targets TEXT[] NOT NULL,
+    health_check_path TEXT NOT NULL DEFAULT '',
+    health_check_interval INTEGER NOT NULL DEFAULT 10 CHECK (health_check_interval > 0),
+    max_fails INTEGER NOT NULL DEFAULT 3 CHECK (max_fails >= 0),
+    fail_timeout INTEGER NOT NULL DEFAULT 30 CHECK (fail_timeout >= 0),
+    creator_id UUID NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+ALTER TABLE resources ADD COLUMN upstream_id UUID REFERENCES upstreams(id) ON DELETE SET NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE resources
+    DROP COLUMN connect_timeout_ms,
+    DROP COLUMN read_timeout_ms,
+    DROP COLUMN retries,
+    DROP COLUMN retry_backoff_ms,
+    DROP COLUMN breaker_error_rate,
+    DROP COLUMN breaker_min_requests,
+    DROP COLUMN breaker_open_ms;
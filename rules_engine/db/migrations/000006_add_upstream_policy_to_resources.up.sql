@@ -0,0 +1,8 @@
+ALTER TABLE resources
+    ADD COLUMN connect_timeout_ms INTEGER NOT NULL DEFAULT 0 CHECK (connect_timeout_ms >= 0),
+    ADD COLUMN read_timeout_ms INTEGER NOT NULL DEFAULT 0 CHECK (read_timeout_ms >= 0),
+    ADD COLUMN retries INTEGER NOT NULL DEFAULT 0 CHECK (retries >= 0),
+    ADD COLUMN retry_backoff_ms INTEGER NOT NULL DEFAULT 100 CHECK (retry_backoff_ms >= 0),
+    ADD COLUMN breaker_error_rate REAL NOT NULL DEFAULT 0 CHECK (breaker_error_rate >= 0 AND breaker_error_rate <= 1),
+    ADD COLUMN breaker_min_requests INTEGER NOT NULL DEFAULT 20 CHECK (breaker_min_requests >= 0),
+    ADD COLUMN breaker_open_ms INTEGER NOT NULL DEFAULT 30000 CHECK (breaker_open_ms >= 0);
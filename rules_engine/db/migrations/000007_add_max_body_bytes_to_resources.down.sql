@@ -0,0 +1 @@
+ALTER TABLE resources DROP COLUMN max_body_bytes;
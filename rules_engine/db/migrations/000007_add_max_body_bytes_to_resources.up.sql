@@ -0,0 +1 @@
+ALTER TABLE resources ADD COLUMN max_body_bytes BIGINT NOT NULL DEFAULT 0 CHECK (max_body_bytes >= 0);
@@ -0,0 +1,2 @@
+ALTER TABLE resources DROP COLUMN inspect_messages;
+ALTER TABLE resources DROP COLUMN allow_upgrade;
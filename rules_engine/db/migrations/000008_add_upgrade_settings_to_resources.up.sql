@@ -0,0 +1,2 @@
+ALTER TABLE resources ADD COLUMN allow_upgrade BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE resources ADD COLUMN inspect_messages BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE resources DROP COLUMN https_redirect;
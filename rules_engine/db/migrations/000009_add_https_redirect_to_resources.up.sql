@@ -0,0 +1 @@
+ALTER TABLE resources ADD COLUMN https_redirect BOOLEAN NOT NULL DEFAULT false;
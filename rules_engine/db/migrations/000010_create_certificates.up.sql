@@ -0,0 +1,10 @@
+CREATE TABLE certificates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    hostnames TEXT[] NOT NULL,
+    cert_pem TEXT NOT NULL,
+    key_pem TEXT NOT NULL,
+    not_after TIMESTAMP NOT NULL,
+    creator_id UUID NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE rules DROP COLUMN log_only;
+ALTER TABLE resources DROP COLUMN log_only;
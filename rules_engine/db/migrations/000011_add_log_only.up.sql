@@ -0,0 +1,2 @@
+ALTER TABLE resources ADD COLUMN log_only BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE rules ADD COLUMN log_only BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,5 @@
+DELETE FROM rules WHERE action_type = 'allow';
+ALTER TABLE rules DROP CONSTRAINT rules_action_type_check;
+ALTER TABLE rules ADD CONSTRAINT rules_action_type_check CHECK (action_type IN ('block', 'sanitize', 'escape'));
+
+ALTER TABLE resource_rule DROP COLUMN priority;
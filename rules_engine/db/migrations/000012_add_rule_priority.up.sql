@@ -0,0 +1,4 @@
+ALTER TABLE resource_rule ADD COLUMN priority INTEGER NOT NULL DEFAULT 100;
+
+ALTER TABLE rules DROP CONSTRAINT rules_action_type_check;
+ALTER TABLE rules ADD CONSTRAINT rules_action_type_check CHECK (action_type IN ('block', 'sanitize', 'escape', 'allow'));
@@ -0,0 +1,4 @@
+ALTER TABLE rules DROP CONSTRAINT rules_attack_type_check;
+ALTER TABLE rules ADD CONSTRAINT rules_attack_type_check CHECK (attack_type IN ('xss', 'csrf', 'sqli', 'custom'));
+
+ALTER TABLE rules ADD COLUMN conditions JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,5 @@
+ALTER TABLE rules DROP COLUMN sec_rule;
+
+DELETE FROM rules WHERE attack_type = 'seclang';
+ALTER TABLE rules DROP CONSTRAINT rules_attack_type_check;
+ALTER TABLE rules ADD CONSTRAINT rules_attack_type_check CHECK (attack_type IN ('xss', 'csrf', 'sqli', 'custom', 'expression'));
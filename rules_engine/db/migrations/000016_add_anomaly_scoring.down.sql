@@ -0,0 +1,8 @@
+ALTER TABLE rules
+    DROP COLUMN paranoia_level,
+    DROP COLUMN severity;
+
+ALTER TABLE resources
+    DROP COLUMN paranoia_level,
+    DROP COLUMN anomaly_threshold,
+    DROP COLUMN evaluation_mode;
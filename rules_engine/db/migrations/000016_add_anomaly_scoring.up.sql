@@ -0,0 +1,8 @@
+ALTER TABLE resources
+    ADD COLUMN evaluation_mode TEXT NOT NULL DEFAULT 'first_match' CHECK (evaluation_mode IN ('first_match', 'anomaly')),
+    ADD COLUMN anomaly_threshold INTEGER NOT NULL DEFAULT 5 CHECK (anomaly_threshold > 0),
+    ADD COLUMN paranoia_level INTEGER NOT NULL DEFAULT 1 CHECK (paranoia_level BETWEEN 1 AND 4);
+
+ALTER TABLE rules
+    ADD COLUMN severity TEXT NOT NULL DEFAULT 'critical' CHECK (severity IN ('critical', 'error', 'warning', 'notice')),
+    ADD COLUMN paranoia_level INTEGER NOT NULL DEFAULT 1 CHECK (paranoia_level BETWEEN 1 AND 4);
@@ -0,0 +1,2 @@
+ALTER TABLE rules
+    ADD COLUMN transformations JSONB NOT NULL DEFAULT '[]';
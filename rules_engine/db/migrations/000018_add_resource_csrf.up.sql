@@ -0,0 +1,12 @@
+-- существующие ресурсы сохраняют прежнюю проверку: csrf-правило требует только непустой токен
+ALTER TABLE resources
+    ADD COLUMN csrf JSONB NOT NULL DEFAULT '{
+        "check_origin": false,
+        "allowed_origins": [],
+        "double_submit": false,
+        "signed_token": false,
+        "cookie_name": "csrf_token",
+        "header_name": "X-CSRF-Token",
+        "field_name": "csrf_token",
+        "token_ttl_seconds": 43200
+    }';
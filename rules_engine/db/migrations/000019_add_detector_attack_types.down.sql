@@ -0,0 +1,3 @@
+DELETE FROM rules WHERE attack_type IN ('path_traversal', 'cmd_injection', 'ssrf', 'lfi', 'rfi');
+ALTER TABLE rules DROP CONSTRAINT rules_attack_type_check;
+ALTER TABLE rules ADD CONSTRAINT rules_attack_type_check CHECK (attack_type IN ('xss', 'csrf', 'sqli', 'custom', 'expression', 'seclang'));
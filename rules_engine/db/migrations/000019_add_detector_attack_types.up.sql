@@ -0,0 +1,2 @@
+ALTER TABLE rules DROP CONSTRAINT rules_attack_type_check;
+ALTER TABLE rules ADD CONSTRAINT rules_attack_type_check CHECK (attack_type IN ('xss', 'csrf', 'sqli', 'custom', 'expression', 'seclang', 'path_traversal', 'cmd_injection', 'ssrf', 'lfi', 'rfi'));
@@ -0,0 +1,11 @@
+CREATE TABLE rule_exclusions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    resource_id UUID NOT NULL,
+    rule_id UUID NOT NULL,
+    target TEXT NOT NULL CHECK (target IN ('arg', 'header', 'cookie', 'json')),
+    name TEXT NOT NULL,
+    ip_list_ids UUID[] NOT NULL DEFAULT '{}',
+    creator_id UUID NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    FOREIGN KEY (rule_id, resource_id) REFERENCES resource_rule (rule_id, resource_id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+-- правило, действие которого с тех пор поменяли вручную, не трогаем
+UPDATE rules SET action_type = 'allow'
+WHERE action_type = 'block'
+  AND id IN (SELECT rule_id FROM detector_allow_rules);
+
+DROP TABLE detector_allow_rules;
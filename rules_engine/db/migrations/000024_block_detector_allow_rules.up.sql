@@ -0,0 +1,13 @@
+-- allow для детектора пропускал найденную атаку мимо остальных правил, поэтому такие правила блокируют.
+-- их id сохраняются в detector_allow_rules, чтобы down вернул прежнее действие
+CREATE TABLE detector_allow_rules (
+    rule_id UUID PRIMARY KEY REFERENCES rules (id) ON DELETE CASCADE
+);
+
+INSERT INTO detector_allow_rules (rule_id)
+SELECT id FROM rules
+WHERE action_type = 'allow'
+  AND attack_type IN ('xss', 'sqli', 'path_traversal', 'cmd_injection', 'ssrf', 'lfi', 'rfi', 'nosql', 'ldap', 'xxe', 'ssti');
+
+UPDATE rules SET action_type = 'block'
+WHERE id IN (SELECT rule_id FROM detector_allow_rules);
@@ -8,6 +8,9 @@ require (
 	github.com/lib/pq v1.10.9
 	go.elastic.co/ecszap v1.0.3
 	go.uber.org/zap v1.27.0
+	golang.org/x/net v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+	router v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -15,6 +18,7 @@ require (
 	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	olympos.io/encoding/edn v0.0.0-20201019073823-d3554ca0b0a3 // indirect
 )
+
+replace router => ../router
@@ -0,0 +1,195 @@
+package condition
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/inspect"
+	"rules-engine/internal/transform"
+)
+
+// поддерживаемые части запроса. header:<name>, arg:<name> и cookie:<name> выбирают заголовок,
+// аргумент из query или разобранного тела (json поля - по пути json.user.name) и cookie.
+const (
+	TargetMethod = "method"
+	TargetHost   = "host"
+	TargetIP     = "ip"
+	TargetURL    = "url"
+	TargetPath   = "path"
+	TargetQuery  = "query"
+	TargetBody   = "body"
+
+	targetHeaderPrefix = "header:"
+	targetArgPrefix    = "arg:"
+	targetCookiePrefix = "cookie:"
+)
+
+const (
+	OpRegex    = "regex"
+	OpContains = "contains"
+	OpEquals   = "equals"
+	OpLengthGt = "length_gt"
+	OpLengthLt = "length_lt"
+	OpLengthEq = "length_eq"
+	OpGt       = "gt"
+	OpLt       = "lt"
+	OpEq       = "eq"
+)
+
+type compiled struct {
+	cond    entity.Condition
+	pattern *regexp.Regexp
+	number  float64
+}
+
+// Matcher - скомпилированный набор условий одного правила.
+type Matcher struct {
+	conds []compiled
+}
+
+// Compile проверяет условия и компилирует регулярки, чтобы ошибка в паттерне обнаруживалась при создании правила.
+func Compile(conds []entity.Condition) (*Matcher, error) {
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("custom rule must have at least one condition")
+	}
+
+	m := &Matcher{conds: make([]compiled, 0, len(conds))}
+	for i, cond := range conds {
+		c, err := compile(cond)
+		if err != nil {
+			return nil, fmt.Errorf("condition %d: %w", i, err)
+		}
+		m.conds = append(m.conds, c)
+	}
+	return m, nil
+}
+
+func compile(cond entity.Condition) (compiled, error) {
+	if err := validateTarget(cond.Target); err != nil {
+		return compiled{}, err
+	}
+
+	c := compiled{cond: cond}
+	switch cond.Operator {
+	case OpRegex:
+		pattern, err := regexp.Compile(cond.Value)
+		if err != nil {
+			return compiled{}, fmt.Errorf("invalid regex %q: %w", cond.Value, err)
+		}
+		c.pattern = pattern
+	case OpContains, OpEquals:
+		if cond.Value == "" {
+			return compiled{}, fmt.Errorf("operator %s requires a value", cond.Operator)
+		}
+	case OpLengthGt, OpLengthLt, OpLengthEq, OpGt, OpLt, OpEq:
+		number, err := strconv.ParseFloat(cond.Value, 64)
+		if err != nil {
+			return compiled{}, fmt.Errorf("operator %s requires a numeric value, got %q", cond.Operator, cond.Value)
+		}
+		c.number = number
+	default:
+		return compiled{}, fmt.Errorf("unknown operator: %s", cond.Operator)
+	}
+	return c, nil
+}
+
+func validateTarget(target string) error {
+	switch target {
+	case TargetMethod, TargetHost, TargetIP, TargetURL, TargetPath, TargetQuery, TargetBody:
+		return nil
+	}
+	if name, ok := strings.CutPrefix(target, targetHeaderPrefix); ok && name != "" {
+		return nil
+	}
+	if name, ok := strings.CutPrefix(target, targetArgPrefix); ok && name != "" {
+		return nil
+	}
+	if name, ok := strings.CutPrefix(target, targetCookiePrefix); ok && name != "" {
+		return nil
+	}
+	return fmt.Errorf("unknown target: %s", target)
+}
+
+// Match возвращает true, если запрос удовлетворяет всем условиям. pipeline применяется к каждому
+// извлеченному значению до сравнения, результаты запоминаются в кэше запроса.
+func (m *Matcher) Match(request *inspect.Request, pipeline *transform.Pipeline) bool {
+	for _, c := range m.conds {
+		value, found := extract(request, c.cond.Target)
+		value = request.Transforms.Apply(pipeline, value)
+		if c.match(value, found) == c.cond.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (c compiled) match(value string, found bool) bool {
+	switch c.cond.Operator {
+	case OpRegex:
+		return found && c.pattern.MatchString(value)
+	case OpContains:
+		return found && strings.Contains(value, c.cond.Value)
+	case OpEquals:
+		return found && value == c.cond.Value
+	case OpLengthGt:
+		return float64(len(value)) > c.number
+	case OpLengthLt:
+		return float64(len(value)) < c.number
+	case OpLengthEq:
+		return float64(len(value)) == c.number
+	}
+
+	// числовые сравнения: нечисловое или отсутствующее значение условию не удовлетворяет
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if !found || err != nil {
+		return false
+	}
+	switch c.cond.Operator {
+	case OpGt:
+		return number > c.number
+	case OpLt:
+		return number < c.number
+	default:
+		return number == c.number
+	}
+}
+
+func extract(request *inspect.Request, target string) (string, bool) {
+	raw := request.Raw
+	switch target {
+	case TargetMethod:
+		return raw.Method, true
+	case TargetHost:
+		return raw.Host, true
+	case TargetIP:
+		return raw.IP, true
+	case TargetURL:
+		return unescape(raw.URL), true
+	case TargetPath:
+		return request.Path, true
+	case TargetQuery:
+		return unescape(request.Query), true
+	case TargetBody:
+		return raw.Body, true
+	}
+
+	if name, ok := strings.CutPrefix(target, targetHeaderPrefix); ok {
+		return request.Header(name)
+	}
+	if name, ok := strings.CutPrefix(target, targetCookiePrefix); ok {
+		return request.Cookie(name)
+	}
+	return request.Arg(strings.TrimPrefix(target, targetArgPrefix))
+}
+
+func unescape(raw string) string {
+	decoded, err := url.QueryUnescape(raw)
+	if err != nil {
+		return raw
+	}
+	return decoded
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -12,6 +13,11 @@ type Config struct {
 	RulesEngineServer `yaml:"rules_engine_server"`
 	RulesEngineDB     `yaml:"rules_engine_db"`
 	AuthURL           string `yaml:"auth_url"`
+	InternalToken     string `yaml:"internal_token" env:"INTERNAL_API_TOKEN"`
+	// CSRFSecrets подписывают csrf-токены: первый выпускает новые, остальные только принимаются до истечения
+	CSRFSecrets []string `yaml:"csrf_secrets" env:"CSRF_SECRETS" env-separator:","`
+	// ResourcesRefreshInterval - как часто анализатор перечитывает ресурсы, измененные другими экземплярами
+	ResourcesRefreshInterval time.Duration `yaml:"resources_refresh_interval" env-default:"10s"`
 }
 
 type RulesEngineServer struct {
@@ -43,6 +49,9 @@ func LoadConfig() (*Config, error) {
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
 		return nil, fmt.Errorf("cannot read config: %w", err)
 	}
+	if cfg.ResourcesRefreshInterval <= 0 {
+		return nil, fmt.Errorf("resources_refresh_interval must be positive")
+	}
 
 	return &cfg, nil
 }
@@ -0,0 +1,128 @@
+package csrf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T, secrets ...string) *Signer {
+	t.Helper()
+	s, err := NewSigner(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewSignerRequiresSecret(t *testing.T) {
+	for _, secrets := range [][]string{nil, {}, {""}} {
+		if _, err := NewSigner(secrets); err == nil {
+			t.Errorf("NewSigner(%q): expected error", secrets)
+		}
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	s := newTestSigner(t, "secret")
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	token := s.Issue("app.example.com", time.Hour)
+	if token == s.Issue("app.example.com", time.Hour) {
+		t.Fatal("expected a fresh nonce for every token")
+	}
+
+	expiry, err := s.Verify(token, "app.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected expiry %v, got %v", now.Add(time.Hour), expiry)
+	}
+
+	payload, mac, _ := cutLast(token, ".")
+	nonce, _, _ := strings.Cut(payload, ".")
+	tests := []struct {
+		name  string
+		token string
+		scope string
+		err   error
+	}{
+		{"other scope", token, "evil.example.com", ErrSignature},
+		{"extended expiry", nonce + ".9999999999." + mac, "app.example.com", ErrSignature},
+		{"other secret", newTestSigner(t, "other").Issue("app.example.com", time.Hour), "app.example.com", ErrSignature},
+		{"no mac", payload, "app.example.com", ErrMalformed},
+		{"no expiry", nonce + "." + mac, "app.example.com", ErrMalformed},
+		{"text expiry", nonce + ".soon." + mac, "app.example.com", ErrMalformed},
+		{"bad encoding", payload + ".!!!", "app.example.com", ErrMalformed},
+		{"empty", "", "app.example.com", ErrMalformed},
+	}
+	for _, tt := range tests {
+		if _, err := s.Verify(tt.token, tt.scope); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	s := newTestSigner(t, "secret")
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	token := s.Issue("app.example.com", time.Minute)
+
+	now = now.Add(time.Minute - time.Second)
+	if _, err := s.Verify(token, "app.example.com"); err != nil {
+		t.Fatalf("expected valid token before expiry, got %v", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := s.Verify(token, "app.example.com"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected %v at expiry, got %v", ErrExpired, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := newTestSigner(t, "old")
+	rotated := newTestSigner(t, "new", "old")
+
+	if _, err := rotated.Verify(old.Issue("app.example.com", time.Hour), "app.example.com"); err != nil {
+		t.Errorf("expected token of the old secret to be accepted after rotation, got %v", err)
+	}
+	token := rotated.Issue("app.example.com", time.Hour)
+	if _, err := newTestSigner(t, "new").Verify(token, "app.example.com"); err != nil {
+		t.Errorf("expected new tokens to be signed by the first secret, got %v", err)
+	}
+	if _, err := old.Verify(token, "app.example.com"); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected new token to be rejected by the old secret alone, got %v", err)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	allowed := []string{"https://app.example.com", "https://admin.example.com"}
+	tests := []struct {
+		origin, referer string
+		allowed         []string
+		ok              bool
+	}{
+		{"https://app.example.com", "", nil, true},
+		{"https://APP.example.com:8443", "", nil, true},
+		{"", "https://app.example.com/form?x=1", nil, true},
+		{"https://evil.example.com", "", nil, false},
+		{"https://evil.example.com", "https://app.example.com/", nil, false},
+		{"", "", nil, false},
+		{"null", "", nil, false},
+		{"app.example.com", "", nil, false},
+		{"https://admin.example.com", "", allowed, true},
+		{"http://app.example.com", "", allowed, false},
+		{"https://app.example.com:8443", "", allowed, false},
+		{"https://other.example.com", "", allowed, false},
+	}
+
+	for _, tt := range tests {
+		err := CheckOrigin(tt.origin, tt.referer, tt.allowed, "app.example.com")
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckOrigin(%q, %q, %v): expected ok=%v, got %v", tt.origin, tt.referer, tt.allowed, tt.ok, err)
+		}
+	}
+}
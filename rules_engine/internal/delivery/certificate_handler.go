@@ -0,0 +1,112 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"rules-engine/internal/delivery/middleware"
+	"rules-engine/internal/entity"
+	"rules-engine/internal/usecase"
+)
+
+type CertificateHandler struct {
+	certificateUseCase *usecase.CertificateUseCase
+}
+
+func NewCertificateHandler(certificateUseCase *usecase.CertificateUseCase) *CertificateHandler {
+	return &CertificateHandler{certificateUseCase: certificateUseCase}
+}
+
+type CertificateRequest struct {
+	Name      string `json:"name"`
+	CertPEM   string `json:"cert_pem"`
+	KeyPEM    string `json:"key_pem"`
+	CreatorID string `json:"creator_id"`
+}
+
+type CertificateResponse struct {
+	Certificates []entity.Certificate `json:"certificates"`
+}
+
+func (req CertificateRequest) params() usecase.CertificateParams {
+	return usecase.CertificateParams{
+		Name:      req.Name,
+		CertPEM:   req.CertPEM,
+		KeyPEM:    req.KeyPEM,
+		CreatorID: req.CreatorID,
+	}
+}
+
+func (h *CertificateHandler) HandleCreateCertificate(w http.ResponseWriter, r *http.Request) {
+	var req CertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		req.CreatorID = user.ID
+	}
+
+	if req.Name == "" || req.CreatorID == "" || req.CertPEM == "" || req.KeyPEM == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
+		return
+	}
+
+	certificate, err := h.certificateUseCase.Create(req.params())
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	certificate.KeyPEM = ""
+	JSONResponse(w, http.StatusOK, certificate, nil)
+}
+
+func (h *CertificateHandler) HandleUpdateCertificate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingID())
+		return
+	}
+
+	var req CertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	certificate, err := h.certificateUseCase.Update(id, req.params())
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	certificate.KeyPEM = ""
+	JSONResponse(w, http.StatusOK, certificate, nil)
+}
+
+// HandleGetCertificates отдает сертификаты без приватных ключей.
+func (h *CertificateHandler) HandleGetCertificates(w http.ResponseWriter, r *http.Request) {
+	certificates, err := h.certificateUseCase.Get()
+	if err != nil {
+		JSONResponse[any](w, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	for i := range certificates {
+		certificates[i].KeyPEM = ""
+	}
+
+	JSONResponse(w, http.StatusOK, CertificateResponse{Certificates: certificates}, nil)
+}
+
+// HandleGetCertificateBundle отдает сертификаты вместе с ключами, доступен только прокси.
+func (h *CertificateHandler) HandleGetCertificateBundle(w http.ResponseWriter, r *http.Request) {
+	certificates, err := h.certificateUseCase.Get()
+	if err != nil {
+		JSONResponse[any](w, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, CertificateResponse{Certificates: certificates}, nil)
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const InternalTokenHeader = "X-Internal-Token"
+
+// InternalMiddleware пропускает только запросы других сервисов файрвола, знающих общий токен.
+// если токен не задан, внутренние маршруты закрыты.
+func InternalMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get(InternalTokenHeader)
+			if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				sendJSONResponse(w, http.StatusForbidden, map[string]string{"error": "Forbidden"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package delivery
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"rules-engine/internal/delivery/middleware"
 	"rules-engine/internal/entity"
@@ -17,12 +18,47 @@ func NewResourceHandler(resourceUseCase *usecase.ResourceUseCase) *ResourceHandl
 }
 
 type ResourceRequest struct {
-	Name       string `json:"name"`
-	HTTPMethod string `json:"http_method"`
-	URL        string `json:"url"`
-	Host       string `json:"host"`
-	CreatorID  string `json:"creator_id"`
-	IsActive   *bool  `json:"is_active"`
+	Name             string                 `json:"name"`
+	HTTPMethod       string                 `json:"http_method"`
+	URL              string                 `json:"url"`
+	Host             string                 `json:"host"`
+	Hostname         *string                `json:"hostname"`
+	UpstreamID       *string                `json:"upstream_id"`
+	Policy           *entity.UpstreamPolicy `json:"policy"`
+	MaxBodyBytes     *int64                 `json:"max_body_bytes"`
+	AllowUpgrade     *bool                  `json:"allow_upgrade"`
+	InspectMessages  *bool                  `json:"inspect_messages"`
+	HTTPSRedirect    *bool                  `json:"https_redirect"`
+	LogOnly          *bool                  `json:"log_only"`
+	EvaluationMode   string                 `json:"evaluation_mode"`
+	AnomalyThreshold *int                   `json:"anomaly_threshold"`
+	ParanoiaLevel    *int                   `json:"paranoia_level"`
+	CSRF             *entity.CSRFPolicy     `json:"csrf"`
+	CreatorID        string                 `json:"creator_id"`
+	IsActive         *bool                  `json:"is_active"`
+}
+
+func (req ResourceRequest) params() usecase.ResourceParams {
+	return usecase.ResourceParams{
+		Name:             req.Name,
+		HTTPMethod:       req.HTTPMethod,
+		URL:              req.URL,
+		Host:             req.Host,
+		Hostname:         req.Hostname,
+		UpstreamID:       req.UpstreamID,
+		Policy:           req.Policy,
+		MaxBodyBytes:     req.MaxBodyBytes,
+		AllowUpgrade:     req.AllowUpgrade,
+		InspectMessages:  req.InspectMessages,
+		HTTPSRedirect:    req.HTTPSRedirect,
+		LogOnly:          req.LogOnly,
+		EvaluationMode:   req.EvaluationMode,
+		AnomalyThreshold: req.AnomalyThreshold,
+		ParanoiaLevel:    req.ParanoiaLevel,
+		CSRF:             req.CSRF,
+		CreatorID:        req.CreatorID,
+		IsActive:         req.IsActive,
+	}
 }
 
 type UpdateIPListReferenceRequest struct {
@@ -30,7 +66,46 @@ type UpdateIPListReferenceRequest struct {
 }
 
 type UpdateRuleReferenceRequest struct {
-	RuleID string `json:"rule_id"`
+	RuleID   string `json:"rule_id"`
+	Priority *int   `json:"priority"`
+}
+
+type ExclusionRequest struct {
+	RuleID    string    `json:"rule_id"`
+	Target    string    `json:"target"`
+	Name      string    `json:"name"`
+	IPListIDs *[]string `json:"ip_list_ids"`
+	CreatorID string    `json:"creator_id"`
+}
+
+func (req ExclusionRequest) params() usecase.ExclusionParams {
+	return usecase.ExclusionParams{
+		RuleID:    req.RuleID,
+		Target:    req.Target,
+		Name:      req.Name,
+		IPListIDs: req.IPListIDs,
+		CreatorID: req.CreatorID,
+	}
+}
+
+type OpenAPIImportRequest struct {
+	Document   string  `json:"document"`
+	Host       string  `json:"host"`
+	Hostname   *string `json:"hostname"`
+	UpstreamID *string `json:"upstream_id"`
+	LogOnly    *bool   `json:"log_only"`
+	CreatorID  string  `json:"creator_id"`
+}
+
+func (req OpenAPIImportRequest) params() usecase.OpenAPIImportParams {
+	return usecase.OpenAPIImportParams{
+		Document:   req.Document,
+		Host:       req.Host,
+		Hostname:   req.Hostname,
+		UpstreamID: req.UpstreamID,
+		LogOnly:    req.LogOnly,
+		CreatorID:  req.CreatorID,
+	}
 }
 
 type ResourcesResponse struct {
@@ -48,12 +123,12 @@ func (h *ResourceHandler) HandleCreateResource(w http.ResponseWriter, r *http.Re
 		req.CreatorID = user.ID
 	}
 
-	if req.Name == "" || req.HTTPMethod == "" || req.URL == "" || req.Host == "" || req.CreatorID == "" {
+	if req.Name == "" || req.HTTPMethod == "" || req.URL == "" || (req.Host == "" && req.UpstreamID == nil) || req.CreatorID == "" {
 		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
 		return
 	}
 
-	resource, err := h.resourceUseCase.Create(req.Name, req.HTTPMethod, req.URL, req.Host, req.CreatorID, req.IsActive)
+	resource, err := h.resourceUseCase.Create(req.params())
 	if err != nil {
 		JSONResponse[any](w, http.StatusBadRequest, nil, err)
 		return
@@ -75,12 +150,15 @@ func (h *ResourceHandler) HandleUpdateResource(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if req.Name == "" && req.HTTPMethod == "" && req.URL == "" && req.Host == "" && req.IsActive == nil {
+	if req.Name == "" && req.HTTPMethod == "" && req.URL == "" && req.Host == "" && req.Hostname == nil && req.UpstreamID == nil &&
+		req.Policy == nil && req.MaxBodyBytes == nil && req.AllowUpgrade == nil && req.InspectMessages == nil &&
+		req.HTTPSRedirect == nil && req.LogOnly == nil && req.EvaluationMode == "" && req.AnomalyThreshold == nil &&
+		req.ParanoiaLevel == nil && req.CSRF == nil && req.IsActive == nil {
 		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
 		return
 	}
 
-	resource, err := h.resourceUseCase.Update(id, req.Name, req.HTTPMethod, req.URL, req.Host, req.IsActive)
+	resource, err := h.resourceUseCase.Update(id, req.params())
 	if err != nil {
 		JSONResponse[any](w, http.StatusBadRequest, nil, err)
 		return
@@ -182,7 +260,7 @@ func (h *ResourceHandler) HandleAttachRule(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err := h.resourceUseCase.AttachRule(id, req.RuleID)
+	err := h.resourceUseCase.AttachRule(id, req.RuleID, req.Priority)
 	if err != nil {
 		JSONResponse[any](w, http.StatusInternalServerError, nil, err)
 		return
@@ -217,3 +295,134 @@ func (h *ResourceHandler) HandleDetachRule(w http.ResponseWriter, r *http.Reques
 
 	JSONResponse[any](w, http.StatusOK, nil, nil)
 }
+
+func (h *ResourceHandler) HandleCreateExclusion(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingID())
+		return
+	}
+
+	var req ExclusionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		req.CreatorID = user.ID
+	}
+
+	if req.RuleID == "" || req.Target == "" || req.Name == "" || req.CreatorID == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
+		return
+	}
+
+	exclusion, err := h.resourceUseCase.CreateExclusion(id, req.params())
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, exclusion, nil)
+}
+
+func (h *ResourceHandler) HandleUpdateExclusion(w http.ResponseWriter, r *http.Request) {
+	id, exclusionID := r.PathValue("id"), r.PathValue("exclusion_id")
+	if id == "" || exclusionID == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingID())
+		return
+	}
+
+	var req ExclusionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	if req.Target == "" && req.Name == "" && req.IPListIDs == nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
+		return
+	}
+
+	exclusion, err := h.resourceUseCase.UpdateExclusion(id, exclusionID, req.params())
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, exclusion, nil)
+}
+
+func (h *ResourceHandler) HandleDeleteExclusion(w http.ResponseWriter, r *http.Request) {
+	id, exclusionID := r.PathValue("id"), r.PathValue("exclusion_id")
+	if id == "" || exclusionID == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingID())
+		return
+	}
+
+	if err := h.resourceUseCase.DeleteExclusion(id, exclusionID); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	JSONResponse[any](w, http.StatusOK, nil, nil)
+}
+
+// HandleUploadOpenAPI принимает документ OpenAPI 3 в json или yaml в теле запроса.
+func (h *ResourceHandler) HandleUploadOpenAPI(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingID())
+		return
+	}
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	var creatorID string
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		creatorID = user.ID
+	}
+
+	if len(content) == 0 || creatorID == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
+		return
+	}
+
+	rule, err := h.resourceUseCase.UploadOpenAPI(id, string(content), creatorID)
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, rule, nil)
+}
+
+func (h *ResourceHandler) HandleImportOpenAPI(w http.ResponseWriter, r *http.Request) {
+	var req OpenAPIImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		req.CreatorID = user.ID
+	}
+
+	if req.Document == "" || (req.Host == "" && req.UpstreamID == nil) || req.CreatorID == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
+		return
+	}
+
+	result, err := h.resourceUseCase.ImportOpenAPI(req.params())
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, result, nil)
+}
@@ -2,6 +2,7 @@ package delivery
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"rules-engine/internal/delivery/middleware"
 	"rules-engine/internal/entity"
@@ -17,11 +18,39 @@ func NewRuleHandler(ruleUseCase *usecase.RuleUseCase) *RuleHandler {
 }
 
 type RuleRequest struct {
-	Name       string `json:"name"`
-	AttackType string `json:"attack_type"`
-	ActionType string `json:"action_type"`
-	CreatorID  string `json:"creator_id"`
-	IsActive   *bool  `json:"is_active"`
+	Name            string                `json:"name"`
+	AttackType      string                `json:"attack_type"`
+	ActionType      string                `json:"action_type"`
+	Conditions      []entity.Condition    `json:"conditions"`
+	Transformations []string              `json:"transformations"`
+	Expression      *string               `json:"expression"`
+	SecRule         *string               `json:"sec_rule"`
+	OpenAPI         *string               `json:"openapi"`
+	GraphQL         *entity.GraphQLPolicy `json:"graphql"`
+	Severity        string                `json:"severity"`
+	ParanoiaLevel   *int                  `json:"paranoia_level"`
+	LogOnly         *bool                 `json:"log_only"`
+	CreatorID       string                `json:"creator_id"`
+	IsActive        *bool                 `json:"is_active"`
+}
+
+func (req RuleRequest) params() usecase.RuleParams {
+	return usecase.RuleParams{
+		Name:            req.Name,
+		AttackType:      req.AttackType,
+		ActionType:      req.ActionType,
+		Conditions:      req.Conditions,
+		Transformations: req.Transformations,
+		Expression:      req.Expression,
+		SecRule:         req.SecRule,
+		OpenAPI:         req.OpenAPI,
+		GraphQL:         req.GraphQL,
+		Severity:        req.Severity,
+		ParanoiaLevel:   req.ParanoiaLevel,
+		LogOnly:         req.LogOnly,
+		CreatorID:       req.CreatorID,
+		IsActive:        req.IsActive,
+	}
 }
 
 type RuleResponse struct {
@@ -44,7 +73,7 @@ func (h *RuleHandler) HandleCreateRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rule, err := h.ruleUseCase.Create(req.Name, req.AttackType, req.ActionType, req.CreatorID, req.IsActive)
+	rule, err := h.ruleUseCase.Create(req.params())
 	if err != nil {
 		JSONResponse[any](w, http.StatusBadRequest, nil, err)
 		return
@@ -66,12 +95,14 @@ func (h *RuleHandler) HandleUpdateRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" && req.AttackType == "" && req.ActionType == "" && req.IsActive == nil {
+	if req.Name == "" && req.AttackType == "" && req.ActionType == "" && req.Conditions == nil && req.Transformations == nil &&
+		req.Expression == nil && req.SecRule == nil && req.OpenAPI == nil && req.GraphQL == nil &&
+		req.Severity == "" && req.ParanoiaLevel == nil && req.LogOnly == nil && req.IsActive == nil {
 		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
 		return
 	}
 
-	rule, err := h.ruleUseCase.Update(id, req.Name, req.AttackType, req.ActionType, req.IsActive)
+	rule, err := h.ruleUseCase.Update(id, req.params())
 	if err != nil {
 		JSONResponse[any](w, http.StatusBadRequest, nil, err)
 		return
@@ -89,3 +120,30 @@ func (h *RuleHandler) HandleGetRules(w http.ResponseWriter, r *http.Request) {
 
 	JSONResponse(w, http.StatusOK, RuleResponse{Rules: rules}, nil)
 }
+
+// HandleImportRules принимает файл правил ModSecurity (SecRule) в теле запроса.
+func (h *RuleHandler) HandleImportRules(w http.ResponseWriter, r *http.Request) {
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	var creatorID string
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		creatorID = user.ID
+	}
+
+	if len(content) == 0 || creatorID == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
+		return
+	}
+
+	result, err := h.ruleUseCase.Import(string(content), creatorID)
+	if err != nil {
+		JSONResponse[any](w, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, result, nil)
+}
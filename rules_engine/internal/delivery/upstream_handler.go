@@ -0,0 +1,104 @@
+package delivery
+
+import (
+	"encoding/json"
+	"net/http"
+	"rules-engine/internal/delivery/middleware"
+	"rules-engine/internal/entity"
+	"rules-engine/internal/usecase"
+)
+
+type UpstreamHandler struct {
+	upstreamUseCase *usecase.UpstreamUseCase
+}
+
+func NewUpstreamHandler(upstreamUseCase *usecase.UpstreamUseCase) *UpstreamHandler {
+	return &UpstreamHandler{upstreamUseCase: upstreamUseCase}
+}
+
+type UpstreamRequest struct {
+	Name                string   `json:"name"`
+	Balancer            string   `json:"balancer"`
+	HashKey             *string  `json:"hash_key"`
+	Targets             []string `json:"targets"`
+	HealthCheckPath     *string  `json:"health_check_path"`
+	HealthCheckInterval int      `json:"health_check_interval"`
+	MaxFails            *int     `json:"max_fails"`
+	FailTimeout         *int     `json:"fail_timeout"`
+	CreatorID           string   `json:"creator_id"`
+}
+
+type UpstreamResponse struct {
+	Upstreams []entity.Upstream `json:"upstreams"`
+}
+
+func (req UpstreamRequest) params() usecase.UpstreamParams {
+	return usecase.UpstreamParams{
+		Name:                req.Name,
+		Balancer:            req.Balancer,
+		HashKey:             req.HashKey,
+		Targets:             req.Targets,
+		HealthCheckPath:     req.HealthCheckPath,
+		HealthCheckInterval: req.HealthCheckInterval,
+		MaxFails:            req.MaxFails,
+		FailTimeout:         req.FailTimeout,
+		CreatorID:           req.CreatorID,
+	}
+}
+
+func (h *UpstreamHandler) HandleCreateUpstream(w http.ResponseWriter, r *http.Request) {
+	var req UpstreamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	if user, ok := middleware.GetUserFromContext(r.Context()); ok {
+		req.CreatorID = user.ID
+	}
+
+	if req.Name == "" || req.CreatorID == "" || len(req.Targets) == 0 {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingFields())
+		return
+	}
+
+	upstream, err := h.upstreamUseCase.Create(req.params())
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, upstream, nil)
+}
+
+func (h *UpstreamHandler) HandleUpdateUpstream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		JSONResponse[any](w, http.StatusBadRequest, nil, errMissingID())
+		return
+	}
+
+	var req UpstreamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	upstream, err := h.upstreamUseCase.Update(id, req.params())
+	if err != nil {
+		JSONResponse[any](w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, upstream, nil)
+}
+
+func (h *UpstreamHandler) HandleGetUpstreams(w http.ResponseWriter, r *http.Request) {
+	upstreams, err := h.upstreamUseCase.Get()
+	if err != nil {
+		JSONResponse[any](w, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, UpstreamResponse{Upstreams: upstreams}, nil)
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+type Certificate struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hostnames []string  `json:"hostnames"`
+	CertPEM   string    `json:"cert_pem"`
+	KeyPEM    string    `json:"key_pem,omitempty"`
+	NotAfter  time.Time `json:"not_after"`
+	CreatorID string    `json:"creator_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
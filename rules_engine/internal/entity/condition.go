@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Condition - условие custom-правила: operator применяется к части запроса target.
+// правило срабатывает, когда выполнены все его условия.
+type Condition struct {
+	Target   string `json:"target"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	Negate   bool   `json:"negate,omitempty"`
+}
+
+// Conditions хранится в jsonb-колонке rules.conditions.
+type Conditions []Condition
+
+func (c Conditions) Value() (driver.Value, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(c)
+}
+
+func (c *Conditions) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("unsupported conditions type %T", src)
+	}
+}
+
+// Transformations - цепочка преобразований, которые применяются к каждой проверяемой части запроса
+// перед сопоставлением. хранится в jsonb-колонке rules.transformations.
+type Transformations []string
+
+func (t Transformations) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(t)
+}
+
+func (t *Transformations) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("unsupported transformations type %T", src)
+	}
+}
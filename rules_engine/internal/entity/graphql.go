@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// GraphQLPolicy задает ограничения graphql-правила. нулевой лимит не ограничивает, а Detectors - типы
+// атак, детекторы которых проверяют строковые значения аргументов. хранится в jsonb-колонке rules.graphql.
+type GraphQLPolicy struct {
+	MaxDepth           int      `json:"max_depth"`
+	MaxAliases         int      `json:"max_aliases"`
+	MaxFields          int      `json:"max_fields"`
+	MaxCost            int      `json:"max_cost"`
+	BlockIntrospection bool     `json:"block_introspection"`
+	Detectors          []string `json:"detectors"`
+}
+
+func (p GraphQLPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *GraphQLPolicy) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("unsupported graphql policy type %T", src)
+	}
+}
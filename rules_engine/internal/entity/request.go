@@ -1,9 +1,10 @@
 package entity
 
 type Request struct {
-	IP        string            `json:"ip"`
-	Method    string            `json:"method"`
-	URL       string            `json:"url"`
-	Headers   map[string]string `json:"headers"`
-	Body      string            `json:"body"`
+	IP      string            `json:"ip"`
+	Host    string            `json:"host"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
 }
@@ -1,16 +1,84 @@
 package entity
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type Resource struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	HTTPMethod string    `json:"http_method"`
-	URL        string    `json:"url"`
-	Host       string    `json:"host"`
-	CreatorID  string    `json:"creator_id"`
-	IsActive   *bool     `json:"is_active"`
-	CreatedAt  time.Time `json:"created_at"`
-	IPLists    []IPList  `json:"ip_lists,omitempty"`
-	Rules      []Rule    `json:"rules,omitempty"`
+	ID               string          `json:"id"`
+	Name             string          `json:"name"`
+	HTTPMethod       string          `json:"http_method"`
+	URL              string          `json:"url"`
+	Host             string          `json:"host"`
+	Hostname         string          `json:"hostname"`
+	UpstreamID       *string         `json:"upstream_id"`
+	Policy           UpstreamPolicy  `json:"policy"`
+	MaxBodyBytes     int64           `json:"max_body_bytes"`
+	AllowUpgrade     bool            `json:"allow_upgrade"`
+	InspectMessages  bool            `json:"inspect_messages"`
+	HTTPSRedirect    bool            `json:"https_redirect"`
+	LogOnly          bool            `json:"log_only"`
+	EvaluationMode   string          `json:"evaluation_mode"`
+	AnomalyThreshold int             `json:"anomaly_threshold"`
+	ParanoiaLevel    int             `json:"paranoia_level"`
+	CSRF             CSRFPolicy      `json:"csrf"`
+	CreatorID        string          `json:"creator_id"`
+	IsActive         *bool           `json:"is_active"`
+	CreatedAt        time.Time       `json:"created_at"`
+	IPLists          []IPList        `json:"ip_lists,omitempty"`
+	Rules            []Rule          `json:"rules,omitempty"`
+	Exclusions       []RuleExclusion `json:"exclusions,omitempty"`
+	Upstream         *Upstream       `json:"upstream,omitempty"`
+}
+
+// режимы вычисления правил ресурса: первое сработавшее правило решает исход
+// или каждое правило добавляет баллы по severity, а блокирует превышение порога
+const (
+	EvaluationFirstMatch = "first_match"
+	EvaluationAnomaly    = "anomaly"
+)
+
+// UpstreamPolicy задает таймауты, ретраи и circuit breaker для запросов прокси к апстриму ресурса.
+// нулевые таймауты означают отсутствие ограничения, нулевой breaker_error_rate отключает breaker.
+type UpstreamPolicy struct {
+	ConnectTimeoutMs   int     `json:"connect_timeout_ms"`
+	ReadTimeoutMs      int     `json:"read_timeout_ms"`
+	Retries            int     `json:"retries"`
+	RetryBackoffMs     int     `json:"retry_backoff_ms"`
+	BreakerErrorRate   float64 `json:"breaker_error_rate"`
+	BreakerMinRequests int     `json:"breaker_min_requests"`
+	BreakerOpenMs      int     `json:"breaker_open_ms"`
+}
+
+// CSRFPolicy задает защиту ресурса от csrf. действует, когда к ресурсу привязано правило csrf:
+// изменяющие запросы проверяются, а ответ на безопасный запрос получает токен в cookie и заголовке.
+// хранится в jsonb-колонке resources.csrf.
+type CSRFPolicy struct {
+	CheckOrigin    bool     `json:"check_origin"`
+	AllowedOrigins []string `json:"allowed_origins"`
+	DoubleSubmit   bool     `json:"double_submit"`
+	// SignedToken требует DoubleSubmit: подпись не привязана к сессии жертвы
+	SignedToken     bool   `json:"signed_token"`
+	CookieName      string `json:"cookie_name"`
+	HeaderName      string `json:"header_name"`
+	FieldName       string `json:"field_name"`
+	TokenTTLSeconds int    `json:"token_ttl_seconds"`
+}
+
+func (p CSRFPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *CSRFPolicy) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("unsupported csrf policy type %T", src)
+	}
 }
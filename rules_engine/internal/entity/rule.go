@@ -11,12 +11,30 @@ const (
 	ActionAllow    Action = "allow"
 )
 
+// severity правила, вес которой добавляется к anomaly score ресурса
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityNotice   = "notice"
+)
+
 type Rule struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	AttackType string    `json:"attack_type"`
-	ActionType Action    `json:"action_type"`
-	IsActive   *bool     `json:"is_active"`
-	CreatorID  string    `json:"creator_id"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	AttackType      string          `json:"attack_type"`
+	ActionType      Action          `json:"action_type"`
+	Conditions      Conditions      `json:"conditions,omitempty"`
+	Transformations Transformations `json:"transformations,omitempty"`
+	Expression      string          `json:"expression,omitempty"`
+	SecRule         string          `json:"sec_rule,omitempty"`
+	OpenAPI         string          `json:"openapi,omitempty"`
+	GraphQL         *GraphQLPolicy  `json:"graphql,omitempty"`
+	Severity        string          `json:"severity"`
+	ParanoiaLevel   int             `json:"paranoia_level"`
+	LogOnly         bool            `json:"log_only"`
+	Priority        *int            `json:"priority,omitempty"`
+	IsActive        *bool           `json:"is_active"`
+	CreatorID       string          `json:"creator_id"`
+	CreatedAt       time.Time       `json:"created_at"`
 }
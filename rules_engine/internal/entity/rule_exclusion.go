@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// RuleExclusion исключает поле запроса из проверки правила ресурса: правило не видит аргумент, заголовок,
+// cookie или поддерево json-тела с именем Name. непустые IPListIDs ограничивают исключение запросами
+// с адресов из этих списков.
+type RuleExclusion struct {
+	ID         string    `json:"id"`
+	ResourceID string    `json:"resource_id"`
+	RuleID     string    `json:"rule_id"`
+	Target     string    `json:"target"`
+	Name       string    `json:"name"`
+	IPListIDs  []string  `json:"ip_list_ids"`
+	CreatorID  string    `json:"creator_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// поля запроса, которые можно исключить. json - путь в json-теле вида user.bio или items.0.text
+const (
+	ExclusionTargetArg    = "arg"
+	ExclusionTargetHeader = "header"
+	ExclusionTargetCookie = "cookie"
+	ExclusionTargetJSON   = "json"
+)
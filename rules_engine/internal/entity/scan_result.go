@@ -4,5 +4,32 @@ type ScanResult struct {
 	Action       Action `json:"action"`
 	ModifiedURL  string `json:"modified_url,omitempty"`
 	ModifiedBody string `json:"modified_body,omitempty"`
-	Reason       string `json:"reason"`
+	// BodyModified - тело изменено правилом. ModifiedBody содержит тело и без изменений
+	BodyModified bool        `json:"body_modified,omitempty"`
+	Reason       string      `json:"reason"`
+	Variable     string      `json:"variable,omitempty"`
+	Matches      []RuleMatch `json:"matches,omitempty"`
+	AnomalyScore int         `json:"anomaly_score,omitempty"`
+	ScoringRules []string    `json:"scoring_rule_ids,omitempty"`
+	CSRFToken    *CSRFToken  `json:"csrf_token,omitempty"`
+}
+
+// CSRFToken - токен, который прокси выставляет в cookie и заголовок ответа на безопасный запрос.
+type CSRFToken struct {
+	Value      string `json:"value"`
+	CookieName string `json:"cookie_name"`
+	HeaderName string `json:"header_name"`
+	MaxAge     int    `json:"max_age"`
+}
+
+// RuleMatch - сработавшее правило или ip-лист. для log_only действие не применялось, а только записано.
+type RuleMatch struct {
+	RuleID     string `json:"rule_id"`
+	RuleName   string `json:"rule_name"`
+	AttackType string `json:"attack_type"`
+	Action     Action `json:"action"`
+	Reason     string `json:"reason"`
+	Variable   string `json:"variable,omitempty"`
+	LogOnly    bool   `json:"log_only"`
+	Score      int    `json:"score,omitempty"`
 }
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+const (
+	BalancerRoundRobin     = "round_robin"
+	BalancerLeastConn      = "least_conn"
+	BalancerConsistentHash = "consistent_hash"
+)
+
+type Upstream struct {
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	Balancer            string    `json:"balancer"`
+	HashKey             string    `json:"hash_key"`
+	Targets             []string  `json:"targets"`
+	HealthCheckPath     string    `json:"health_check_path"`
+	HealthCheckInterval int       `json:"health_check_interval"`
+	MaxFails            int       `json:"max_fails"`
+	FailTimeout         int       `json:"fail_timeout"`
+	CreatorID           string    `json:"creator_id"`
+	CreatedAt           time.Time `json:"created_at"`
+}
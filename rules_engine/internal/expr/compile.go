@@ -0,0 +1,303 @@
+package expr
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+type valueType string
+
+const (
+	typeBool    valueType = "bool"
+	typeInt     valueType = "int"
+	typeString  valueType = "string"
+	typeMap     valueType = "map(string, string)"
+	typeCIDR    valueType = "cidr"
+	typeRequest valueType = "request"
+)
+
+type evalFn func(*activation) (any, error)
+
+// поля объекта request и переменные верхнего уровня
+var requestFields = map[string]valueType{
+	"method":  typeString,
+	"host":    typeString,
+	"ip":      typeString,
+	"url":     typeString,
+	"path":    typeString,
+	"query":   typeString,
+	"body":    typeString,
+	"headers": typeMap,
+	"args":    typeMap,
+	"cookies": typeMap,
+}
+
+func compile(n node) (valueType, evalFn, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		value := n.value
+		eval := func(*activation) (any, error) { return value, nil }
+		switch value.(type) {
+		case bool:
+			return typeBool, eval, nil
+		case int64:
+			return typeInt, eval, nil
+		default:
+			return typeString, eval, nil
+		}
+	case *identNode:
+		return compileIdent(n)
+	case *memberNode:
+		return compileMember(n)
+	case *indexNode:
+		return compileIndex(n)
+	case *callNode:
+		return compileCall(n)
+	case *unaryNode:
+		return compileUnary(n)
+	case *binaryNode:
+		return compileBinary(n)
+	}
+	return "", nil, &Error{Pos: n.position(), Msg: "unsupported expression"}
+}
+
+func compileIdent(n *identNode) (valueType, evalFn, error) {
+	switch n.name {
+	case "request":
+		return typeRequest, func(*activation) (any, error) { return nil, nil }, nil
+	case "ip":
+		return typeString, func(a *activation) (any, error) { return a.request.IP, nil }, nil
+	}
+	return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("undeclared reference %q (available: request, ip)", n.name)}
+}
+
+func compileMember(n *memberNode) (valueType, evalFn, error) {
+	t, _, err := compile(n.x)
+	if err != nil {
+		return "", nil, err
+	}
+	if t != typeRequest {
+		return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("type %s has no field %q", t, n.name)}
+	}
+
+	fieldType, ok := requestFields[n.name]
+	if !ok {
+		return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("request has no field %q", n.name)}
+	}
+	name := n.name
+	return fieldType, func(a *activation) (any, error) { return a.fields[name], nil }, nil
+}
+
+func compileIndex(n *indexNode) (valueType, evalFn, error) {
+	xt, x, err := compile(n.x)
+	if err != nil {
+		return "", nil, err
+	}
+	kt, key, err := compile(n.key)
+	if err != nil {
+		return "", nil, err
+	}
+	if xt != typeMap {
+		return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("type %s does not support indexing", xt)}
+	}
+	if kt != typeString {
+		return "", nil, &Error{Pos: n.key.position(), Msg: fmt.Sprintf("map key must be string, got %s", kt)}
+	}
+
+	return typeString, func(a *activation) (any, error) {
+		m, k, err := evalIndex(a, x, key)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := m.lookup(k)
+		if !ok {
+			return nil, fmt.Errorf("no such key: %s", k)
+		}
+		return value, nil
+	}, nil
+}
+
+func evalIndex(a *activation, x, key evalFn) (mapValue, string, error) {
+	m, err := x(a)
+	if err != nil {
+		return mapValue{}, "", err
+	}
+	k, err := key(a)
+	if err != nil {
+		return mapValue{}, "", err
+	}
+	return m.(mapValue), k.(string), nil
+}
+
+func compileUnary(n *unaryNode) (valueType, evalFn, error) {
+	t, x, err := compile(n.x)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if n.op == "!" {
+		if t != typeBool {
+			return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("operator ! expects bool, got %s", t)}
+		}
+		return typeBool, func(a *activation) (any, error) {
+			v, err := x(a)
+			if err != nil {
+				return nil, err
+			}
+			return !v.(bool), nil
+		}, nil
+	}
+
+	if t != typeInt {
+		return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("operator - expects int, got %s", t)}
+	}
+	return typeInt, func(a *activation) (any, error) {
+		v, err := x(a)
+		if err != nil {
+			return nil, err
+		}
+		return -v.(int64), nil
+	}, nil
+}
+
+func compileBinary(n *binaryNode) (valueType, evalFn, error) {
+	lt, l, err := compile(n.l)
+	if err != nil {
+		return "", nil, err
+	}
+	rt, r, err := compile(n.r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mismatch := &Error{Pos: n.pos, Msg: fmt.Sprintf("no matching overload for %s %s %s", lt, n.op, rt)}
+
+	switch n.op {
+	case "&&", "||":
+		if lt != typeBool || rt != typeBool {
+			return "", nil, mismatch
+		}
+		return typeBool, logical(n.op == "&&", l, r), nil
+	case "==", "!=":
+		if lt != rt || (lt != typeBool && lt != typeInt && lt != typeString) {
+			return "", nil, mismatch
+		}
+		negate := n.op == "!="
+		return typeBool, binary(l, r, func(x, y any) (any, error) { return (x == y) != negate, nil }), nil
+	case "<", "<=", ">", ">=":
+		if lt != rt || (lt != typeInt && lt != typeString) {
+			return "", nil, mismatch
+		}
+		op := n.op
+		return typeBool, binary(l, r, func(x, y any) (any, error) { return compare(op, x, y), nil }), nil
+	case "+":
+		if lt == typeString && rt == typeString {
+			return typeString, binary(l, r, func(x, y any) (any, error) { return x.(string) + y.(string), nil }), nil
+		}
+		fallthrough
+	case "-", "*", "/", "%":
+		if lt != typeInt || rt != typeInt {
+			return "", nil, mismatch
+		}
+		op := n.op
+		return typeInt, binary(l, r, func(x, y any) (any, error) { return arithmetic(op, x.(int64), y.(int64)) }), nil
+	case "in":
+		return compileIn(lt, rt, l, r, mismatch)
+	}
+	return "", nil, mismatch
+}
+
+// compileIn: ключ в map или ip в подсети. невалидный ip в подсеть не входит.
+func compileIn(lt, rt valueType, l, r evalFn, mismatch error) (valueType, evalFn, error) {
+	if lt != typeString {
+		return "", nil, mismatch
+	}
+
+	switch rt {
+	case typeMap:
+		return typeBool, binary(l, r, func(x, y any) (any, error) {
+			_, ok := y.(mapValue).lookup(x.(string))
+			return ok, nil
+		}), nil
+	case typeCIDR:
+		return typeBool, binary(l, r, func(x, y any) (any, error) {
+			ip := parseIP(x.(string))
+			return ip != nil && y.(*net.IPNet).Contains(ip), nil
+		}), nil
+	}
+	return "", nil, mismatch
+}
+
+func logical(and bool, l, r evalFn) evalFn {
+	return func(a *activation) (any, error) {
+		x, err := l(a)
+		if err != nil {
+			return nil, err
+		}
+		if x.(bool) != and {
+			return x, nil
+		}
+		return r(a)
+	}
+}
+
+func binary(l, r evalFn, op func(x, y any) (any, error)) evalFn {
+	return func(a *activation) (any, error) {
+		x, err := l(a)
+		if err != nil {
+			return nil, err
+		}
+		y, err := r(a)
+		if err != nil {
+			return nil, err
+		}
+		return op(x, y)
+	}
+}
+
+func compare(op string, x, y any) bool {
+	var c int
+	switch x := x.(type) {
+	case int64:
+		y := y.(int64)
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	case string:
+		c = strings.Compare(x, y.(string))
+	}
+
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func arithmetic(op string, x, y int64) (any, error) {
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	}
+
+	if y == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if op == "/" {
+		return x / y, nil
+	}
+	return x % y, nil
+}
@@ -0,0 +1,113 @@
+// Package expr реализует небольшой язык условий в стиле CEL для правил rules engine.
+//
+//	request.method == "POST" && !has(request.headers["X-Api-Key"]) && ip in cidr("10.0.0.0/8")
+//
+// выражение типизируется при компиляции и должно возвращать bool.
+package expr
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/inspect"
+)
+
+// Error - ошибка компиляции с позицией в исходном выражении.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos+1, e.Msg)
+}
+
+// Program - скомпилированное выражение, безопасное для конкурентного использования.
+type Program struct {
+	source string
+	eval   evalFn
+}
+
+func Compile(source string) (*Program, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, &Error{Msg: "expression is empty"}
+	}
+
+	tree, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	t, eval, err := compile(tree)
+	if err != nil {
+		return nil, err
+	}
+	if t != typeBool {
+		return nil, &Error{Pos: tree.position(), Msg: fmt.Sprintf("expression must evaluate to bool, got %s", t)}
+	}
+	return &Program{source: source, eval: eval}, nil
+}
+
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Eval вычисляет выражение для запроса. ошибки времени выполнения (нет ключа, невалидный base64) возвращаются как error.
+func (p *Program) Eval(request *inspect.Request) (bool, error) {
+	value, err := p.eval(newActivation(request))
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+type activation struct {
+	request *entity.Request
+	fields  map[string]any
+}
+
+func newActivation(request *inspect.Request) *activation {
+	raw := request.Raw
+	return &activation{
+		request: raw,
+		fields: map[string]any{
+			"method":  raw.Method,
+			"host":    raw.Host,
+			"ip":      raw.IP,
+			"url":     raw.URL,
+			"path":    request.Path,
+			"query":   request.Query,
+			"body":    raw.Body,
+			"headers": mapValue{values: request.Headers, canonical: true},
+			"args":    fieldsMap(request.Args()),
+			"cookies": fieldsMap(request.Cookies),
+		},
+	}
+}
+
+// fieldsMap оставляет первое значение повторяющегося ключа, как url.Values.Get.
+func fieldsMap(fields []inspect.Field) mapValue {
+	values := make(map[string]string, len(fields))
+	for _, f := range fields {
+		if _, ok := values[f.Name]; !ok {
+			values[f.Name] = f.Value
+		}
+	}
+	return mapValue{values: values}
+}
+
+// mapValue - map<string, string>. ключи заголовков сравниваются без учета регистра.
+type mapValue struct {
+	values    map[string]string
+	canonical bool
+}
+
+func (m mapValue) lookup(key string) (string, bool) {
+	if m.canonical {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+	}
+	value, ok := m.values[key]
+	return value, ok
+}
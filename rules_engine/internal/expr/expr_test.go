@@ -0,0 +1,212 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/inspect"
+)
+
+func testRequest() *inspect.Request {
+	return inspect.Parse(&entity.Request{
+		IP:     "10.1.2.3",
+		Host:   "api.example.com",
+		Method: "POST",
+		URL:    "/orders?id=42&token=YWRtaW4%3D&name=Bob",
+		Headers: map[string]string{
+			"Content-Type": "application/x-www-form-urlencoded",
+			"X-Api-Key":    "secret",
+			"Cookie":       "session=abc; theme=dark",
+		},
+		Body: "qty=3",
+	})
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		msg    string
+	}{
+		{"", "expression is empty"},
+		{`request.method`, "must evaluate to bool, got string"},
+		{`request.method == 1`, "no matching overload for string == int"},
+		{`request.method && true`, "no matching overload for string && bool"},
+		{`1 + "a" == 2`, "no matching overload for int + string"},
+		{`!request.path`, "operator ! expects bool, got string"},
+		{`-request.path == 1`, "operator - expects int, got string"},
+		{`request.headers == request.args`, "no matching overload"},
+		{`request.path["a"] == "b"`, "type string does not support indexing"},
+		{`request.headers[1] == "b"`, "map key must be string, got int"},
+		{`request.user == "b"`, `request has no field "user"`},
+		{`user == "b"`, `undeclared reference "user"`},
+		{`request.method.size == 1`, "has no field"},
+		{`nope(request.path)`, `undeclared function "nope"`},
+		{`contains(request.path)`, "contains() expects 2 argument(s), got 1"},
+		{`lower(1) == "a"`, "lower() argument 1 must be string, got int"},
+		{`size(1) == 1`, "size() expects one string or map argument"},
+		{`request.path.matches(request.query)`, "pattern must be a string literal"},
+		{`request.path.matches("(")`, "invalid regex"},
+		{`ip in cidr("10.0.0.0/33")`, `invalid cidr "10.0.0.0/33"`},
+		{`ip in cidr(1)`, "cidr() argument 1 must be string, got int"},
+		{`1 in request.headers`, "no matching overload for int in map(string, string)"},
+		{`ip in request.path`, "no matching overload for string in string"},
+		{`has(request.headers)`, "has() expects a string field or map index"},
+		{`has(request.path["a"])`, "has() expects a map index"},
+		{`has("a")`, "has() expects a field selection or map index"},
+		{`has(request.headers["a"], request.args["b"])`, "has() expects exactly one argument"},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.source)
+		var compileErr *Error
+		if !errors.As(err, &compileErr) {
+			t.Errorf("Compile(%q): expected compile error, got %v", tt.source, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Compile(%q): expected error containing %q, got %q", tt.source, tt.msg, err)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{`request.method == "POST" && request.host == "api.example.com"`, true},
+		{`request.path == "/orders" && request.query.contains("id=42")`, true},
+		{`request.headers["x-api-key"] == "secret"`, true},
+		{`request.args["id"] == "42" && int(request.args["id"]) > 40`, true},
+		{`request.args["qty"] == "3"`, true},
+		{`request.cookies["theme"] == 'dark'`, true},
+		{`base64_decode(request.args["token"]) == "admin"`, true},
+		{`lower(request.args["name"]) == "bob" && upper(request.args["name"]) == "BOB"`, true},
+		{`request.path.starts_with("/ord") && !request.path.ends_with("/")`, true},
+		{`request.path.matches("^/orders$")`, true},
+		{`size(request.cookies) == 2 && request.method.length() == 4`, true},
+		{`"Cookie" in request.headers && "session" in request.cookies`, true},
+		{`"missing" in request.args`, false},
+		{`1 + 2 * 3 == 7 && 7 % 4 == 3 && -(1 - 3) == 2`, true},
+		{`"a" + "b" == "ab" && "a" < "b"`, true},
+		{`request.method != "GET" || request.args["missing"] == "x"`, true},
+		{`request.method == "GET" && request.args["missing"] == "x"`, false},
+	}
+
+	request := testRequest()
+	for _, tt := range tests {
+		program, err := Compile(tt.source)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.source, err)
+			continue
+		}
+		got, err := program.Eval(request)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{`has(request.headers["X-Api-Key"])`, true},
+		{`has(request.headers["x-api-key"])`, true},
+		{`has(request.headers["Authorization"])`, false},
+		{`has(request.args["id"])`, true},
+		{`has(request.args["ID"])`, false},
+		{`has(request.cookies["session"])`, true},
+		{`has(request.cookies["missing"])`, false},
+		{`has(request.body)`, true},
+		{`!has(request.headers["Authorization"]) && has(request.query)`, true},
+	}
+
+	request := testRequest()
+	for _, tt := range tests {
+		program, err := Compile(tt.source)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.source, err)
+			continue
+		}
+		if got, err := program.Eval(request); err != nil || got != tt.want {
+			t.Errorf("Eval(%q) = %v, %v, want %v", tt.source, got, err, tt.want)
+		}
+	}
+
+	program, err := Compile(`has(request.body)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := inspect.Parse(&entity.Request{Method: "GET", URL: "/"})
+	if got, err := program.Eval(empty); err != nil || got {
+		t.Errorf("has(request.body) on empty body = %v, %v, want false", got, err)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		msg    string
+	}{
+		{`request.headers["Authorization"] == "x"`, "no such key: Authorization"},
+		{`request.args["missing"].contains("x")`, "no such key: missing"},
+		{`base64_decode(request.host) == "x"`, "invalid base64 value"},
+		{`int(request.args["name"]) == 1`, "invalid syntax"},
+		{`1 / (int(request.args["id"]) - 42) == 0`, "division by zero"},
+		{`ip in cidr(request.args["name"])`, `invalid cidr "Bob"`},
+	}
+
+	request := testRequest()
+	for _, tt := range tests {
+		program, err := Compile(tt.source)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.source, err)
+			continue
+		}
+		_, err = program.Eval(request)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Eval(%q): expected error containing %q, got %v", tt.source, tt.msg, err)
+		}
+	}
+}
+
+func TestCIDR(t *testing.T) {
+	tests := []struct {
+		ip     string
+		source string
+		want   bool
+	}{
+		{"10.1.2.3", `ip in cidr("10.0.0.0/8")`, true},
+		{"10.1.2.3", `request.ip in cidr("10.1.2.3")`, true},
+		{"10.1.2.4", `ip in cidr("10.1.2.3")`, false},
+		{"192.168.1.1", `ip in cidr("10.0.0.0/8")`, false},
+		{"192.168.1.1:5000", `ip in cidr("192.168.0.0/16")`, true},
+		{"::ffff:10.1.2.3", `ip in cidr("10.0.0.0/8")`, true},
+		{"2001:db8::1", `ip in cidr("2001:db8::/32")`, true},
+		{"[2001:db8::1]:443", `ip in cidr("2001:db8::/32")`, true},
+		{"2001:db8::1", `ip in cidr("10.0.0.0/8")`, false},
+		{"not-an-ip", `ip in cidr("0.0.0.0/0")`, false},
+		{"", `ip in cidr("0.0.0.0/0")`, false},
+		{"10.1.2.3", `request.args["net"] != "" && ip in cidr(request.args["net"])`, true},
+	}
+
+	for _, tt := range tests {
+		program, err := Compile(tt.source)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.source, err)
+			continue
+		}
+		request := inspect.Parse(&entity.Request{IP: tt.ip, Method: "GET", URL: "/?net=10.1.0.0%2F16"})
+		if got, err := program.Eval(request); err != nil || got != tt.want {
+			t.Errorf("Eval(%q) for ip %q = %v, %v, want %v", tt.source, tt.ip, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package expr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// встроенные функции. каждую можно вызвать как f(x, ...) или как x.f(...)
+var functions = map[string]func(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error){
+	"cidr":          compileCIDR,
+	"matches":       compileMatches,
+	"lower":         stringFunc(strings.ToLower),
+	"upper":         stringFunc(strings.ToUpper),
+	"trim":          stringFunc(strings.TrimSpace),
+	"url_decode":    stringFunc(urlDecode),
+	"base64_decode": compileBase64Decode,
+	"size":          compileSize,
+	"length":        compileSize,
+	"contains":      predicate(strings.Contains),
+	"starts_with":   predicate(strings.HasPrefix),
+	"ends_with":     predicate(strings.HasSuffix),
+	"int":           compileInt,
+}
+
+func compileCall(n *callNode) (valueType, evalFn, error) {
+	// has - макрос: аргумент не вычисляется, а проверяется наличие ключа
+	if n.name == "has" {
+		return compileHas(n)
+	}
+
+	fn, ok := functions[n.name]
+	if !ok {
+		return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("undeclared function %q", n.name)}
+	}
+
+	types := make([]valueType, len(n.args))
+	args := make([]evalFn, len(n.args))
+	for i, arg := range n.args {
+		t, eval, err := compile(arg)
+		if err != nil {
+			return "", nil, err
+		}
+		types[i], args[i] = t, eval
+	}
+	return fn(n, types, args)
+}
+
+func compileHas(n *callNode) (valueType, evalFn, error) {
+	if len(n.args) != 1 {
+		return "", nil, &Error{Pos: n.pos, Msg: "has() expects exactly one argument"}
+	}
+
+	switch arg := n.args[0].(type) {
+	case *indexNode:
+		xt, x, err := compile(arg.x)
+		if err != nil {
+			return "", nil, err
+		}
+		kt, key, err := compile(arg.key)
+		if err != nil {
+			return "", nil, err
+		}
+		if xt != typeMap || kt != typeString {
+			return "", nil, &Error{Pos: arg.pos, Msg: fmt.Sprintf("has() expects a map index, got %s[%s]", xt, kt)}
+		}
+		return typeBool, func(a *activation) (any, error) {
+			m, k, err := evalIndex(a, x, key)
+			if err != nil {
+				return nil, err
+			}
+			_, ok := m.lookup(k)
+			return ok, nil
+		}, nil
+	case *memberNode:
+		t, field, err := compile(arg)
+		if err != nil {
+			return "", nil, err
+		}
+		if t != typeString {
+			return "", nil, &Error{Pos: arg.pos, Msg: fmt.Sprintf("has() expects a string field or map index, got %s", t)}
+		}
+		// строковое поле считается заданным, если оно не пустое
+		return typeBool, func(a *activation) (any, error) {
+			v, err := field(a)
+			if err != nil {
+				return nil, err
+			}
+			return v.(string) != "", nil
+		}, nil
+	}
+	return "", nil, &Error{Pos: n.pos, Msg: `has() expects a field selection or map index, e.g. has(request.headers["X-Api-Key"])`}
+}
+
+func checkArgs(n *callNode, types []valueType, want ...valueType) error {
+	if len(types) != len(want) {
+		return &Error{Pos: n.pos, Msg: fmt.Sprintf("%s() expects %d argument(s), got %d", n.name, len(want), len(types))}
+	}
+	for i, t := range types {
+		if t != want[i] {
+			return &Error{Pos: n.args[i].position(), Msg: fmt.Sprintf("%s() argument %d must be %s, got %s", n.name, i+1, want[i], t)}
+		}
+	}
+	return nil
+}
+
+// literal возвращает значение строкового литерала, чтобы проверить его еще при компиляции.
+func literal(n node) (string, bool) {
+	lit, ok := n.(*literalNode)
+	if !ok {
+		return "", false
+	}
+	s, ok := lit.value.(string)
+	return s, ok
+}
+
+func compileCIDR(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error) {
+	if err := checkArgs(n, types, typeString); err != nil {
+		return "", nil, err
+	}
+
+	if raw, ok := literal(n.args[0]); ok {
+		network, err := parseCIDR(raw)
+		if err != nil {
+			return "", nil, &Error{Pos: n.args[0].position(), Msg: err.Error()}
+		}
+		return typeCIDR, func(*activation) (any, error) { return network, nil }, nil
+	}
+
+	return typeCIDR, func(a *activation) (any, error) {
+		raw, err := args[0](a)
+		if err != nil {
+			return nil, err
+		}
+		return parseCIDR(raw.(string))
+	}, nil
+}
+
+// parseCIDR принимает подсеть или одиночный адрес.
+func parseCIDR(raw string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(raw); err == nil {
+		return network, nil
+	}
+	ip := parseIP(raw)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid cidr %q", raw)
+	}
+	bits := 8 * len(ip)
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func parseIP(raw string) net.IP {
+	ip := net.ParseIP(strings.Trim(raw, "[]"))
+	if ip == nil {
+		if host, _, err := net.SplitHostPort(raw); err == nil {
+			ip = net.ParseIP(host)
+		}
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+func compileMatches(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error) {
+	if err := checkArgs(n, types, typeString, typeString); err != nil {
+		return "", nil, err
+	}
+
+	raw, ok := literal(n.args[1])
+	if !ok {
+		return "", nil, &Error{Pos: n.args[1].position(), Msg: "matches() pattern must be a string literal"}
+	}
+	pattern, err := regexp.Compile(raw)
+	if err != nil {
+		return "", nil, &Error{Pos: n.args[1].position(), Msg: fmt.Sprintf("invalid regex: %v", err)}
+	}
+
+	return typeBool, func(a *activation) (any, error) {
+		s, err := args[0](a)
+		if err != nil {
+			return nil, err
+		}
+		return pattern.MatchString(s.(string)), nil
+	}, nil
+}
+
+func stringFunc(f func(string) string) func(*callNode, []valueType, []evalFn) (valueType, evalFn, error) {
+	return func(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error) {
+		if err := checkArgs(n, types, typeString); err != nil {
+			return "", nil, err
+		}
+		return typeString, func(a *activation) (any, error) {
+			s, err := args[0](a)
+			if err != nil {
+				return nil, err
+			}
+			return f(s.(string)), nil
+		}, nil
+	}
+}
+
+func predicate(f func(s, sub string) bool) func(*callNode, []valueType, []evalFn) (valueType, evalFn, error) {
+	return func(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error) {
+		if err := checkArgs(n, types, typeString, typeString); err != nil {
+			return "", nil, err
+		}
+		return typeBool, binary(args[0], args[1], func(x, y any) (any, error) { return f(x.(string), y.(string)), nil }), nil
+	}
+}
+
+func urlDecode(raw string) string {
+	decoded, err := url.QueryUnescape(raw)
+	if err != nil {
+		return raw
+	}
+	return decoded
+}
+
+func compileBase64Decode(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error) {
+	if err := checkArgs(n, types, typeString); err != nil {
+		return "", nil, err
+	}
+	return typeString, func(a *activation) (any, error) {
+		s, err := args[0](a)
+		if err != nil {
+			return nil, err
+		}
+		return base64Decode(s.(string))
+	}, nil
+}
+
+// base64Decode принимает стандартный и url-safe алфавит, с паддингом и без.
+func base64Decode(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(raw); err == nil {
+			return string(decoded), nil
+		}
+	}
+	return "", fmt.Errorf("invalid base64 value")
+}
+
+func compileSize(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error) {
+	if len(types) != 1 || (types[0] != typeString && types[0] != typeMap) {
+		return "", nil, &Error{Pos: n.pos, Msg: fmt.Sprintf("%s() expects one string or map argument", n.name)}
+	}
+	return typeInt, func(a *activation) (any, error) {
+		v, err := args[0](a)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := v.(mapValue); ok {
+			return int64(len(m.values)), nil
+		}
+		return int64(len(v.(string))), nil
+	}, nil
+}
+
+func compileInt(n *callNode, types []valueType, args []evalFn) (valueType, evalFn, error) {
+	if err := checkArgs(n, types, typeString); err != nil {
+		return "", nil, err
+	}
+	return typeInt, func(a *activation) (any, error) {
+		s, err := args[0](a)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(strings.TrimSpace(s.(string)), 10, 64)
+	}, nil
+}
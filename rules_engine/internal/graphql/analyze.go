@@ -0,0 +1,227 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// maxVisits ограничивает обход: фрагменты, вложенные друг в друга по нескольку раз, раскрываются
+// экспоненциально, и обход останавливается раньше, чем займет процессор
+const maxVisits = 100000
+
+// аргументы пагинации, значение которых умножает стоимость вложенных полей
+var listArguments = []string{"first", "last", "limit"}
+
+// Report - метрики запроса и строковые значения его аргументов.
+type Report struct {
+	Depth   int
+	Aliases int
+	Fields  int
+	Cost    int
+	// Introspection - путь первого поля интроспекции (__schema или __type), пустой если их нет
+	Introspection string
+	Arguments     []Argument
+}
+
+// Argument - строковое значение аргумента. Path - путь поля в ответе и путь значения внутри аргумента,
+// например user.posts(where.title).
+type Argument struct {
+	Path  string
+	Value string
+}
+
+type walker struct {
+	doc       *Document
+	variables map[string]any
+	defaults  map[string]*value
+	report    *Report
+	visits    int
+	active    map[string]bool
+}
+
+// Analyze считает метрики операции operationName или, если имя пустое, всех операций документа.
+// variables - значения переменных из запроса, для отсутствующих берутся значения по умолчанию.
+func (d *Document) Analyze(operationName string, variables map[string]any) (*Report, error) {
+	operations := d.operations
+	if operationName != "" {
+		operations = nil
+		for _, op := range d.operations {
+			if op.name == operationName {
+				operations = append(operations, op)
+			}
+		}
+		if len(operations) == 0 {
+			return nil, fmt.Errorf("operation %s is not defined", operationName)
+		}
+	}
+
+	report := &Report{}
+	for _, op := range operations {
+		w := &walker{
+			doc:       d,
+			variables: variables,
+			defaults:  op.variables,
+			report:    report,
+			active:    make(map[string]bool),
+		}
+		cost, err := w.walk(op.selections, 1, "")
+		if err != nil {
+			return nil, err
+		}
+		report.Cost = add(report.Cost, cost)
+	}
+	return report, nil
+}
+
+// walk обходит выборку и возвращает ее стоимость: поле стоит 1 плюс стоимость вложенной выборки,
+// умноженная на размер запрошенной страницы.
+func (w *walker) walk(selections []selection, depth int, path string) (int, error) {
+	cost := 0
+	for _, s := range selections {
+		w.visits++
+		if w.visits > maxVisits {
+			return 0, fmt.Errorf("document expands to more than %d selections", maxVisits)
+		}
+
+		if s.name == "" {
+			children := s.selections
+			if s.spread != "" {
+				f, ok := w.doc.fragments[s.spread]
+				if !ok {
+					return 0, fmt.Errorf("fragment %s is not defined", s.spread)
+				}
+				if w.active[s.spread] {
+					return 0, fmt.Errorf("fragment %s spreads itself", s.spread)
+				}
+				children = f.selections
+				w.active[s.spread] = true
+			}
+			c, err := w.walk(children, depth, path)
+			delete(w.active, s.spread)
+			if err != nil {
+				return 0, err
+			}
+			cost = add(cost, c)
+			continue
+		}
+
+		key := s.name
+		if s.alias != "" {
+			key = s.alias
+			w.report.Aliases++
+		}
+		if path != "" {
+			key = path + "." + key
+		}
+		w.report.Fields++
+		w.report.Depth = max(w.report.Depth, depth)
+		if (s.name == "__schema" || s.name == "__type") && w.report.Introspection == "" {
+			w.report.Introspection = key
+		}
+		for _, arg := range s.arguments {
+			w.collect(key+"("+arg.name, arg.value)
+		}
+
+		if len(s.selections) == 0 {
+			cost = add(cost, 1)
+			continue
+		}
+		c, err := w.walk(s.selections, depth+1, key)
+		if err != nil {
+			return 0, err
+		}
+		cost = add(cost, add(1, mul(w.pageSize(s.arguments), c)))
+	}
+	return cost, nil
+}
+
+// collect добавляет в отчет строковые значения аргумента, переменные подставляются.
+func (w *walker) collect(path string, v *value) {
+	switch v.kind {
+	case valueString:
+		w.report.Arguments = append(w.report.Arguments, Argument{Path: path + ")", Value: v.raw})
+	case valueList:
+		for i, item := range v.list {
+			w.collect(path+"."+strconv.Itoa(i), item)
+		}
+	case valueObject:
+		for _, field := range v.fields {
+			w.collect(path+"."+field.name, field.value)
+		}
+	case valueVariable:
+		if variable, ok := w.variables[v.raw]; ok {
+			w.collectJSON(path, variable)
+		} else if def := w.defaults[v.raw]; def != nil && def.kind != valueVariable {
+			w.collect(path, def)
+		}
+	}
+}
+
+func (w *walker) collectJSON(path string, v any) {
+	switch v := v.(type) {
+	case string:
+		w.report.Arguments = append(w.report.Arguments, Argument{Path: path + ")", Value: v})
+	case []any:
+		for i, item := range v {
+			w.collectJSON(path+"."+strconv.Itoa(i), item)
+		}
+	case map[string]any:
+		// ключи сортируются, чтобы первое нарушение не зависело от порядка обхода map
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			w.collectJSON(path+"."+key, v[key])
+		}
+	}
+}
+
+// pageSize возвращает значение first, last или limit. поле без них считается одним объектом.
+func (w *walker) pageSize(arguments []argument) int {
+	size := 1
+	for _, arg := range arguments {
+		if !slices.Contains(listArguments, arg.name) {
+			continue
+		}
+
+		raw := ""
+		switch v := arg.value; v.kind {
+		case valueInt:
+			raw = v.raw
+		case valueVariable:
+			switch variable := w.variables[v.raw].(type) {
+			case json.Number:
+				raw = variable.String()
+			case float64:
+				raw = strconv.FormatFloat(variable, 'f', -1, 64)
+			case nil:
+				if def := w.defaults[v.raw]; def != nil && def.kind == valueInt {
+					raw = def.raw
+				}
+			}
+		}
+		// страница больше MaxInt32 все равно упирается в предел стоимости
+		if n, err := strconv.ParseFloat(raw, 64); err == nil && n > float64(size) {
+			size = int(min(n, math.MaxInt32))
+		}
+	}
+	return size
+}
+
+// add и mul не переполняются: стоимость огромного запроса упирается в math.MaxInt32
+func add(a, b int) int {
+	return min(a+b, math.MaxInt32)
+}
+
+func mul(a, b int) int {
+	if a != 0 && b > math.MaxInt32/a {
+		return math.MaxInt32
+	}
+	return min(a*b, math.MaxInt32)
+}
@@ -0,0 +1,199 @@
+package graphql
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/inspect"
+)
+
+func analyze(t *testing.T, query, operation string, variables map[string]any) (*Report, error) {
+	t.Helper()
+	doc, err := Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", query, err)
+	}
+	return doc.Analyze(operation, variables)
+}
+
+func TestAnalyzeMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		variables map[string]any
+		want      Report
+	}{
+		{name: "nested fields", query: `{ a { b { c } } }`,
+			want: Report{Depth: 3, Fields: 3, Cost: 3}},
+		{name: "aliases", query: `{ x: user(id: 1) { name } y: user(id: 2) { name } }`,
+			want: Report{Depth: 2, Aliases: 2, Fields: 4, Cost: 4}},
+		// title стоит 1, posts 1+5*1, users 1+10*6
+		{name: "page size multiplies cost", query: `{ users(first: 10) { posts(last: 5) { title } } }`,
+			want: Report{Depth: 3, Fields: 3, Cost: 61}},
+		{name: "page size from variable", query: `query Q($n: Int) { users(limit: $n) { id } }`,
+			variables: map[string]any{"n": json.Number("100")},
+			want:      Report{Depth: 2, Fields: 2, Cost: 101}},
+		{name: "page size from default", query: `query Q($n: Int = 3) { users(first: $n) { id } }`,
+			want: Report{Depth: 2, Fields: 2, Cost: 4}},
+		{name: "huge page size is capped", query: `{ a(first: 99999999999) { b(first: 99999999999) { c } } }`,
+			want: Report{Depth: 3, Fields: 3, Cost: 2147483647}},
+		{name: "fragments and inline fragments count at their use site",
+			query: `{ me { ...F ... on User { email } } } fragment F on User { friends(first: 2) { name } }`,
+			want:  Report{Depth: 3, Fields: 4, Cost: 5}},
+		{name: "fragment used twice", query: `{ a { ...F } b { ...F } } fragment F on T { x y }`,
+			want: Report{Depth: 2, Fields: 6, Cost: 6}},
+		{name: "introspection", query: `{ me { id } __schema { types { name } } }`,
+			want: Report{Depth: 3, Fields: 5, Cost: 5, Introspection: "__schema"}},
+		{name: "nested type introspection", query: `{ q: node { t: __type(name: "User") { name } } }`,
+			want: Report{Depth: 3, Aliases: 2, Fields: 3, Cost: 3, Introspection: "q.t",
+				Arguments: []Argument{{Path: "q.t(name)", Value: "User"}}}},
+		{name: "selected operation", query: `query A { a } query B { b { c } }`, operation: "B",
+			want: Report{Depth: 2, Fields: 2, Cost: 2}},
+		{name: "all operations", query: `query A { a } query B { b { c } }`,
+			want: Report{Depth: 2, Fields: 3, Cost: 3}},
+	}
+
+	for _, tt := range tests {
+		report, err := analyze(t, tt.query, tt.operation, tt.variables)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*report, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, *report)
+		}
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		operation string
+		err       string
+	}{
+		{"self spread", `{ ...A } fragment A on Q { a ...A }`, "", "fragment A spreads itself"},
+		{"fragment cycle", `{ ...A } fragment A on Q { ...B } fragment B on Q { ...C } fragment C on Q { ...A }`, "",
+			"spreads itself"},
+		{"cycle inside field", `{ ...A } fragment A on Q { user { ...B } } fragment B on U { friend { ...A } }`, "",
+			"spreads itself"},
+		{"undefined fragment", `{ ...Missing }`, "", "fragment Missing is not defined"},
+		{"unknown operation", `query A { a }`, "B", "operation B is not defined"},
+		{"exponential fragments",
+			`{ ...F5 }
+			fragment F0 on Q { a b c d e f g h i j }
+			fragment F1 on Q { a: x { ...F0 } b: x { ...F0 } c: x { ...F0 } d: x { ...F0 } e: x { ...F0 } f: x { ...F0 } g: x { ...F0 } h: x { ...F0 } i: x { ...F0 } j: x { ...F0 } }
+			fragment F2 on Q { a: x { ...F1 } b: x { ...F1 } c: x { ...F1 } d: x { ...F1 } e: x { ...F1 } f: x { ...F1 } g: x { ...F1 } h: x { ...F1 } i: x { ...F1 } j: x { ...F1 } }
+			fragment F3 on Q { a: x { ...F2 } b: x { ...F2 } c: x { ...F2 } d: x { ...F2 } e: x { ...F2 } f: x { ...F2 } g: x { ...F2 } h: x { ...F2 } i: x { ...F2 } j: x { ...F2 } }
+			fragment F4 on Q { a: x { ...F3 } b: x { ...F3 } c: x { ...F3 } d: x { ...F3 } e: x { ...F3 } f: x { ...F3 } g: x { ...F3 } h: x { ...F3 } i: x { ...F3 } j: x { ...F3 } }
+			fragment F5 on Q { a: x { ...F4 } b: x { ...F4 } c: x { ...F4 } d: x { ...F4 } e: x { ...F4 } f: x { ...F4 } g: x { ...F4 } h: x { ...F4 } i: x { ...F4 } j: x { ...F4 } }`,
+			"", "more than 100000 selections"},
+	}
+
+	for _, tt := range tests {
+		_, err := analyze(t, tt.query, tt.operation, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestArgumentPaths(t *testing.T) {
+	query := `query Search($filter: Filter, $term: String = "default") {
+		search(where: {title: "x", tags: ["a", "b"]}, filter: $filter, term: $term, first: 10) {
+			items: results { author(name: "bob") { id } }
+		}
+	}`
+	variables := map[string]any{
+		"filter": map[string]any{"owner": "alice", "ids": []any{"1", json.Number("2")}},
+	}
+
+	report, err := analyze(t, query, "", variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Argument{
+		{Path: "search(where.title)", Value: "x"},
+		{Path: "search(where.tags.0)", Value: "a"},
+		{Path: "search(where.tags.1)", Value: "b"},
+		{Path: "search(filter.ids.0)", Value: "1"},
+		{Path: "search(filter.owner)", Value: "alice"},
+		{Path: "search(term)", Value: "default"},
+		{Path: "search.items.author(name)", Value: "bob"},
+	}
+	if !reflect.DeepEqual(report.Arguments, want) {
+		t.Errorf("expected arguments\n%+v\ngot\n%+v", want, report.Arguments)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{``, "document has no operations"},
+		{`fragment F on Q { a }`, "document has no operations"},
+		{`{ a `, "expected"},
+		{`{ a(x: ) }`, "expected a value"},
+		{`{ a } fragment F on Q { a } fragment F on Q { b }`, "fragment F is defined more than once"},
+		{`type Query { a: Int }`, "expected an operation or a fragment"},
+		{`{ a(x: "unterminated) }`, "string"},
+		{`{` + strings.Repeat(`a {`, 200) + `b` + strings.Repeat(`}`, 201), "nested deeper than"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%.40q): expected error containing %q, got %v", tt.query, tt.err, err)
+		}
+	}
+}
+
+func TestRequests(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		want        []Request
+		err         bool
+	}{
+		{name: "json body", method: "POST", url: "/graphql", contentType: "application/json",
+			body: `{"query": "query Q($id: ID) { user(id: $id) { name } }", "operationName": "Q", "variables": {"id": "7"}}`,
+			want: []Request{{Query: "query Q($id: ID) { user(id: $id) { name } }", OperationName: "Q", Variables: map[string]any{"id": "7"}}}},
+		{name: "batch", method: "POST", url: "/graphql", contentType: "application/json",
+			body: `[{"query": "{ a }"}, {"extensions": {"persistedQuery": {}}}, {"query": "{ b }"}]`,
+			want: []Request{{Query: "{ a }"}, {Query: "{ b }"}}},
+		{name: "graphql body", method: "POST", url: "/graphql", contentType: "application/graphql",
+			body: "{ me { id } }",
+			want: []Request{{Query: "{ me { id } }"}}},
+		{name: "get query", method: "GET", url: `/graphql?query=%7B%20a%20%7D&operationName=A&variables=%7B%22n%22%3A1%7D`,
+			want: []Request{{Query: "{ a }", OperationName: "A", Variables: map[string]any{"n": json.Number("1")}}}},
+		{name: "persisted query", method: "POST", url: "/graphql", contentType: "application/json",
+			body: `{"extensions": {"persistedQuery": {"sha256Hash": "abc"}}}`},
+		{name: "malformed json", method: "POST", url: "/graphql", contentType: "application/json",
+			body: `{"query": `, err: true},
+	}
+
+	for _, tt := range tests {
+		headers := map[string]string{}
+		if tt.contentType != "" {
+			headers["Content-Type"] = tt.contentType
+		}
+		r := inspect.Parse(&entity.Request{Method: tt.method, URL: tt.url, Headers: headers, Body: tt.body})
+
+		requests, err := Requests(r)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(requests, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, requests)
+		}
+	}
+}
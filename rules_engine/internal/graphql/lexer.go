@@ -0,0 +1,238 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTokens ограничивает размер документа: разбор большего документа не нужен ни одному клиенту
+const maxTokens = 20000
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of document"
+	case tokenString:
+		return "string"
+	}
+	return strconv.Quote(t.value)
+}
+
+// lex разбивает документ на лексемы. запятые, пробелы и комментарии в GraphQL незначимы и пропускаются.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; ; {
+		i = skipIgnored(src, i)
+		if i >= len(src) {
+			return append(tokens, token{kind: tokenEOF, pos: i}), nil
+		}
+		if len(tokens) == maxTokens {
+			return nil, fmt.Errorf("document has more than %d tokens", maxTokens)
+		}
+
+		c := src[i]
+		var (
+			tok token
+			err error
+		)
+		switch {
+		case strings.HasPrefix(src[i:], "..."):
+			tok, i = token{tokenPunct, "...", i}, i+3
+		case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+			tok, i = token{tokenPunct, string(c), i}, i+1
+		case isNameStart(c):
+			start := i
+			for i < len(src) && isNameContinue(src[i]) {
+				i++
+			}
+			tok = token{tokenName, src[start:i], start}
+		case c == '-' || isDigit(c):
+			tok, i, err = lexNumber(src, i)
+		case c == '"':
+			tok, i, err = lexString(src, i)
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			err = fmt.Errorf("unexpected character %q at %d", r, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func skipIgnored(src string, i int) int {
+	for i < len(src) {
+		switch {
+		case src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r' || src[i] == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case src[i] == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func lexNumber(src string, i int) (token, int, error) {
+	start := i
+	kind := tokenInt
+	if src[i] == '-' {
+		i++
+	}
+	digits := func() bool {
+		from := i
+		for i < len(src) && isDigit(src[i]) {
+			i++
+		}
+		return i > from
+	}
+
+	if !digits() {
+		return token{}, i, fmt.Errorf("invalid number at %d", start)
+	}
+	if i < len(src) && src[i] == '.' {
+		i++
+		kind = tokenFloat
+		if !digits() {
+			return token{}, i, fmt.Errorf("invalid number at %d", start)
+		}
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		i++
+		kind = tokenFloat
+		if i < len(src) && (src[i] == '+' || src[i] == '-') {
+			i++
+		}
+		if !digits() {
+			return token{}, i, fmt.Errorf("invalid number at %d", start)
+		}
+	}
+	if i < len(src) && (src[i] == '.' || isNameStart(src[i])) {
+		return token{}, i, fmt.Errorf("invalid number at %d", start)
+	}
+	return token{kind, src[start:i], start}, i, nil
+}
+
+// lexString возвращает значение строки с раскрытыми escape-последовательностями. блочная строка
+// возвращается без выравнивания отступов: для проверки значения оно не важно.
+func lexString(src string, i int) (token, int, error) {
+	start := i
+	if strings.HasPrefix(src[i:], `"""`) {
+		i += 3
+		var b strings.Builder
+		for i < len(src) {
+			switch {
+			case strings.HasPrefix(src[i:], `\"""`):
+				b.WriteString(`"""`)
+				i += 4
+			case strings.HasPrefix(src[i:], `"""`):
+				return token{tokenString, b.String(), start}, i + 3, nil
+			default:
+				b.WriteByte(src[i])
+				i++
+			}
+		}
+		return token{}, i, fmt.Errorf("unterminated block string at %d", start)
+	}
+
+	i++
+	var b strings.Builder
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '"':
+			return token{tokenString, b.String(), start}, i + 1, nil
+		case c == '\n' || c == '\r':
+			return token{}, i, fmt.Errorf("unterminated string at %d", start)
+		case c != '\\':
+			b.WriteByte(c)
+			i++
+			continue
+		}
+
+		if i+1 >= len(src) {
+			break
+		}
+		switch esc := src[i+1]; esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			r, n, err := unicodeEscape(src[i+2:])
+			if err != nil {
+				return token{}, i, fmt.Errorf("invalid unicode escape at %d", i)
+			}
+			b.WriteRune(r)
+			i += n
+		default:
+			return token{}, i, fmt.Errorf("invalid escape sequence at %d", i)
+		}
+		i += 2
+	}
+	return token{}, i, fmt.Errorf("unterminated string at %d", start)
+}
+
+// unicodeEscape разбирает XXXX или {X...} после \u и возвращает длину разобранного.
+func unicodeEscape(s string) (rune, int, error) {
+	hex, n := "", 0
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return 0, 0, fmt.Errorf("unterminated escape")
+		}
+		hex, n = s[1:end], end+1
+	} else if len(s) >= 4 {
+		hex, n = s[:4], 4
+	}
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || code > utf8.MaxRune {
+		return 0, 0, fmt.Errorf("invalid escape")
+	}
+	return rune(code), n, nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,412 @@
+// Package graphql разбирает исполняемый документ GraphQL (операции и фрагменты) и считает по нему
+// глубину, число полей и алиасов и стоимость запроса, а также собирает строковые значения аргументов
+// с путем поля, в котором они переданы.
+//
+// схема api при этом не нужна: стоимость считается по аргументам пагинации first, last и limit,
+// а определения типов в документе запроса не допускаются.
+package graphql
+
+import "fmt"
+
+// maxNesting ограничивает вложенность разбора, чтобы документ не мог исчерпать стек
+const maxNesting = 128
+
+type valueKind int
+
+const (
+	valueVariable valueKind = iota
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueNull
+	valueEnum
+	valueList
+	valueObject
+)
+
+// Document - разобранный документ запроса.
+type Document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string
+	name       string
+	variables  map[string]*value
+	selections []selection
+}
+
+type fragment struct {
+	name       string
+	selections []selection
+}
+
+// selection - поле, ...фрагмент или inline-фрагмент. у поля непустое name, у фрагмента - spread.
+type selection struct {
+	alias      string
+	name       string
+	arguments  []argument
+	spread     string
+	selections []selection
+}
+
+type argument struct {
+	name  string
+	value *value
+}
+
+type value struct {
+	kind   valueKind
+	raw    string
+	list   []*value
+	fields []argument
+}
+
+type parser struct {
+	tokens  []token
+	pos     int
+	nesting int
+}
+
+func Parse(src string) (*Document, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	doc := &Document{fragments: make(map[string]*fragment)}
+	for p.peek().kind != tokenEOF {
+		tok := p.peek()
+		switch {
+		case tok.value == "{" && tok.kind == tokenPunct:
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: selections})
+		case tok.kind == tokenName && (tok.value == "query" || tok.value == "mutation" || tok.value == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case tok.kind == tokenName && tok.value == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.fragments[f.name]; exists {
+				return nil, fmt.Errorf("fragment %s is defined more than once", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, fmt.Errorf("unexpected %s at %d, expected an operation or a fragment", tok, tok.pos)
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return doc, nil
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.next().value, variables: make(map[string]*value)}
+	if p.peek().kind == tokenName {
+		op.name = p.next().value
+	}
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if err := p.typeRef(); err != nil {
+				return nil, err
+			}
+			op.variables[name] = nil
+			if p.skip("=") {
+				if op.variables[name], err = p.value(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.directives(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	p.next()
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, fmt.Errorf("fragment cannot be named on")
+	}
+	if tok := p.next(); tok.kind != tokenName || tok.value != "on" {
+		return nil, fmt.Errorf("unexpected %s at %d, expected on", tok, tok.pos)
+	}
+	if _, err := p.name(); err != nil {
+		return nil, err
+	}
+	if err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{name: name, selections: selections}, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []selection
+	for !p.skip("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("empty selection set")
+	}
+	return selections, nil
+}
+
+func (p *parser) selection() (selection, error) {
+	if p.skip("...") {
+		if tok := p.peek(); tok.kind == tokenName && tok.value != "on" {
+			p.next()
+			return selection{spread: tok.value}, p.directives()
+		}
+		// inline-фрагмент: условие типа и директивы не влияют на подсчет
+		if tok := p.peek(); tok.kind == tokenName && tok.value == "on" {
+			p.next()
+			if _, err := p.name(); err != nil {
+				return selection{}, err
+			}
+		}
+		if err := p.directives(); err != nil {
+			return selection{}, err
+		}
+		selections, err := p.selectionSet()
+		return selection{selections: selections}, err
+	}
+
+	var s selection
+	name, err := p.name()
+	if err != nil {
+		return s, err
+	}
+	s.name = name
+	if p.skip(":") {
+		s.alias = name
+		if s.name, err = p.name(); err != nil {
+			return s, err
+		}
+	}
+	if s.arguments, err = p.arguments(); err != nil {
+		return s, err
+	}
+	if err := p.directives(); err != nil {
+		return s, err
+	}
+	if tok := p.peek(); tok.kind == tokenPunct && tok.value == "{" {
+		s.selections, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *parser) arguments() ([]argument, error) {
+	if !p.skip("(") {
+		return nil, nil
+	}
+	var arguments []argument
+	for !p.skip(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument{name: name, value: v})
+	}
+	return arguments, nil
+}
+
+// directives пропускает директивы: @include и @skip зависят от переменных, поэтому поле считается всегда.
+func (p *parser) directives() error {
+	for p.skip("@") {
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if _, err := p.arguments(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) typeRef() error {
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.leave()
+
+	if p.skip("[") {
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	p.skip("!")
+	return nil
+}
+
+func (p *parser) value() (*value, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	tok := p.next()
+	switch tok.kind {
+	case tokenInt:
+		return &value{kind: valueInt, raw: tok.value}, nil
+	case tokenFloat:
+		return &value{kind: valueFloat, raw: tok.value}, nil
+	case tokenString:
+		return &value{kind: valueString, raw: tok.value}, nil
+	case tokenName:
+		switch tok.value {
+		case "true", "false":
+			return &value{kind: valueBoolean, raw: tok.value}, nil
+		case "null":
+			return &value{kind: valueNull}, nil
+		}
+		return &value{kind: valueEnum, raw: tok.value}, nil
+	case tokenPunct:
+		switch tok.value {
+		case "$":
+			name, err := p.name()
+			return &value{kind: valueVariable, raw: name}, err
+		case "[":
+			v := &value{kind: valueList}
+			for !p.skip("]") {
+				item, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				v.list = append(v.list, item)
+			}
+			return v, nil
+		case "{":
+			v := &value{kind: valueObject}
+			for !p.skip("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				field, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				v.fields = append(v.fields, argument{name: name, value: field})
+			}
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at %d, expected a value", tok, tok.pos)
+}
+
+func (p *parser) enter() error {
+	p.nesting++
+	if p.nesting > maxNesting {
+		return fmt.Errorf("document is nested deeper than %d levels", maxNesting)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.nesting--
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next не сдвигается дальше EOF, поэтому незакрытая скобка дает ошибку, а не выход за массив.
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) skip(punct string) bool {
+	if tok := p.peek(); tok.kind == tokenPunct && tok.value == punct {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) error {
+	if tok := p.next(); tok.kind != tokenPunct || tok.value != punct {
+		return fmt.Errorf("unexpected %s at %d, expected %q", tok, tok.pos, punct)
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	tok := p.next()
+	if tok.kind != tokenName {
+		return "", fmt.Errorf("unexpected %s at %d, expected a name", tok, tok.pos)
+	}
+	return tok.value, nil
+}
@@ -0,0 +1,103 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"rules-engine/internal/inspect"
+)
+
+// Request - одна операция из http-запроса к graphql endpoint.
+type Request struct {
+	Query         string
+	OperationName string
+	Variables     map[string]any
+}
+
+type jsonRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+}
+
+// Requests извлекает операции из запроса: json-тело с query, variables и operationName или массив таких
+// объектов (batch), тело application/graphql, или query аргументы GET-запроса.
+// пустой результат означает, что запрос не содержит документа graphql, например persisted query по хэшу.
+func Requests(r *inspect.Request) ([]Request, error) {
+	body := r.Raw.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Headers["Content-Type"])
+
+	switch {
+	case body != "" && mediaType == "application/graphql":
+		return []Request{{Query: body}}, nil
+	case body != "" && r.Processor == inspect.ProcessorJSON:
+		var batch []jsonRequest
+		trimmed := bytes.TrimSpace([]byte(body))
+		if bytes.HasPrefix(trimmed, []byte("[")) {
+			if err := decode(trimmed, &batch); err != nil {
+				return nil, err
+			}
+		} else {
+			var single jsonRequest
+			if err := decode(trimmed, &single); err != nil {
+				return nil, err
+			}
+			batch = []jsonRequest{single}
+		}
+
+		var requests []Request
+		for _, item := range batch {
+			if item.Query == "" {
+				continue
+			}
+			variables, err := parseVariables(item.Variables)
+			if err != nil {
+				return nil, err
+			}
+			requests = append(requests, Request{Query: item.Query, OperationName: item.OperationName, Variables: variables})
+		}
+		return requests, nil
+	}
+
+	query, ok := r.Arg("query")
+	if !ok || query == "" {
+		return nil, nil
+	}
+	operationName, _ := r.Arg("operationName")
+	rawVariables, _ := r.Arg("variables")
+	variables, err := parseVariables(json.RawMessage(rawVariables))
+	if err != nil {
+		return nil, err
+	}
+	return []Request{{Query: query, OperationName: operationName, Variables: variables}}, nil
+}
+
+// parseVariables принимает объект, null или строку с json-объектом: некоторые клиенты кодируют variables дважды.
+func parseVariables(raw json.RawMessage) (map[string]any, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return nil, fmt.Errorf("invalid variables: %w", err)
+		}
+		return parseVariables(json.RawMessage(strings.TrimSpace(encoded)))
+	}
+
+	var variables map[string]any
+	if err := decode(raw, &variables); err != nil {
+		return nil, fmt.Errorf("invalid variables: %w", err)
+	}
+	return variables, nil
+}
+
+func decode(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,183 @@
+package inspect
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+var errTooManyArgs = fmt.Errorf("request body has more than %d arguments", maxArgs)
+
+// parseMultipart раскладывает поля формы в ARGS_POST, а файлы в FILES. содержимое файлов только считается.
+func (r *Request) parseMultipart(boundary string) error {
+	if boundary == "" {
+		return fmt.Errorf("multipart body without boundary")
+	}
+
+	reader := multipart.NewReader(strings.NewReader(r.Raw.Body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid multipart body: %v", err)
+		}
+		if len(r.ArgsPost)+len(r.Files) == maxArgs {
+			return errTooManyArgs
+		}
+
+		name := part.FormName()
+		for key, values := range part.Header {
+			for _, value := range values {
+				r.PartHeaders = append(r.PartHeaders, Field{Name: name, Value: key + ": " + value})
+			}
+		}
+
+		if filename := part.FileName(); filename != "" {
+			size, err := io.Copy(io.Discard, part)
+			if err != nil {
+				return fmt.Errorf("invalid multipart body: %v", err)
+			}
+			r.Files = append(r.Files, File{
+				Field:       name,
+				Filename:    filename,
+				ContentType: part.Header.Get("Content-Type"),
+				Size:        int(size),
+			})
+			continue
+		}
+
+		value, err := io.ReadAll(part)
+		if err != nil {
+			return fmt.Errorf("invalid multipart body: %v", err)
+		}
+		r.ArgsPost = append(r.ArgsPost, Field{Name: name, Value: string(value)})
+	}
+}
+
+// parseJSON разворачивает json в плоские аргументы с именами вида json.user.emails.0, как в Coraza.
+// документ читается потоком, чтобы аргументы шли в порядке тела.
+func parseJSON(body string) ([]Field, error) {
+	p := &jsonParser{dec: json.NewDecoder(strings.NewReader(body))}
+	p.dec.UseNumber()
+
+	if err := p.value("json", 0); err != nil {
+		return p.fields, err
+	}
+	if _, err := p.dec.Token(); !errors.Is(err, io.EOF) {
+		return p.fields, fmt.Errorf("invalid json body: unexpected data after value")
+	}
+	return p.fields, nil
+}
+
+type jsonParser struct {
+	dec    *json.Decoder
+	fields []Field
+}
+
+func (p *jsonParser) value(name string, depth int) error {
+	tok, err := p.dec.Token()
+	if err != nil {
+		return fmt.Errorf("invalid json body: %v", err)
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		if len(p.fields) == maxArgs {
+			return errTooManyArgs
+		}
+		p.fields = append(p.fields, Field{Name: name, Value: jsonScalar(tok)})
+		return nil
+	}
+
+	if depth == maxDepth {
+		return fmt.Errorf("json body is nested deeper than %d levels", maxDepth)
+	}
+	for i := 0; p.dec.More(); i++ {
+		key := strconv.Itoa(i)
+		if delim == '{' {
+			keyTok, err := p.dec.Token()
+			if err != nil {
+				return fmt.Errorf("invalid json body: %v", err)
+			}
+			key = keyTok.(string)
+		}
+		if err := p.value(name+"."+key, depth+1); err != nil {
+			return err
+		}
+	}
+
+	// закрывающая скобка
+	if _, err := p.dec.Token(); err != nil {
+		return fmt.Errorf("invalid json body: %v", err)
+	}
+	return nil
+}
+
+func jsonScalar(tok json.Token) string {
+	switch v := tok.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// parseXML собирает текст элементов и значения атрибутов с путями вида /order/item и /order/item/@id.
+// внешние сущности не разрешаются: encoding/xml не загружает DTD, а возвращает DOCTYPE как есть.
+func parseXML(body string) ([]Field, string, error) {
+	dec := xml.NewDecoder(strings.NewReader(body))
+
+	var fields []Field
+	var path []string
+	var dtd string
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			if len(path) > 0 {
+				return fields, dtd, fmt.Errorf("invalid xml body: unexpected end of document")
+			}
+			return fields, dtd, nil
+		}
+		if err != nil {
+			return fields, dtd, fmt.Errorf("invalid xml body: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(path) == maxDepth {
+				return fields, dtd, fmt.Errorf("xml body is nested deeper than %d levels", maxDepth)
+			}
+			path = append(path, t.Name.Local)
+			element := "/" + strings.Join(path, "/")
+			for _, attr := range t.Attr {
+				fields = append(fields, Field{Name: element + "/@" + attr.Name.Local, Value: attr.Value})
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" || len(path) == 0 {
+				continue
+			}
+			fields = append(fields, Field{Name: "/" + strings.Join(path, "/"), Value: text})
+		case xml.Directive:
+			if strings.HasPrefix(string(t), "DOCTYPE") {
+				dtd = string(t)
+			}
+		}
+
+		if len(fields) > maxArgs {
+			return fields[:maxArgs], dtd, errTooManyArgs
+		}
+	}
+}
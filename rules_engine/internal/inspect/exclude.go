@@ -0,0 +1,75 @@
+package inspect
+
+import (
+	"net/textproto"
+)
+
+// коллекции, из которых исключение убирает поле
+const (
+	CollectionArgsGet  = "ARGS_GET"
+	CollectionArgsPost = "ARGS_POST"
+	CollectionCookies  = "REQUEST_COOKIES"
+	CollectionHeaders  = "REQUEST_HEADERS"
+)
+
+// Excluded сообщает, исключено ли поле коллекции из проверки правила. имена заголовков передаются
+// в канонической форме.
+type Excluded func(collection, name string) bool
+
+// Exclude возвращает копию запроса без исключенных аргументов, заголовков и cookie: правило, проверяющее
+// копию, их не видит. url и тело копии переписываются по всем полям исходного запроса, а исключенные
+// поля при этом остаются как есть.
+func (r *Request) Exclude(excluded Excluded) *Request {
+	c := *r
+	c.origin = r.source()
+	c.excluded = excluded
+	c.ArgsGet = withoutExcluded(r.ArgsGet, CollectionArgsGet, excluded)
+	c.ArgsPost = withoutExcluded(r.ArgsPost, CollectionArgsPost, excluded)
+	c.Cookies = withoutExcluded(r.Cookies, CollectionCookies, excluded)
+
+	raw := *r.Raw
+	raw.Headers = make(map[string]string, len(r.Raw.Headers))
+	for name, value := range r.Raw.Headers {
+		if !excluded(CollectionHeaders, textproto.CanonicalMIMEHeaderKey(name)) {
+			raw.Headers[name] = value
+		}
+	}
+	c.Raw = &raw
+	c.Headers = make(map[string]string, len(r.Headers))
+	for name, value := range r.Headers {
+		if !excluded(CollectionHeaders, name) {
+			c.Headers[name] = value
+		}
+	}
+	return &c
+}
+
+// HasExclusions сообщает, что запрос - копия с исключенными полями. url и тело в ней целиком содержат
+// и исключенные поля, поэтому проверять их как одну строку нельзя.
+func (r *Request) HasExclusions() bool {
+	return r.excluded != nil
+}
+
+func (r *Request) source() *Request {
+	if r.origin != nil {
+		return r.origin
+	}
+	return r
+}
+
+// skips возвращает проверку, которую переписывание применяет к полям исходного запроса.
+func (r *Request) skips(collection string) func(name string) bool {
+	return func(name string) bool {
+		return r.excluded != nil && r.excluded(collection, name)
+	}
+}
+
+func withoutExcluded(fields []Field, collection string, excluded Excluded) []Field {
+	kept := make([]Field, 0, len(fields))
+	for _, f := range fields {
+		if !excluded(collection, f.Name) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,187 @@
+package inspect
+
+import (
+	"mime"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/transform"
+)
+
+// процессоры тела запроса, значения совпадают с REQBODY_PROCESSOR в ModSecurity
+const (
+	ProcessorURLEncoded = "URLENCODED"
+	ProcessorMultipart  = "MULTIPART"
+	ProcessorJSON       = "JSON"
+	ProcessorXML        = "XML"
+)
+
+// ограничения разбора, чтобы тело запроса не могло заставить анализатор разворачивать бесконечную структуру
+const (
+	maxArgs  = 1024
+	maxDepth = 64
+)
+
+// Field - именованное значение части запроса.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// File - файл из multipart тела. содержимое файла не считается аргументом и не инспектируется как текст.
+type File struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Size        int
+}
+
+// Request - запрос, разобранный по content-type на именованные переменные.
+// строится один раз на запрос и используется всеми видами правил.
+type Request struct {
+	Raw *entity.Request
+
+	Path    string
+	Query   string
+	Headers map[string]string
+
+	ArgsGet     []Field
+	ArgsPost    []Field
+	Cookies     []Field
+	Files       []File
+	PartHeaders []Field
+	XML         []Field
+	// DTD - содержимое директивы DOCTYPE xml-тела. сущности из нее не разрешаются, но по ней видна xxe
+	DTD string
+
+	// Transforms запоминает результаты преобразований значений запроса, общие для всех правил
+	Transforms *transform.Cache
+
+	// Processor пустой, если тело не разбиралось: неизвестный content-type или пустое тело
+	Processor string
+	BodyError error
+
+	// origin - исходный запрос копии с исключенными полями, см. Exclude
+	origin   *Request
+	excluded Excluded
+}
+
+func Parse(request *entity.Request) *Request {
+	parsed, err := url.Parse(request.URL)
+	if err != nil {
+		parsed = &url.URL{Path: request.URL}
+	}
+
+	r := &Request{
+		Raw:        request,
+		Path:       parsed.Path,
+		Query:      parsed.RawQuery,
+		Headers:    make(map[string]string, len(request.Headers)),
+		Transforms: transform.NewCache(),
+	}
+	for name, value := range request.Headers {
+		r.Headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+
+	r.ArgsGet = parseURLEncoded(parsed.RawQuery)
+	r.Cookies = parseCookies(r.Headers["Cookie"])
+	r.parseBody()
+	return r
+}
+
+func (r *Request) parseBody() {
+	if r.Raw.Body == "" {
+		return
+	}
+
+	mediaType, params, err := mime.ParseMediaType(r.Headers["Content-Type"])
+	if err != nil {
+		return
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		r.Processor = ProcessorURLEncoded
+		r.ArgsPost = parseURLEncoded(r.Raw.Body)
+	case mediaType == "multipart/form-data":
+		r.Processor = ProcessorMultipart
+		r.BodyError = r.parseMultipart(params["boundary"])
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		r.Processor = ProcessorJSON
+		r.ArgsPost, r.BodyError = parseJSON(r.Raw.Body)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		r.Processor = ProcessorXML
+		r.XML, r.DTD, r.BodyError = parseXML(r.Raw.Body)
+	}
+}
+
+// Args возвращает query и body аргументы в порядке ARGS из ModSecurity.
+func (r *Request) Args() []Field {
+	args := make([]Field, 0, len(r.ArgsGet)+len(r.ArgsPost))
+	args = append(args, r.ArgsGet...)
+	return append(args, r.ArgsPost...)
+}
+
+// Arg возвращает первое значение аргумента с данным именем: сначала из query, затем из тела.
+func (r *Request) Arg(name string) (string, bool) {
+	return lookup(r.Args(), name)
+}
+
+func (r *Request) Cookie(name string) (string, bool) {
+	return lookup(r.Cookies, name)
+}
+
+// Header ищет заголовок без учета регистра.
+func (r *Request) Header(name string) (string, bool) {
+	value, ok := r.Headers[textproto.CanonicalMIMEHeaderKey(name)]
+	return value, ok
+}
+
+// BodyInspected сообщает, разобрано ли тело целиком на переменные. если нет, правила должны смотреть сырое тело.
+func (r *Request) BodyInspected() bool {
+	return r.Processor != "" && r.BodyError == nil
+}
+
+func lookup(fields []Field, name string) (string, bool) {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+func parseURLEncoded(raw string) []Field {
+	var fields []Field
+	for _, pair := range strings.FieldsFunc(raw, func(r rune) bool { return r == '&' || r == ';' }) {
+		if len(fields) == maxArgs {
+			break
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		fields = append(fields, Field{Name: unescape(name), Value: unescape(value)})
+	}
+	return fields
+}
+
+func parseCookies(raw string) []Field {
+	var fields []Field
+	for _, part := range strings.Split(raw, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		fields = append(fields, Field{Name: strings.TrimSpace(name), Value: strings.Trim(strings.TrimSpace(value), `"`)})
+	}
+	return fields
+}
+
+func unescape(raw string) string {
+	decoded, err := url.QueryUnescape(raw)
+	if err != nil {
+		return raw
+	}
+	return decoded
+}
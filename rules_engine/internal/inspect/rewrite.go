@@ -0,0 +1,132 @@
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RewriteURL применяет fn к именам и значениям query аргументов и собирает url обратно с url-кодированием,
+// чтобы результат fn не мог выйти за пределы своего аргумента. путь не меняется.
+func (r *Request) RewriteURL(fn func(string) string) string {
+	encoded, changed := rewriteURLEncoded(r.source().ArgsGet, fn, r.skips(CollectionArgsGet))
+	if !changed {
+		return r.Raw.URL
+	}
+	path, _, _ := strings.Cut(r.Raw.URL, "?")
+	return path + "?" + encoded
+}
+
+// RewriteBody применяет fn к значениям тела с учетом его формата: аргументы формы кодируются обратно
+// как форма, строки json - как строки json. неразобранное тело передается в fn целиком,
+// а multipart и xml не переписываются: правку в них нельзя сделать, не сломав структуру.
+func (r *Request) RewriteBody(fn func(string) string) string {
+	body := r.Raw.Body
+	if !r.BodyInspected() {
+		return fn(body)
+	}
+
+	switch r.Processor {
+	case ProcessorURLEncoded:
+		if encoded, changed := rewriteURLEncoded(r.source().ArgsPost, fn, r.skips(CollectionArgsPost)); changed {
+			return encoded
+		}
+	case ProcessorJSON:
+		if rewritten, err := rewriteJSON(body, fn, r.skips(CollectionArgsPost)); err == nil {
+			return rewritten
+		}
+	}
+	return body
+}
+
+// rewriteURLEncoded не трогает поля, для которых skip вернул true.
+func rewriteURLEncoded(fields []Field, fn func(string) string, skip func(name string) bool) (string, bool) {
+	changed := false
+	pairs := make([]string, len(fields))
+	for i, f := range fields {
+		name, value := f.Name, f.Value
+		if !skip(f.Name) {
+			name, value = fn(f.Name), fn(f.Value)
+		}
+		if name != f.Name || value != f.Value {
+			changed = true
+		}
+		pairs[i] = url.QueryEscape(name) + "=" + url.QueryEscape(value)
+	}
+	return strings.Join(pairs, "&"), changed
+}
+
+// rewriteJSON переписывает строки документа, сохраняя порядок ключей. encoding/json экранирует <, > и &
+// как \u003c, поэтому строка остается безопасной и при вставке json в html. skip получает имена
+// аргументов в том виде, в каком их строит parseJSON.
+func rewriteJSON(body string, fn func(string) string, skip func(name string) bool) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+
+	var b strings.Builder
+	if err := rewriteJSONValue(dec, &b, fn, skip, "json"); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func rewriteJSONValue(dec *json.Decoder, b *strings.Builder, fn func(string) string, skip func(name string) bool, name string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		b.WriteRune(rune(v))
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			child := name + "." + strconv.Itoa(i)
+			if v == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = name + "." + key.(string)
+				if skip(child) {
+					writeJSONString(b, key.(string))
+				} else {
+					writeJSONString(b, fn(key.(string)))
+				}
+				b.WriteByte(':')
+			}
+			if err := rewriteJSONValue(dec, b, fn, skip, child); err != nil {
+				return err
+			}
+		}
+		closing, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		b.WriteRune(rune(closing.(json.Delim)))
+	case string:
+		if skip(name) {
+			writeJSONString(b, v)
+		} else {
+			writeJSONString(b, fn(v))
+		}
+	case json.Number:
+		b.WriteString(v.String())
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case nil:
+		b.WriteString("null")
+	default:
+		return fmt.Errorf("unexpected json token %v", tok)
+	}
+	return nil
+}
+
+func writeJSONString(b *strings.Builder, s string) {
+	encoded, _ := json.Marshal(s)
+	b.Write(encoded)
+}
@@ -0,0 +1,49 @@
+// Package ldap ищет инъекцию в фильтр LDAP: значение закрывает свой компонент фильтра и добавляет новый,
+// как в admin)(|(password=*) или *)(uid=*))(|(uid=*.
+//
+// одиночная * не считается атакой: это обычный поиск по шаблону в поле поиска.
+package ldap
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// breakout - закрытие компонента и начало нового: )(uid=, )(|(cn=, *)(
+	breakout = regexp.MustCompile(`\)\s*\(\s*[|&!]?\s*\(?\s*[\w.;-]+\s*[~<>]?=|\*\s*\)\s*\(|\)\s*\(\s*[|&]\s*\)`)
+	// compound - составной фильтр внутри значения: (|(uid=*)(uid=admin))
+	compound = regexp.MustCompile(`\(\s*[|&!]\s*\(\s*[\w.;-]+\s*[~<>]?=`)
+)
+
+// Detect сообщает, выходит ли значение за пределы своего компонента фильтра LDAP, и возвращает найденное.
+func Detect(input string) (string, bool) {
+	if !strings.ContainsAny(input, "()") {
+		return "", false
+	}
+	if m := breakout.FindString(input); m != "" {
+		return "filter breakout " + m, true
+	}
+	if m := compound.FindString(input); m != "" {
+		return "nested filter " + m, true
+	}
+	if strings.Contains(input, ")\x00") {
+		return "null byte after filter", true
+	}
+	return "", false
+}
+
+var (
+	metaRemover = strings.NewReplacer("*", "", "(", "", ")", "", `\`, "", "\x00", "")
+	metaEscaper = strings.NewReplacer(`\`, `\5c`, "*", `\2a`, "(", `\28`, ")", `\29`, "\x00", `\00`)
+)
+
+// Sanitize убирает из значения метасимволы фильтра.
+func Sanitize(input string) string {
+	return metaRemover.Replace(input)
+}
+
+// Escape экранирует метасимволы фильтра по RFC 4515: значение сравнивается буквально.
+func Escape(input string) string {
+	return metaEscaper.Replace(input)
+}
@@ -0,0 +1,54 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+)
+
+var breakouts = []string{
+	"admin)(|(password=*)",
+	"*)(uid=*))(|(uid=*",
+	"admin)(&)",
+	"x)(cn=admin",
+	"(|(uid=*)(uid=admin))",
+	"(&(objectClass=*)(uid=admin))",
+	"admin)\x00",
+}
+
+func TestDetect(t *testing.T) {
+	for _, value := range breakouts {
+		if _, ok := Detect(value); !ok {
+			t.Errorf("Detect(%q) missed the filter injection", value)
+		}
+	}
+
+	// скобки и * в обычном тексте и поиске по шаблону
+	for _, value := range []string{"admin", "*", "jo*n", "John Smith (sales)", "f(x) = (a + b)", ""} {
+		if detail, ok := Detect(value); ok {
+			t.Errorf("Detect(%q) = %q on a benign value", value, detail)
+		}
+	}
+}
+
+// значение после Escape или Sanitize подставляется в фильтр и остается внутри своего компонента
+func TestNeutralizedValueStaysInFilter(t *testing.T) {
+	for _, value := range breakouts {
+		for name, neutralize := range map[string]func(string) string{"Escape": Escape, "Sanitize": Sanitize} {
+			safe := neutralize(value)
+			if detail, ok := Detect(safe); ok {
+				t.Errorf("%s(%q) = %q is still detected: %s", name, value, safe, detail)
+			}
+			filter := "(&(uid=" + safe + ")(objectClass=person))"
+			if strings.Count(filter, "(") != 3 || strings.Count(filter, ")") != 3 || strings.ContainsRune(filter, 0) {
+				t.Errorf("%s(%q) breaks the filter %q", name, value, filter)
+			}
+		}
+	}
+
+	if got := Escape(`a\b*`); got != `a\5cb\2a` {
+		t.Errorf(`Escape escapes the backslash first, got %q`, got)
+	}
+	if got := Sanitize("John Smith"); got != "John Smith" {
+		t.Errorf("Sanitize changed a plain value: %q", got)
+	}
+}
@@ -0,0 +1,53 @@
+// Package nosql ищет инъекцию операторов MongoDB: {"password": {"$ne": null}} в json, password[$ne]= в форме
+// и javascript, которым значение выходит из строки в $where.
+//
+// оператор в json-теле виден по имени аргумента (json.password.$ne), а не по значению, поэтому детектор
+// проверяет и имена, и значения, в которых json передан строкой.
+package nosql
+
+import "regexp"
+
+// operators - операторы запросов и агрегации, через которые значение меняет смысл условия
+const operators = `ne|eq|gt|gte|lt|lte|in|nin|regex|where|exists|expr|function|accumulator|or|and|not|nor|` +
+	`elemMatch|all|size|type|mod|text|jsonSchema|lookup|merge|out|set|unset|rename|inc|push`
+
+var (
+	// operatorKey - оператор сегментом имени аргумента: json.user.$ne, user[$ne] или сам ключ $ne
+	operatorKey = regexp.MustCompile(`(?:^|[.\[])\$(?:` + operators + `)(?:$|[.\]])`)
+	// operatorObject - json с оператором внутри строкового значения
+	operatorObject = regexp.MustCompile(`\{\s*["']?\$(?:` + operators + `)["']?\s*:`)
+	// whereBreakout - выход из строки в javascript-условии $where: ' || '1'=='1, '; return true; var a='
+	whereBreakout = regexp.MustCompile(`['"]\s*(?:\|\||&&)\s*(?:['"]?\w*['"]?\s*===?|this\.)|;\s*(?:return\s+(?:true|1|this)\b|while\s*\(\s*(?:true|1)\s*\)|sleep\s*\(\s*\d+)|\bdb\.\w+\.(?:find|drop|insert|remove|update)\w*\s*\(`)
+	// operatorPrefix - $ оператора, который убирает Sanitize
+	operatorPrefix = regexp.MustCompile(`(^|[.\[{"'\s])\$(` + operators + `)\b`)
+)
+
+// Detect сообщает, есть ли в имени или значении оператор MongoDB или выход из строки в $where,
+// и возвращает найденное.
+func Detect(input string) (string, bool) {
+	if m := operatorKey.FindString(input); m != "" {
+		return "operator " + trimDelims(m), true
+	}
+	if m := operatorObject.FindString(input); m != "" {
+		return "operator object " + m, true
+	}
+	if m := whereBreakout.FindString(input); m != "" {
+		return "javascript " + m, true
+	}
+	return "", false
+}
+
+// Sanitize убирает $ перед операторами: {"$ne": null} становится обычным полем {"ne": null}.
+func Sanitize(input string) string {
+	return operatorPrefix.ReplaceAllString(input, "${1}${2}")
+}
+
+func trimDelims(s string) string {
+	for len(s) > 0 && (s[0] == '.' || s[0] == '[') {
+		s = s[1:]
+	}
+	for len(s) > 0 && (s[len(s)-1] == '.' || s[len(s)-1] == ']') {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package nosql
+
+import (
+	"strings"
+	"testing"
+)
+
+// в json-теле оператор приходит именем аргумента, в форме - индексом в квадратных скобках
+func TestDetectOperatorInName(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+	}{
+		{"json.password.$ne", "$ne"},
+		{"json.filter.0.$where", "$where"},
+		{"json.$or.0.role", "$or"},
+		{"password[$ne]", "$ne"},
+		{"user[$regex]", "$regex"},
+		{"$gt", "$gt"},
+	}
+
+	for _, tt := range tests {
+		detail, ok := Detect(tt.name)
+		if !ok || detail != "operator "+tt.operator {
+			t.Errorf("Detect(%q) = %q, %v; want operator %s", tt.name, detail, ok, tt.operator)
+		}
+	}
+
+	for _, name := range []string{"json.password", "json.price.$", "json.items.0.name", "$nevermind", "user[ne]"} {
+		if detail, ok := Detect(name); ok {
+			t.Errorf("Detect(%q) reported %q for an ordinary argument name", name, detail)
+		}
+	}
+}
+
+func TestDetectValue(t *testing.T) {
+	attacks := []string{
+		// json передан строкой внутри значения
+		`{"$gt": ""}`,
+		`{ '$ne' : null }`,
+		// выход из строки в $where
+		`' || '1'=='1`,
+		`" && this.password.match(/^a/)//`,
+		`'; return true; var a='`,
+		`'; while(true){}`,
+		`'; sleep(5000); var x='`,
+		`db.users.drop()`,
+	}
+	benign := []string{
+		"costs $10 or more",
+		`{"amount": "$5"}`,
+		"rock || roll",
+		"return true if the user exists",
+		"admin",
+		"",
+	}
+
+	for _, value := range attacks {
+		if _, ok := Detect(value); !ok {
+			t.Errorf("Detect(%q) missed the injection", value)
+		}
+	}
+	for _, value := range benign {
+		if detail, ok := Detect(value); ok {
+			t.Errorf("Detect(%q) = %q on a benign value", value, detail)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		`{"$ne": null}`:     `{"ne": null}`,
+		"password[$ne]":     "password[ne]",
+		"json.user.$regex":  "json.user.regex",
+		"costs $10":         "costs $10",
+		"$nevermind, $ne=1": "$nevermind, ne=1",
+	}
+
+	for input, want := range tests {
+		got := Sanitize(input)
+		if got != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", input, got, want)
+		}
+		if strings.Contains(input, "$ne") || strings.Contains(input, "$regex") {
+			if detail, ok := Detect(got); ok {
+				t.Errorf("Sanitize(%q) = %q is still detected: %s", input, got, detail)
+			}
+		}
+	}
+}
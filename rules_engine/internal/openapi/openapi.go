@@ -0,0 +1,443 @@
+// Package openapi строит по документу OpenAPI 3 позитивную модель api: какие пути и методы объявлены,
+// какие у них параметры пути, query, заголовков и cookie и какая схема у json-тела. запрос, который
+// не укладывается в модель, отклоняется с указанием нарушения.
+//
+// поддерживаются документы 3.0 и 3.1 в json и yaml со ссылками $ref внутри документа. внешние ссылки
+// не загружаются: документ должен быть самодостаточным.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// методы, которые может объявлять path item
+var methods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+var (
+	pathParam  = regexp.MustCompile(`\{([^{}/]+)\}`)
+	wholeParam = regexp.MustCompile(`^\{[^{}/]+\}$`)
+)
+
+// Spec - разобранный документ, готовый к проверке запросов. после Parse не меняется и безопасен
+// для одновременного использования.
+type Spec struct {
+	Title   string
+	Version string
+
+	basePaths []string
+	paths     []*path
+}
+
+// Operation - объявленная пара метода и пути. Pattern записан в синтаксисе url ресурса, см. router.ParsePattern.
+type Operation struct {
+	ID      string
+	Method  string
+	Path    string
+	Pattern string
+}
+
+type path struct {
+	template   string
+	regex      *regexp.Regexp
+	names      []string
+	operations map[string]*operation
+}
+
+type operation struct {
+	id         string
+	parameters []*Parameter
+	body       *RequestBody
+}
+
+type document struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas       map[string]*Schema      `json:"schemas"`
+		Parameters    map[string]*Parameter   `json:"parameters"`
+		RequestBodies map[string]*RequestBody `json:"requestBodies"`
+	} `json:"components"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Style    string  `json:"style"`
+	Explode  *bool   `json:"explode"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Ref      string                `json:"$ref"`
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type rawOperation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// Parse разбирает документ в json или yaml и проверяет, что все ссылки разрешаются, а шаблоны путей и
+// регулярные выражения схем компилируются.
+func Parse(content string) (*Spec, error) {
+	raw, err := toJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc document
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("invalid openapi document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version %q, expected 3.x", doc.OpenAPI)
+	}
+	if len(doc.Paths) == 0 {
+		return nil, fmt.Errorf("openapi document declares no paths")
+	}
+
+	r := &resolver{doc: &doc, prepared: make(map[*Schema]bool)}
+	spec := &Spec{Title: doc.Info.Title, Version: doc.Info.Version}
+	for _, server := range doc.Servers {
+		spec.basePaths = append(spec.basePaths, basePath(server.URL))
+	}
+
+	for template, item := range doc.Paths {
+		p, err := r.path(template, item)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", template, err)
+		}
+		spec.paths = append(spec.paths, p)
+	}
+
+	// конкретные пути проверяются раньше шаблонных: /users/me раньше /users/{id}
+	sort.Slice(spec.paths, func(i, j int) bool {
+		a, b := spec.paths[i], spec.paths[j]
+		if len(a.names) != len(b.names) {
+			return len(a.names) < len(b.names)
+		}
+		return a.template < b.template
+	})
+	return spec, nil
+}
+
+// Operations перечисляет объявленные операции в порядке проверки путей. базовый путь первого сервера
+// входит в Pattern.
+func (s *Spec) Operations() []Operation {
+	base := ""
+	if len(s.basePaths) > 0 {
+		base = s.basePaths[0]
+	}
+
+	var operations []Operation
+	for _, p := range s.paths {
+		for _, method := range methods {
+			op, ok := p.operations[method]
+			if !ok {
+				continue
+			}
+			operations = append(operations, Operation{
+				ID:      op.id,
+				Method:  method,
+				Path:    p.template,
+				Pattern: resourcePattern(base + p.template),
+			})
+		}
+	}
+	return operations
+}
+
+// resourcePattern оставляет шаблон как есть, если каждый параметр занимает целый сегмент, иначе
+// переводит его в регулярное выражение.
+func resourcePattern(template string) string {
+	for _, segment := range strings.Split(template, "/") {
+		if strings.ContainsAny(segment, "{}") && !wholeParam.MatchString(segment) {
+			return "~" + templateRegex(template)
+		}
+	}
+	return template
+}
+
+func templateRegex(template string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range pathParam.FindAllStringIndex(template, -1) {
+		b.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		b.WriteString(`([^/]+)`)
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(template[last:]))
+	return b.String()
+}
+
+// basePath возвращает путь url сервера без завершающего /. переменные сервера в пути не поддерживаются
+// и дают пустой базовый путь.
+func basePath(server string) string {
+	u, err := url.Parse(server)
+	if err != nil || strings.Contains(u.Path, "{") {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// toJSON переводит yaml в json, чтобы дальше разбирать документ одним способом. json - подмножество
+// yaml, но разбирается напрямую, чтобы сохранить точные числа.
+func toJSON(content string) ([]byte, error) {
+	trimmed := bytes.TrimSpace([]byte(content))
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if !json.Valid(trimmed) {
+			return nil, fmt.Errorf("invalid openapi document: malformed json")
+		}
+		return trimmed, nil
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(trimmed, &doc); err != nil {
+		return nil, fmt.Errorf("invalid openapi document: %w", err)
+	}
+	return json.Marshal(jsonCompatible(doc))
+}
+
+// jsonCompatible заменяет map[any]any, которые yaml строит для нестроковых ключей (коды ответов 200:),
+// на map[string]any.
+func jsonCompatible(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = jsonCompatible(value)
+		}
+		return v
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, value := range v {
+			converted[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return converted
+	case []any:
+		for i, value := range v {
+			v[i] = jsonCompatible(value)
+		}
+		return v
+	}
+	return v
+}
+
+type resolver struct {
+	doc      *document
+	prepared map[*Schema]bool
+}
+
+func (r *resolver) path(template string, item map[string]json.RawMessage) (*path, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("path must start with /")
+	}
+	if _, ok := item["$ref"]; ok {
+		return nil, fmt.Errorf("path item references are not supported")
+	}
+
+	p := &path{
+		template:   template,
+		regex:      regexp.MustCompile("^" + templateRegex(template) + "$"),
+		operations: make(map[string]*operation),
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(template, -1) {
+		p.names = append(p.names, match[1])
+	}
+
+	var shared []*Parameter
+	if raw, ok := item["parameters"]; ok {
+		if err := json.Unmarshal(raw, &shared); err != nil {
+			return nil, fmt.Errorf("invalid parameters: %w", err)
+		}
+	}
+
+	for key, raw := range item {
+		method := strings.ToUpper(key)
+		if !slices.Contains(methods, method) {
+			continue
+		}
+		var rawOp rawOperation
+		if err := json.Unmarshal(raw, &rawOp); err != nil {
+			return nil, fmt.Errorf("invalid %s operation: %w", method, err)
+		}
+		op, err := r.operation(p, shared, rawOp)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		p.operations[method] = op
+	}
+	return p, nil
+}
+
+// operation объединяет параметры пути и операции: параметр операции заменяет одноименный параметр пути.
+func (r *resolver) operation(p *path, shared []*Parameter, raw rawOperation) (*operation, error) {
+	op := &operation{id: raw.OperationID}
+
+	byKey := make(map[string]int)
+	for _, list := range [][]*Parameter{shared, raw.Parameters} {
+		for _, param := range list {
+			resolved, err := r.parameter(param)
+			if err != nil {
+				return nil, err
+			}
+			key := resolved.In + " " + resolved.Name
+			if resolved.In == "header" {
+				key = resolved.In + " " + strings.ToLower(resolved.Name)
+			}
+			if i, ok := byKey[key]; ok {
+				op.parameters[i] = resolved
+				continue
+			}
+			byKey[key] = len(op.parameters)
+			op.parameters = append(op.parameters, resolved)
+		}
+	}
+
+	for _, name := range p.names {
+		if _, ok := byKey["path "+name]; !ok {
+			return nil, fmt.Errorf("path parameter %q is not declared", name)
+		}
+	}
+
+	if raw.RequestBody != nil {
+		body, err := r.requestBody(raw.RequestBody)
+		if err != nil {
+			return nil, err
+		}
+		op.body = body
+	}
+	return op, nil
+}
+
+func (r *resolver) parameter(param *Parameter) (*Parameter, error) {
+	if param == nil {
+		return nil, fmt.Errorf("empty parameter")
+	}
+	if param.Ref != "" {
+		name, err := componentName(param.Ref, "parameters")
+		if err != nil {
+			return nil, err
+		}
+		target, ok := r.doc.Components.Parameters[name]
+		if !ok || target.Ref != "" {
+			return nil, fmt.Errorf("unresolved reference %q", param.Ref)
+		}
+		param = target
+	}
+
+	switch param.In {
+	case "path", "query", "header", "cookie":
+	default:
+		return nil, fmt.Errorf("parameter %q has unknown location %q", param.Name, param.In)
+	}
+	if param.Name == "" {
+		return nil, fmt.Errorf("parameter without name in %s", param.In)
+	}
+	if err := r.schema(param.Schema); err != nil {
+		return nil, fmt.Errorf("parameter %q: %w", param.Name, err)
+	}
+	return param, nil
+}
+
+func (r *resolver) requestBody(body *RequestBody) (*RequestBody, error) {
+	if body.Ref != "" {
+		name, err := componentName(body.Ref, "requestBodies")
+		if err != nil {
+			return nil, err
+		}
+		target, ok := r.doc.Components.RequestBodies[name]
+		if !ok || target.Ref != "" {
+			return nil, fmt.Errorf("unresolved reference %q", body.Ref)
+		}
+		body = target
+	}
+
+	for mediaType, media := range body.Content {
+		if media == nil {
+			continue
+		}
+		if err := r.schema(media.Schema); err != nil {
+			return nil, fmt.Errorf("request body %s: %w", mediaType, err)
+		}
+	}
+	return body, nil
+}
+
+// schema разрешает ссылки и готовит схему к проверке. общие компоненты готовятся один раз, поэтому
+// рекурсивные схемы не зацикливают разбор.
+func (r *resolver) schema(s *Schema) error {
+	if s == nil || r.prepared[s] {
+		return nil
+	}
+	r.prepared[s] = true
+
+	if s.Ref != "" {
+		name, err := componentName(s.Ref, "schemas")
+		if err != nil {
+			return err
+		}
+		target, ok := r.doc.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("unresolved reference %q", s.Ref)
+		}
+		s.ref = target
+		if err := r.schema(target); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if err := s.prepare(); err != nil {
+		return err
+	}
+
+	children := append([]*Schema{s.Items, s.Not, s.additional}, s.AllOf...)
+	children = append(children, s.AnyOf...)
+	children = append(children, s.OneOf...)
+	for _, property := range s.Properties {
+		children = append(children, property)
+	}
+	for _, child := range children {
+		if err := r.schema(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// componentName принимает только ссылки внутри документа вида #/components/<kind>/<name>.
+func componentName(ref, kind string) (string, error) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		if !strings.HasPrefix(ref, "#") {
+			return "", fmt.Errorf("external reference %q is not supported", ref)
+		}
+		return "", fmt.Errorf("reference %q must point to components/%s", ref, kind)
+	}
+	// экранирование json pointer: ~1 - это /, ~0 - это ~
+	name := strings.TrimPrefix(ref, prefix)
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(name), nil
+}
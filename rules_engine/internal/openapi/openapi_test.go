@@ -0,0 +1,279 @@
+package openapi
+
+import (
+	"strings"
+	"testing"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/inspect"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info:
+  title: shop
+  version: "1.0"
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users/me:
+    get: {operationId: me}
+  /users/{id}:
+    parameters:
+      - $ref: '#/components/parameters/UserID'
+    get:
+      operationId: getUser
+      parameters:
+        - {name: fields, in: query, schema: {type: array, items: {type: string, enum: [name, email]}}, explode: false}
+        - {name: X-Request-Id, in: header, required: true, schema: {type: string, format: uuid}}
+    put:
+      operationId: updateUser
+      requestBody:
+        $ref: '#/components/requestBodies/User'
+  /orders:
+    get:
+      operationId: listOrders
+      parameters:
+        - {name: limit, in: query, schema: {type: integer, minimum: 1, maximum: 100}}
+        - {name: filter, in: query, style: deepObject, schema: {type: object, properties: {status: {type: string}}}}
+        - {name: session, in: cookie, required: true, schema: {type: string, minLength: 8}}
+    post:
+      operationId: createOrder
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Order'}
+          application/x-www-form-urlencoded:
+            schema:
+              type: object
+              properties:
+                qty: {type: integer}
+components:
+  parameters:
+    UserID: {name: id, in: path, required: true, schema: {type: integer}}
+  requestBodies:
+    User:
+      content:
+        application/json:
+          schema: {$ref: '#/components/schemas/User'}
+  schemas:
+    User:
+      type: object
+      additionalProperties: false
+      required: [name, id]
+      properties:
+        id: {type: integer, readOnly: true}
+        name: {type: string, maxLength: 5}
+        manager: {$ref: '#/components/schemas/User'}
+    Order:
+      type: object
+      required: [items]
+      additionalProperties: {type: string}
+      properties:
+        items:
+          type: array
+          minItems: 1
+          items: {$ref: '#/components/schemas/Item'}
+    Item:
+      type: object
+      additionalProperties: false
+      properties:
+        sku: {type: string, pattern: '^[A-Z]{3}$'}
+        qty: {type: integer, minimum: 1}
+`
+
+func TestValidate(t *testing.T) {
+	spec, err := Parse(testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const requestID = "6f1c1e8e-3c4b-4a57-9a55-2f4f5f8b1c2d"
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		headers  map[string]string
+		body     string
+		variable string
+		reason   string
+	}{
+		{name: "concrete path before template", method: "GET", url: "/v1/users/me"},
+		{name: "path outside base path", method: "GET", url: "/users/me",
+			variable: "REQUEST_FILENAME", reason: "path /users/me is not declared"},
+		{name: "undeclared path", method: "GET", url: "/v1/admin",
+			variable: "REQUEST_FILENAME", reason: "path /v1/admin is not declared"},
+		{name: "undeclared method", method: "DELETE", url: "/v1/users/me",
+			variable: "REQUEST_METHOD", reason: "method DELETE is not declared for /users/me"},
+		{name: "path parameter from $ref", method: "GET", url: "/v1/users/7",
+			headers: map[string]string{"X-Request-Id": requestID}},
+		{name: "path parameter type", method: "GET", url: "/v1/users/abc",
+			headers:  map[string]string{"X-Request-Id": requestID},
+			variable: "REQUEST_FILENAME", reason: `path parameter "id"`},
+		{name: "required header", method: "GET", url: "/v1/users/7",
+			variable: "REQUEST_HEADERS:X-Request-Id", reason: `header "X-Request-Id" is required`},
+		{name: "header format", method: "GET", url: "/v1/users/7",
+			headers:  map[string]string{"X-Request-Id": "42"},
+			variable: "REQUEST_HEADERS:X-Request-Id", reason: "uuid"},
+		{name: "comma separated array", method: "GET", url: "/v1/users/7?fields=name,email",
+			headers: map[string]string{"X-Request-Id": requestID}},
+		{name: "array item enum", method: "GET", url: "/v1/users/7?fields=name,password",
+			headers:  map[string]string{"X-Request-Id": requestID},
+			variable: "ARGS_GET:fields", reason: "password"},
+		{name: "query and cookie", method: "GET", url: "/v1/orders?limit=10&filter[status]=new",
+			headers: map[string]string{"Cookie": "session=abcdefgh"}},
+		{name: "query maximum", method: "GET", url: "/v1/orders?limit=1000",
+			headers:  map[string]string{"Cookie": "session=abcdefgh"},
+			variable: "ARGS_GET:limit", reason: "maximum"},
+		{name: "query type", method: "GET", url: "/v1/orders?limit=ten",
+			headers:  map[string]string{"Cookie": "session=abcdefgh"},
+			variable: "ARGS_GET:limit", reason: `query parameter "limit"`},
+		{name: "repeated scalar query", method: "GET", url: "/v1/orders?limit=1&limit=2",
+			headers:  map[string]string{"Cookie": "session=abcdefgh"},
+			variable: "ARGS_GET:limit", reason: "must not be repeated"},
+		{name: "undeclared query", method: "GET", url: "/v1/orders?debug=1",
+			headers:  map[string]string{"Cookie": "session=abcdefgh"},
+			variable: "ARGS_GET:debug", reason: `query parameter "debug" is not declared`},
+		{name: "missing cookie", method: "GET", url: "/v1/orders",
+			variable: "REQUEST_COOKIES:session", reason: `cookie "session" is required`},
+		{name: "short cookie", method: "GET", url: "/v1/orders",
+			headers:  map[string]string{"Cookie": "session=abc"},
+			variable: "REQUEST_COOKIES:session", reason: "minLength"},
+		{name: "json body", method: "POST", url: "/v1/orders",
+			headers: map[string]string{"Content-Type": "application/json"},
+			body:    `{"items": [{"sku": "ABC", "qty": 2}], "note": "leave at door"}`},
+		{name: "required body", method: "POST", url: "/v1/orders",
+			variable: "REQUEST_BODY", reason: "request body is required"},
+		{name: "undeclared content type", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "text/plain"},
+			body:     "items",
+			variable: "REQUEST_HEADERS:Content-Type", reason: `content type "text/plain" is not allowed`},
+		{name: "malformed json", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{"items": [`,
+			variable: "REQUEST_BODY", reason: "not valid json"},
+		{name: "trailing json", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{"items": [{}]} {}`,
+			variable: "REQUEST_BODY", reason: "data after the json value"},
+		{name: "missing required property", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{}`,
+			variable: "REQUEST_BODY", reason: `required property "items" is missing`},
+		{name: "nested $ref pattern", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{"items": [{"sku": "abc"}]}`,
+			variable: "ARGS_POST:json.items.0.sku", reason: "pattern"},
+		{name: "additionalProperties false", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{"items": [{"sku": "ABC", "price": 0}]}`,
+			variable: "ARGS_POST:json.items.0.price", reason: `property "price" is not allowed`},
+		{name: "additionalProperties schema", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{"items": [{"qty": 1}], "note": 5}`,
+			variable: "ARGS_POST:json.note", reason: "string"},
+		{name: "form body", method: "POST", url: "/v1/orders",
+			headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:    "qty=3"},
+		{name: "form body type", method: "POST", url: "/v1/orders",
+			headers:  map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			body:     "qty=three",
+			variable: "ARGS_POST:qty", reason: "integer"},
+		{name: "undeclared body", method: "GET", url: "/v1/users/me",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{}`,
+			variable: "REQUEST_BODY", reason: "request body is not declared"},
+		{name: "requestBody $ref and readOnly", method: "PUT", url: "/v1/users/7",
+			headers: map[string]string{"Content-Type": "application/json"},
+			body:    `{"name": "ann", "manager": {"name": "bob"}}`},
+		{name: "recursive $ref", method: "PUT", url: "/v1/users/7",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{"name": "ann", "manager": {"name": "bob", "manager": {"name": "charlie"}}}`,
+			variable: "ARGS_POST:json.manager.manager.name", reason: "maxLength"},
+		{name: "additionalProperties false through $ref", method: "PUT", url: "/v1/users/7",
+			headers:  map[string]string{"Content-Type": "application/json"},
+			body:     `{"name": "ann", "role": "admin"}`,
+			variable: "ARGS_POST:json.role", reason: `property "role" is not allowed`},
+	}
+
+	for _, tt := range tests {
+		headers := tt.headers
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		r := inspect.Parse(&entity.Request{Method: tt.method, URL: tt.url, Headers: headers, Body: tt.body})
+
+		v := spec.Validate(r)
+		switch {
+		case v == nil && tt.variable != "":
+			t.Errorf("%s: expected violation in %s, got none", tt.name, tt.variable)
+		case v != nil && tt.variable == "":
+			t.Errorf("%s: unexpected violation in %s: %s", tt.name, v.Variable, v.Reason)
+		case v != nil && (v.Variable != tt.variable || !strings.Contains(v.Reason, tt.reason)):
+			t.Errorf("%s: expected violation in %s containing %q, got %s: %s", tt.name, tt.variable, tt.reason, v.Variable, v.Reason)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{"swagger 2", `{"swagger": "2.0", "paths": {"/a": {}}}`, "unsupported openapi version"},
+		{"no paths", `{"openapi": "3.1.0", "paths": {}}`, "declares no paths"},
+		{"malformed json", `{"openapi": "3.1.0",`, "malformed json"},
+		{"undeclared path parameter", `{"openapi": "3.0.0", "paths": {"/a/{id}": {"get": {}}}}`,
+			`path parameter "id" is not declared`},
+		{"unresolved schema $ref", `{"openapi": "3.0.0", "paths": {"/a": {"post": {"requestBody": {"content": {
+			"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}}}}}}}`,
+			`unresolved reference "#/components/schemas/Missing"`},
+		{"external $ref", `{"openapi": "3.0.0", "paths": {"/a": {"get": {"parameters": [
+			{"$ref": "common.yaml#/components/parameters/Limit"}]}}}}`,
+			"external reference"},
+		{"$ref to wrong component", `{"openapi": "3.0.0", "paths": {"/a": {"get": {"parameters": [
+			{"$ref": "#/components/schemas/Limit"}]}}}}`,
+			"must point to components/parameters"},
+		{"unknown parameter location", `{"openapi": "3.0.0", "paths": {"/a": {"get": {"parameters": [
+			{"name": "x", "in": "body"}]}}}}`,
+			`unknown location "body"`},
+		{"invalid pattern", `{"openapi": "3.0.0", "paths": {"/a": {"get": {"parameters": [
+			{"name": "x", "in": "query", "schema": {"type": "string", "pattern": "("}}]}}}}`,
+			`parameter "x"`},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.doc)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestOperations(t *testing.T) {
+	spec, err := Parse(testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, op := range spec.Operations() {
+		got = append(got, op.Method+" "+op.Pattern+" "+op.ID)
+	}
+	want := []string{
+		"GET /v1/orders listOrders",
+		"POST /v1/orders createOrder",
+		"GET /v1/users/me me",
+		"GET /v1/users/{id} getUser",
+		"PUT /v1/users/{id} updateUser",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected operations\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	if pattern := resourcePattern("/files/{name}.{ext}"); pattern != `~/files/([^/]+)\.([^/]+)` {
+		t.Errorf("unexpected pattern for partial segment parameters: %s", pattern)
+	}
+}
@@ -0,0 +1,437 @@
+package openapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxDepth ограничивает вложенность проверки: значения тела и ссылки схем друг на друга
+const maxDepth = 64
+
+var uuidFormat = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Schema - подмножество JSON Schema, которое OpenAPI использует для описания значений.
+// exclusiveMinimum и exclusiveMaximum принимаются и как флаги 3.0, и как числа 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 json.RawMessage    `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Nullable             bool               `json:"nullable"`
+	ReadOnly             bool               `json:"readOnly"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     json.RawMessage    `json:"exclusiveMinimum"`
+	ExclusiveMaximum     json.RawMessage    `json:"exclusiveMaximum"`
+	MultipleOf           *float64           `json:"multipleOf"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	UniqueItems          bool               `json:"uniqueItems"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	MinProperties        *int               `json:"minProperties"`
+	MaxProperties        *int               `json:"maxProperties"`
+	AllOf                []*Schema          `json:"allOf"`
+	AnyOf                []*Schema          `json:"anyOf"`
+	OneOf                []*Schema          `json:"oneOf"`
+	Not                  *Schema            `json:"not"`
+
+	ref          *Schema
+	types        []string
+	pattern      *regexp.Regexp
+	exclusiveMin *float64
+	exclusiveMax *float64
+	// closed запрещает свойства, не объявленные в properties; additional описывает их значения
+	closed     bool
+	additional *Schema
+}
+
+// schemaError - нарушение схемы значением по пути at. путь строится как имена аргументов в inspect:
+// json.user.tags.0.
+type schemaError struct {
+	at     string
+	reason string
+}
+
+func (s *Schema) prepare() error {
+	if len(s.Type) > 0 {
+		var single string
+		if err := json.Unmarshal(s.Type, &single); err == nil {
+			s.types = []string{single}
+		} else if err := json.Unmarshal(s.Type, &s.types); err != nil {
+			return fmt.Errorf("invalid schema type %s", s.Type)
+		}
+	}
+	if s.Nullable && len(s.types) > 0 {
+		s.types = append(s.types, "null")
+	}
+
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("unsupported pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+
+	var err error
+	if s.exclusiveMin, s.Minimum, err = exclusiveBound(s.ExclusiveMinimum, s.Minimum); err != nil {
+		return err
+	}
+	if s.exclusiveMax, s.Maximum, err = exclusiveBound(s.ExclusiveMaximum, s.Maximum); err != nil {
+		return err
+	}
+
+	switch strings.TrimSpace(string(s.AdditionalProperties)) {
+	case "", "true", "null":
+	case "false":
+		s.closed = true
+	default:
+		s.additional = &Schema{}
+		if err := json.Unmarshal(s.AdditionalProperties, s.additional); err != nil {
+			return fmt.Errorf("invalid additionalProperties: %w", err)
+		}
+	}
+	return nil
+}
+
+// exclusiveBound возвращает строгую границу и оставшуюся нестрогую. в 3.0 exclusiveMinimum: true
+// делает строгой саму minimum.
+func exclusiveBound(raw json.RawMessage, inclusive *float64) (*float64, *float64, error) {
+	switch strings.TrimSpace(string(raw)) {
+	case "", "false", "null":
+		return nil, inclusive, nil
+	case "true":
+		return inclusive, nil, nil
+	}
+	var bound float64
+	if err := json.Unmarshal(raw, &bound); err != nil {
+		return nil, nil, fmt.Errorf("invalid exclusive bound %s", raw)
+	}
+	return &bound, inclusive, nil
+}
+
+// validate проверяет значение, разобранное json.Decoder с UseNumber: числа приходят как json.Number.
+func (s *Schema) validate(v any, at string, depth int) *schemaError {
+	if s == nil {
+		return nil
+	}
+	if depth > maxDepth {
+		return &schemaError{at, "value is nested too deep"}
+	}
+	if s.ref != nil {
+		if err := s.ref.validate(v, at, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(v, t) }) {
+		return &schemaError{at, fmt.Sprintf("%s is not of type %s", describe(v), strings.Join(s.types, " or "))}
+	}
+	if v == nil {
+		// null допускается типом, ограничения значений к нему не относятся
+		return s.validateComposition(v, at, depth)
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return equal(e, v) }) {
+		return &schemaError{at, fmt.Sprintf("%s is not one of %s", describe(v), enumList(s.Enum))}
+	}
+
+	var err *schemaError
+	switch v := v.(type) {
+	case string:
+		err = s.validateString(v, at)
+	case json.Number:
+		err = s.validateNumber(v, at)
+	case []any:
+		err = s.validateArray(v, at, depth)
+	case map[string]any:
+		err = s.validateObject(v, at, depth)
+	}
+	if err != nil {
+		return err
+	}
+	return s.validateComposition(v, at, depth)
+}
+
+func (s *Schema) validateString(v, at string) *schemaError {
+	length := utf8.RuneCountInString(v)
+	if s.MinLength != nil && length < *s.MinLength {
+		return &schemaError{at, fmt.Sprintf("length %d is less than minLength %d", length, *s.MinLength)}
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return &schemaError{at, fmt.Sprintf("length %d is greater than maxLength %d", length, *s.MaxLength)}
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		return &schemaError{at, fmt.Sprintf("%s does not match pattern %s", describe(v), s.Pattern)}
+	}
+	if !validFormat(s.Format, v) {
+		return &schemaError{at, fmt.Sprintf("%s is not a valid %s", describe(v), s.Format)}
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(v json.Number, at string) *schemaError {
+	f, err := v.Float64()
+	if err != nil {
+		return &schemaError{at, fmt.Sprintf("%s is out of range", v)}
+	}
+	switch {
+	case s.Minimum != nil && f < *s.Minimum:
+		return &schemaError{at, fmt.Sprintf("%s is less than minimum %v", v, *s.Minimum)}
+	case s.Maximum != nil && f > *s.Maximum:
+		return &schemaError{at, fmt.Sprintf("%s is greater than maximum %v", v, *s.Maximum)}
+	case s.exclusiveMin != nil && f <= *s.exclusiveMin:
+		return &schemaError{at, fmt.Sprintf("%s is not greater than exclusiveMinimum %v", v, *s.exclusiveMin)}
+	case s.exclusiveMax != nil && f >= *s.exclusiveMax:
+		return &schemaError{at, fmt.Sprintf("%s is not less than exclusiveMaximum %v", v, *s.exclusiveMax)}
+	case s.MultipleOf != nil && *s.MultipleOf > 0 && !isMultiple(f, *s.MultipleOf):
+		return &schemaError{at, fmt.Sprintf("%s is not a multiple of %v", v, *s.MultipleOf)}
+	}
+
+	switch s.Format {
+	case "int32":
+		if f < math.MinInt32 || f > math.MaxInt32 {
+			return &schemaError{at, fmt.Sprintf("%s is out of int32 range", v)}
+		}
+	case "int64":
+		if _, err := strconv.ParseInt(v.String(), 10, 64); err != nil && isInteger(v) {
+			return &schemaError{at, fmt.Sprintf("%s is out of int64 range", v)}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(v []any, at string, depth int) *schemaError {
+	if s.MinItems != nil && len(v) < *s.MinItems {
+		return &schemaError{at, fmt.Sprintf("%d items is less than minItems %d", len(v), *s.MinItems)}
+	}
+	if s.MaxItems != nil && len(v) > *s.MaxItems {
+		return &schemaError{at, fmt.Sprintf("%d items is greater than maxItems %d", len(v), *s.MaxItems)}
+	}
+	for i, item := range v {
+		if err := s.Items.validate(item, child(at, strconv.Itoa(i)), depth+1); err != nil {
+			return err
+		}
+		if !s.UniqueItems {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if equal(v[j], item) {
+				return &schemaError{at, fmt.Sprintf("items %d and %d are equal, items must be unique", j, i)}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateObject(v map[string]any, at string, depth int) *schemaError {
+	if s.MinProperties != nil && len(v) < *s.MinProperties {
+		return &schemaError{at, fmt.Sprintf("%d properties is less than minProperties %d", len(v), *s.MinProperties)}
+	}
+	if s.MaxProperties != nil && len(v) > *s.MaxProperties {
+		return &schemaError{at, fmt.Sprintf("%d properties is greater than maxProperties %d", len(v), *s.MaxProperties)}
+	}
+
+	// readOnly свойства отдает только сервер, поэтому в запросе они не обязательны
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok && !(s.Properties[name] != nil && s.Properties[name].ReadOnly) {
+			return &schemaError{at, fmt.Sprintf("required property %q is missing", name)}
+		}
+	}
+
+	// свойства проверяются в отсортированном порядке, чтобы причина отказа не зависела от порядка map
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		child := child(at, name)
+		property, declared := s.Properties[name]
+		switch {
+		case declared:
+			if err := property.validate(v[name], child, depth+1); err != nil {
+				return err
+			}
+		case s.closed:
+			return &schemaError{child, fmt.Sprintf("property %q is not allowed", name)}
+		case s.additional != nil:
+			if err := s.additional.validate(v[name], child, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateComposition(v any, at string, depth int) *schemaError {
+	for _, sub := range s.AllOf {
+		if err := sub.validate(v, at, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if len(s.AnyOf) > 0 && !slices.ContainsFunc(s.AnyOf, func(sub *Schema) bool { return sub.validate(v, at, depth+1) == nil }) {
+		return &schemaError{at, fmt.Sprintf("%s does not match any schema of anyOf", describe(v))}
+	}
+
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, sub := range s.OneOf {
+			if sub.validate(v, at, depth+1) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return &schemaError{at, fmt.Sprintf("%s matches %d schemas of oneOf, expected exactly one", describe(v), matched)}
+		}
+	}
+
+	if s.Not != nil && s.Not.validate(v, at, depth+1) == nil {
+		return &schemaError{at, fmt.Sprintf("%s matches the schema in not", describe(v))}
+	}
+	return nil
+}
+
+// child строит путь вложенного значения. у полей формы корень пустой, у json-тела - json.
+func child(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func hasType(v any, t string) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case json.Number:
+		return t == "number" || t == "integer" && isInteger(v)
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+// isInteger считает целыми и числа с нулевой дробной частью: 1.0 - целое в JSON Schema.
+func isInteger(v json.Number) bool {
+	if _, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+		return true
+	}
+	f, err := v.Float64()
+	return err == nil && f == math.Trunc(f) && !math.IsInf(f, 0)
+}
+
+func isMultiple(f, of float64) bool {
+	q := f / of
+	return math.Abs(q-math.Round(q)) < 1e-9
+}
+
+// validFormat проверяет известные форматы строк. неизвестный формат по спецификации не ограничивает значение.
+func validFormat(format, v string) bool {
+	var err error
+	switch format {
+	case "date":
+		_, err = time.Parse(time.DateOnly, v)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, v)
+	case "uuid":
+		return uuidFormat.MatchString(v)
+	case "email":
+		var address *mail.Address
+		address, err = mail.ParseAddress(v)
+		return err == nil && address.Name == "" && address.Address == v
+	case "ipv4":
+		ip := net.ParseIP(v)
+		return ip != nil && ip.To4() != nil && !strings.Contains(v, ":")
+	case "ipv6":
+		return net.ParseIP(v) != nil && strings.Contains(v, ":")
+	case "uri":
+		var u *url.URL
+		u, err = url.Parse(v)
+		return err == nil && u.Scheme != ""
+	case "byte":
+		_, err = base64.StdEncoding.DecodeString(v)
+	}
+	return err == nil
+}
+
+// equal сравнивает значения как json: числа документа (float64) и запроса (json.Number) - по значению.
+func equal(a, b any) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []any:
+		normalized := make([]any, len(v))
+		for i, item := range v {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for key, item := range v {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	}
+	return v
+}
+
+// describe показывает значение в причине отказа. длинные строки обрезаются: причина попадает в логи.
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		if utf8.RuneCountInString(v) > 64 {
+			v = string([]rune(v)[:64]) + "..."
+		}
+		return strconv.Quote(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprint(v)
+}
+
+func enumList(enum []any) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		encoded, _ := json.Marshal(e)
+		values[i] = string(encoded)
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
@@ -0,0 +1,369 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+
+	"rules-engine/internal/inspect"
+)
+
+// заголовки, которые по спецификации описываются не параметрами, а content и security схемами
+var reservedHeaders = []string{"Accept", "Content-Type", "Authorization"}
+
+// Violation - первое найденное расхождение запроса с документом. Variable названа как переменная
+// SecLang, в которой найдено нарушение.
+type Violation struct {
+	Variable string
+	Reason   string
+}
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// Validate проверяет запрос по документу: путь, метод, параметры, content-type и схему тела.
+// query аргументы, не объявленные операцией, считаются нарушением.
+func (s *Spec) Validate(r *inspect.Request) *Violation {
+	p, values, ok := s.match(r.Path)
+	if !ok {
+		return &Violation{"REQUEST_FILENAME", fmt.Sprintf("path %s is not declared", r.Path)}
+	}
+
+	method := strings.ToUpper(r.Raw.Method)
+	op, ok := p.operations[method]
+	if !ok {
+		return &Violation{"REQUEST_METHOD", fmt.Sprintf("method %s is not declared for %s", method, p.template)}
+	}
+
+	if v := op.validateParameters(r, values); v != nil {
+		return v
+	}
+	return op.validateBody(r)
+}
+
+// match ищет путь документа относительно базовых путей серверов и возвращает значения параметров пути.
+func (s *Spec) match(requestPath string) (*path, map[string]string, bool) {
+	bases := s.basePaths
+	if len(bases) == 0 {
+		bases = []string{""}
+	}
+
+	for _, base := range bases {
+		if base != "" && requestPath != base && !strings.HasPrefix(requestPath, base+"/") {
+			continue
+		}
+		relative := strings.TrimPrefix(requestPath, base)
+		if relative == "" {
+			relative = "/"
+		}
+		for _, p := range s.paths {
+			groups := p.regex.FindStringSubmatch(relative)
+			if groups == nil {
+				continue
+			}
+			values := make(map[string]string, len(p.names))
+			for i, name := range p.names {
+				values[name] = groups[i+1]
+			}
+			return p, values, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (op *operation) validateParameters(r *inspect.Request, pathValues map[string]string) *Violation {
+	// declared отмечает query аргументы, которые описаны параметрами операции
+	declared := make(map[string]bool)
+
+	for _, param := range op.parameters {
+		schema := param.Schema.resolved()
+		var (
+			values   []string
+			value    any
+			variable string
+		)
+		switch param.In {
+		case "path":
+			variable = "REQUEST_FILENAME"
+			values = []string{pathValues[param.Name]}
+			value = coerceList(schema, values[0])
+		case "header":
+			name := textproto.CanonicalMIMEHeaderKey(param.Name)
+			if slices.Contains(reservedHeaders, name) {
+				continue
+			}
+			variable = "REQUEST_HEADERS:" + name
+			if header, ok := r.Header(name); ok {
+				values = []string{header}
+				value = coerceList(schema, header)
+			}
+		case "cookie":
+			variable = "REQUEST_COOKIES:" + param.Name
+			if cookie, ok := r.Cookie(param.Name); ok {
+				values = []string{cookie}
+				value = coerce(schema, cookie)
+			}
+		case "query":
+			variable = "ARGS_GET:" + param.Name
+			var v *Violation
+			if values, value, v = queryValue(param, schema, r.ArgsGet, declared); v != nil {
+				return v
+			}
+		}
+
+		if len(values) == 0 {
+			if param.Required || param.In == "path" {
+				return &Violation{variable, fmt.Sprintf("%s is required", location(param))}
+			}
+			continue
+		}
+		if err := param.Schema.validate(value, "", 0); err != nil {
+			return &Violation{variable, fmt.Sprintf("%s: %s", location(param), err.reason)}
+		}
+	}
+
+	for _, arg := range r.ArgsGet {
+		if !declared[arg.Name] {
+			return &Violation{"ARGS_GET:" + arg.Name, fmt.Sprintf("query parameter %q is not declared", arg.Name)}
+		}
+	}
+	return nil
+}
+
+// queryValue собирает значение query параметра с учетом style и explode: массив из повторов или через
+// запятую, объект из аргументов name[key] (deepObject) или из отдельных аргументов по свойствам (form).
+func queryValue(param *Parameter, schema *Schema, args []inspect.Field, declared map[string]bool) ([]string, any, *Violation) {
+	explode := param.Explode == nil || *param.Explode
+	var values []string
+
+	if schema.is("object") && (param.Style == "deepObject" || explode) {
+		object := make(map[string]any)
+		for _, arg := range args {
+			key, ok := "", false
+			if param.Style == "deepObject" {
+				if rest, found := strings.CutPrefix(arg.Name, param.Name+"["); found {
+					key, ok = strings.CutSuffix(rest, "]")
+				}
+			} else {
+				key, ok = arg.Name, schema.Properties[arg.Name] != nil
+			}
+			if !ok {
+				continue
+			}
+			declared[arg.Name] = true
+			values = append(values, arg.Value)
+			object[key] = coerce(schema.Properties[key].resolved(), arg.Value)
+		}
+		return values, object, nil
+	}
+
+	for _, arg := range args {
+		if arg.Name == param.Name {
+			declared[arg.Name] = true
+			values = append(values, arg.Value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil, nil
+	}
+
+	if !schema.is("array") {
+		if len(values) > 1 {
+			return nil, nil, &Violation{"ARGS_GET:" + param.Name, fmt.Sprintf("%s must not be repeated", location(param))}
+		}
+		return values, coerce(schema, values[0]), nil
+	}
+
+	if !explode {
+		return values, coerceList(schema, strings.Join(values, ",")), nil
+	}
+	items := make([]any, len(values))
+	for i, value := range values {
+		items[i] = coerce(schema.Items.resolved(), value)
+	}
+	return values, items, nil
+}
+
+func (op *operation) validateBody(r *inspect.Request) *Violation {
+	body := r.Raw.Body
+	if op.body == nil {
+		if body != "" {
+			return &Violation{"REQUEST_BODY", "request body is not declared for this operation"}
+		}
+		return nil
+	}
+	if body == "" {
+		if op.body.Required {
+			return &Violation{"REQUEST_BODY", "request body is required"}
+		}
+		return nil
+	}
+
+	contentType := r.Headers["Content-Type"]
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := op.body.media(mediaType)
+	if !ok {
+		return &Violation{"REQUEST_HEADERS:Content-Type", fmt.Sprintf("content type %q is not allowed, expected one of %s",
+			contentType, strings.Join(op.body.mediaTypes(), ", "))}
+	}
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+
+	var (
+		value any
+		at    string
+	)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		dec := json.NewDecoder(strings.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return &Violation{"REQUEST_BODY", fmt.Sprintf("request body is not valid json: %v", err)}
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return &Violation{"REQUEST_BODY", "request body has data after the json value"}
+		}
+		// пути совпадают с именами аргументов json-тела в inspect
+		at = "json"
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		value = formObject(media.Schema.resolved(), r)
+	default:
+		return nil
+	}
+
+	err := media.Schema.validate(value, at, 0)
+	if err == nil {
+		return nil
+	}
+	if err.at == at {
+		return &Violation{"REQUEST_BODY", fmt.Sprintf("request body: %s", err.reason)}
+	}
+	return &Violation{"ARGS_POST:" + err.at, fmt.Sprintf("request body %s: %s", err.at, err.reason)}
+}
+
+// media выбирает описание тела: точный тип, затем type/* и */*.
+func (b *RequestBody) media(mediaType string) (*MediaType, bool) {
+	if mediaType == "" {
+		return nil, false
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, candidate := range []string{mediaType, major + "/*", "*/*"} {
+		for key, media := range b.Content {
+			declared, _, err := mime.ParseMediaType(key)
+			if err == nil && declared == candidate {
+				return media, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (b *RequestBody) mediaTypes() []string {
+	types := make([]string, 0, len(b.Content))
+	for key := range b.Content {
+		types = append(types, key)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// formObject собирает объект из полей формы. повторенное поле становится массивом, а файл multipart -
+// строкой с его именем: схема binary описывает файл как строку.
+func formObject(schema *Schema, r *inspect.Request) map[string]any {
+	values := make(map[string][]string)
+	var names []string
+	for _, f := range r.ArgsPost {
+		if _, ok := values[f.Name]; !ok {
+			names = append(names, f.Name)
+		}
+		values[f.Name] = append(values[f.Name], f.Value)
+	}
+	for _, f := range r.Files {
+		if _, ok := values[f.Field]; !ok {
+			names = append(names, f.Field)
+		}
+		values[f.Field] = append(values[f.Field], f.Filename)
+	}
+
+	object := make(map[string]any, len(names))
+	for _, name := range names {
+		var property *Schema
+		if schema != nil {
+			property = schema.Properties[name].resolved()
+		}
+		if property.is("array") || len(values[name]) > 1 {
+			items := make([]any, len(values[name]))
+			for i, value := range values[name] {
+				var itemSchema *Schema
+				if property != nil {
+					itemSchema = property.Items.resolved()
+				}
+				items[i] = coerce(itemSchema, value)
+			}
+			object[name] = items
+			continue
+		}
+		object[name] = coerce(property, values[name][0])
+	}
+	return object
+}
+
+// coerceList разбирает значение style simple: массив перечисляется через запятую.
+func coerceList(schema *Schema, value string) any {
+	if !schema.is("array") {
+		return coerce(schema, value)
+	}
+	parts := strings.Split(value, ",")
+	items := make([]any, len(parts))
+	for i, part := range parts {
+		items[i] = coerce(schema.Items.resolved(), part)
+	}
+	return items
+}
+
+// coerce переводит строку параметра в тип схемы. строка, которая не переводится, остается строкой,
+// и проверка типа сообщает о несовпадении.
+func coerce(schema *Schema, value string) any {
+	switch {
+	case schema.is("integer") || schema.is("number"):
+		if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+			return json.Number(value)
+		}
+	case schema.is("boolean"):
+		if value == "true" || value == "false" {
+			return value == "true"
+		}
+	}
+	return value
+}
+
+// resolved переходит по $ref к схеме, которая описывает значение.
+func (s *Schema) resolved() *Schema {
+	for i := 0; s != nil && s.ref != nil && i < maxDepth; i++ {
+		s = s.ref
+	}
+	return s
+}
+
+func (s *Schema) is(t string) bool {
+	return s != nil && slices.Contains(s.types, t)
+}
+
+func location(param *Parameter) string {
+	switch param.In {
+	case "path":
+		return fmt.Sprintf("path parameter %q", param.Name)
+	case "header":
+		return fmt.Sprintf("header %q", param.Name)
+	case "cookie":
+		return fmt.Sprintf("cookie %q", param.Name)
+	}
+	return fmt.Sprintf("query parameter %q", param.Name)
+}
@@ -0,0 +1,10 @@
+package repository
+
+import "rules-engine/internal/entity"
+
+type CertificateRepository interface {
+	GetCertificates() ([]entity.Certificate, error)
+	CreateCertificate(certificate *entity.Certificate) (*entity.Certificate, error)
+	UpdateCertificate(certificate *entity.Certificate) (*entity.Certificate, error)
+	GetCertificate(id string) (*entity.Certificate, error)
+}
@@ -8,5 +8,4 @@ type IPListRepository interface {
 	UpdateIPList(ipList *entity.IPList) (*entity.IPList, error)
 	GetIPList(id string) (*entity.IPList, error)
 	GetIPListsForResource(resourceID string) ([]entity.IPList, error)
-	GetIPListsByURL(url, method string) ([]entity.IPList, error)
 }
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rules-engine/internal/entity"
+
+	"rules-engine/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const certificateColumns = `id, name, hostnames, cert_pem, key_pem, not_after, creator_id, created_at`
+
+type PostgresCertificateRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCertificateRepository(db *sql.DB) repository.CertificateRepository {
+	return &PostgresCertificateRepository{db: db}
+}
+
+func scanCertificate(row rowScanner, certificate *entity.Certificate) error {
+	return row.Scan(
+		&certificate.ID,
+		&certificate.Name,
+		pq.Array(&certificate.Hostnames),
+		&certificate.CertPEM,
+		&certificate.KeyPEM,
+		&certificate.NotAfter,
+		&certificate.CreatorID,
+		&certificate.CreatedAt,
+	)
+}
+
+func (r *PostgresCertificateRepository) GetCertificates() ([]entity.Certificate, error) {
+	rows, err := r.db.Query("SELECT " + certificateColumns + " FROM certificates")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certificates []entity.Certificate
+	for rows.Next() {
+		var certificate entity.Certificate
+		if err := scanCertificate(rows, &certificate); err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+func (r *PostgresCertificateRepository) CreateCertificate(certificate *entity.Certificate) (*entity.Certificate, error) {
+	certificate.ID = uuid.New().String()
+
+	var created entity.Certificate
+	err := scanCertificate(r.db.QueryRow(`
+		INSERT INTO certificates (id, name, hostnames, cert_pem, key_pem, not_after, creator_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+certificateColumns,
+		certificate.ID, certificate.Name, pq.Array(certificate.Hostnames), certificate.CertPEM, certificate.KeyPEM,
+		certificate.NotAfter, certificate.CreatorID,
+	), &created)
+	return &created, err
+}
+
+func (r *PostgresCertificateRepository) UpdateCertificate(certificate *entity.Certificate) (*entity.Certificate, error) {
+	var updated entity.Certificate
+	err := scanCertificate(r.db.QueryRow(`
+		UPDATE certificates
+		SET name=$1, hostnames=$2, cert_pem=$3, key_pem=$4, not_after=$5
+		WHERE id=$6
+		RETURNING `+certificateColumns,
+		certificate.Name, pq.Array(certificate.Hostnames), certificate.CertPEM, certificate.KeyPEM, certificate.NotAfter,
+		certificate.ID,
+	), &updated)
+	return &updated, err
+}
+
+func (r *PostgresCertificateRepository) GetCertificate(id string) (*entity.Certificate, error) {
+	certificate := &entity.Certificate{}
+	err := scanCertificate(r.db.QueryRow("SELECT "+certificateColumns+" FROM certificates WHERE id = $1", id), certificate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get certificate: %w", err)
+	}
+	return certificate, nil
+}
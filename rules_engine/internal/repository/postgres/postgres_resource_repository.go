@@ -12,6 +12,15 @@ import (
 	"github.com/google/uuid"
 )
 
+const resourceColumns = `id, name, http_method, url, host, hostname, upstream_id,
+	connect_timeout_ms, read_timeout_ms, retries, retry_backoff_ms, breaker_error_rate, breaker_min_requests, breaker_open_ms,
+	max_body_bytes, allow_upgrade, inspect_messages, https_redirect, log_only,
+	evaluation_mode, anomaly_threshold, paranoia_level, csrf, creator_id, is_active, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
 type PostgresResourceRepository struct {
 	db *sql.DB
 }
@@ -20,8 +29,39 @@ func NewPostgresResourceRepository(db *sql.DB) repository.ResourceRepository {
 	return &PostgresResourceRepository{db: db}
 }
 
+func scanResource(row rowScanner, resource *entity.Resource) error {
+	return row.Scan(
+		&resource.ID,
+		&resource.Name,
+		&resource.HTTPMethod,
+		&resource.URL,
+		&resource.Host,
+		&resource.Hostname,
+		&resource.UpstreamID,
+		&resource.Policy.ConnectTimeoutMs,
+		&resource.Policy.ReadTimeoutMs,
+		&resource.Policy.Retries,
+		&resource.Policy.RetryBackoffMs,
+		&resource.Policy.BreakerErrorRate,
+		&resource.Policy.BreakerMinRequests,
+		&resource.Policy.BreakerOpenMs,
+		&resource.MaxBodyBytes,
+		&resource.AllowUpgrade,
+		&resource.InspectMessages,
+		&resource.HTTPSRedirect,
+		&resource.LogOnly,
+		&resource.EvaluationMode,
+		&resource.AnomalyThreshold,
+		&resource.ParanoiaLevel,
+		&resource.CSRF,
+		&resource.CreatorID,
+		&resource.IsActive,
+		&resource.CreatedAt,
+	)
+}
+
 func (r *PostgresResourceRepository) GetResources() ([]entity.Resource, error) {
-	rows, err := r.db.Query("SELECT id, name, http_method, url, host, is_active, created_at, creator_id FROM resources")
+	rows, err := r.db.Query("SELECT " + resourceColumns + " FROM resources")
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +70,7 @@ func (r *PostgresResourceRepository) GetResources() ([]entity.Resource, error) {
 	var resources []entity.Resource
 	for rows.Next() {
 		var res entity.Resource
-		if err := rows.Scan(&res.ID, &res.Name, &res.HTTPMethod, &res.URL, &res.Host, &res.IsActive, &res.CreatedAt, &res.CreatorID); err != nil {
+		if err := scanResource(rows, &res); err != nil {
 			return nil, err
 		}
 		resources = append(resources, res)
@@ -42,59 +82,90 @@ func (r *PostgresResourceRepository) CreateResource(resource *entity.Resource) (
 	resource.ID = uuid.New().String()
 
 	var createdResource entity.Resource
-	err := r.db.QueryRow(`
-		INSERT INTO resources (id, name, http_method, url, host, creator_id, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, name, http_method, url, host, creator_id, is_active, created_at
-	`, resource.ID, resource.Name, resource.HTTPMethod, resource.URL, resource.Host, resource.CreatorID, resource.IsActive).Scan(
-		&createdResource.ID,
-		&createdResource.Name,
-		&createdResource.HTTPMethod,
-		&createdResource.URL,
-		&createdResource.Host,
-		&createdResource.CreatorID,
-		&createdResource.IsActive,
-		&createdResource.CreatedAt,
-	)
+	err := scanResource(r.db.QueryRow(`
+		INSERT INTO resources (
+			id, name, http_method, url, host, hostname, upstream_id,
+			connect_timeout_ms, read_timeout_ms, retries, retry_backoff_ms, breaker_error_rate, breaker_min_requests, breaker_open_ms,
+			max_body_bytes, allow_upgrade, inspect_messages, https_redirect, log_only,
+			evaluation_mode, anomaly_threshold, paranoia_level, csrf, creator_id, is_active
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
+		RETURNING `+resourceColumns,
+		resource.ID,
+		resource.Name,
+		resource.HTTPMethod,
+		resource.URL,
+		resource.Host,
+		resource.Hostname,
+		resource.UpstreamID,
+		resource.Policy.ConnectTimeoutMs,
+		resource.Policy.ReadTimeoutMs,
+		resource.Policy.Retries,
+		resource.Policy.RetryBackoffMs,
+		resource.Policy.BreakerErrorRate,
+		resource.Policy.BreakerMinRequests,
+		resource.Policy.BreakerOpenMs,
+		resource.MaxBodyBytes,
+		resource.AllowUpgrade,
+		resource.InspectMessages,
+		resource.HTTPSRedirect,
+		resource.LogOnly,
+		resource.EvaluationMode,
+		resource.AnomalyThreshold,
+		resource.ParanoiaLevel,
+		resource.CSRF,
+		resource.CreatorID,
+		resource.IsActive,
+	), &createdResource)
 	return &createdResource, err
 }
 
 func (r *PostgresResourceRepository) UpdateResource(resource *entity.Resource) (*entity.Resource, error) {
 	var updatedResource entity.Resource
 
-	err := r.db.QueryRow(`
+	err := scanResource(r.db.QueryRow(`
 		UPDATE resources
-		SET name=$1, http_method=$2, url=$3, host=$4, is_active=$5
-		WHERE id=$6
-		RETURNING id, name, http_method, url, host, creator_id, is_active, created_at
-	`, resource.Name, resource.HTTPMethod, resource.URL, resource.Host, resource.IsActive, resource.ID).Scan(
-		&updatedResource.ID,
-		&updatedResource.Name,
-		&updatedResource.HTTPMethod,
-		&updatedResource.URL,
-		&updatedResource.Host,
-		&updatedResource.CreatorID,
-		&updatedResource.IsActive,
-		&updatedResource.CreatedAt,
-	)
+		SET name=$1, http_method=$2, url=$3, host=$4, hostname=$5, upstream_id=$6,
+			connect_timeout_ms=$7, read_timeout_ms=$8, retries=$9, retry_backoff_ms=$10,
+			breaker_error_rate=$11, breaker_min_requests=$12, breaker_open_ms=$13, max_body_bytes=$14,
+			allow_upgrade=$15, inspect_messages=$16, https_redirect=$17, log_only=$18,
+			evaluation_mode=$19, anomaly_threshold=$20, paranoia_level=$21, csrf=$22, is_active=$23
+		WHERE id=$24
+		RETURNING `+resourceColumns,
+		resource.Name,
+		resource.HTTPMethod,
+		resource.URL,
+		resource.Host,
+		resource.Hostname,
+		resource.UpstreamID,
+		resource.Policy.ConnectTimeoutMs,
+		resource.Policy.ReadTimeoutMs,
+		resource.Policy.Retries,
+		resource.Policy.RetryBackoffMs,
+		resource.Policy.BreakerErrorRate,
+		resource.Policy.BreakerMinRequests,
+		resource.Policy.BreakerOpenMs,
+		resource.MaxBodyBytes,
+		resource.AllowUpgrade,
+		resource.InspectMessages,
+		resource.HTTPSRedirect,
+		resource.LogOnly,
+		resource.EvaluationMode,
+		resource.AnomalyThreshold,
+		resource.ParanoiaLevel,
+		resource.CSRF,
+		resource.IsActive,
+		resource.ID,
+	), &updatedResource)
 
 	return &updatedResource, err
 }
 
 func (r *PostgresResourceRepository) GetResource(id string) (*entity.Resource, error) {
-	query := `SELECT id, name, http_method, url, host, created_at, creator_id, is_active FROM resources WHERE id = $1`
+	query := "SELECT " + resourceColumns + " FROM resources WHERE id = $1"
 
 	resource := &entity.Resource{}
-	err := r.db.QueryRow(query, id).Scan(
-		&resource.ID,
-		&resource.Name,
-		&resource.HTTPMethod,
-		&resource.URL,
-		&resource.Host,
-		&resource.CreatedAt,
-		&resource.CreatorID,
-		&resource.IsActive,
-	)
+	err := scanResource(r.db.QueryRow(query, id), resource)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -16,9 +16,12 @@ func NewPostgresResourceRuleRepository(db *sql.DB) repository.ResourceRuleReposi
 	return &PostgresResourceRuleRepository{db: db}
 }
 
-func (r *PostgresResourceRuleRepository) AttachRule(resourceID, ruleID string) error {
-	_, err := r.db.Exec("INSERT INTO resource_rule (id, resource_id, rule_id) VALUES ($1, $2, $3)",
-		uuid.New().String(), resourceID, ruleID)
+// AttachRule привязывает правило к ресурсу. повторная привязка меняет только приоритет.
+func (r *PostgresResourceRuleRepository) AttachRule(resourceID, ruleID string, priority int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO resource_rule (id, resource_id, rule_id, priority) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (rule_id, resource_id) DO UPDATE SET priority = EXCLUDED.priority`,
+		uuid.New().String(), resourceID, ruleID, priority)
 	return err
 }
 
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rules-engine/internal/entity"
+
+	"rules-engine/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const ruleExclusionColumns = `id, resource_id, rule_id, target, name, ip_list_ids, creator_id, created_at`
+
+type PostgresRuleExclusionRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRuleExclusionRepository(db *sql.DB) repository.RuleExclusionRepository {
+	return &PostgresRuleExclusionRepository{db: db}
+}
+
+func scanRuleExclusion(row rowScanner, exclusion *entity.RuleExclusion) error {
+	return row.Scan(
+		&exclusion.ID,
+		&exclusion.ResourceID,
+		&exclusion.RuleID,
+		&exclusion.Target,
+		&exclusion.Name,
+		pq.Array(&exclusion.IPListIDs),
+		&exclusion.CreatorID,
+		&exclusion.CreatedAt,
+	)
+}
+
+func (r *PostgresRuleExclusionRepository) GetExclusionsForResource(resourceID string) ([]entity.RuleExclusion, error) {
+	rows, err := r.db.Query("SELECT "+ruleExclusionColumns+" FROM rule_exclusions WHERE resource_id = $1 ORDER BY created_at", resourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exclusions []entity.RuleExclusion
+	for rows.Next() {
+		var exclusion entity.RuleExclusion
+		if err := scanRuleExclusion(rows, &exclusion); err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, exclusion)
+	}
+	return exclusions, nil
+}
+
+func (r *PostgresRuleExclusionRepository) GetExclusion(id string) (*entity.RuleExclusion, error) {
+	exclusion := &entity.RuleExclusion{}
+	err := scanRuleExclusion(r.db.QueryRow("SELECT "+ruleExclusionColumns+" FROM rule_exclusions WHERE id = $1", id), exclusion)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rule exclusion: %w", err)
+	}
+	return exclusion, nil
+}
+
+func (r *PostgresRuleExclusionRepository) CreateExclusion(exclusion *entity.RuleExclusion) (*entity.RuleExclusion, error) {
+	exclusion.ID = uuid.New().String()
+
+	var created entity.RuleExclusion
+	err := scanRuleExclusion(r.db.QueryRow(`
+		INSERT INTO rule_exclusions (id, resource_id, rule_id, target, name, ip_list_ids, creator_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+ruleExclusionColumns,
+		exclusion.ID, exclusion.ResourceID, exclusion.RuleID, exclusion.Target, exclusion.Name,
+		pq.Array(exclusion.IPListIDs), exclusion.CreatorID,
+	), &created)
+	return &created, err
+}
+
+func (r *PostgresRuleExclusionRepository) UpdateExclusion(exclusion *entity.RuleExclusion) (*entity.RuleExclusion, error) {
+	var updated entity.RuleExclusion
+	err := scanRuleExclusion(r.db.QueryRow(`
+		UPDATE rule_exclusions
+		SET target=$1, name=$2, ip_list_ids=$3
+		WHERE id=$4
+		RETURNING `+ruleExclusionColumns,
+		exclusion.Target, exclusion.Name, pq.Array(exclusion.IPListIDs), exclusion.ID,
+	), &updated)
+	return &updated, err
+}
+
+func (r *PostgresRuleExclusionRepository) DeleteExclusion(id string) error {
+	_, err := r.db.Exec("DELETE FROM rule_exclusions WHERE id = $1", id)
+	return err
+}
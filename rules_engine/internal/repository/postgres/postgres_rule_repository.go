@@ -12,6 +12,9 @@ import (
 	"github.com/google/uuid"
 )
 
+const ruleColumns = `id, name, attack_type, action_type, conditions, transformations, expression, sec_rule, openapi,
+	graphql, severity, paranoia_level, log_only, is_active, creator_id, created_at`
+
 type PostgresRuleRepository struct {
 	db *sql.DB
 }
@@ -20,8 +23,29 @@ func NewPostgresRuleRepository(db *sql.DB) repository.RuleRepository {
 	return &PostgresRuleRepository{db: db}
 }
 
-func (r *PostgresRuleRepository) GetRules() ([]entity.Rule, error) {
-	rows, err := r.db.Query("SELECT id, name, attack_type, action_type, is_active, created_at, creator_id FROM rules")
+func scanRule(row rowScanner, rule *entity.Rule, extra ...any) error {
+	return row.Scan(append([]any{
+		&rule.ID,
+		&rule.Name,
+		&rule.AttackType,
+		&rule.ActionType,
+		&rule.Conditions,
+		&rule.Transformations,
+		&rule.Expression,
+		&rule.SecRule,
+		&rule.OpenAPI,
+		&rule.GraphQL,
+		&rule.Severity,
+		&rule.ParanoiaLevel,
+		&rule.LogOnly,
+		&rule.IsActive,
+		&rule.CreatorID,
+		&rule.CreatedAt,
+	}, extra...)...)
+}
+
+func (r *PostgresRuleRepository) queryRules(query string, args ...any) ([]entity.Rule, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +54,7 @@ func (r *PostgresRuleRepository) GetRules() ([]entity.Rule, error) {
 	var rules []entity.Rule
 	for rows.Next() {
 		var res entity.Rule
-		if err := rows.Scan(&res.ID, &res.Name, &res.AttackType, &res.ActionType, &res.IsActive, &res.CreatedAt, &res.CreatorID); err != nil {
+		if err := scanRule(rows, &res); err != nil {
 			return nil, err
 		}
 		rules = append(rules, res)
@@ -38,23 +62,25 @@ func (r *PostgresRuleRepository) GetRules() ([]entity.Rule, error) {
 	return rules, nil
 }
 
+func (r *PostgresRuleRepository) GetRules() ([]entity.Rule, error) {
+	return r.queryRules("SELECT " + ruleColumns + " FROM rules")
+}
+
 func (r *PostgresRuleRepository) CreateRule(rule *entity.Rule) (*entity.Rule, error) {
 	rule.ID = uuid.New().String()
 
 	var createdRule entity.Rule
-	err := r.db.QueryRow(`
-		INSERT INTO rules (id, name, attack_type, action_type, creator_id, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, name, attack_type, action_type, creator_id, is_active, created_at
-	`, rule.ID, rule.Name, rule.AttackType, rule.ActionType, rule.CreatorID, rule.IsActive).Scan(
-		&createdRule.ID,
-		&createdRule.Name,
-		&createdRule.AttackType,
-		&createdRule.ActionType,
-		&createdRule.CreatorID,
-		&createdRule.IsActive,
-		&createdRule.CreatedAt,
-	)
+	err := scanRule(r.db.QueryRow(`
+		INSERT INTO rules (
+			id, name, attack_type, action_type, conditions, transformations, expression, sec_rule, openapi, graphql,
+			severity, paranoia_level, log_only, creator_id, is_active
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING `+ruleColumns,
+		rule.ID, rule.Name, rule.AttackType, rule.ActionType, rule.Conditions, rule.Transformations, rule.Expression,
+		rule.SecRule, rule.OpenAPI, rule.GraphQL, rule.Severity, rule.ParanoiaLevel, rule.LogOnly, rule.CreatorID,
+		rule.IsActive,
+	), &createdRule)
 
 	return &createdRule, err
 }
@@ -62,36 +88,21 @@ func (r *PostgresRuleRepository) CreateRule(rule *entity.Rule) (*entity.Rule, er
 func (r *PostgresRuleRepository) UpdateRule(rule *entity.Rule) (*entity.Rule, error) {
 	var updatedRule entity.Rule
 
-	err := r.db.QueryRow(`
+	err := scanRule(r.db.QueryRow(`
 		UPDATE rules
-		SET name=$1, attack_type=$2, action_type=$3, is_active=$4
-		WHERE id=$5
-		RETURNING id, name, attack_type, action_type, creator_id, is_active, created_at
-	`, rule.Name, rule.AttackType, rule.ActionType, rule.IsActive, rule.ID).Scan(
-		&updatedRule.ID,
-		&updatedRule.Name,
-		&updatedRule.AttackType,
-		&updatedRule.ActionType,
-		&updatedRule.CreatorID,
-		&updatedRule.IsActive,
-		&updatedRule.CreatedAt,
-	)
+		SET name=$1, attack_type=$2, action_type=$3, conditions=$4, transformations=$5, expression=$6, sec_rule=$7,
+			openapi=$8, graphql=$9, severity=$10, paranoia_level=$11, log_only=$12, is_active=$13
+		WHERE id=$14
+		RETURNING `+ruleColumns,
+		rule.Name, rule.AttackType, rule.ActionType, rule.Conditions, rule.Transformations, rule.Expression, rule.SecRule,
+		rule.OpenAPI, rule.GraphQL, rule.Severity, rule.ParanoiaLevel, rule.LogOnly, rule.IsActive, rule.ID,
+	), &updatedRule)
 	return &updatedRule, err
 }
 
 func (r *PostgresRuleRepository) GetRule(id string) (*entity.Rule, error) {
-	query := `SELECT id, name, attack_type, action_type, created_at, creator_id, is_active FROM rules WHERE id = $1`
-
 	rule := &entity.Rule{}
-	err := r.db.QueryRow(query, id).Scan(
-		&rule.ID,
-		&rule.Name,
-		&rule.AttackType,
-		&rule.ActionType,
-		&rule.CreatedAt,
-		&rule.CreatorID,
-		&rule.IsActive,
-	)
+	err := scanRule(r.db.QueryRow("SELECT "+ruleColumns+" FROM rules WHERE id = $1", id), rule)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -103,51 +114,16 @@ func (r *PostgresRuleRepository) GetRule(id string) (*entity.Rule, error) {
 }
 
 func (r *PostgresRuleRepository) GetRulesForResource(resourceID string) ([]entity.Rule, error) {
-	query := `
-		SELECT t1.id, t1.name, t1.attack_type, t1.action_type, t1.is_active, t1.creator_id, t1.created_at
-		FROM rules AS t1
-		INNER JOIN resource_rule AS t2
-		ON t1.id = t2.rule_id
-		WHERE t2.resource_id = $1
-	`
-
-	rows, err := r.db.Query(query, resourceID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("failed to get rules: %w", err)
-	}
-	defer rows.Close()
-
-	var rules []entity.Rule
-	for rows.Next() {
-		var res entity.Rule
-		if err := rows.Scan(&res.ID, &res.Name, &res.AttackType, &res.ActionType, &res.IsActive, &res.CreatorID, &res.CreatedAt); err != nil {
-			return nil, err
-		}
-		rules = append(rules, res)
-	}
-
-	return rules, nil
-}
-
-func (r *PostgresRuleRepository) GetRulesByURL(url, method string) ([]entity.Rule, error) {
-	query := `
-		SELECT t1.id, t1.name, t1.attack_type, t1.action_type, t1.is_active, t1.creator_id, t1.created_at
-		FROM rules AS t1
-		INNER JOIN resource_rule AS t2
-		ON t1.id = t2.rule_id
-		INNER JOIN resources AS t3
-		ON t2.resource_id = t3.id
-		WHERE t3.url = $1 AND t3.http_method = $2
-	`
-
-	rows, err := r.db.Query(query, url, method)
+	// порядок вычисления: приоритет, затем время привязки и id, чтобы результат не зависел от плана запроса
+	rows, err := r.db.Query(`
+		SELECT `+ruleColumns+`, rr.priority
+		FROM rules
+		JOIN (
+			SELECT rule_id, priority, created_at AS attached_at FROM resource_rule WHERE resource_id = $1
+		) rr ON rr.rule_id = rules.id
+		ORDER BY rr.priority, rr.attached_at, rules.id
+	`, resourceID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
 		return nil, fmt.Errorf("failed to get rules: %w", err)
 	}
 	defer rows.Close()
@@ -155,11 +131,12 @@ func (r *PostgresRuleRepository) GetRulesByURL(url, method string) ([]entity.Rul
 	var rules []entity.Rule
 	for rows.Next() {
 		var res entity.Rule
-		if err := rows.Scan(&res.ID, &res.Name, &res.AttackType, &res.ActionType, &res.IsActive, &res.CreatorID, &res.CreatedAt); err != nil {
-			return nil, err
+		var priority int
+		if err := scanRule(rows, &res, &priority); err != nil {
+			return nil, fmt.Errorf("failed to get rules: %w", err)
 		}
+		res.Priority = &priority
 		rules = append(rules, res)
 	}
-
 	return rules, nil
 }
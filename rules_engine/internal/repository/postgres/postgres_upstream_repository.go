@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"rules-engine/internal/entity"
+
+	"rules-engine/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type PostgresUpstreamRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresUpstreamRepository(db *sql.DB) repository.UpstreamRepository {
+	return &PostgresUpstreamRepository{db: db}
+}
+
+func (r *PostgresUpstreamRepository) GetUpstreams() ([]entity.Upstream, error) {
+	rows, err := r.db.Query(`
+		SELECT id, name, balancer, hash_key, targets, health_check_path, health_check_interval, max_fails, fail_timeout, creator_id, created_at
+		FROM upstreams
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var upstreams []entity.Upstream
+	for rows.Next() {
+		var res entity.Upstream
+		if err := rows.Scan(
+			&res.ID,
+			&res.Name,
+			&res.Balancer,
+			&res.HashKey,
+			pq.Array(&res.Targets),
+			&res.HealthCheckPath,
+			&res.HealthCheckInterval,
+			&res.MaxFails,
+			&res.FailTimeout,
+			&res.CreatorID,
+			&res.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, res)
+	}
+	return upstreams, nil
+}
+
+func (r *PostgresUpstreamRepository) CreateUpstream(upstream *entity.Upstream) (*entity.Upstream, error) {
+	upstream.ID = uuid.New().String()
+
+	var created entity.Upstream
+	err := r.db.QueryRow(`
+		INSERT INTO upstreams (id, name, balancer, hash_key, targets, health_check_path, health_check_interval, max_fails, fail_timeout, creator_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, name, balancer, hash_key, targets, health_check_path, health_check_interval, max_fails, fail_timeout, creator_id, created_at
+	`, upstream.ID, upstream.Name, upstream.Balancer, upstream.HashKey, pq.Array(upstream.Targets), upstream.HealthCheckPath,
+		upstream.HealthCheckInterval, upstream.MaxFails, upstream.FailTimeout, upstream.CreatorID).Scan(
+		&created.ID,
+		&created.Name,
+		&created.Balancer,
+		&created.HashKey,
+		pq.Array(&created.Targets),
+		&created.HealthCheckPath,
+		&created.HealthCheckInterval,
+		&created.MaxFails,
+		&created.FailTimeout,
+		&created.CreatorID,
+		&created.CreatedAt,
+	)
+	return &created, err
+}
+
+func (r *PostgresUpstreamRepository) UpdateUpstream(upstream *entity.Upstream) (*entity.Upstream, error) {
+	var updated entity.Upstream
+	err := r.db.QueryRow(`
+		UPDATE upstreams
+		SET name=$1, balancer=$2, hash_key=$3, targets=$4, health_check_path=$5, health_check_interval=$6, max_fails=$7, fail_timeout=$8
+		WHERE id=$9
+		RETURNING id, name, balancer, hash_key, targets, health_check_path, health_check_interval, max_fails, fail_timeout, creator_id, created_at
+	`, upstream.Name, upstream.Balancer, upstream.HashKey, pq.Array(upstream.Targets), upstream.HealthCheckPath,
+		upstream.HealthCheckInterval, upstream.MaxFails, upstream.FailTimeout, upstream.ID).Scan(
+		&updated.ID,
+		&updated.Name,
+		&updated.Balancer,
+		&updated.HashKey,
+		pq.Array(&updated.Targets),
+		&updated.HealthCheckPath,
+		&updated.HealthCheckInterval,
+		&updated.MaxFails,
+		&updated.FailTimeout,
+		&updated.CreatorID,
+		&updated.CreatedAt,
+	)
+	return &updated, err
+}
+
+func (r *PostgresUpstreamRepository) GetUpstream(id string) (*entity.Upstream, error) {
+	query := `
+		SELECT id, name, balancer, hash_key, targets, health_check_path, health_check_interval, max_fails, fail_timeout, creator_id, created_at
+		FROM upstreams WHERE id = $1
+	`
+
+	upstream := &entity.Upstream{}
+	err := r.db.QueryRow(query, id).Scan(
+		&upstream.ID,
+		&upstream.Name,
+		&upstream.Balancer,
+		&upstream.HashKey,
+		pq.Array(&upstream.Targets),
+		&upstream.HealthCheckPath,
+		&upstream.HealthCheckInterval,
+		&upstream.MaxFails,
+		&upstream.FailTimeout,
+		&upstream.CreatorID,
+		&upstream.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get upstream: %w", err)
+	}
+	return upstream, nil
+}
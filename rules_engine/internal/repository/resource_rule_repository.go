@@ -1,6 +1,6 @@
 package repository
 
 type ResourceRuleRepository interface {
-	AttachRule(resourceID, ruleID string) error
+	AttachRule(resourceID, ruleID string, priority int) error
 	DetachRule(resourceID, ruleID string) error
 }
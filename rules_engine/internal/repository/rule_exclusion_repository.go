@@ -0,0 +1,11 @@
+package repository
+
+import "rules-engine/internal/entity"
+
+type RuleExclusionRepository interface {
+	GetExclusionsForResource(resourceID string) ([]entity.RuleExclusion, error)
+	GetExclusion(id string) (*entity.RuleExclusion, error)
+	CreateExclusion(exclusion *entity.RuleExclusion) (*entity.RuleExclusion, error)
+	UpdateExclusion(exclusion *entity.RuleExclusion) (*entity.RuleExclusion, error)
+	DeleteExclusion(id string) error
+}
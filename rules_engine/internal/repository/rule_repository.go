@@ -8,5 +8,4 @@ type RuleRepository interface {
 	UpdateRule(rule *entity.Rule) (*entity.Rule, error)
 	GetRule(id string) (*entity.Rule, error)
 	GetRulesForResource(id string) ([]entity.Rule, error)
-	GetRulesByURL(url, method string) ([]entity.Rule, error)
 }
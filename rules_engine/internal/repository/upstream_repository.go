@@ -0,0 +1,10 @@
+package repository
+
+import "rules-engine/internal/entity"
+
+type UpstreamRepository interface {
+	GetUpstreams() ([]entity.Upstream, error)
+	CreateUpstream(upstream *entity.Upstream) (*entity.Upstream, error)
+	UpdateUpstream(upstream *entity.Upstream) (*entity.Upstream, error)
+	GetUpstream(id string) (*entity.Upstream, error)
+}
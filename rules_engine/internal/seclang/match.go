@@ -0,0 +1,64 @@
+package seclang
+
+import "strings"
+
+// Match - сработавшее правило и переменная, на которой сработало первое звено цепочки.
+type Match struct {
+	RuleID   int
+	Msg      string
+	Severity string
+	VarName  string
+	Value    string
+}
+
+// Match проверяет правило и его цепочку. все звенья цепочки должны сработать.
+func (r *Rule) Match(tx *Transaction) (*Match, bool) {
+	var (
+		matched []Field
+		first   Field
+	)
+	for link := r; link != nil; link = link.chain {
+		matched = link.matchVariables(tx, matched)
+		if len(matched) == 0 {
+			return nil, false
+		}
+		if link == r {
+			first = matched[0]
+		}
+	}
+
+	msg := strings.NewReplacer("%{MATCHED_VAR_NAME}", first.Name, "%{MATCHED_VAR}", first.Value,
+		"%{matched_var_name}", first.Name, "%{matched_var}", first.Value).Replace(r.Msg)
+	return &Match{RuleID: r.ID, Msg: msg, Severity: r.Severity, VarName: first.Name, Value: first.Value}, true
+}
+
+// matchVariables возвращает все переменные, на которых сработал оператор: они нужны следующему звену цепочки.
+func (r *Rule) matchVariables(tx *Transaction, previous []Field) []Field {
+	var exclusions []Variable
+	for _, v := range r.variables {
+		if v.Exclude {
+			exclusions = append(exclusions, v)
+		}
+	}
+
+	var matched []Field
+	for _, v := range r.variables {
+		if v.Exclude {
+			continue
+		}
+		for _, f := range tx.fields(v, exclusions, previous) {
+			value := tx.transforms.Apply(r.transform, f.Value)
+			if r.operator.match(value) != r.operator.negate {
+				matched = append(matched, Field{Name: variableName(v, f), Value: value})
+			}
+		}
+	}
+	return matched
+}
+
+func variableName(v Variable, f Field) string {
+	if v.Count || f.Name == v.Collection || strings.HasPrefix(v.Collection, "MATCHED_VAR") {
+		return f.Name
+	}
+	return v.Collection + ":" + f.Name
+}
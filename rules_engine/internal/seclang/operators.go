@@ -0,0 +1,236 @@
+package seclang
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"rules-engine/internal/sqli"
+	"rules-engine/internal/xss"
+)
+
+type operator struct {
+	name   string
+	arg    string
+	negate bool
+	match  func(string) bool
+}
+
+func parseOperator(raw string) (*operator, error) {
+	raw = strings.TrimSpace(raw)
+	op := &operator{name: "rx"}
+	if strings.HasPrefix(raw, "!") {
+		op.negate, raw = true, strings.TrimSpace(raw[1:])
+	}
+
+	if strings.HasPrefix(raw, "@") {
+		name, arg, _ := strings.Cut(raw[1:], " ")
+		op.name, op.arg = name, strings.TrimSpace(arg)
+	} else {
+		op.arg = raw
+	}
+
+	if strings.Contains(op.arg, "%{") {
+		return nil, fmt.Errorf("macro expansion in @%s argument is not supported", op.name)
+	}
+
+	match, err := compileOperator(op.name, op.arg)
+	if err != nil {
+		return nil, err
+	}
+	op.match = match
+	return op, nil
+}
+
+func compileOperator(name, arg string) (func(string) bool, error) {
+	switch name {
+	case "rx":
+		pattern, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("@rx pattern is not supported by RE2: %v", err)
+		}
+		return pattern.MatchString, nil
+	case "pm":
+		phrases := strings.Fields(strings.ToLower(arg))
+		if len(phrases) == 0 {
+			return nil, fmt.Errorf("@pm requires at least one phrase")
+		}
+		return func(s string) bool {
+			s = strings.ToLower(s)
+			for _, phrase := range phrases {
+				if strings.Contains(s, phrase) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "contains":
+		return func(s string) bool { return strings.Contains(s, arg) }, nil
+	case "containsWord":
+		return func(s string) bool { return containsWord(s, arg) }, nil
+	case "streq":
+		return func(s string) bool { return s == arg }, nil
+	case "beginsWith":
+		return func(s string) bool { return strings.HasPrefix(s, arg) }, nil
+	case "endsWith":
+		return func(s string) bool { return strings.HasSuffix(s, arg) }, nil
+	case "within":
+		return func(s string) bool { return strings.Contains(arg, s) }, nil
+	case "eq", "ne", "gt", "ge", "lt", "le":
+		return compileNumeric(name, arg)
+	case "ipMatch":
+		return compileIPMatch(arg)
+	case "validateByteRange":
+		return compileByteRange(arg)
+	case "validateUrlEncoding":
+		return invalidURLEncoding, nil
+	case "validateUtf8Encoding":
+		return func(s string) bool { return !utf8.ValidString(s) }, nil
+	case "detectSQLi":
+		return func(s string) bool {
+			_, ok := sqli.Detect(s)
+			return ok
+		}, nil
+	case "detectXSS":
+		return func(s string) bool {
+			_, ok := xss.Detect(s)
+			return ok
+		}, nil
+	case "unconditionalMatch":
+		return func(string) bool { return true }, nil
+	case "noMatch":
+		return func(string) bool { return false }, nil
+	}
+	return nil, fmt.Errorf("operator @%s is not supported", name)
+}
+
+// invalidURLEncoding срабатывает на % без двух шестнадцатеричных цифр.
+func invalidURLEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if i+2 >= len(s) || !isHexDigit(s[i+1]) || !isHexDigit(s[i+2]) {
+			return true
+		}
+		i += 2
+	}
+	return false
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func containsWord(s, word string) bool {
+	for i := 0; ; {
+		idx := strings.Index(s[i:], word)
+		if idx == -1 {
+			return false
+		}
+		start, end := i+idx, i+idx+len(word)
+		if (start == 0 || !isWordChar(s[start-1])) && (end == len(s) || !isWordChar(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// compileNumeric сравнивает как atoi в ModSecurity: нечисловое значение считается нулем.
+func compileNumeric(name, arg string) (func(string) bool, error) {
+	want, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("@%s requires an integer argument, got %q", name, arg)
+	}
+
+	return func(s string) bool {
+		got, _ := strconv.Atoi(strings.TrimSpace(s))
+		switch name {
+		case "eq":
+			return got == want
+		case "ne":
+			return got != want
+		case "gt":
+			return got > want
+		case "ge":
+			return got >= want
+		case "lt":
+			return got < want
+		default:
+			return got <= want
+		}
+	}, nil
+}
+
+func compileIPMatch(arg string) (func(string) bool, error) {
+	var networks []*net.IPNet
+	for _, part := range strings.Split(arg, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if ip := net.ParseIP(part); ip != nil && ip.To4() != nil {
+				part += "/32"
+			} else {
+				part += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("@ipMatch: invalid address %q", part)
+		}
+		networks = append(networks, network)
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("@ipMatch requires at least one address")
+	}
+
+	return func(s string) bool {
+		ip := net.ParseIP(strings.Trim(s, "[]"))
+		if ip == nil {
+			return false
+		}
+		for _, network := range networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// compileByteRange срабатывает, если в значении есть байт вне разрешенных диапазонов.
+func compileByteRange(arg string) (func(string) bool, error) {
+	var allowed [256]bool
+	for _, part := range strings.Split(arg, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		lo, err := strconv.Atoi(from)
+		hi := lo
+		if isRange && err == nil {
+			hi, err = strconv.Atoi(to)
+		}
+		if err != nil || lo < 0 || hi > 255 || lo > hi {
+			return nil, fmt.Errorf("@validateByteRange: invalid range %q", part)
+		}
+		for b := lo; b <= hi; b++ {
+			allowed[b] = true
+		}
+	}
+
+	return func(s string) bool {
+		for i := 0; i < len(s); i++ {
+			if !allowed[s[i]] {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
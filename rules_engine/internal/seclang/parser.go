@@ -0,0 +1,369 @@
+// Package seclang разбирает практичное подмножество ModSecurity SecLang (директиву SecRule) и вычисляет правила над запросом.
+package seclang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rules-engine/internal/transform"
+)
+
+// Rule - правило SecRule вместе с цепочкой chain. все части компилируются при разборе.
+type Rule struct {
+	ID         int
+	Phase      int
+	Msg        string
+	Severity   string
+	Tags       []string
+	Disruptive string
+	Raw        string
+	Line       int
+
+	variables []Variable
+	operator  *operator
+	transform *transform.Pipeline
+	chain     *Rule
+	chained   bool
+}
+
+// ParseError описывает директиву, которую не удалось импортировать.
+type ParseError struct {
+	Line   int    `json:"line"`
+	ID     int    `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e ParseError) Error() string {
+	if e.ID != 0 {
+		return fmt.Sprintf("line %d (rule %d): %s", e.Line, e.ID, e.Reason)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+type directive struct {
+	line int
+	raw  string
+	text string
+}
+
+// директивы без влияния на проверку запроса: метки для skipAfter и подпись набора правил
+var noopDirectives = map[string]bool{"SecMarker": true, "SecComponentSignature": true}
+
+// действия, которые не влияют на результат проверки запроса и при импорте пропускаются
+var ignoredActions = map[string]bool{
+	"log": true, "nolog": true, "auditlog": true, "noauditlog": true, "capture": true, "multiMatch": true,
+	"ctl": true, "setvar": true, "setenv": true, "ver": true, "rev": true, "maturity": true, "accuracy": true,
+	"status": true, "logdata": true, "expirevar": true, "initcol": true, "setuid": true, "setsid": true,
+	"deprecatevar": true, "sanitiseArg": true, "sanitiseMatched": true, "sanitiseMatchedBytes": true,
+	"sanitiseRequestHeader": true, "sanitiseResponseHeader": true,
+}
+
+// Parse разбирает файл правил. правила, которые не удалось разобрать, возвращаются как ошибки, остальные импортируются.
+// ошибка в любом звене цепочки отбрасывает всю цепочку.
+func Parse(src string) ([]*Rule, []ParseError) {
+	var (
+		rules []*Rule
+		errs  []ParseError
+		group []*Rule
+		err   *ParseError
+	)
+
+	for _, d := range splitDirectives(src) {
+		inChain := len(group) > 0
+		if !inChain && noopDirectives[strings.Fields(d.text)[0]] {
+			continue
+		}
+		rule, parseErr := parseDirective(d, inChain)
+		if parseErr != nil && err == nil {
+			err = &ParseError{Line: d.line, Reason: parseErr.Error()}
+			if inChain {
+				err.ID = group[0].ID
+			} else if rule != nil {
+				err.ID = rule.ID
+			}
+		}
+		if rule == nil {
+			// директива не SecRule прерывает цепочку
+			rule = &Rule{}
+		}
+
+		group = append(group, rule)
+		if rule.chained {
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, *err)
+		} else {
+			rules = append(rules, linkChain(group))
+		}
+		group, err = nil, nil
+	}
+
+	if len(group) > 0 {
+		if err == nil {
+			err = &ParseError{Line: group[0].Line, ID: group[0].ID, Reason: "chain is not terminated"}
+		}
+		errs = append(errs, *err)
+	}
+	return rules, errs
+}
+
+func parseDirective(d directive, inChain bool) (*Rule, error) {
+	args, err := splitArgs(d.text)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(args[0], "SecRule") {
+		return nil, fmt.Errorf("directive %s is not supported", args[0])
+	}
+
+	rule, err := parseRule(args[1:], inChain)
+	if rule != nil {
+		rule.Line, rule.Raw = d.line, d.raw
+	}
+	return rule, err
+}
+
+func linkChain(group []*Rule) *Rule {
+	head := group[0]
+	for i := 1; i < len(group); i++ {
+		group[i-1].chain = group[i]
+		head.Raw += "\n" + group[i].Raw
+	}
+	return head
+}
+
+// ParanoiaLevel возвращает уровень паранойи из тега paranoia-level/N, которым CRS размечает правила.
+func (r *Rule) ParanoiaLevel() int {
+	for _, tag := range r.Tags {
+		if level, ok := strings.CutPrefix(tag, "paranoia-level/"); ok {
+			if n, err := strconv.Atoi(level); err == nil && n >= 1 && n <= 4 {
+				return n
+			}
+		}
+	}
+	return 1
+}
+
+// Compile разбирает исходный текст одного правила (с цепочкой), сохраненного при импорте.
+func Compile(raw string) (*Rule, error) {
+	rules, errs := Parse(raw)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if len(rules) != 1 {
+		return nil, fmt.Errorf("expected exactly one SecRule, got %d", len(rules))
+	}
+	return rules[0], nil
+}
+
+// splitDirectives склеивает строки с продолжением '\' и отбрасывает комментарии.
+func splitDirectives(src string) []directive {
+	var (
+		directives []directive
+		current    *directive
+	)
+
+	for i, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if current == nil {
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			current = &directive{line: i + 1}
+		} else {
+			current.raw += "\n"
+		}
+
+		current.raw += line
+		if strings.HasSuffix(trimmed, `\`) {
+			current.text += strings.TrimSuffix(trimmed, `\`) + " "
+			continue
+		}
+		current.text += trimmed
+		directives = appendDirective(directives, current)
+		current = nil
+	}
+
+	if current != nil {
+		directives = appendDirective(directives, current)
+	}
+	return directives
+}
+
+// appendDirective пропускает директиву из одних переносов строки через \: у нее нет даже имени.
+func appendDirective(directives []directive, d *directive) []directive {
+	if strings.TrimSpace(d.text) == "" {
+		return directives
+	}
+	return append(directives, *d)
+}
+
+// splitArgs разбивает директиву на аргументы. в кавычках \" означает кавычку, остальные \ сохраняются для регулярок.
+func splitArgs(text string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == ' ' || text[i] == '\t':
+			i++
+		case text[i] == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(text) && text[j] != '"'; j++ {
+				if text[j] == '\\' && j+1 < len(text) && text[j+1] == '"' {
+					j++
+				}
+				b.WriteByte(text[j])
+			}
+			if j >= len(text) {
+				return nil, fmt.Errorf("unterminated quoted argument")
+			}
+			args = append(args, b.String())
+			i = j + 1
+		default:
+			j := i
+			for j < len(text) && text[j] != ' ' && text[j] != '\t' {
+				j++
+			}
+			args = append(args, text[i:j])
+			i = j
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty directive")
+	}
+	return args, nil
+}
+
+func parseRule(args []string, inChain bool) (*Rule, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("SecRule expects variables, operator and actions")
+	}
+
+	rule := &Rule{Phase: 2, Disruptive: "pass"}
+	var transforms []string
+	if len(args) == 3 {
+		var err error
+		transforms, err = rule.parseActions(args[2], inChain)
+		if err != nil {
+			return rule, err
+		}
+	}
+	if !inChain && rule.ID == 0 {
+		return rule, fmt.Errorf("rule has no id")
+	}
+
+	variables, err := parseVariables(args[0])
+	if err != nil {
+		return rule, err
+	}
+	rule.variables = variables
+
+	op, err := parseOperator(args[1])
+	if err != nil {
+		return rule, err
+	}
+	rule.operator = op
+
+	rule.transform, err = transform.Compile(transforms)
+	if err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+// parseActions доходит до конца списка даже после ошибки, чтобы цепочка с ошибкой была распознана целиком.
+func (r *Rule) parseActions(raw string, inChain bool) ([]string, error) {
+	var (
+		transforms []string
+		firstErr   error
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, action := range splitActions(raw) {
+		name, value, _ := strings.Cut(action, ":")
+		name = strings.TrimSpace(name)
+		value = strings.Trim(strings.TrimSpace(value), "'")
+
+		switch name {
+		case "id":
+			id, err := strconv.Atoi(value)
+			if err != nil || id <= 0 {
+				fail(fmt.Errorf("invalid id %q", value))
+			}
+			r.ID = id
+		case "phase":
+			phase, err := parsePhase(value)
+			if err != nil {
+				fail(err)
+			}
+			r.Phase = phase
+		case "deny", "block", "drop", "pass", "allow":
+			if inChain {
+				fail(fmt.Errorf("disruptive action %s is only allowed in the chain starter", name))
+			}
+			r.Disruptive = name
+		case "msg":
+			r.Msg = value
+		case "severity":
+			r.Severity = value
+		case "tag":
+			r.Tags = append(r.Tags, value)
+		case "t":
+			transforms = append(transforms, value)
+		case "chain":
+			r.chained = true
+		case "skip", "skipAfter":
+			fail(fmt.Errorf("flow control action %s is not supported", name))
+		case "redirect", "proxy", "exec":
+			fail(fmt.Errorf("action %s is not supported", name))
+		default:
+			if !ignoredActions[name] {
+				fail(fmt.Errorf("unknown action %s", name))
+			}
+		}
+	}
+	return transforms, firstErr
+}
+
+func parsePhase(value string) (int, error) {
+	switch value {
+	case "1", "2":
+		return int(value[0] - '0'), nil
+	case "request":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("phase %s is not supported: only request phases 1 and 2 are evaluated", value)
+}
+
+// splitActions делит список действий по запятым вне одинарных кавычек.
+func splitActions(raw string) []string {
+	var (
+		actions []string
+		quoted  bool
+		start   int
+	)
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				actions = append(actions, strings.TrimSpace(raw[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(raw[start:]); last != "" {
+		actions = append(actions, last)
+	}
+	return actions
+}
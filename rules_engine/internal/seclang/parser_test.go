@@ -0,0 +1,39 @@
+package seclang
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		ids    []int
+		errors int
+	}{
+		{"empty", "", nil, 0},
+		{"only continuation", "\\\n\n", nil, 0},
+		{"trailing continuation", "\\", nil, 0},
+		{"blank continuations", "  \\\n  \\\n\n# comment\n", nil, 0},
+		{"rule", `SecRule ARGS "@rx attack" "id:1,phase:2,deny"`, []int{1}, 0},
+		{"continued rule", "SecRule ARGS \"@rx attack\" \\\n  \"id:2,phase:2,deny\"", []int{2}, 0},
+		{"chain", "SecRule ARGS \"@rx a\" \"id:3,phase:2,deny,chain\"\nSecRule ARGS \"@rx b\" \"\"", []int{3}, 0},
+		{"marker between rules", "SecMarker END\n\\\n\nSecRule ARGS \"@rx a\" \"id:4,phase:2,deny\"", []int{4}, 0},
+		{"unknown directive", "SecFoo bar", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, errs := Parse(tt.src)
+			if len(errs) != tt.errors {
+				t.Fatalf("expected %d errors, got %v", tt.errors, errs)
+			}
+			if len(rules) != len(tt.ids) {
+				t.Fatalf("expected %d rules, got %d", len(tt.ids), len(rules))
+			}
+			for i, rule := range rules {
+				if rule.ID != tt.ids[i] {
+					t.Errorf("expected rule %d, got %d", tt.ids[i], rule.ID)
+				}
+			}
+		})
+	}
+}
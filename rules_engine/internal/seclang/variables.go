@@ -0,0 +1,259 @@
+package seclang
+
+import (
+	"fmt"
+	"net/textproto"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"rules-engine/internal/inspect"
+	"rules-engine/internal/transform"
+)
+
+// Variable - элемент списка переменных SecRule: ARGS, !ARGS:foo, &ARGS, REQUEST_HEADERS:/^x-/.
+type Variable struct {
+	Collection string
+	Key        string
+	Exclude    bool
+	Count      bool
+
+	keyPattern *regexp.Regexp
+}
+
+// Field - одно значение коллекции.
+type Field = inspect.Field
+
+// коллекции, которые разбираются из запроса
+var collections = map[string]bool{
+	"ARGS": true, "ARGS_GET": true, "ARGS_POST": true,
+	"ARGS_NAMES": true, "ARGS_GET_NAMES": true, "ARGS_POST_NAMES": true,
+	"REQUEST_HEADERS": true, "REQUEST_HEADERS_NAMES": true,
+	"REQUEST_COOKIES": true, "REQUEST_COOKIES_NAMES": true,
+	"REQUEST_BODY": true, "REQUEST_URI": true, "REQUEST_URI_RAW": true, "REQUEST_FILENAME": true,
+	"REQUEST_BASENAME": true, "REQUEST_METHOD": true, "REQUEST_LINE": true, "REQUEST_PROTOCOL": true,
+	"QUERY_STRING": true, "REMOTE_ADDR": true, "SERVER_NAME": true,
+	"ARGS_COMBINED_SIZE": true, "REQUEST_BODY_LENGTH": true,
+	"FILES": true, "FILES_NAMES": true, "FILES_SIZES": true, "FILES_COMBINED_SIZE": true,
+	"MULTIPART_PART_HEADERS": true, "XML": true,
+	"REQBODY_PROCESSOR": true, "REQBODY_ERROR": true, "REQBODY_ERROR_MSG": true,
+	"MATCHED_VAR": true, "MATCHED_VAR_NAME": true, "MATCHED_VARS": true, "MATCHED_VARS_NAMES": true,
+}
+
+// коллекции, которые распознаются, но пока не заполняются: переменные из них не дают значений
+var emptyCollections = map[string]bool{
+	"MULTIPART_STRICT_ERROR": true, "MULTIPART_UNMATCHED_BOUNDARY": true,
+	"TX": true, "IP": true, "GEO": true, "UNIQUE_ID": true,
+}
+
+func parseVariables(raw string) ([]Variable, error) {
+	var variables []Variable
+	for _, part := range strings.Split(raw, "|") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		v := Variable{}
+		if strings.HasPrefix(part, "!") {
+			v.Exclude, part = true, part[1:]
+		}
+		if strings.HasPrefix(part, "&") {
+			v.Count, part = true, part[1:]
+		}
+
+		name, key, _ := strings.Cut(part, ":")
+		v.Collection = strings.ToUpper(name)
+		if !collections[v.Collection] && !emptyCollections[v.Collection] {
+			return nil, fmt.Errorf("variable %s is not supported", name)
+		}
+
+		if strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/") && len(key) > 1 {
+			pattern, err := regexp.Compile("(?i)" + key[1:len(key)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid variable key regex %s: %v", key, err)
+			}
+			v.keyPattern = pattern
+		} else {
+			v.Key = strings.Trim(key, "'")
+		}
+
+		if v.Exclude && v.Key == "" && v.keyPattern == nil {
+			return nil, fmt.Errorf("exclusion %s must select a key", part)
+		}
+		variables = append(variables, v)
+	}
+
+	if len(variables) == 0 {
+		return nil, fmt.Errorf("rule has no variables")
+	}
+	return variables, nil
+}
+
+func (v Variable) matchesKey(name string) bool {
+	// XML:/* выбирает текст всех элементов, XML://@* - все атрибуты
+	if v.Collection == "XML" {
+		switch v.Key {
+		case "/*":
+			return !strings.Contains(name, "/@")
+		case "//@*":
+			return strings.Contains(name, "/@")
+		}
+	}
+
+	switch {
+	case v.keyPattern != nil:
+		return v.keyPattern.MatchString(name)
+	case v.Key != "":
+		return strings.EqualFold(v.Key, name)
+	}
+	return true
+}
+
+// Transaction - разобранный запрос: коллекции SecLang строятся один раз и используются всеми правилами.
+type Transaction struct {
+	collections map[string][]Field
+	transforms  *transform.Cache
+}
+
+func NewTransaction(request *inspect.Request) *Transaction {
+	tx := &Transaction{collections: make(map[string][]Field), transforms: request.Transforms}
+	raw := request.Raw
+
+	headers := make([]Field, 0, len(raw.Headers)+1)
+	hasHost := false
+	for name, value := range raw.Headers {
+		if textproto.CanonicalMIMEHeaderKey(name) == "Host" {
+			hasHost = true
+		}
+		headers = append(headers, Field{Name: name, Value: value})
+	}
+	if !hasHost && raw.Host != "" {
+		headers = append(headers, Field{Name: "Host", Value: raw.Host})
+	}
+	tx.setWithNames("REQUEST_HEADERS", headers)
+	tx.setWithNames("REQUEST_COOKIES", request.Cookies)
+
+	args := request.Args()
+	tx.setWithNames("ARGS_GET", request.ArgsGet)
+	tx.setWithNames("ARGS_POST", request.ArgsPost)
+	tx.setWithNames("ARGS", args)
+
+	combined := 0
+	for _, f := range args {
+		combined += len(f.Name) + len(f.Value)
+	}
+	tx.set("ARGS_COMBINED_SIZE", strconv.Itoa(combined))
+	tx.set("REQUEST_BODY_LENGTH", strconv.Itoa(len(raw.Body)))
+
+	// в FILES ключ - имя поля формы, значение - имя файла, как в ModSecurity
+	files := make([]Field, len(request.Files))
+	fileNames := make([]Field, len(request.Files))
+	sizes := make([]Field, len(request.Files))
+	combinedFiles := 0
+	for i, f := range request.Files {
+		files[i] = Field{Name: f.Field, Value: f.Filename}
+		fileNames[i] = Field{Name: f.Field, Value: f.Field}
+		sizes[i] = Field{Name: f.Field, Value: strconv.Itoa(f.Size)}
+		combinedFiles += f.Size
+	}
+	tx.collections["FILES"] = files
+	tx.collections["FILES_NAMES"] = fileNames
+	tx.collections["FILES_SIZES"] = sizes
+	tx.set("FILES_COMBINED_SIZE", strconv.Itoa(combinedFiles))
+	tx.collections["MULTIPART_PART_HEADERS"] = request.PartHeaders
+	tx.collections["XML"] = request.XML
+
+	tx.set("REQBODY_PROCESSOR", request.Processor)
+	reqbodyError := "0"
+	if request.BodyError != nil {
+		reqbodyError = "1"
+		tx.set("REQBODY_ERROR_MSG", request.BodyError.Error())
+	} else {
+		tx.set("REQBODY_ERROR_MSG", "")
+	}
+	tx.set("REQBODY_ERROR", reqbodyError)
+
+	uri := raw.URL
+	tx.set("REQUEST_BODY", raw.Body)
+	tx.set("REQUEST_URI", uri)
+	tx.set("REQUEST_URI_RAW", uri)
+	tx.set("REQUEST_FILENAME", request.Path)
+	tx.set("REQUEST_BASENAME", path.Base(request.Path))
+	tx.set("REQUEST_METHOD", raw.Method)
+	tx.set("REQUEST_PROTOCOL", "HTTP/1.1")
+	tx.set("REQUEST_LINE", raw.Method+" "+uri+" HTTP/1.1")
+	tx.set("QUERY_STRING", request.Query)
+	tx.set("REMOTE_ADDR", raw.IP)
+	tx.set("SERVER_NAME", raw.Host)
+	return tx
+}
+
+func (tx *Transaction) set(name, value string) {
+	tx.collections[name] = []Field{{Name: name, Value: value}}
+}
+
+func (tx *Transaction) setWithNames(name string, fields []Field) {
+	names := make([]Field, len(fields))
+	for i, f := range fields {
+		names[i] = Field{Name: f.Name, Value: f.Name}
+	}
+	tx.collections[name] = fields
+	tx.collections[name+"_NAMES"] = names
+}
+
+// fields возвращает значения переменной с учетом исключений из того же списка переменных.
+// matched - переменные, на которых сработало предыдущее звено цепочки (MATCHED_VAR и MATCHED_VARS).
+func (tx *Transaction) fields(v Variable, exclusions []Variable, matched []Field) []Field {
+	source, ok := tx.collections[v.Collection]
+	switch v.Collection {
+	case "MATCHED_VARS", "MATCHED_VARS_NAMES", "MATCHED_VAR", "MATCHED_VAR_NAME":
+		source, ok = matchedFields(v.Collection, matched), true
+	}
+	if !ok {
+		// незаполненная коллекция не дает значений, в том числе и счетчика
+		return nil
+	}
+
+	var selected []Field
+	for _, f := range source {
+		if !v.matchesKey(f.Name) {
+			continue
+		}
+		excluded := false
+		for _, ex := range exclusions {
+			if ex.Collection == v.Collection && ex.matchesKey(f.Name) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			selected = append(selected, f)
+		}
+	}
+
+	if v.Count {
+		return []Field{{Name: "&" + v.Collection, Value: fmt.Sprint(len(selected))}}
+	}
+	return selected
+}
+
+func matchedFields(collection string, matched []Field) []Field {
+	if len(matched) == 0 {
+		return []Field{}
+	}
+
+	fields := matched
+	if collection == "MATCHED_VAR" || collection == "MATCHED_VAR_NAME" {
+		fields = matched[len(matched)-1:]
+	}
+	if collection == "MATCHED_VARS_NAMES" || collection == "MATCHED_VAR_NAME" {
+		names := make([]Field, len(fields))
+		for i, f := range fields {
+			names[i] = Field{Name: f.Name, Value: f.Name}
+		}
+		return names
+	}
+	return fields
+}
@@ -0,0 +1,51 @@
+package sqli
+
+// классы слов sql. все, чего нет в таблице, - bareword (n), а bareword перед скобкой - функция (f)
+var words = map[string]byte{
+	// начало оператора
+	"SELECT": tokenStatement, "INSERT": tokenStatement, "UPDATE": tokenStatement, "DELETE": tokenStatement,
+	"DROP": tokenStatement, "CREATE": tokenStatement, "ALTER": tokenStatement, "TRUNCATE": tokenStatement,
+	"REPLACE": tokenStatement, "RENAME": tokenStatement, "GRANT": tokenStatement, "REVOKE": tokenStatement,
+	"EXEC": tokenStatement, "EXECUTE": tokenStatement, "DECLARE": tokenStatement, "SHUTDOWN": tokenStatement,
+	"WAITFOR": tokenStatement, "HANDLER": tokenStatement, "LOAD": tokenStatement, "CALL": tokenStatement,
+	"SHOW": tokenStatement, "DESCRIBE": tokenStatement, "USE": tokenStatement, "BEGIN": tokenStatement,
+	"COPY": tokenStatement, "PREPARE": tokenStatement,
+
+	"UNION": tokenUnion, "EXCEPT": tokenUnion, "INTERSECT": tokenUnion, "MINUS": tokenUnion,
+
+	"AND": tokenLogic, "OR": tokenLogic, "XOR": tokenLogic,
+
+	// операторы-слова
+	"LIKE": tokenOperator, "RLIKE": tokenOperator, "ILIKE": tokenOperator, "REGEXP": tokenOperator,
+	"SOUNDS": tokenOperator, "IS": tokenOperator, "IN": tokenOperator, "BETWEEN": tokenOperator,
+	"DIV": tokenOperator, "MOD": tokenOperator, "NOT": tokenOperator, "COLLATE": tokenOperator,
+	"ESCAPE": tokenOperator, "SIMILAR": tokenOperator, "GLOB": tokenOperator, "MATCH": tokenOperator,
+
+	// литералы ведут себя как числа
+	"NULL": tokenNumber, "TRUE": tokenNumber, "FALSE": tokenNumber, "UNKNOWN": tokenNumber,
+
+	// части запроса после условия: по ним видно продолжение запроса за числом
+	"ORDER": tokenClause, "GROUP": tokenClause, "BY": tokenClause, "HAVING": tokenClause, "LIMIT": tokenClause,
+	"INTO": tokenClause, "PROCEDURE": tokenClause,
+
+	// части запроса
+	"FROM": tokenKeyword, "WHERE": tokenKeyword, "OFFSET": tokenKeyword, "OUTFILE": tokenKeyword,
+	"DUMPFILE": tokenKeyword, "VALUES": tokenKeyword, "SET": tokenKeyword, "TABLE": tokenKeyword,
+	"DATABASE": tokenKeyword, "JOIN": tokenKeyword,
+	"ON": tokenKeyword, "AS": tokenKeyword, "ALL": tokenKeyword, "DISTINCT": tokenKeyword, "DELAY": tokenKeyword,
+	"CASE": tokenKeyword, "WHEN": tokenKeyword, "THEN": tokenKeyword, "ELSE": tokenKeyword, "END": tokenKeyword,
+	"ASC": tokenKeyword, "DESC": tokenKeyword, "TOP": tokenKeyword, "FETCH": tokenKeyword, "RETURNING": tokenKeyword,
+	"INFORMATION_SCHEMA": tokenKeyword, "SYSOBJECTS": tokenKeyword, "SYSCOLUMNS": tokenKeyword,
+
+	// функции, которые встречаются в атаках и без скобки сразу после имени
+	"SLEEP": tokenFunction, "BENCHMARK": tokenFunction, "PG_SLEEP": tokenFunction, "CHAR": tokenFunction,
+	"CHR": tokenFunction, "CONCAT": tokenFunction, "CONCAT_WS": tokenFunction, "GROUP_CONCAT": tokenFunction,
+	"SUBSTRING": tokenFunction, "SUBSTR": tokenFunction, "MID": tokenFunction, "ASCII": tokenFunction,
+	"ORD": tokenFunction, "VERSION": tokenFunction, "USER": tokenFunction, "CURRENT_USER": tokenFunction,
+	"SYSTEM_USER": tokenFunction, "SESSION_USER": tokenFunction, "LOAD_FILE": tokenFunction,
+	"EXTRACTVALUE": tokenFunction, "UPDATEXML": tokenFunction, "CAST": tokenFunction, "CONVERT": tokenFunction,
+	"IF": tokenFunction, "IFNULL": tokenFunction, "NULLIF": tokenFunction, "COALESCE": tokenFunction,
+	"COUNT": tokenFunction, "HEX": tokenFunction, "UNHEX": tokenFunction, "MD5": tokenFunction,
+	"SHA1": tokenFunction, "LENGTH": tokenFunction, "LEN": tokenFunction, "DBMS_PIPE": tokenFunction,
+	"UTL_INADDR": tokenFunction, "XP_CMDSHELL": tokenFunction, "RANDOMBLOB": tokenFunction,
+}
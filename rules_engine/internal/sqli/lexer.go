@@ -0,0 +1,245 @@
+package sqli
+
+import "strings"
+
+// классы токенов совпадают по смыслу с буквами отпечатков libinjection
+const (
+	tokenString    byte = 's'
+	tokenNumber    byte = '1'
+	tokenBareword  byte = 'n'
+	tokenKeyword   byte = 'k'
+	tokenClause    byte = 'B'
+	tokenStatement byte = 'E'
+	tokenUnion     byte = 'U'
+	tokenFunction  byte = 'f'
+	tokenLogic     byte = '&'
+	tokenOperator  byte = 'o'
+	tokenVariable  byte = 'v'
+	tokenComment   byte = 'c'
+	tokenLeftPar   byte = '('
+	tokenRightPar  byte = ')'
+	tokenComma     byte = ','
+	tokenSemicolon byte = ';'
+)
+
+type token struct {
+	class byte
+	value string
+}
+
+type lexer struct {
+	s   string
+	pos int
+}
+
+// tokenize разбирает вход. ненулевая quote означает контекст строки: вход начинается внутри литерала с этой кавычкой.
+func tokenize(s string, quote byte) []token {
+	l := &lexer{s: s}
+
+	var tokens []token
+	if quote != 0 {
+		tokens = append(tokens, l.quoted(quote))
+	}
+	for l.pos < len(l.s) {
+		if tok, ok := l.next(); ok {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+func (l *lexer) next() (token, bool) {
+	c := l.s[l.pos]
+	switch {
+	case isSpace(c):
+		l.pos++
+		return token{}, false
+	case c == '\'' || c == '"':
+		l.pos++
+		return l.quoted(c), true
+	case c == '`':
+		// идентификатор в обратных кавычках
+		l.pos++
+		end := strings.IndexByte(l.s[l.pos:], '`')
+		if end == -1 {
+			end = len(l.s) - l.pos
+		}
+		value := l.s[l.pos : l.pos+end]
+		l.pos = min(len(l.s), l.pos+end+1)
+		return token{class: tokenBareword, value: value}, true
+	case c == '#':
+		return l.comment(len(l.s)), true
+	case strings.HasPrefix(l.s[l.pos:], "--"):
+		return l.comment(len(l.s)), true
+	case strings.HasPrefix(l.s[l.pos:], "/*!"):
+		// исполняемый комментарий MySQL /*!50000 union*/: содержимое - это код
+		l.pos += 3
+		for i := 0; i < 5 && l.pos < len(l.s) && isDigit(l.s[l.pos]); i++ {
+			l.pos++
+		}
+		return token{}, false
+	case strings.HasPrefix(l.s[l.pos:], "/*"):
+		end := strings.Index(l.s[l.pos+2:], "*/")
+		if end == -1 {
+			return l.comment(len(l.s)), true
+		}
+		return l.comment(l.pos + 2 + end + 2), true
+	case strings.HasPrefix(l.s[l.pos:], "*/"):
+		// закрытие исполняемого комментария
+		l.pos += 2
+		return token{}, false
+	case c == '(':
+		l.pos++
+		return token{class: tokenLeftPar, value: "("}, true
+	case c == ')':
+		l.pos++
+		return token{class: tokenRightPar, value: ")"}, true
+	case c == ',':
+		l.pos++
+		return token{class: tokenComma, value: ","}, true
+	case c == ';':
+		l.pos++
+		return token{class: tokenSemicolon, value: ";"}, true
+	case c == '@':
+		return l.variable(), true
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.s) && isDigit(l.s[l.pos+1])):
+		return l.number(), true
+	case isWordStart(c):
+		return l.word(), true
+	}
+	return l.operator(), true
+}
+
+// quoted читает строку до закрывающей кавычки. удвоенная кавычка и экранирование обратным слэшем строку не закрывают.
+// незакрытая строка остается строкой: приложение само допишет закрывающую кавычку.
+func (l *lexer) quoted(quote byte) token {
+	start := l.pos
+	for l.pos < len(l.s) {
+		switch l.s[l.pos] {
+		case '\\':
+			l.pos += 2
+			continue
+		case quote:
+			if l.pos+1 < len(l.s) && l.s[l.pos+1] == quote {
+				l.pos += 2
+				continue
+			}
+			value := l.s[start:l.pos]
+			l.pos++
+			return token{class: tokenString, value: value}
+		}
+		l.pos++
+	}
+	l.pos = len(l.s)
+	return token{class: tokenString, value: l.s[start:]}
+}
+
+func (l *lexer) comment(end int) token {
+	value := l.s[l.pos:end]
+	l.pos = end
+	return token{class: tokenComment, value: value}
+}
+
+func (l *lexer) variable() token {
+	start := l.pos
+	for l.pos < len(l.s) && l.s[l.pos] == '@' {
+		l.pos++
+	}
+	for l.pos < len(l.s) && isWordChar(l.s[l.pos]) {
+		l.pos++
+	}
+	return token{class: tokenVariable, value: l.s[start:l.pos]}
+}
+
+// number читает только саму запись числа: MySQL разбирает 1and как 1 and, и лексер должен делать так же.
+func (l *lexer) number() token {
+	start := l.pos
+	if prefix := strings.ToLower(l.s[l.pos:min(len(l.s), l.pos+2)]); prefix == "0x" || prefix == "0b" {
+		l.pos += 2
+		for l.pos < len(l.s) && isHex(l.s[l.pos]) {
+			l.pos++
+		}
+		return token{class: tokenNumber, value: l.s[start:l.pos]}
+	}
+
+	for l.pos < len(l.s) && (isDigit(l.s[l.pos]) || l.s[l.pos] == '.') {
+		l.pos++
+	}
+	// экспонента 1e-5
+	if l.pos < len(l.s) && (l.s[l.pos] == 'e' || l.s[l.pos] == 'E') {
+		i := l.pos + 1
+		if i < len(l.s) && (l.s[i] == '-' || l.s[i] == '+') {
+			i++
+		}
+		if i < len(l.s) && isDigit(l.s[i]) {
+			l.pos = i
+			for l.pos < len(l.s) && isDigit(l.s[l.pos]) {
+				l.pos++
+			}
+		}
+	}
+	return token{class: tokenNumber, value: l.s[start:l.pos]}
+}
+
+func (l *lexer) word() token {
+	start := l.pos
+	for l.pos < len(l.s) && (isWordChar(l.s[l.pos]) || l.s[l.pos] == '.') {
+		l.pos++
+	}
+	value := l.s[start:l.pos]
+
+	class, known := words[strings.ToUpper(value)]
+	if !known {
+		class = tokenBareword
+	}
+
+	// слово перед скобкой - вызов функции. известная функция без скобки - обычное слово
+	if class == tokenBareword || class == tokenFunction {
+		class = tokenBareword
+		if rest := strings.TrimLeft(l.s[l.pos:], " \t\r\n\v\f"); strings.HasPrefix(rest, "(") {
+			class = tokenFunction
+		}
+	}
+	return token{class: class, value: value}
+}
+
+var operators = []string{"<=>", "!<", "!>", "<>", "!=", "<=", ">=", "||", "&&", ":=", "::", "<<", ">>"}
+
+func (l *lexer) operator() token {
+	for _, op := range operators {
+		if strings.HasPrefix(l.s[l.pos:], op) {
+			l.pos += len(op)
+			if op == "||" || op == "&&" {
+				return token{class: tokenLogic, value: op}
+			}
+			return token{class: tokenOperator, value: op}
+		}
+	}
+	value := l.s[l.pos : l.pos+1]
+	l.pos++
+	return token{class: tokenOperator, value: value}
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\v', '\f', 0:
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func isWordStart(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c == '_' || c == '$' || c >= 0x80
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || isDigit(c)
+}
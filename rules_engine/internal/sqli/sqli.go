@@ -0,0 +1,142 @@
+// Package sqli распознает sql-инъекции по последовательности токенов, как libinjection.
+//
+// вход разбирается лексером sql в трех контекстах: как есть (числовой параметр) и как продолжение
+// строки в одинарных и двойных кавычках. из токенов строится отпечаток вида s&sos для ' or '1'='1,
+// который сравнивается с шаблонами атак. обычный текст дает отпечатки из слов и не совпадает с ними.
+package sqli
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxFingerprint - сколько токенов отпечатка учитывается: атака видна по началу
+const maxFingerprint = 8
+
+// шаблоны для входа в контексте строки: отпечаток начинается с закрытой строки s
+var quotePatterns = compile(
+	// ' or '1'='1, ' or 1=1--, ') or ('a'='a, ' or true#
+	`^s\)*&\(*[s1nv]\)*([o&;c]|$)`,
+	// ' and sleep(5), ' or (select ...)
+	`^s\)*&\(*[fE]`,
+	// '+sleep(5)+', '||(select ...)||', '='
+	`^s\)*o\(*[s1vfE]`,
+	// ' union select
+	`^s\)*U`,
+	// '; drop table users
+	`^s\)*;\(*E`,
+	// admin'--, admin')#
+	`^s\)*c$`,
+	// ' order by 5--, ' having 1=1, ' into outfile
+	`^s\)*B`,
+)
+
+// шаблоны для входа как есть: числовой параметр или имя колонки
+var plainPatterns = compile(
+	// 1 or 1=1, 1) or (1=1, 1 and true--. одно 1 or 2 без продолжения не отличить от текста
+	`^1\)*&\(*[1sv]\)*[o&;c]`,
+	// id or 1=1, id and 'a'='a
+	`^[nv]\)*&\(*[1sv]\)*o`,
+	// 1 and x=1
+	`^[1nv]\)*&\(*n\)*o\(*[1sv]`,
+	// 1 and sleep(5), 1 or (select ...)
+	`^1\)*&\(*[fE]`,
+	`^[nv]\)*&\(*f`,
+	// 1 union select, -1 union (select. после слова union select - это еще и обычный текст
+	`^[1v]\)*U\(*E`,
+	// 1 rlike (select 1), 1=(select 1)
+	`^[1nv]\)*o\(*E`,
+	// 1; drop table users
+	`^[1nv]\)*;\(*E`,
+	// 1 order by 5, 1 procedure analyse()
+	`^1\)*B`,
+	// select * from, select @@version, select char(...)
+	`^\(*E[ovf]`,
+	// select a, b from users; select password from users
+	`^\(*E[1snv](,[1snfv])*k[n(]`,
+)
+
+func compile(patterns ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		compiled[i] = regexp.MustCompile(p)
+	}
+	return compiled
+}
+
+// Detect сообщает, похож ли вход на sql-инъекцию, и возвращает отпечаток, по которому это решено.
+func Detect(input string) (string, bool) {
+	if fp := Fingerprint(input, 0); matchAny(plainPatterns, fp) {
+		return fp, true
+	}
+	// без кавычки во входе контекст строки дает одну незакрытую строку
+	for _, quote := range []byte{'\'', '"'} {
+		if strings.IndexByte(input, quote) == -1 {
+			continue
+		}
+		if fp := Fingerprint(input, quote); matchAny(quotePatterns, fp) {
+			return fp, true
+		}
+	}
+	return "", false
+}
+
+// Fingerprint возвращает отпечаток входа в контексте quote (0 - вход как есть).
+func Fingerprint(input string, quote byte) string {
+	return fold(tokenize(input, quote))
+}
+
+// fold сворачивает токены в отпечаток: убирает комментарии внутри выражения, унарные операторы
+// и слова, не меняющие смысла (union all), и склеивает соседние строки и операторы.
+func fold(tokens []token) string {
+	var fp []byte
+	for i, tok := range tokens {
+		if len(fp) == maxFingerprint {
+			break
+		}
+
+		var last byte
+		if len(fp) > 0 {
+			last = fp[len(fp)-1]
+		}
+
+		switch {
+		case tok.class == tokenComment && i < len(tokens)-1:
+			// 1/**/or/**/1=1: комментарий внутри выражения - это пробел
+			continue
+		case tok.class == tokenOperator && isUnary(tok.value) && (last == 0 || strings.IndexByte("o&(,kBE;U", last) != -1):
+			continue
+		case tok.class == tokenOperator && last == tokenOperator:
+			// not like, <=-, is not
+			continue
+		case tok.class == tokenString && last == tokenString:
+			// 'a' 'b' в MySQL - одна строка
+			continue
+		case tok.class == tokenKeyword && last == tokenUnion:
+			// union all, union distinct
+			continue
+		case tok.class == tokenClause && last == tokenClause:
+			// order by, group by
+			continue
+		}
+		fp = append(fp, tok.class)
+	}
+	return string(fp)
+}
+
+func isUnary(op string) bool {
+	switch strings.ToUpper(op) {
+	case "+", "-", "!", "~", "NOT":
+		return true
+	}
+	return false
+}
+
+func matchAny(patterns []*regexp.Regexp, fp string) bool {
+	for _, p := range patterns {
+		if p.MatchString(fp) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+// Package ssti ищет внедрение в серверные шаблоны: выражение в синтаксисе шаблонизатора ({{7*7}}, ${7*7},
+// <%= 7*7 %>, #{7*7}) с арифметикой или обращением к объектам, через которые выполняется код.
+//
+// обычная подстановка вроде {{ user.name }} атакой не считается: так пишут тексты о самих шаблонах.
+package ssti
+
+import (
+	"regexp"
+	"strings"
+)
+
+// expression - выражение внутри разделителей jinja2/twig, spring el/freemarker, erb/jsp, ruby/thymeleaf и razor
+var expression = regexp.MustCompile(`\{\{(.{1,200}?)\}\}|\{%(.{1,200}?)%\}|[$#*@]\{(.{1,200}?)\}|<%=?(.{1,200}?)%>|@\((.{1,200}?)\)`)
+
+var (
+	// arithmetic - проба шаблонизатора: 7*7 или 7*'7' превращается в 49 или 7777777
+	arithmetic = regexp.MustCompile(`\b\d+\s*[-*+/%]\s*['"]?\d+\b`)
+	// dangerous - объекты и методы, через которые из шаблона читается конфигурация или выполняется код
+	dangerous = regexp.MustCompile(`(?i)__\w+__|\b(?:config|self|lipsum|cycler|joiner|namespace|url_for|get_flashed_messages|` +
+		`constructor|process|require|getclass|forname|getruntime|runtime|popen|subprocess|exec|eval|system|` +
+		`getclassloader|class\.inspect|new\s+java)\b|` + "`" + `|\bT\s*\(|\?new\s*\(|\|\s*attr\s*\(`)
+	// directive - директивы шаблонизаторов без разделителей-выражений: freemarker, velocity и smarty
+	directive = regexp.MustCompile(`(?i)<#(?:assign|import|include|list|if)\b|#set\s*\(\s*\$|#evaluate\s*\(|\$class\.inspect|\{php\}|\{\s*(?:system|exec|passthru|shell_exec)\s*\(`)
+)
+
+// Detect сообщает, похоже ли значение на внедрение в шаблон, и возвращает найденное выражение.
+func Detect(input string) (string, bool) {
+	if !strings.ContainsAny(input, "{%#(") {
+		return "", false
+	}
+	if m := directive.FindString(input); m != "" {
+		return "directive " + m, true
+	}
+	for _, m := range expression.FindAllStringSubmatch(input, -1) {
+		// ${{7*7}} - то же выражение с лишними скобками
+		body := strings.Trim(strings.Join(m[1:], ""), "{}")
+		if arithmetic.MatchString(body) || dangerous.MatchString(body) {
+			return "expression " + m[0], true
+		}
+	}
+	return "", false
+}
+
+var delimiters = strings.NewReplacer("{{", "", "}}", "", "{%", "", "%}", "", "${", "", "#{", "", "*{", "", "@{", "", "<%", "", "%>", "", "<#", "")
+
+// Sanitize убирает разделители выражений шаблонизаторов: остается обычный текст.
+func Sanitize(input string) string {
+	return delimiters.Replace(input)
+}
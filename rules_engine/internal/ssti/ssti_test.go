@@ -0,0 +1,81 @@
+package ssti
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectProbes(t *testing.T) {
+	// пробы, которыми определяют шаблонизатор, и выражения, через которые из него выполняется код
+	probes := map[string][]string{
+		"jinja2": {
+			"{{7*7}}",
+			"{{7*'7'}}",
+			"{{ config.items() }}",
+			"{{ self.__init__.__globals__ }}",
+			"{{ ''.__class__.__mro__[1].__subclasses__() }}",
+			"{{ request|attr('application') }}",
+		},
+		"spring el": {"${7*7}", "${{7*7}}", "${T(java.lang.Runtime).getRuntime().exec('id')}", "*{7*7}"},
+		"erb":       {"<%= 7*7 %>", "<%= `id` %>", "#{7*7}"},
+		"razor":     {"@(7*7)"},
+		"freemarker": {
+			`<#assign ex="freemarker.template.utility.Execute"?new()>`,
+			`${"freemarker.template.utility.Execute"?new()("id")}`,
+		},
+		"velocity": {"#set($x = 1)", "$class.inspect('java.lang.Runtime')"},
+		"smarty":   {"{php}echo 1;{/php}", "{system('id')}"},
+	}
+
+	for engine, inputs := range probes {
+		for _, input := range inputs {
+			detail, ok := Detect(input)
+			if !ok {
+				t.Errorf("%s: Detect(%q) missed the template injection", engine, input)
+				continue
+			}
+			if !strings.HasPrefix(detail, "expression ") && !strings.HasPrefix(detail, "directive ") {
+				t.Errorf("%s: Detect(%q) returned unexpected detail %q", engine, input, detail)
+			}
+		}
+	}
+}
+
+func TestDetectIgnoresPlainSubstitution(t *testing.T) {
+	for _, input := range []string{
+		"{{ user.name }}",
+		"${name}",
+		"#{id}",
+		"price: 7*7 = 49",
+		"50% off (today only)",
+		"issue #42",
+		"",
+	} {
+		if detail, ok := Detect(input); ok {
+			t.Errorf("Detect(%q) = %q on a benign value", input, detail)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"{{7*7}}", "7*7"},
+		// закрывающая } у ${ ... } остается: без открывающей части она не образует выражение
+		{"${7*7}", "7*7}"},
+		{"<%= 7*7 %>", "= 7*7 "},
+		{"hello", "hello"},
+	}
+
+	for _, tt := range tests {
+		got := Sanitize(tt.input)
+		if got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if _, ok := Detect(got); ok {
+			t.Errorf("Sanitize(%q) = %q is still detected", tt.input, got)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package transform
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func trimLeft(s string) string {
+	return strings.TrimLeftFunc(s, unicode.IsSpace)
+}
+
+func trimRight(s string) string {
+	return strings.TrimRightFunc(s, unicode.IsSpace)
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// urlDecode декодирует %XX и '+', оставляя невалидные последовательности как есть.
+func urlDecode(s string) string {
+	if !strings.ContainsAny(s, "%+") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '+':
+			b.WriteByte(' ')
+		case s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// urlDecodeUni дополнительно понимает нестандартную форму %uHHHH.
+func urlDecodeUni(s string) string {
+	if !strings.Contains(s, "%u") && !strings.Contains(s, "%U") {
+		return urlDecode(s)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+5 < len(s) && (s[i+1] == 'u' || s[i+1] == 'U') &&
+			isHex(s[i+2]) && isHex(s[i+3]) && isHex(s[i+4]) && isHex(s[i+5]) {
+			code, _ := strconv.ParseUint(s[i+2:i+6], 16, 32)
+			b.WriteRune(rune(code))
+			i += 5
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return urlDecode(b.String())
+}
+
+func htmlEntityDecode(s string) string {
+	if !strings.Contains(s, "&") {
+		return s
+	}
+	return html.UnescapeString(s)
+}
+
+// jsDecode декодирует escape-последовательности js: \xHH, \uHHHH, \n и т.д.
+func jsDecode(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		c := s[i+1]
+		switch {
+		case c == 'x' && i+3 < len(s) && isHex(s[i+2]) && isHex(s[i+3]):
+			b.WriteByte(unhex(s[i+2])<<4 | unhex(s[i+3]))
+			i += 3
+		case c == 'u' && i+5 < len(s) && isHex(s[i+2]) && isHex(s[i+3]) && isHex(s[i+4]) && isHex(s[i+5]):
+			code, _ := strconv.ParseUint(s[i+2:i+6], 16, 32)
+			b.WriteRune(rune(code))
+			i += 5
+		default:
+			b.WriteByte(jsEscape(c))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func jsEscape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	case 'v':
+		return '\v'
+	case '0':
+		return 0
+	}
+	return c
+}
+
+// cssDecode декодирует \H..HHHHHH escape-последовательности css.
+func cssDecode(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && j-i <= 6 && isHex(s[j]) {
+			j++
+		}
+		if j == i+1 {
+			if s[j] != '\n' {
+				b.WriteByte(s[j])
+			}
+			i = j
+			continue
+		}
+
+		code, _ := strconv.ParseUint(s[i+1:j], 16, 32)
+		b.WriteRune(rune(code))
+		if j < len(s) && s[j] == ' ' {
+			j++
+		}
+		i = j - 1
+	}
+	return b.String()
+}
+
+func base64Decode(s string) string {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding} {
+		if decoded, err := encoding.DecodeString(s); err == nil {
+			return string(decoded)
+		}
+	}
+	return s
+}
+
+// base64DecodeExt игнорирует символы вне алфавита base64.
+func base64DecodeExt(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '+' || c == '/' {
+			b.WriteByte(c)
+		}
+	}
+	decoded, err := base64.RawStdEncoding.DecodeString(b.String())
+	if err != nil {
+		return s
+	}
+	return string(decoded)
+}
+
+func hexDecode(s string) string {
+	if len(s)%2 != 0 {
+		return s
+	}
+
+	out := make([]byte, 0, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		if !isHex(s[i]) || !isHex(s[i+1]) {
+			return s
+		}
+		out = append(out, unhex(s[i])<<4|unhex(s[i+1]))
+	}
+	return string(out)
+}
+
+func compressWhitespace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == ' ' {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func removeWhitespace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == ' ' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func removeNulls(s string) string {
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+func replaceNulls(s string) string {
+	return strings.ReplaceAll(s, "\x00", " ")
+}
+
+// removeComments удаляет комментарии /* */, --, # и <!-- -->, как их используют в обходах сигнатур.
+func removeComments(s string) string {
+	return stripComments(s, "")
+}
+
+func replaceComments(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "/*") {
+			end := strings.Index(s[i+2:], "*/")
+			b.WriteByte(' ')
+			if end == -1 {
+				return b.String()
+			}
+			i += end + 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func stripComments(s, replacement string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			b.WriteString(replacement)
+			if end == -1 {
+				return b.String()
+			}
+			i += end + 3
+		case strings.HasPrefix(s[i:], "<!--"):
+			end := strings.Index(s[i+4:], "-->")
+			b.WriteString(replacement)
+			if end == -1 {
+				return b.String()
+			}
+			i += end + 6
+		case strings.HasPrefix(s[i:], "--"), s[i] == '#':
+			end := strings.IndexByte(s[i:], '\n')
+			b.WriteString(replacement)
+			if end == -1 {
+				return b.String()
+			}
+			i += end - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func removeCommentsChar(s string) string {
+	return strings.NewReplacer("/*", "", "*/", "", "<!--", "", "-->", "", "--", "", "#", "").Replace(s)
+}
+
+func normalizePath(s string) string {
+	if s == "" {
+		return s
+	}
+	cleaned := path.Clean(s)
+	if strings.HasSuffix(s, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+func normalizePathWin(s string) string {
+	return normalizePath(strings.ReplaceAll(s, `\`, "/"))
+}
+
+// cmdLine нормализует командную строку так же, как t:cmdLine: удаляет \ " ' ^, сжимает пробелы,
+// убирает пробелы перед / и ( и приводит к нижнему регистру.
+func cmdLine(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '"' || r == '\'' || r == '^':
+			continue
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == ',' || r == ';':
+			space = true
+			continue
+		case r == '/' || r == '(':
+			space = false
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// utf8ToUnicode переводит многобайтные символы в %uHHHH. избыточные (overlong) формы ASCII, например C0 BC,
+// которые декодер UTF-8 отвергает, сворачиваются в исходный символ: так их нормализуют уязвимые серверы.
+func utf8ToUnicode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if c, size, ok := overlongASCII(s[i:]); ok {
+			b.WriteByte(c)
+			i += size
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r < utf8.RuneSelf || r == utf8.RuneError {
+			b.WriteByte(s[i])
+		} else {
+			fmt.Fprintf(&b, "%%u%04x", r)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func overlongASCII(s string) (byte, int, bool) {
+	isCont := func(c byte) bool { return c&0xC0 == 0x80 }
+	switch {
+	case len(s) >= 2 && (s[0] == 0xC0 || s[0] == 0xC1) && isCont(s[1]):
+		return (s[0]&0x1F)<<6 | s[1]&0x3F, 2, true
+	case len(s) >= 3 && s[0] == 0xE0 && s[1] == 0x80 && isCont(s[2]):
+		return s[2] & 0x3F, 3, true
+	case len(s) >= 3 && s[0] == 0xE0 && s[1] == 0x81 && isCont(s[2]):
+		return 0x40 | s[2]&0x3F, 3, true
+	}
+	return 0, 0, false
+}
+
+func length(s string) string {
+	return strconv.Itoa(len(s))
+}
@@ -0,0 +1,131 @@
+// Package transform содержит преобразования значений перед проверкой, совместимые по именам с t:-действиями ModSecurity.
+package transform
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Func func(string) string
+
+var funcs = map[string]Func{
+	"lowercase":          strings.ToLower,
+	"uppercase":          strings.ToUpper,
+	"trim":               strings.TrimSpace,
+	"trimLeft":           trimLeft,
+	"trimRight":          trimRight,
+	"urlDecode":          urlDecode,
+	"urlDecodeUni":       urlDecodeUni,
+	"htmlEntityDecode":   htmlEntityDecode,
+	"jsDecode":           jsDecode,
+	"cssDecode":          cssDecode,
+	"escapeSeqDecode":    jsDecode,
+	"base64Decode":       base64Decode,
+	"base64DecodeExt":    base64DecodeExt,
+	"hexDecode":          hexDecode,
+	"compressWhitespace": compressWhitespace,
+	"removeWhitespace":   removeWhitespace,
+	"removeNulls":        removeNulls,
+	"replaceNulls":       replaceNulls,
+	"removeComments":     removeComments,
+	"replaceComments":    replaceComments,
+	"removeCommentsChar": removeCommentsChar,
+	"normalizePath":      normalizePath,
+	"normalisePath":      normalizePath,
+	"normalizePathWin":   normalizePathWin,
+	"normalisePathWin":   normalizePathWin,
+	"cmdLine":            cmdLine,
+	"utf8toUnicode":      utf8ToUnicode,
+	"length":             length,
+}
+
+// Lookup возвращает преобразование по имени.
+func Lookup(name string) (Func, bool) {
+	f, ok := funcs[name]
+	return f, ok
+}
+
+// Pipeline - скомпилированная цепочка преобразований. none сбрасывает накопленные до него.
+type Pipeline struct {
+	funcs []Func
+	// keys[i] идентифицирует первые i+1 преобразований: по нему кэш находит уже вычисленный префикс цепочки
+	keys []string
+}
+
+func Compile(names []string) (*Pipeline, error) {
+	var active []string
+	for _, name := range names {
+		if name == "none" {
+			active = active[:0]
+			continue
+		}
+		if _, ok := funcs[name]; !ok {
+			return nil, fmt.Errorf("unsupported transformation: %s", name)
+		}
+		active = append(active, name)
+	}
+
+	p := &Pipeline{funcs: make([]Func, len(active)), keys: make([]string, len(active))}
+	for i, name := range active {
+		p.funcs[i] = funcs[name]
+		p.keys[i] = strings.Join(active[:i+1], ",")
+	}
+	return p, nil
+}
+
+// Key - канонический вид цепочки без сброшенных через none преобразований.
+func (p *Pipeline) Key() string {
+	if p == nil || len(p.keys) == 0 {
+		return ""
+	}
+	return p.keys[len(p.keys)-1]
+}
+
+func (p *Pipeline) Apply(s string) string {
+	if p == nil {
+		return s
+	}
+	for _, f := range p.funcs {
+		s = f(s)
+	}
+	return s
+}
+
+type cacheKey struct {
+	chain string
+	value string
+}
+
+// Cache запоминает результаты преобразований в пределах одного запроса. правила с общим префиксом цепочки,
+// например urlDecodeUni и urlDecodeUni,lowercase, не декодируют одно и то же значение повторно.
+// не безопасен для конкурентного использования.
+type Cache struct {
+	values map[cacheKey]string
+}
+
+func NewCache() *Cache {
+	return &Cache{values: make(map[cacheKey]string)}
+}
+
+func (c *Cache) Apply(p *Pipeline, s string) string {
+	if p == nil || len(p.funcs) == 0 {
+		return s
+	}
+	if c == nil {
+		return p.Apply(s)
+	}
+
+	// ищем самый длинный уже вычисленный префикс цепочки и продолжаем с него
+	start, value := 0, s
+	for i := len(p.keys) - 1; i >= 0; i-- {
+		if cached, ok := c.values[cacheKey{chain: p.keys[i], value: s}]; ok {
+			start, value = i+1, cached
+			break
+		}
+	}
+	for i := start; i < len(p.funcs); i++ {
+		value = p.funcs[i](value)
+		c.values[cacheKey{chain: p.keys[i], value: s}] = value
+	}
+	return value
+}
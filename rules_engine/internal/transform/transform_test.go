@@ -0,0 +1,155 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"lowercase", "SeLeCt", "select"},
+		{"uppercase", "union", "UNION"},
+		{"trim", " \t id \n", "id"},
+		{"trimLeft", "  id ", "id "},
+		{"trimRight", "  id ", "  id"},
+		{"urlDecode", "%3Cscript%3e+x", "<script> x"},
+		{"urlDecode", "100%25%zz%4", "100%%zz%4"},
+		{"urlDecodeUni", "%u003cscript%U003E%41", "<script>A"},
+		{"urlDecodeUni", "%u00", "%u00"},
+		{"htmlEntityDecode", "&lt;img&#x20;src&#61;x&gt;", "<img src=x>"},
+		{"jsDecode", `\x3c\u0073\n\q\`, "<s\nq\\"},
+		{"escapeSeqDecode", `\x27\t`, "'\t"},
+		{"cssDecode", `\3c script\3e`, "<script>"},
+		{"cssDecode", `ja\vascript`, "javascript"},
+		{"base64Decode", "YWRtaW4=", "admin"},
+		{"base64Decode", "YWRtaW4", "admin"},
+		{"base64Decode", "not base64!", "not base64!"},
+		{"base64DecodeExt", "YW Rt.aW4=", "admin"},
+		{"hexDecode", "3c7363726970743e", "<script>"},
+		{"hexDecode", "3c7", "3c7"},
+		{"hexDecode", "zz", "zz"},
+		{"compressWhitespace", "a \t\n  b", "a b"},
+		{"removeWhitespace", " a b\t", "ab"},
+		{"removeNulls", "a\x00b", "ab"},
+		{"replaceNulls", "a\x00b", "a b"},
+		{"removeComments", "1/*x*/OR<!--y-->2-- tail\nnext#c", "1OR2\nnext"},
+		{"removeComments", "un/*ion", "un"},
+		{"replaceComments", "1/*x*/OR/*", "1 OR "},
+		{"removeCommentsChar", "a/*b*/c--d#e<!--f-->", "abcdef"},
+		{"normalizePath", "/a/./b/../c/", "/a/c/"},
+		{"normalizePath", "/../../etc/passwd", "/etc/passwd"},
+		{"normalizePath", "", ""},
+		{"normalisePath", "a//b", "a/b"},
+		{"normalizePathWin", `\a\..\b\`, "/b/"},
+		{"cmdLine", `C:\WINDOWS\system32\cmd.exe /c "who^ami"`, "c:windowssystem32cmd.exe/c whoami"},
+		{"cmdLine", "cat  /etc/passwd ; id", "cat/etc/passwd id"},
+		{"utf8toUnicode", "é", "%u00e9"},
+		{"utf8toUnicode", "\xc0\xbcscript\xe0\x80\xbe", "<script>"},
+		{"utf8toUnicode", "ascii\xff", "ascii\xff"},
+		{"length", "héllo", "6"},
+	}
+
+	for _, tt := range tests {
+		f, ok := Lookup(tt.name)
+		if !ok {
+			t.Errorf("transformation %s is not registered", tt.name)
+			continue
+		}
+		if got := f(tt.input); got != tt.want {
+			t.Errorf("%s(%q) = %q, want %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		names []string
+		key   string
+		input string
+		want  string
+	}{
+		{nil, "", "A%41", "A%41"},
+		{[]string{"urlDecode", "lowercase"}, "urlDecode,lowercase", "A%41", "aa"},
+		{[]string{"lowercase", "none", "urlDecode"}, "urlDecode", "A%41", "AA"},
+		{[]string{"urlDecode", "none"}, "", "A%41", "A%41"},
+	}
+
+	for _, tt := range tests {
+		p, err := Compile(tt.names)
+		if err != nil {
+			t.Errorf("Compile(%v): %v", tt.names, err)
+			continue
+		}
+		if p.Key() != tt.key {
+			t.Errorf("Compile(%v).Key() = %q, want %q", tt.names, p.Key(), tt.key)
+		}
+		if got := p.Apply(tt.input); got != tt.want {
+			t.Errorf("Compile(%v).Apply(%q) = %q, want %q", tt.names, tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := Compile([]string{"lowercase", "rot13"}); err == nil || !strings.Contains(err.Error(), "rot13") {
+		t.Errorf("expected unsupported transformation error, got %v", err)
+	}
+}
+
+// counting регистрирует преобразование, считающее свои вызовы.
+func counting(t *testing.T, name string, f Func) *int {
+	calls := new(int)
+	funcs[name] = func(s string) string {
+		*calls++
+		return f(s)
+	}
+	t.Cleanup(func() { delete(funcs, name) })
+	return calls
+}
+
+func TestCacheReusesPrefix(t *testing.T) {
+	decodes := counting(t, "countedDecode", urlDecode)
+	lowers := counting(t, "countedLower", strings.ToLower)
+
+	decode, err := Compile([]string{"countedDecode"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodeLower, err := Compile([]string{"countedDecode", "countedLower"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewCache()
+	steps := []struct {
+		p       *Pipeline
+		input   string
+		want    string
+		decodes int
+		lowers  int
+	}{
+		// первая цепочка вычисляется целиком
+		{decode, "A%41", "AA", 1, 0},
+		// вторая продолжает с закэшированного префикса
+		{decodeLower, "A%41", "aa", 1, 1},
+		// повторный вызов полностью из кэша
+		{decodeLower, "A%41", "aa", 1, 1},
+		{decode, "A%41", "AA", 1, 1},
+		// другое значение кэшируется отдельно
+		{decodeLower, "B%42", "bb", 2, 2},
+	}
+	for i, step := range steps {
+		if got := cache.Apply(step.p, step.input); got != step.want {
+			t.Errorf("step %d: Apply(%q) = %q, want %q", i, step.input, got, step.want)
+		}
+		if *decodes != step.decodes || *lowers != step.lowers {
+			t.Errorf("step %d: expected %d decodes and %d lowers, got %d and %d", i, step.decodes, step.lowers, *decodes, *lowers)
+		}
+	}
+
+	var nilCache *Cache
+	if got := nilCache.Apply(decodeLower, "C%43"); got != "cc" || *decodes != 3 {
+		t.Errorf("nil cache: Apply = %q after %d decodes, want %q after 3", got, *decodes, "cc")
+	}
+}
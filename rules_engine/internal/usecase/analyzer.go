@@ -1,59 +1,164 @@
 package usecase
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"net/textproto"
 	"net/url"
-	"regexp"
+	"router"
+	"rules-engine/internal/condition"
+	"rules-engine/internal/csrf"
 	"rules-engine/internal/entity"
+	"rules-engine/internal/expr"
+	"rules-engine/internal/graphql"
+	"rules-engine/internal/inspect"
+	"rules-engine/internal/logger"
+	"rules-engine/internal/openapi"
 	"rules-engine/internal/repository"
+	"rules-engine/internal/seclang"
+	"rules-engine/internal/transform"
 	"strings"
-)
+	"sync"
+	"time"
 
-const sqlInjectionPattern = `(?i)(\b(select|insert|update|delete|drop|union|join|cast|create|alter|truncate|grant|revoke|nullif|execute)\b[\s\S]*?['";\-\+=])|(\b(or|and)\b\s+('[^']*'|\d+)\s*=\s*('[^']*'|\d+))|(--|#)|(;[\s]*(select|insert|update|delete|drop|create|alter|truncate))|(%27|%2D%2D|%23)`
-const xssPattern = `(?i)<script.*?>.*?</script>`
+	"go.uber.org/zap"
+)
 
 type AnalyzerUseCase struct {
+	resources  *ResourceRoutes
 	ruleRepo   repository.RuleRepository
 	ipListRepo repository.IPListRepository
+	exclusions repository.RuleExclusionRepository
+
+	csrfSigner *csrf.Signer
+
+	customRules sync.Map
+	expressions sync.Map
+	secRules    sync.Map
+	specs       sync.Map
+	pipelines   sync.Map
+}
+
+// severityScores задает вклад сработавшего правила в anomaly score по его severity
+var severityScores = map[string]int{
+	entity.SeverityCritical: 5,
+	entity.SeverityError:    4,
+	entity.SeverityWarning:  3,
+	entity.SeverityNotice:   2,
+}
+
+// attackDetector - встроенный детектор атаки: как найти атаку в значении и как обезвредить значение.
+// обезвреженное значение кодируется обратно по формату своей части запроса, см. inspect.Request.RewriteBody.
+type attackDetector struct {
+	attack   string
+	detect   func(string) bool
+	sanitize func(string) string
+	escape   func(string) string
+}
+
+type compiledRule struct {
+	raw     string
+	matcher *condition.Matcher
+}
+
+type compiledPipeline struct {
+	source   string
+	pipeline *transform.Pipeline
+}
 
-	sqlPattern *regexp.Regexp
-	xssPattern *regexp.Regexp
+type compiledSecRule struct {
+	source string
+	rule   *seclang.Rule
 }
 
-func NewAnalyzerUseCase(ruleRepo repository.RuleRepository, ipListRepo repository.IPListRepository) *AnalyzerUseCase {
-	sqlRegex := regexp.MustCompile(sqlInjectionPattern)
-	xssRegex := regexp.MustCompile(xssPattern)
+type compiledSpec struct {
+	source string
+	spec   *openapi.Spec
+}
 
+func NewAnalyzerUseCase(
+	resources *ResourceRoutes,
+	ruleRepo repository.RuleRepository,
+	ipListRepo repository.IPListRepository,
+	exclusions repository.RuleExclusionRepository,
+	csrfSigner *csrf.Signer,
+) *AnalyzerUseCase {
 	return &AnalyzerUseCase{
+		resources:  resources,
 		ruleRepo:   ruleRepo,
 		ipListRepo: ipListRepo,
-		sqlPattern: sqlRegex,
-		xssPattern: xssRegex,
+		exclusions: exclusions,
+		csrfSigner: csrfSigner,
 	}
 }
 
 func (a *AnalyzerUseCase) AnalyzeRequest(request *entity.Request) (*entity.ScanResult, error) {
-	result, err := a.applyIPLists(request)
+	resource, err := a.matchResource(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if resource == nil {
+		return &entity.ScanResult{
+			Action:       entity.ActionAllow,
+			Reason:       "No resource matched the request.",
+			ModifiedURL:  request.URL,
+			ModifiedBody: request.Body,
+		}, nil
+	}
+
+	ipResult, err := a.applyIPLists(request, resource)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: приоритеты у правил??
-	if result.Action == entity.ActionBlock {
-		return result, nil
+	if ipResult.Action == entity.ActionBlock {
+		return ipResult, nil
 	}
 
-	result, err = a.applyRules(request)
+	result, err := a.applyRules(request, resource)
 	if err != nil {
 		return nil, err
 	}
 
+	result.Matches = append(ipResult.Matches, result.Matches...)
 	return result, nil
 }
 
-func (a *AnalyzerUseCase) applyRules(request *entity.Request) (*entity.ScanResult, error) {
-	rules, err := a.ruleRepo.GetRulesByURL(extractPath(request.URL), request.Method)
+func newRuleMatch(rule entity.Rule, result *entity.ScanResult, logOnly bool) entity.RuleMatch {
+	return entity.RuleMatch{
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		AttackType: rule.AttackType,
+		Action:     result.Action,
+		Reason:     result.Reason,
+		Variable:   result.Variable,
+		LogOnly:    logOnly,
+	}
+}
+
+// matchResource находит самый специфичный активный ресурс для запроса так же, как это делает роутер прокси.
+func (a *AnalyzerUseCase) matchResource(request *entity.Request) (*entity.Resource, error) {
+	routes, err := a.resources.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	resource, _, err := routes.Lookup(request.Host, requestPath(request.URL), request.Method)
+	if errors.Is(err, router.ErrNotFound) || errors.Is(err, router.ErrMethodNotAllowed) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &resource, nil
+}
+
+func (a *AnalyzerUseCase) applyRules(request *entity.Request, resource *entity.Resource) (*entity.ScanResult, error) {
+	rules, err := a.ruleRepo.GetRulesForResource(resource.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error while loading rules for resource")
 	}
@@ -65,164 +170,565 @@ func (a *AnalyzerUseCase) applyRules(request *entity.Request) (*entity.ScanResul
 		ModifiedBody: request.Body,
 	}
 
+	anomaly := resource.EvaluationMode == entity.EvaluationAnomaly
+	exclusions := a.loadExclusions(resource)
+	lists := make(map[string]*entity.IPList)
+
+	// запрос разбирается по content-type один раз и пересобирается только после модификации url или body
+	var parsed *inspect.Request
+	var tx *seclang.Transaction
+	inspected := func() *inspect.Request {
+		if parsed == nil {
+			scanned := *request
+			scanned.URL, scanned.Body = result.ModifiedURL, result.ModifiedBody
+			parsed = inspect.Parse(&scanned)
+		}
+		return parsed
+	}
+
 	for _, rule := range rules {
 		if rule.IsActive == nil || !*rule.IsActive {
 			continue
 		}
+		if max(rule.ParanoiaLevel, 1) > max(resource.ParanoiaLevel, 1) {
+			continue
+		}
+
+		// правило с исключениями проверяет копию запроса без исключенных полей
+		req := inspected()
+		excluded := a.applicableExclusions(exclusions[rule.ID], req, lists)
+		if len(excluded) > 0 {
+			req = req.Exclude(excludes(excluded))
+		}
 
 		// передаем в apply функции модифицированные url и body, чтобы в случае нескольких правил с sanitize или escape применились все действия
 		var tempResult *entity.ScanResult
 		switch rule.AttackType {
-		case "xss":
-			tempResult = a.applyXSSRule(result.ModifiedURL, result.ModifiedBody, rule)
-		case "csrf":
-			tempResult = a.applyCSRFRule(request, rule)
-		case "sqli":
-			tempResult = a.applySQLIRule(result.ModifiedURL, result.ModifiedBody, rule)
+		case attackTypeCSRF:
+			// безопасный запрос не проверяется, а получает токен для следующих изменяющих запросов
+			if isSafeMethod(request.Method) {
+				result.CSRFToken = a.issueCSRFToken(req, resource)
+				continue
+			}
+			tempResult = a.applyCSRFRule(req, resource)
+		case attackTypeCustom:
+			tempResult = a.applyCustomRule(req, rule)
+		case attackTypeExpression:
+			tempResult = a.applyExpressionRule(req, rule)
+		case attackTypeSecLang:
+			if req.HasExclusions() {
+				tempResult = a.applySecLangRule(seclang.NewTransaction(req), rule)
+				break
+			}
+			if tx == nil {
+				tx = seclang.NewTransaction(req)
+			}
+			tempResult = a.applySecLangRule(tx, rule)
+		case attackTypeOpenAPI:
+			tempResult = a.applyOpenAPIRule(req, rule)
+		case attackTypeGraphQL:
+			tempResult = a.applyGraphQLRule(req, rule)
 		default:
+			d, ok := detectors[rule.AttackType]
+			if !ok {
+				continue
+			}
+			tempResult = a.applyDetectorRule(d, req, rule)
+		}
+
+		if tempResult == nil {
 			continue
 		}
 
-		if tempResult != nil && tempResult.Action == "block" {
+		// в режиме log_only правило только фиксируется: запрос не блокируется и не модифицируется
+		logOnly := resource.LogOnly || rule.LogOnly
+		match := newRuleMatch(rule, tempResult, logOnly)
+
+		// в режиме anomaly блокирующее правило не блокирует сразу, а добавляет свой вес к общему счету
+		scoring := anomaly && tempResult.Action == entity.ActionBlock && !rule.LogOnly
+		if scoring {
+			match.Score = severityScores[rule.Severity]
+			result.AnomalyScore += match.Score
+			result.ScoringRules = append(result.ScoringRules, rule.ID)
+		}
+
+		result.Matches = append(result.Matches, match)
+		if logOnly || scoring {
+			continue
+		}
+
+		if tempResult.Action == entity.ActionBlock {
+			tempResult.Matches = result.Matches
 			return tempResult, nil
 		}
 
-		if tempResult != nil {
+		// allow терминальное: следующие по приоритету правила уже не вычисляются, но счет, набранный
+		// правилами до него, все равно сравнивается с порогом
+		if tempResult.Action == entity.ActionAllow {
+			result.Reason = fmt.Sprintf("Request allowed by rule %s.", rule.Name)
+			break
+		}
+
+		// apply функции возвращают только измененные части
+		if tempResult.ModifiedBody != "" {
 			result.ModifiedBody = tempResult.ModifiedBody
+			result.BodyModified = true
+			parsed, tx = nil, nil
+		}
+		if tempResult.ModifiedURL != "" {
 			result.ModifiedURL = tempResult.ModifiedURL
+			parsed, tx = nil, nil
 		}
 	}
 
+	if anomaly && result.AnomalyScore >= resource.AnomalyThreshold {
+		reason := fmt.Sprintf("Inbound anomaly score %d exceeded threshold %d.", result.AnomalyScore, resource.AnomalyThreshold)
+		if resource.LogOnly {
+			result.Matches = append(result.Matches, entity.RuleMatch{
+				AttackType: "anomaly_score",
+				Action:     entity.ActionBlock,
+				Reason:     reason,
+				LogOnly:    true,
+			})
+			return result, nil
+		}
+
+		return &entity.ScanResult{
+			Action:       entity.ActionBlock,
+			Reason:       reason,
+			AnomalyScore: result.AnomalyScore,
+			ScoringRules: result.ScoringRules,
+			Matches:      result.Matches,
+		}, nil
+	}
+
 	// важно: мы отдаем только allow или block. не даем информацию о escape или sanitize
 	return result, nil
 }
 
-func (a *AnalyzerUseCase) applyXSSRule(url, body string, rule entity.Rule) *entity.ScanResult {
-	xssDetected := false
-	modifiedBody := body
-	modifiedURL := url
-	if a.xssPattern.MatchString(body) {
-		xssDetected = true
-		switch rule.ActionType {
-		case entity.ActionSanitize:
-			modifiedBody = a.xssPattern.ReplaceAllString(body, "")
-		case entity.ActionEscape:
-			modifiedBody = escapeHTML(body)
-		}
+// applyDetectorRule ищет атаку в разобранных переменных запроса и сообщает, в какой из них она найдена.
+// сырое тело проверяется, только если его не удалось разобрать: иначе бинарные файлы multipart дают ложные срабатывания.
+func (a *AnalyzerUseCase) applyDetectorRule(d attackDetector, request *inspect.Request, rule entity.Rule) *entity.ScanResult {
+	pipeline, err := a.pipeline(rule)
+	if err != nil {
+		logger.Logger().Info("skipping rule with invalid transformations", zap.String("rule_id", rule.ID), zap.Error(err))
+		return nil
+	}
+	detect := func(value string) bool {
+		return d.detect(request.Transforms.Apply(pipeline, value))
 	}
 
+	url, body := request.Raw.URL, request.Raw.Body
 	decodedURL := decodeURL(url)
-	if a.xssPattern.MatchString(decodedURL) {
-		xssDetected = true
-		switch rule.ActionType {
-		case entity.ActionSanitize:
-			modifiedURL = a.xssPattern.ReplaceAllString(decodedURL, "")
-		case entity.ActionEscape:
-			modifiedURL = escapeHTML(decodedURL)
-		}
-	}
 
-	if xssDetected {
-		result := &entity.ScanResult{
-			Action: rule.ActionType,
-			Reason: "XSS detected in request.",
-		}
+	urlVariable, inURL := findAttack(detect, "ARGS_GET", request.ArgsGet)
+	if !inURL && detect(request.Path) {
+		urlVariable, inURL = "REQUEST_FILENAME", true
+	}
+	if !inURL && !request.HasExclusions() && detect(decodedURL) {
+		urlVariable, inURL = "REQUEST_URI", true
+	}
 
-		if rule.ActionType != entity.ActionBlock {
-			if modifiedBody != body {
-				result.ModifiedBody = modifiedBody
-			}
-			if modifiedURL != url {
-				result.ModifiedURL = modifiedURL
+	bodyVariable, inBody := findAttack(detect, "ARGS_POST", request.ArgsPost)
+	if !inBody {
+		bodyVariable, inBody = findAttack(detect, "XML", request.XML)
+	}
+	if !inBody && request.DTD != "" && detect(request.DTD) {
+		bodyVariable, inBody = "XML:DOCTYPE", true
+	}
+	if !inBody {
+		for _, f := range request.Files {
+			if detect(f.Filename) {
+				bodyVariable, inBody = "FILES:"+f.Field, true
+				break
 			}
 		}
+	}
+	if !inBody && !request.BodyInspected() && detect(body) {
+		bodyVariable, inBody = "REQUEST_BODY", true
+	}
+
+	cookieVariable, inCookie := findAttack(detect, "REQUEST_COOKIES", request.Cookies)
+
+	var variable string
+	switch {
+	case inURL:
+		variable = urlVariable
+	case inBody:
+		variable = bodyVariable
+	case inCookie:
+		variable = cookieVariable
+	default:
+		return nil
+	}
 
+	result := &entity.ScanResult{
+		Action:   rule.ActionType,
+		Reason:   fmt.Sprintf("%s detected in %s.", d.attack, variable),
+		Variable: variable,
+	}
+	if rule.ActionType == entity.ActionBlock {
 		return result
 	}
 
-	return nil
+	neutralize := d.sanitize
+	if rule.ActionType == entity.ActionEscape {
+		neutralize = d.escape
+	}
+	modifiedURL, modifiedBody := url, body
+	if inURL {
+		modifiedURL = request.RewriteURL(neutralize)
+	}
+	if inBody {
+		modifiedBody = request.RewriteBody(neutralize)
+	}
+
+	// атаку в cookie, в пути или в multipart и xml теле нельзя обезвредить правкой аргументов,
+	// поэтому такой запрос блокируется
+	if inCookie || (modifiedBody == body && modifiedURL == url) {
+		result.Action = entity.ActionBlock
+		return result
+	}
+	if modifiedBody != body {
+		result.ModifiedBody = modifiedBody
+	}
+	if modifiedURL != url {
+		result.ModifiedURL = modifiedURL
+	}
+	return result
 }
 
-func (a *AnalyzerUseCase) applyCSRFRule(request *entity.Request, rule entity.Rule) *entity.ScanResult {
-	if request.Headers["X-Csrf-Token"] == "" {
-		return &entity.ScanResult{
-			Action: entity.ActionBlock,
-			Reason: "Missing CSRF token.",
+// findAttack возвращает имя первого поля, в значении или имени которого найдена атака.
+func findAttack(detect func(string) bool, collection string, fields []inspect.Field) (string, bool) {
+	for _, f := range fields {
+		if detect(f.Value) || detect(f.Name) {
+			return collection + ":" + f.Name, true
 		}
 	}
-	return nil
+	return "", false
 }
 
-func (a *AnalyzerUseCase) applySQLIRule(url, body string, rule entity.Rule) *entity.ScanResult {
-	sqlDetected := false
-	modifiedBody := body
-	modifiedURL := url
+// applyCustomRule проверяет условия по уже модифицированным предыдущими правилами url и body.
+func (a *AnalyzerUseCase) applyCustomRule(request *inspect.Request, rule entity.Rule) *entity.ScanResult {
+	matcher, err := a.customMatcher(rule)
+	if err != nil {
+		logger.Logger().Info("skipping invalid custom rule", zap.String("rule_id", rule.ID), zap.Error(err))
+		return nil
+	}
 
-	if a.sqlPattern.MatchString(body) {
-		sqlDetected = true
-		switch rule.ActionType {
-		case entity.ActionSanitize:
-			modifiedBody = a.sqlPattern.ReplaceAllString(body, "")
-		case entity.ActionEscape:
-			modifiedBody = escapeSQL(body)
-		}
+	pipeline, err := a.pipeline(rule)
+	if err != nil {
+		logger.Logger().Info("skipping rule with invalid transformations", zap.String("rule_id", rule.ID), zap.Error(err))
+		return nil
+	}
+	if !matcher.Match(request, pipeline) {
+		return nil
 	}
 
-	decodedURL := decodeURL(url)
-	if a.sqlPattern.MatchString(decodedURL) {
-		sqlDetected = true
-		switch rule.ActionType {
-		case entity.ActionSanitize:
-			modifiedURL = a.sqlPattern.ReplaceAllString(decodedURL, "")
-		case entity.ActionEscape:
-			modifiedURL = escapeSQL(decodedURL)
-		}
+	return &entity.ScanResult{
+		Action: rule.ActionType,
+		Reason: fmt.Sprintf("Custom rule %s matched.", rule.Name),
+	}
+}
+
+// customMatcher кэширует скомпилированные условия, пока правило не изменилось.
+func (a *AnalyzerUseCase) customMatcher(rule entity.Rule) (*condition.Matcher, error) {
+	raw, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := a.customRules.Load(rule.ID); ok && cached.(compiledRule).raw == string(raw) {
+		return cached.(compiledRule).matcher, nil
+	}
+
+	matcher, err := condition.Compile(rule.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	a.customRules.Store(rule.ID, compiledRule{raw: string(raw), matcher: matcher})
+	return matcher, nil
+}
+
+// pipeline кэширует цепочку преобразований правила, пока она не изменилась.
+func (a *AnalyzerUseCase) pipeline(rule entity.Rule) (*transform.Pipeline, error) {
+	source := strings.Join(rule.Transformations, ",")
+	if cached, ok := a.pipelines.Load(rule.ID); ok && cached.(compiledPipeline).source == source {
+		return cached.(compiledPipeline).pipeline, nil
+	}
+
+	pipeline, err := transform.Compile(rule.Transformations)
+	if err != nil {
+		return nil, err
+	}
+	a.pipelines.Store(rule.ID, compiledPipeline{source: source, pipeline: pipeline})
+	return pipeline, nil
+}
+
+func (a *AnalyzerUseCase) applyExpressionRule(request *inspect.Request, rule entity.Rule) *entity.ScanResult {
+	program, err := a.expressionProgram(rule)
+	if err != nil {
+		logger.Logger().Info("skipping invalid expression rule", zap.String("rule_id", rule.ID), zap.Error(err))
+		return nil
+	}
+
+	matched, err := program.Eval(request)
+	if err != nil {
+		// ошибка вычисления (например, нет ключа без has()) означает, что правило не сработало
+		logger.Logger().Info("expression evaluation failed", zap.String("rule_id", rule.ID), zap.Error(err))
+		return nil
+	}
+	if !matched {
+		return nil
+	}
+
+	return &entity.ScanResult{
+		Action: rule.ActionType,
+		Reason: fmt.Sprintf("Expression rule %s matched.", rule.Name),
+	}
+}
+
+// expressionProgram кэширует скомпилированное выражение, пока его текст не изменился.
+func (a *AnalyzerUseCase) expressionProgram(rule entity.Rule) (*expr.Program, error) {
+	if cached, ok := a.expressions.Load(rule.ID); ok && cached.(*expr.Program).Source() == rule.Expression {
+		return cached.(*expr.Program), nil
+	}
+
+	program, err := expr.Compile(rule.Expression)
+	if err != nil {
+		return nil, err
+	}
+	a.expressions.Store(rule.ID, program)
+	return program, nil
+}
+
+func (a *AnalyzerUseCase) applySecLangRule(tx *seclang.Transaction, rule entity.Rule) *entity.ScanResult {
+	secRule, err := a.secRule(rule)
+	if err != nil {
+		logger.Logger().Info("skipping invalid seclang rule", zap.String("rule_id", rule.ID), zap.Error(err))
+		return nil
+	}
+
+	match, ok := secRule.Match(tx)
+	if !ok {
+		return nil
+	}
+
+	return &entity.ScanResult{
+		Action:   rule.ActionType,
+		Reason:   fmt.Sprintf("SecRule %d matched %s: %s", match.RuleID, match.VarName, match.Msg),
+		Variable: match.VarName,
+	}
+}
+
+// secRule кэширует разобранное правило, пока его текст не изменился.
+func (a *AnalyzerUseCase) secRule(rule entity.Rule) (*seclang.Rule, error) {
+	if cached, ok := a.secRules.Load(rule.ID); ok && cached.(compiledSecRule).source == rule.SecRule {
+		return cached.(compiledSecRule).rule, nil
+	}
+
+	secRule, err := seclang.Compile(rule.SecRule)
+	if err != nil {
+		return nil, err
+	}
+	a.secRules.Store(rule.ID, compiledSecRule{source: rule.SecRule, rule: secRule})
+	return secRule, nil
+}
+
+// applyOpenAPIRule срабатывает на запрос, который не соответствует документу openapi-правила.
+func (a *AnalyzerUseCase) applyOpenAPIRule(request *inspect.Request, rule entity.Rule) *entity.ScanResult {
+	spec, err := a.spec(rule)
+	if err != nil {
+		logger.Logger().Info("skipping invalid openapi rule", zap.String("rule_id", rule.ID), zap.Error(err))
+		return nil
 	}
 
-	if sqlDetected {
-		result := &entity.ScanResult{
-			Action: rule.ActionType,
-			Reason: "SQL injection detected in request.",
+	violation := spec.Validate(request)
+	if violation == nil {
+		return nil
+	}
+
+	return &entity.ScanResult{
+		Action:   rule.ActionType,
+		Reason:   fmt.Sprintf("OpenAPI violation: %s.", violation.Reason),
+		Variable: violation.Variable,
+	}
+}
+
+// spec кэширует разобранный документ, пока его текст не изменился.
+func (a *AnalyzerUseCase) spec(rule entity.Rule) (*openapi.Spec, error) {
+	if cached, ok := a.specs.Load(rule.ID); ok && cached.(compiledSpec).source == rule.OpenAPI {
+		return cached.(compiledSpec).spec, nil
+	}
+
+	spec, err := openapi.Parse(rule.OpenAPI)
+	if err != nil {
+		return nil, err
+	}
+	a.specs.Store(rule.ID, compiledSpec{source: rule.OpenAPI, spec: spec})
+	return spec, nil
+}
+
+// applyGraphQLRule разбирает операции graphql-запроса и блокирует интроспекцию, превышение лимитов политики
+// и атаку в строковом аргументе. лимиты batch-запроса считаются по сумме операций, глубина - по максимальной.
+// запрос без документа graphql правило пропускает, а документ, который не разбирается, блокирует.
+func (a *AnalyzerUseCase) applyGraphQLRule(request *inspect.Request, rule entity.Rule) *entity.ScanResult {
+	if rule.GraphQL == nil {
+		return nil
+	}
+	policy := *rule.GraphQL
+	block := func(variable, reason string, args ...any) *entity.ScanResult {
+		return &entity.ScanResult{Action: rule.ActionType, Reason: fmt.Sprintf(reason, args...), Variable: variable}
+	}
+
+	requests, err := graphql.Requests(request)
+	if err != nil {
+		return block("REQUEST_BODY", "Invalid GraphQL request: %v.", err)
+	}
+
+	total := graphql.Report{}
+	for _, r := range requests {
+		doc, err := graphql.Parse(r.Query)
+		if err != nil {
+			return block("GRAPHQL", "Invalid GraphQL document: %v.", err)
+		}
+		report, err := doc.Analyze(r.OperationName, r.Variables)
+		if err != nil {
+			return block("GRAPHQL", "Invalid GraphQL document: %v.", err)
 		}
 
-		if rule.ActionType != entity.ActionBlock {
-			if modifiedBody != body {
-				result.ModifiedBody = modifiedBody
-			}
-			if modifiedURL != url {
-				result.ModifiedURL = modifiedURL
+		if policy.BlockIntrospection && report.Introspection != "" {
+			return block("GRAPHQL:"+report.Introspection, "GraphQL introspection is not allowed: %s.", report.Introspection)
+		}
+		for _, arg := range report.Arguments {
+			for _, name := range policy.Detectors {
+				if d, ok := detectors[name]; ok && d.detect(arg.Value) {
+					return block("GRAPHQL:"+arg.Path, "%s detected in GraphQL argument %s.", d.attack, arg.Path)
+				}
 			}
 		}
 
-		return result
+		total.Depth = max(total.Depth, report.Depth)
+		total.Aliases += report.Aliases
+		total.Fields += report.Fields
+		total.Cost += report.Cost
+	}
+
+	limits := []struct {
+		name         string
+		value, limit int
+	}{
+		{"depth", total.Depth, policy.MaxDepth},
+		{"alias count", total.Aliases, policy.MaxAliases},
+		{"field count", total.Fields, policy.MaxFields},
+		{"cost", total.Cost, policy.MaxCost},
+	}
+	for _, l := range limits {
+		if l.limit > 0 && l.value > l.limit {
+			return block("GRAPHQL", "GraphQL query %s %d exceeds limit %d.", l.name, l.value, l.limit)
+		}
+	}
+	return nil
+}
+
+// applyCSRFRule проверяет изменяющий запрос по csrf-политике ресурса: источник по Origin и Referer,
+// совпадение токена из заголовка или поля формы с cookie и подпись токена.
+func (a *AnalyzerUseCase) applyCSRFRule(request *inspect.Request, resource *entity.Resource) *entity.ScanResult {
+	policy := resource.CSRF
+	block := func(reason, variable string) *entity.ScanResult {
+		return &entity.ScanResult{Action: entity.ActionBlock, Reason: reason, Variable: variable}
 	}
 
+	if policy.CheckOrigin {
+		origin, _ := request.Header("Origin")
+		referer, _ := request.Header("Referer")
+		if err := csrf.CheckOrigin(origin, referer, policy.AllowedOrigins, request.Raw.Host); err != nil {
+			return block(fmt.Sprintf("CSRF origin check failed: %v.", err), "REQUEST_HEADERS:Origin")
+		}
+	}
+	// политика из одной проверки источника токен не требует, а пустая требует только его наличия,
+	// как csrf-правило до появления политики
+	if policy.CheckOrigin && !policy.DoubleSubmit && !policy.SignedToken {
+		return nil
+	}
+
+	token, ok := request.Header(policy.HeaderName)
+	tokenVariable := "REQUEST_HEADERS:" + policy.HeaderName
+	if !ok {
+		token, _ = request.Arg(policy.FieldName)
+		tokenVariable = "ARGS:" + policy.FieldName
+	}
+	if token == "" {
+		return block("Missing CSRF token.", tokenVariable)
+	}
+
+	// подписанный токен сверяется с cookie и без double_submit: его может получить любой клиент
+	if policy.DoubleSubmit || policy.SignedToken {
+		cookie, _ := request.Cookie(policy.CookieName)
+		if cookie == "" || !csrf.Equal(token, cookie) {
+			return block("CSRF token does not match cookie.", "REQUEST_COOKIES:"+policy.CookieName)
+		}
+	}
+	if policy.SignedToken {
+		if _, err := a.csrfSigner.Verify(token, request.Raw.Host); err != nil {
+			return block(fmt.Sprintf("Invalid CSRF token: %v.", err), tokenVariable)
+		}
+	}
 	return nil
 }
 
-func escapeHTML(input string) string {
-	replacer := strings.NewReplacer(
-		"<", "&lt;",
-		">", "&gt;",
-		"\"", "&quot;",
-		"'", "&#39;",
-		"&", "&amp;",
-	)
-	return replacer.Replace(input)
+// issueCSRFToken выпускает новый токен, если в cookie его нет, он не проходит проверку
+// или прожил больше половины срока. иначе клиент продолжает пользоваться текущим.
+func (a *AnalyzerUseCase) issueCSRFToken(request *inspect.Request, resource *entity.Resource) *entity.CSRFToken {
+	policy := resource.CSRF
+	if !policy.DoubleSubmit && !policy.SignedToken {
+		return nil
+	}
+
+	ttl := time.Duration(policy.TokenTTLSeconds) * time.Second
+	if cookie, ok := request.Cookie(policy.CookieName); ok {
+		expiry, err := a.csrfSigner.Verify(cookie, request.Raw.Host)
+		if err == nil && time.Until(expiry) > ttl/2 {
+			return nil
+		}
+	}
+
+	return &entity.CSRFToken{
+		Value:      a.csrfSigner.Issue(request.Raw.Host, ttl),
+		CookieName: policy.CookieName,
+		HeaderName: policy.HeaderName,
+		MaxAge:     policy.TokenTTLSeconds,
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
 }
 
+// sqlMetaReplacer убирает символы, которыми значение выходит из строкового литерала sql или обрезает запрос
+var sqlMetaReplacer = strings.NewReplacer("'", "", "\"", "", "`", "", ";", "", "--", "", "#", "", "/*", "", "*/", "")
+
 func escapeSQL(input string) string {
 	return strings.ReplaceAll(input, "'", "''")
 }
 
-func extractPath(fullURL string) string {
+// requestPath возвращает декодированный путь запроса. прокси выбирает ресурс по r.URL.Path, и анализатор
+// должен выбирать по тому же пути, иначе /%6Cogin дойдет до ресурса /login без проверки.
+func requestPath(fullURL string) string {
 	fullURL = strings.TrimSpace(fullURL)
 
-	if idx := strings.IndexAny(fullURL, "?&"); idx != -1 {
-		return fullURL[:idx]
+	parsed, err := url.Parse(fullURL)
+	if err != nil {
+		if idx := strings.IndexAny(fullURL, "?#"); idx != -1 {
+			return fullURL[:idx]
+		}
+		return fullURL
 	}
-
-	return fullURL
+	return parsed.Path
 }
 
 func decodeURL(raw string) string {
@@ -233,28 +739,133 @@ func decodeURL(raw string) string {
 	return decoded
 }
 
-func (a *AnalyzerUseCase) applyIPLists(request *entity.Request) (*entity.ScanResult, error) {
-	lists, err := a.ipListRepo.GetIPListsByURL(extractPath(request.URL), request.Method)
+// loadExclusions группирует исключения ресурса по правилам. если их не удалось загрузить,
+// правила применяются целиком: ложное срабатывание лучше пропущенной атаки.
+func (a *AnalyzerUseCase) loadExclusions(resource *entity.Resource) map[string][]entity.RuleExclusion {
+	exclusions, err := a.exclusions.GetExclusionsForResource(resource.ID)
+	if err != nil {
+		logger.Logger().Info("error fetching rule exclusions for resource", zap.String("resource_id", resource.ID), zap.Error(err))
+		return nil
+	}
+
+	byRule := make(map[string][]entity.RuleExclusion)
+	for _, exclusion := range exclusions {
+		byRule[exclusion.RuleID] = append(byRule[exclusion.RuleID], exclusion)
+	}
+	return byRule
+}
+
+// applicableExclusions оставляет исключения, ограничения которых подходят запросу: ip входит в один из
+// списков исключения (тип списка не важен).
+// lists кэширует загруженные списки на время запроса.
+func (a *AnalyzerUseCase) applicableExclusions(exclusions []entity.RuleExclusion, request *inspect.Request, lists map[string]*entity.IPList) []entity.RuleExclusion {
+	var applicable []entity.RuleExclusion
+	for _, exclusion := range exclusions {
+		if len(exclusion.IPListIDs) > 0 && !a.inIPLists(request.Raw.IP, exclusion.IPListIDs, lists) {
+			continue
+		}
+		applicable = append(applicable, exclusion)
+	}
+	return applicable
+}
+
+func (a *AnalyzerUseCase) inIPLists(raw string, ids []string, lists map[string]*entity.IPList) bool {
+	ip := parseRequestIP(raw)
+	if ip == nil {
+		return false
+	}
+
+	for _, id := range ids {
+		list, ok := lists[id]
+		if !ok {
+			var err error
+			if list, err = a.ipListRepo.GetIPList(id); err != nil {
+				logger.Logger().Info("error fetching ip list for rule exclusion", zap.String("ip_list_id", id), zap.Error(err))
+			}
+			lists[id] = list
+		}
+		if list != nil && list.IP.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludes сопоставляет исключения с полями коллекций inspect.Request. json путь исключает и все
+// вложенные в него значения.
+func excludes(exclusions []entity.RuleExclusion) inspect.Excluded {
+	return func(collection, name string) bool {
+		for _, exclusion := range exclusions {
+			switch exclusion.Target {
+			case entity.ExclusionTargetArg:
+				if (collection == inspect.CollectionArgsGet || collection == inspect.CollectionArgsPost) && name == exclusion.Name {
+					return true
+				}
+			case entity.ExclusionTargetJSON:
+				path := "json." + exclusion.Name
+				if collection == inspect.CollectionArgsPost && (name == path || strings.HasPrefix(name, path+".")) {
+					return true
+				}
+			case entity.ExclusionTargetHeader:
+				if collection == inspect.CollectionHeaders && name == textproto.CanonicalMIMEHeaderKey(exclusion.Name) {
+					return true
+				}
+			case entity.ExclusionTargetCookie:
+				if collection == inspect.CollectionCookies && name == exclusion.Name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+func (a *AnalyzerUseCase) applyIPLists(request *entity.Request, resource *entity.Resource) (*entity.ScanResult, error) {
+	lists, err := a.ipListRepo.GetIPListsForResource(resource.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error while loading ip lists for resource")
 	}
 
-	var result *entity.ScanResult
+	allowed := &entity.ScanResult{
+		Action: entity.ActionAllow,
+		Reason: "Requester IP was detected in ip list.",
+	}
 
 	for _, list := range lists {
-		result = a.checkIP(request, list)
-		if result.Action == entity.ActionBlock {
+		result := a.checkIP(request, list)
+		if result.Action != entity.ActionBlock {
+			continue
+		}
+
+		result.Matches = []entity.RuleMatch{{
+			RuleID:     list.ID,
+			RuleName:   list.IP.String(),
+			AttackType: "ip_list",
+			Action:     result.Action,
+			Reason:     result.Reason,
+			LogOnly:    resource.LogOnly,
+		}}
+		if !resource.LogOnly {
 			return result, nil
 		}
+		allowed.Matches = append(allowed.Matches, result.Matches...)
 	}
-	return &entity.ScanResult{
-		Action: entity.ActionAllow,
-		Reason: "Requester IP was detected in ip list.",
-	}, nil
+	return allowed, nil
+}
+
+// parseRequestIP принимает адрес как v4, так и v6. порт допускается для совместимости со старыми клиентами.
+func parseRequestIP(raw string) net.IP {
+	if ip := net.ParseIP(strings.Trim(raw, "[]")); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		return net.ParseIP(host)
+	}
+	return nil
 }
 
 func (a *AnalyzerUseCase) checkIP(request *entity.Request, iPList entity.IPList) *entity.ScanResult {
-	ip := net.ParseIP(strings.Split(request.IP, ":")[0])
+	ip := parseRequestIP(request.IP)
 	if ip == nil {
 		return &entity.ScanResult{
 			Action: entity.ActionBlock,
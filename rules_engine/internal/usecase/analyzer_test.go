@@ -0,0 +1,275 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"rules-engine/internal/csrf"
+	"rules-engine/internal/entity"
+	"rules-engine/internal/repository"
+)
+
+// фейковые репозитории реализуют только то, что читает анализатор
+type fakeResourceRepo struct {
+	repository.ResourceRepository
+	resources []entity.Resource
+	loads     int
+}
+
+func (f *fakeResourceRepo) GetResources() ([]entity.Resource, error) {
+	f.loads++
+	return f.resources, nil
+}
+
+type fakeRuleRepo struct {
+	repository.RuleRepository
+	rules []entity.Rule
+}
+
+func (f *fakeRuleRepo) GetRulesForResource(string) ([]entity.Rule, error) {
+	return f.rules, nil
+}
+
+type fakeIPListRepo struct {
+	repository.IPListRepository
+	lists map[string]*entity.IPList
+}
+
+func (fakeIPListRepo) GetIPListsForResource(string) ([]entity.IPList, error) {
+	return nil, nil
+}
+
+func (f fakeIPListRepo) GetIPList(id string) (*entity.IPList, error) {
+	return f.lists[id], nil
+}
+
+type fakeExclusionRepo struct {
+	repository.RuleExclusionRepository
+	exclusions []entity.RuleExclusion
+}
+
+func (f fakeExclusionRepo) GetExclusionsForResource(string) ([]entity.RuleExclusion, error) {
+	return f.exclusions, nil
+}
+
+func testSigner() *csrf.Signer {
+	signer, err := csrf.NewSigner([]string{"test-secret"})
+	if err != nil {
+		panic(err)
+	}
+	return signer
+}
+
+func newTestAnalyzer(resources *fakeResourceRepo, rules ...entity.Rule) *AnalyzerUseCase {
+	active := true
+	for i := range resources.resources {
+		resources.resources[i].IsActive = &active
+	}
+	for i := range rules {
+		rules[i].IsActive = &active
+		rules[i].ParanoiaLevel = 1
+		if rules[i].ID == "" {
+			rules[i].ID = rules[i].Name
+		}
+	}
+	return NewAnalyzerUseCase(NewResourceRoutes(resources, time.Minute), &fakeRuleRepo{rules: rules}, fakeIPListRepo{}, fakeExclusionRepo{}, testSigner())
+}
+
+func TestAnalyzerMatchesDecodedPath(t *testing.T) {
+	resources := &fakeResourceRepo{resources: []entity.Resource{
+		{ID: "login", URL: "/login", HTTPMethod: "GET"},
+		{ID: "api", URL: "/api/*", HTTPMethod: "GET"},
+	}}
+	a := newTestAnalyzer(resources, entity.Rule{Name: "xss", AttackType: attackTypeXSS, ActionType: entity.ActionBlock})
+
+	tests := []string{
+		"/login?q=<script>alert(1)</script>",
+		"/%6Cogin?q=<script>alert(1)</script>",
+		"/api%2Fsecret?q=<script>alert(1)</script>",
+		"/%61pi/secret?q=%3Cscript%3Ealert(1)%3C/script%3E",
+	}
+	for _, url := range tests {
+		result, err := a.AnalyzeRequest(&entity.Request{Method: "GET", URL: url, IP: "10.0.0.1"})
+		if err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+		if result.Action != entity.ActionBlock {
+			t.Errorf("%s: expected block, got %s (%s)", url, result.Action, result.Reason)
+		}
+	}
+}
+
+func TestCSRFRuleWithoutPolicyRequiresToken(t *testing.T) {
+	// такую политику получают существующие ресурсы при миграции
+	policy := entity.CSRFPolicy{CookieName: "csrf_token", HeaderName: "X-CSRF-Token", FieldName: "csrf_token"}
+	resources := &fakeResourceRepo{resources: []entity.Resource{{ID: "form", URL: "/form", HTTPMethod: "POST", CSRF: policy}}}
+	a := newTestAnalyzer(resources, entity.Rule{Name: "csrf", AttackType: attackTypeCSRF, ActionType: entity.ActionBlock})
+
+	tests := []struct {
+		headers map[string]string
+		action  entity.Action
+	}{
+		{nil, entity.ActionBlock},
+		{map[string]string{"X-Csrf-Token": ""}, entity.ActionBlock},
+		{map[string]string{"X-Csrf-Token": "any"}, entity.ActionAllow},
+	}
+	for _, tt := range tests {
+		result, err := a.AnalyzeRequest(&entity.Request{Method: "POST", URL: "/form", Headers: tt.headers, IP: "10.0.0.1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Action != tt.action {
+			t.Errorf("%v: expected %s, got %s (%s)", tt.headers, tt.action, result.Action, result.Reason)
+		}
+	}
+}
+
+func TestCSRFSignedTokenMustMatchCookie(t *testing.T) {
+	// политика, сохраненная до того, как signed_token стал требовать double_submit
+	policy := entity.CSRFPolicy{SignedToken: true, CookieName: "csrf_token", HeaderName: "X-CSRF-Token", FieldName: "csrf_token"}
+	resources := &fakeResourceRepo{resources: []entity.Resource{{ID: "form", URL: "/form", HTTPMethod: "POST", Hostname: "app.example.com", CSRF: policy}}}
+	a := newTestAnalyzer(resources, entity.Rule{Name: "csrf", AttackType: attackTypeCSRF, ActionType: entity.ActionBlock})
+
+	// токен, выпущенный прокси атакующему, подписан верно
+	token := a.csrfSigner.Issue("app.example.com", time.Hour)
+	tests := []struct {
+		headers map[string]string
+		action  entity.Action
+	}{
+		{map[string]string{"X-Csrf-Token": token}, entity.ActionBlock},
+		{map[string]string{"X-Csrf-Token": token, "Cookie": "csrf_token=" + a.csrfSigner.Issue("app.example.com", time.Hour)}, entity.ActionBlock},
+		{map[string]string{"X-Csrf-Token": token, "Cookie": "csrf_token=" + token}, entity.ActionAllow},
+	}
+	for _, tt := range tests {
+		request := &entity.Request{Method: "POST", Host: "app.example.com", URL: "/form", Headers: tt.headers, IP: "10.0.0.1"}
+		result, err := a.AnalyzeRequest(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Action != tt.action {
+			t.Errorf("%v: expected %s, got %s (%s)", tt.headers, tt.action, result.Action, result.Reason)
+		}
+	}
+}
+
+func TestSetCSRFRequiresDoubleSubmitForSignedToken(t *testing.T) {
+	resource := &entity.Resource{}
+	if err := setCSRF(resource, &entity.CSRFPolicy{SignedToken: true}); err == nil {
+		t.Error("expected signed_token without double_submit to be rejected")
+	}
+	if err := setCSRF(resource, &entity.CSRFPolicy{SignedToken: true, DoubleSubmit: true}); err != nil {
+		t.Errorf("expected signed_token with double_submit to be accepted, got %v", err)
+	}
+}
+
+func TestAnalyzerReportsBodyModification(t *testing.T) {
+	resources := &fakeResourceRepo{resources: []entity.Resource{{ID: "form", URL: "/form", HTTPMethod: "POST"}}}
+	a := newTestAnalyzer(resources, entity.Rule{Name: "xss", AttackType: attackTypeXSS, ActionType: entity.ActionSanitize})
+
+	tests := []struct {
+		body     string
+		modified bool
+	}{
+		{`{"comment": "hello"}`, false},
+		{`{"comment": "<script>alert(1)</script>hello"}`, true},
+	}
+	for _, tt := range tests {
+		request := &entity.Request{
+			Method:  "POST",
+			URL:     "/form",
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    tt.body,
+			IP:      "10.0.0.1",
+		}
+		result, err := a.AnalyzeRequest(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Action != entity.ActionAllow || result.BodyModified != tt.modified {
+			t.Errorf("%s: expected allow with body_modified=%v, got %s with %v (%s)", tt.body, tt.modified, result.Action, result.BodyModified, result.Reason)
+		}
+		if result.BodyModified && result.ModifiedBody == tt.body {
+			t.Errorf("%s: body reported as modified but unchanged", tt.body)
+		}
+	}
+}
+
+func TestAnomalyThresholdCheckedBeforeAllow(t *testing.T) {
+	resources := &fakeResourceRepo{resources: []entity.Resource{{
+		ID: "form", URL: "/form", HTTPMethod: "GET", EvaluationMode: entity.EvaluationAnomaly, AnomalyThreshold: 5,
+	}}}
+	allow := entity.Rule{
+		Name: "trusted", AttackType: attackTypeCustom, ActionType: entity.ActionAllow, Severity: entity.SeverityNotice,
+		Conditions: entity.Conditions{{Target: "arg:trusted", Operator: "equals", Value: "1"}},
+	}
+	a := newTestAnalyzer(resources,
+		entity.Rule{Name: "xss", AttackType: attackTypeXSS, ActionType: entity.ActionBlock, Severity: entity.SeverityCritical},
+		allow,
+	)
+
+	tests := []struct {
+		url    string
+		action entity.Action
+	}{
+		{"/form?trusted=1", entity.ActionAllow},
+		{"/form?trusted=1&q=<script>alert(1)</script>", entity.ActionBlock},
+		{"/form?q=<script>alert(1)</script>", entity.ActionBlock},
+	}
+	for _, tt := range tests {
+		result, err := a.AnalyzeRequest(&entity.Request{Method: "GET", URL: tt.url, IP: "10.0.0.1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Action != tt.action {
+			t.Errorf("%s: expected %s, got %s (%s)", tt.url, tt.action, result.Action, result.Reason)
+		}
+	}
+}
+
+func TestGraphQLRuleLimits(t *testing.T) {
+	resources := &fakeResourceRepo{resources: []entity.Resource{{ID: "api", URL: "/graphql", HTTPMethod: "POST"}}}
+	rule := entity.Rule{
+		Name: "graphql", AttackType: attackTypeGraphQL, ActionType: entity.ActionBlock,
+		GraphQL: &entity.GraphQLPolicy{
+			MaxDepth: 3, MaxAliases: 2, MaxFields: 10, MaxCost: 50, BlockIntrospection: true, Detectors: []string{"sqli"},
+		},
+	}
+	a := newTestAnalyzer(resources, rule)
+
+	tests := []struct {
+		body     string
+		variable string
+		reason   string
+	}{
+		{`{"query": "{ me { friends(first: 5) { name } } }"}`, "", ""},
+		{`{"query": "{ a { b { c { d } } } }"}`, "GRAPHQL", "depth 4 exceeds limit 3"},
+		{`{"query": "{ x: me { id } y: me { id } z: me { id } }"}`, "GRAPHQL", "alias count 3 exceeds limit 2"},
+		{`{"query": "{ a b c d e f g h i j k }"}`, "GRAPHQL", "field count 11 exceeds limit 10"},
+		{`{"query": "{ users(first: 100) { id } }"}`, "GRAPHQL", "cost 101 exceeds limit 50"},
+		// лимиты batch-запроса считаются по сумме операций
+		{`[{"query": "{ x: a y: a }"}, {"query": "{ z: a }"}]`, "GRAPHQL", "alias count 3 exceeds limit 2"},
+		{`{"query": "{ node { __type(name: \"User\") { name } } }"}`, "GRAPHQL:node.__type", "introspection is not allowed"},
+		{`{"query": "query Q($n: String) { user(name: $n) { id } }", "variables": {"n": "1' OR '1'='1"}}`, "GRAPHQL:user(name)", "SQL injection detected"},
+		{`{"query": "{ ...A } fragment A on Q { ...A }"}`, "GRAPHQL", "fragment A spreads itself"},
+		{`{"query": "{ a "}`, "GRAPHQL", "Invalid GraphQL document"},
+	}
+	for _, tt := range tests {
+		result, err := a.AnalyzeRequest(&entity.Request{
+			Method: "POST", URL: "/graphql", IP: "10.0.0.1",
+			Headers: map[string]string{"Content-Type": "application/json"}, Body: tt.body,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.variable == "" {
+			if result.Action != entity.ActionAllow {
+				t.Errorf("%s: expected allow, got %s (%s)", tt.body, result.Action, result.Reason)
+			}
+			continue
+		}
+		if result.Action != entity.ActionBlock || result.Variable != tt.variable || !strings.Contains(result.Reason, tt.reason) {
+			t.Errorf("%s: expected block in %s for %q, got %s in %s (%s)", tt.body, tt.variable, tt.reason, result.Action, result.Variable, result.Reason)
+		}
+	}
+}
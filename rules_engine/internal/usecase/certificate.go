@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/repository"
+)
+
+type CertificateUseCase struct {
+	repo repository.CertificateRepository
+}
+
+func NewCertificateUseCase(repo repository.CertificateRepository) *CertificateUseCase {
+	return &CertificateUseCase{repo: repo}
+}
+
+type CertificateParams struct {
+	Name      string
+	CertPEM   string
+	KeyPEM    string
+	CreatorID string
+}
+
+func (u *CertificateUseCase) Get() ([]entity.Certificate, error) {
+	return u.repo.GetCertificates()
+}
+
+func (u *CertificateUseCase) Create(params CertificateParams) (*entity.Certificate, error) {
+	certificate := &entity.Certificate{
+		Name:      params.Name,
+		CreatorID: params.CreatorID,
+		CreatedAt: time.Now(),
+	}
+
+	if err := setKeyPair(certificate, params.CertPEM, params.KeyPEM); err != nil {
+		return nil, err
+	}
+
+	return u.repo.CreateCertificate(certificate)
+}
+
+func (u *CertificateUseCase) Update(id string, params CertificateParams) (*entity.Certificate, error) {
+	certificate, err := u.repo.GetCertificate(id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching certificate: %w", err)
+	}
+	if certificate == nil {
+		return nil, fmt.Errorf("certificate not found: id=%s", id)
+	}
+
+	if params.Name != "" {
+		certificate.Name = params.Name
+	}
+	if params.CertPEM != "" || params.KeyPEM != "" {
+		if params.CertPEM == "" || params.KeyPEM == "" {
+			return nil, fmt.Errorf("cert_pem and key_pem must be updated together")
+		}
+		if err := setKeyPair(certificate, params.CertPEM, params.KeyPEM); err != nil {
+			return nil, err
+		}
+	}
+
+	return u.repo.UpdateCertificate(certificate)
+}
+
+// setKeyPair проверяет, что сертификат и ключ составляют пару, и берет из сертификата имена и срок действия.
+func setKeyPair(certificate *entity.Certificate, certPEM, keyPEM string) error {
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return fmt.Errorf("invalid key pair: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	hostnames := leaf.DNSNames
+	if len(hostnames) == 0 && leaf.Subject.CommonName != "" {
+		hostnames = []string{leaf.Subject.CommonName}
+	}
+	if len(hostnames) == 0 {
+		return fmt.Errorf("certificate has no dns names")
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	certificate.Hostnames = hostnames
+	certificate.CertPEM = certPEM
+	certificate.KeyPEM = keyPEM
+	certificate.NotAfter = leaf.NotAfter
+	return nil
+}
@@ -0,0 +1,510 @@
+package usecase
+
+import (
+	"net/netip"
+	"regexp"
+	"rules-engine/internal/ldap"
+	"rules-engine/internal/nosql"
+	"rules-engine/internal/sqli"
+	"rules-engine/internal/ssti"
+	"rules-engine/internal/xss"
+	"rules-engine/internal/xxe"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// detectors - встроенные детекторы по типу атаки. у ssrf, lfi, rfi и xxe нет безопасной формы значения,
+// у nosql и ssti - экранирования: правило с неопределенным действием не проходит validateRule.
+var detectors = map[string]attackDetector{
+	attackTypeXSS: {
+		attack:   "XSS",
+		detect:   detected(xss.Detect),
+		sanitize: xss.Sanitize,
+		escape:   xss.Escape,
+	},
+	attackTypeSQLI: {
+		attack:   "SQL injection",
+		detect:   detected(sqli.Detect),
+		sanitize: sqlMetaReplacer.Replace,
+		escape:   escapeSQL,
+	},
+	attackTypePathTraversal: {
+		attack:   "Path traversal",
+		detect:   detectPathTraversal,
+		sanitize: sanitizePathTraversal,
+		escape:   sanitizePathTraversal,
+	},
+	attackTypeCmdInjection: {
+		attack:   "Command injection",
+		detect:   detectCmdInjection,
+		sanitize: shellMetaReplacer.Replace,
+		escape:   escapeShell,
+	},
+	attackTypeSSRF: {attack: "SSRF", detect: detectSSRF},
+	attackTypeLFI:  {attack: "Local file inclusion", detect: detectLFI},
+	attackTypeRFI:  {attack: "Remote file inclusion", detect: detectRFI},
+	attackTypeNoSQL: {
+		attack:   "NoSQL injection",
+		detect:   detected(nosql.Detect),
+		sanitize: nosql.Sanitize,
+	},
+	attackTypeLDAP: {
+		attack:   "LDAP injection",
+		detect:   detected(ldap.Detect),
+		sanitize: ldap.Sanitize,
+		escape:   ldap.Escape,
+	},
+	attackTypeXXE: {attack: "XXE", detect: detected(xxe.Detect)},
+	attackTypeSSTI: {
+		attack:   "Template injection",
+		detect:   detected(ssti.Detect),
+		sanitize: ssti.Sanitize,
+	},
+}
+
+// detected отбрасывает описание срабатывания детектора из отдельного пакета.
+func detected(detect func(string) (string, bool)) func(string) bool {
+	return func(s string) bool {
+		_, ok := detect(s)
+		return ok
+	}
+}
+
+// maxDecodeRounds ограничивает снятие вложенного кодирования: %25252e раскрывается за три шага
+const maxDecodeRounds = 3
+
+// overlongDot - точка, слеш и обратный слеш в overlong utf-8 и в %u-кодировке iis
+var overlongDot = regexp.MustCompile(`(?i)%c0%ae|%e0%80%ae|%c0%2e|%u002e|%uff0e|%c0%af|%e0%80%af|%c1%9c|%u2215|%u2216|%uff0f`)
+
+var overlongChars = map[string]string{
+	"%c0%ae": ".", "%e0%80%ae": ".", "%c0%2e": ".", "%u002e": ".", "%uff0e": ".",
+	"%c0%af": "/", "%e0%80%af": "/", "%u2215": "/", "%uff0f": "/",
+	"%c1%9c": `\`, "%u2216": `\`,
+}
+
+// decodePath раскрывает вложенное процентное кодирование и overlong-формы и приводит разделители windows к /.
+func decodePath(value string) string {
+	for i := 0; i < maxDecodeRounds; i++ {
+		decoded := overlongDot.ReplaceAllStringFunc(value, func(s string) string {
+			return overlongChars[strings.ToLower(s)]
+		})
+		decoded = unescapePercent(decoded)
+		if decoded == value {
+			break
+		}
+		value = decoded
+	}
+	return strings.ReplaceAll(value, `\`, "/")
+}
+
+// unescapePercent раскрывает корректные %XX и оставляет остальное как есть, в отличие от url.PathUnescape,
+// который отказывается от всего значения из-за одного %zz.
+func unescapePercent(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// traversalSegment - сегмент пути из двух и более точек. ..;/ tomcat считает тем же ..
+var traversalSegment = regexp.MustCompile(`^\.{2,}(;.*)?$`)
+
+func detectPathTraversal(value string) bool {
+	if !strings.Contains(value, ".") && !strings.Contains(value, "%") {
+		return false
+	}
+	for _, segment := range strings.Split(decodePath(value), "/") {
+		if traversalSegment.MatchString(strings.TrimSpace(segment)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizePathTraversal убирает из пути сегменты выхода в родительский каталог. escape для пути
+// делает то же самое: экранированный .. файловая система все равно поймет как выход наверх.
+func sanitizePathTraversal(value string) string {
+	segments := strings.Split(decodePath(value), "/")
+	kept := segments[:0]
+	for _, segment := range segments {
+		if !traversalSegment.MatchString(strings.TrimSpace(segment)) {
+			kept = append(kept, segment)
+		}
+	}
+	return strings.Join(kept, "/")
+}
+
+// shellCommand - команда после разделителя ; & | перевода строки или внутри подстановки $( и `.
+// путь перед командой (/bin/cat, /usr/bin/id) и .exe отбрасываются.
+var shellCommand = regexp.MustCompile("(?:[;&|\\n\\r`]|\\$\\(|[<>]\\()\\s*(?:[\\w.~-]*/)*([a-z][a-z0-9]*)(?:\\.exe)?\\b")
+
+// shellEvasion - приемы, которые shell убирает перед запуском: кавычки внутри слова (w'h'o'am'i),
+// обратный слеш (c\at), пустые переменные ($@) и $IFS вместо пробела
+var shellEvasion = strings.NewReplacer(
+	"${ifs}", " ", "$ifs$9", " ", "$ifs", " ",
+	"'", "", `"`, "", `\`, "", "$@", "", "$*", "",
+)
+
+// strongCommands почти не встречаются в тексте после разделителя и означают атаку сами по себе
+var strongCommands = map[string]bool{
+	"bash": true, "sh": true, "zsh": true, "ksh": true, "csh": true, "dash": true, "busybox": true,
+	"cmd": true, "powershell": true, "pwsh": true, "certutil": true, "systeminfo": true, "tasklist": true,
+	"whoami": true, "uname": true, "id": true, "ifconfig": true, "ipconfig": true, "hostname": true,
+	"pwd": true, "ls": true, "env": true, "printenv": true, "netstat": true, "nslookup": true,
+	"wget": true, "curl": true, "nc": true, "ncat": true, "netcat": true, "telnet": true,
+	"python": true, "python3": true, "perl": true, "ruby": true, "php": true, "base64": true,
+	"chmod": true, "chown": true,
+}
+
+// weakCommands - обычные слова (cat, type, sleep): атакой они считаются только с аргументом,
+// похожим на аргумент команды, - путем, флагом, числом или переменной
+var weakCommands = map[string]bool{
+	"cat": true, "echo": true, "sleep": true, "ping": true, "rm": true, "type": true, "dir": true,
+	"kill": true, "touch": true, "mkdir": true, "cp": true, "mv": true, "more": true, "less": true,
+	"head": true, "tail": true, "find": true, "grep": true, "awk": true, "sed": true, "xargs": true,
+	"exec": true, "eval": true, "timeout": true, "net": true, "ps": true, "nohup": true, "set": true,
+}
+
+func detectCmdInjection(value string) bool {
+	if !strings.ContainsAny(value, ";&|\n\r`$<>") {
+		return false
+	}
+	normalized := shellEvasion.Replace(strings.ToLower(value))
+	for _, m := range shellCommand.FindAllStringSubmatchIndex(normalized, -1) {
+		command, rest := normalized[m[2]:m[3]], normalized[m[1]:]
+		if rest != "" && !strings.ContainsAny(rest[:1], " \t\n\r;&|`)<>'\"") {
+			// &id=5 во вложенной строке запроса или cat.txt после разделителя - не команда
+			continue
+		}
+		if strongCommands[command] {
+			return true
+		}
+		if weakCommands[command] && shellArgument(rest) {
+			return true
+		}
+	}
+	return false
+}
+
+func shellArgument(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t")
+	return rest != "" && strings.ContainsAny(rest[:1], "-/$~.0123456789<>|;&`")
+}
+
+// shellMetaReplacer убирает символы, которыми значение завершает команду или подставляет другую
+var shellMetaReplacer = strings.NewReplacer(";", "", "&", "", "|", "", "`", "", "$", "", "<", "", ">", "", "\n", "", "\r", "")
+
+// escapeShell экранирует обратным слешем специальные символы shell: значение остается одним аргументом команды.
+func escapeShell(input string) string {
+	var b strings.Builder
+	for _, r := range input {
+		if strings.ContainsRune(";&|`$<>()\\'\"*?[]{}!#~ \t", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ssrfSchemes позволяют через запрос на внутренний адрес говорить с redis, memcached и другими tcp-сервисами
+var ssrfSchemes = map[string]bool{"gopher": true, "dict": true, "tftp": true, "netdoc": true}
+
+// internalPrefixes - адреса, не покрытые методами netip.Addr: cgnat (облачные метаданные alibaba),
+// "этот" хост 0.0.0.0/8 и служебные сети
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+var hostname = regexp.MustCompile(`^[a-z0-9_-]+(?:\.[a-z0-9_-]+)*$`)
+
+// internalDomains - зоны, которые разрешаются только во внутренней сети
+var internalDomains = []string{"localhost", "local", "internal", "localdomain", "home.arpa"}
+
+// loopbackDomains - публичные домены, все поддомены которых указывают на 127.0.0.1
+var loopbackDomains = []string{"localtest.me", "lvh.me", "vcap.me"}
+
+// ipDomains - wildcard-dns, которые разрешают имя в записанный в нем адрес: 10.0.0.1.nip.io, 10-0-0-1.sslip.io
+var ipDomains = []string{"nip.io", "sslip.io", "xip.io"}
+
+// metadataHosts - имена сервисов метаданных облаков. однословные разрешаются через search-домен виртуальной машины
+var metadataHosts = []string{
+	"metadata", "instance-data", "metadata.google.internal", "instance-data.ec2.internal",
+	"metadata.tencentyun.com", "metadata.platformequinix.com",
+}
+
+// detectSSRF находит ссылку на внутренний адрес. имя хоста разрешается без dns: распознаются все записи ip,
+// которые понимают http-клиенты (2130706433, 0x7f.1, [::ffff:127.0.0.1]), внутренние зоны, имена сервисов
+// метаданных и wildcard-dns с адресом в имени. прочие однословные имена не проверяются: по ним не отличить
+// внутренний сервис от опечатки или относительной ссылки.
+func detectSSRF(value string) bool {
+	scheme, authority, ok := splitURL(value)
+	if !ok {
+		return false
+	}
+	if ssrfSchemes[scheme] {
+		return true
+	}
+	for _, a := range authority {
+		if internalHost(urlHostname(a)) {
+			return true
+		}
+	}
+	return false
+}
+
+var urlScheme = regexp.MustCompile(`^([a-z][a-z0-9+.-]*):[/\\]{2}`)
+
+// splitURL возвращает схему и варианты authority ссылки. http://a.com\@10.0.0.1 браузер читает как хост a.com,
+// а часть библиотек - как 10.0.0.1, поэтому проверяются оба прочтения.
+func splitURL(value string) (string, []string, bool) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	var scheme, rest string
+	switch {
+	case strings.HasPrefix(v, "//"):
+		rest = v[2:]
+	default:
+		m := urlScheme.FindStringSubmatch(v)
+		if m == nil {
+			return "", nil, false
+		}
+		scheme, rest = m[1], v[len(m[0]):]
+	}
+
+	var authority []string
+	for _, terminators := range []string{`/?#\`, "/?#"} {
+		a := rest
+		if i := strings.IndexAny(a, terminators); i != -1 {
+			a = a[:i]
+		}
+		authority = append(authority, a)
+	}
+	return scheme, authority, true
+}
+
+// urlHostname убирает из authority учетные данные и порт.
+func urlHostname(authority string) string {
+	if i := strings.LastIndex(authority, "@"); i != -1 {
+		authority = authority[i+1:]
+	}
+	host := unescapePercent(authority)
+	if strings.HasPrefix(host, "[") {
+		if i := strings.Index(host, "]"); i != -1 {
+			return host[1:i]
+		}
+		return host[1:]
+	}
+	if i := strings.LastIndex(host, ":"); i != -1 && strings.Count(host, ":") == 1 {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+func internalHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	if addr, ok := parseHostIP(host); ok {
+		return internalAddr(addr)
+	}
+	if slices.Contains(metadataHosts, host) {
+		return true
+	}
+	if !hostname.MatchString(host) {
+		return false
+	}
+	for _, domain := range internalDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	for _, domain := range loopbackDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	for _, domain := range ipDomains {
+		if prefix, ok := strings.CutSuffix(host, "."+domain); ok {
+			addr, ok := embeddedIP(prefix)
+			return ok && internalAddr(addr)
+		}
+	}
+	return false
+}
+
+// parseHostIP разбирает хост как ip: ipv6, ipv4 в точечной записи и формы inet_aton - одно число (2130706433),
+// сокращенные (127.1), восьмеричные (0177.0.0.1) и шестнадцатеричные (0x7f000001) части.
+func parseHostIP(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	var ip uint64
+	for i, part := range parts {
+		n, err := parseInetPart(part)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		// последняя часть заполняет все оставшиеся байты адреса
+		bits := 8
+		if i == len(parts)-1 {
+			bits = 8 * (5 - len(parts))
+		}
+		if n >= 1<<bits {
+			return netip.Addr{}, false
+		}
+		ip = ip<<bits | n
+	}
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}), true
+}
+
+func parseInetPart(part string) (uint64, error) {
+	switch {
+	case strings.HasPrefix(part, "0x"):
+		return strconv.ParseUint(part[2:], 16, 32)
+	case len(part) > 1 && part[0] == '0':
+		return strconv.ParseUint(part[1:], 8, 32)
+	default:
+		return strconv.ParseUint(part, 10, 32)
+	}
+}
+
+// embeddedIP достает адрес из имени wildcard-dns: последние четыре метки, метка с дефисами или hex.
+func embeddedIP(prefix string) (netip.Addr, bool) {
+	labels := strings.Split(prefix, ".")
+	if len(labels) >= 4 {
+		if addr, err := netip.ParseAddr(strings.Join(labels[len(labels)-4:], ".")); err == nil {
+			return addr, true
+		}
+	}
+	last := labels[len(labels)-1]
+	if dashed := strings.Split(last, "-"); len(dashed) >= 4 {
+		if addr, err := netip.ParseAddr(strings.Join(dashed[len(dashed)-4:], ".")); err == nil {
+			return addr, true
+		}
+	}
+	if len(last) == 8 {
+		if n, err := strconv.ParseUint(last, 16, 32); err == nil {
+			return netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+var (
+	nat64Prefix  = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour    = netip.MustParsePrefix("2002::/16")
+	v4Compatible = netip.MustParsePrefix("::/96")
+)
+
+// internalAddr проверяет адрес, в том числе ipv4, вложенный в ipv6 (::ffff:a.b.c.d, nat64, 6to4).
+func internalAddr(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if addr.Is6() {
+		b := addr.As16()
+		switch {
+		case nat64Prefix.Contains(addr), v4Compatible.Contains(addr) && !addr.IsUnspecified() && !addr.IsLoopback():
+			addr = netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})
+		case sixToFour.Contains(addr):
+			addr = netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]})
+		}
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// lfiWrappers читают локальный файл или выполняют код вместо обычного пути
+var lfiWrappers = []string{
+	"file:", "php://", "zip://", "phar://", "glob://", "expect://", "compress.zlib://", "compress.bzip2://", "jar:file:",
+}
+
+// sensitiveFile - файлы с учетными данными, конфигурацией и логами, которые читают через включение файла
+var sensitiveFile = regexp.MustCompile(`(?:^|/)(?:etc/(?:passwd|shadow|group|hosts|issue|crontab|sudoers|hostname)\b|` +
+	`proc/(?:self|\d+)/|proc/version|var/log/|var/run/secrets/|\.ssh/|id_rsa|\.aws/credentials|\.bash_history|` +
+	`\.htpasswd|\.htaccess|\.git/|\.env$|boot\.ini|win\.ini|windows/system32/|windows/repair/|web-inf/|inetpub/)`)
+
+// pathLike - значение, которое само является путем: абсолютным, относительным от . или ~, или с диском windows
+var pathLike = regexp.MustCompile(`^(?:[a-z]:)?/|^\.|^~`)
+
+// detectLFI находит включение локального файла: обертки php и file:, обрезку расширения нулевым байтом
+// и пути к системным файлам. путь должен начинаться со значения, чтобы не ловить упоминание /etc/hosts в тексте.
+func detectLFI(value string) bool {
+	if strings.ContainsRune(value, 0) {
+		return true
+	}
+	v := strings.ToLower(strings.TrimSpace(decodePath(value)))
+	if strings.ContainsRune(v, 0) {
+		return true
+	}
+	for _, wrapper := range lfiWrappers {
+		if strings.HasPrefix(v, wrapper) {
+			return true
+		}
+	}
+	if !pathLike.MatchString(v) {
+		return false
+	}
+	for strings.Contains(v, "//") {
+		v = strings.ReplaceAll(v, "//", "/")
+	}
+	return sensitiveFile.MatchString(strings.ReplaceAll(v, "/./", "/"))
+}
+
+var remoteScheme = regexp.MustCompile(`^(?:https?|ftps?)://`)
+
+// detectRFI находит включение удаленного файла: ссылку с ? или нулевым байтом в конце, которые отрезают
+// дописанное приложением расширение, unc-путь, обертку data:// и ссылку на ip-адрес в записи inet_aton
+// (2130706433, 0x7f.1), которой прячут адрес от фильтров. обычная запись адреса встречается в легитимных
+// ссылках и сама по себе не считается атакой, внутренние адреса находит детектор ssrf.
+func detectRFI(value string) bool {
+	v := strings.ToLower(strings.TrimSpace(unescapePercent(value)))
+	if strings.HasPrefix(v, "data://") {
+		return true
+	}
+	if strings.HasPrefix(v, `\\`) && strings.Contains(v[2:], `\`) {
+		return true
+	}
+	if !remoteScheme.MatchString(v) {
+		return false
+	}
+	if strings.HasSuffix(v, "?") || strings.ContainsRune(v, 0) {
+		return true
+	}
+	_, authority, _ := splitURL(v)
+	return obfuscatedIP(urlHostname(authority[0]))
+}
+
+// obfuscatedIP - ipv4 в записи, которую понимает inet_aton, но не netip: одним числом, сокращенная,
+// с восьмеричными или шестнадцатеричными частями.
+func obfuscatedIP(host string) bool {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return false
+	}
+	_, ok := parseHostIP(host)
+	return ok
+}
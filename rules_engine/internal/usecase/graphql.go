@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"fmt"
+
+	"rules-engine/internal/entity"
+)
+
+func defaultGraphQLPolicy() entity.GraphQLPolicy {
+	return entity.GraphQLPolicy{
+		MaxDepth:           10,
+		MaxAliases:         30,
+		MaxFields:          500,
+		MaxCost:            5000,
+		BlockIntrospection: true,
+		Detectors: []string{
+			attackTypeSQLI, attackTypeNoSQL, attackTypeXSS, attackTypeCmdInjection, attackTypePathTraversal, attackTypeSSTI,
+		},
+	}
+}
+
+// setGraphQL заменяет политику graphql-правила целиком. graphql-правило без политики получает политику
+// по умолчанию, в которой интроспекция запрещена.
+func setGraphQL(rule *entity.Rule, policy *entity.GraphQLPolicy) {
+	if policy != nil {
+		rule.GraphQL = policy
+	}
+	if rule.GraphQL == nil && rule.AttackType == attackTypeGraphQL {
+		defaults := defaultGraphQLPolicy()
+		rule.GraphQL = &defaults
+	}
+	if rule.GraphQL != nil && rule.GraphQL.Detectors == nil {
+		rule.GraphQL.Detectors = []string{}
+	}
+}
+
+func validateGraphQLPolicy(policy *entity.GraphQLPolicy) error {
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"max_depth", policy.MaxDepth},
+		{"max_aliases", policy.MaxAliases},
+		{"max_fields", policy.MaxFields},
+		{"max_cost", policy.MaxCost},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 {
+			return fmt.Errorf("graphql %s must not be negative", limit.name)
+		}
+	}
+
+	for _, name := range policy.Detectors {
+		if _, ok := detectors[name]; !ok {
+			return fmt.Errorf("unknown graphql detector: %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"testing"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/inspect"
+)
+
+// детекторы видят разобранное тело так же, как анализатор: оператор nosql - в имени json-аргумента,
+// xxe - в DOCTYPE xml-тела, остальные атаки - в значениях полей
+func TestInjectionInParsedBodies(t *testing.T) {
+	tests := []struct {
+		name        string
+		attackType  string
+		contentType string
+		body        string
+		variable    string
+	}{
+		{"nosql operator as json key", attackTypeNoSQL, "application/json",
+			`{"user":"admin","password":{"$ne":null}}`, "ARGS_POST:json.password.$ne"},
+		{"nosql operator in form index", attackTypeNoSQL, "application/x-www-form-urlencoded",
+			"user=admin&password[$ne]=x", "ARGS_POST:password[$ne]"},
+		{"nosql where breakout in json value", attackTypeNoSQL, "application/json",
+			`{"filter":{"name":"' || '1'=='1"}}`, "ARGS_POST:json.filter.name"},
+		{"ldap breakout in json array", attackTypeLDAP, "application/json",
+			`{"users":["bob","admin)(|(password=*)"]}`, "ARGS_POST:json.users.1"},
+		{"ldap breakout in xml attribute", attackTypeLDAP, "application/xml",
+			`<search><user uid="*)(uid=*))(|(uid=*"/></search>`, "XML:/search/user/@uid"},
+		// ссылка &xxe; обрывает разбор тела, но DOCTYPE к этому моменту уже прочитан
+		{"external entity in xml doctype", attackTypeXXE, "application/xml",
+			`<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>`, "XML:DOCTYPE"},
+		{"entity expansion in soap doctype", attackTypeXXE, "application/soap+xml",
+			`<!DOCTYPE e [<!ENTITY a "x"><!ENTITY b "&a;&a;&a;">]><e>&b;</e>`, "XML:DOCTYPE"},
+		{"xinclude namespace in xml attribute", attackTypeXXE, "text/xml",
+			`<foo xmlns:xi="http://www.w3.org/2001/XInclude"><bar/></foo>`, "XML:/foo/@xi"},
+		{"template expression in xml text", attackTypeSSTI, "application/xml",
+			`<comment><text>{{ config.items() }}</text></comment>`, "XML:/comment/text"},
+		{"template expression in nested json", attackTypeSSTI, "application/json",
+			`{"profile":{"bio":"${7*7}"}}`, "ARGS_POST:json.profile.bio"},
+
+		// те же символы в обычных данных
+		{"dollar amount in json", attackTypeNoSQL, "application/json", `{"price":"$10","note":"rock || roll"}`, ""},
+		{"parentheses in xml text", attackTypeLDAP, "application/xml", `<user><name>John Smith (sales)</name></user>`, ""},
+		{"html doctype", attackTypeXXE, "application/xml", `<!DOCTYPE html><html><body/></html>`, ""},
+		{"plain substitution in json", attackTypeSSTI, "application/json", `{"template":"Hello, {{ user.name }}"}`, ""},
+	}
+
+	analyzer := &AnalyzerUseCase{}
+	for _, tt := range tests {
+		request := inspect.Parse(&entity.Request{
+			Method:  "POST",
+			URL:     "/api/items",
+			Headers: map[string]string{"Content-Type": tt.contentType},
+			Body:    tt.body,
+		})
+		rule := entity.Rule{ID: tt.name, AttackType: tt.attackType, ActionType: entity.ActionBlock}
+		result := analyzer.applyDetectorRule(detectors[tt.attackType], request, rule)
+		variable := ""
+		if result != nil {
+			variable = result.Variable
+		}
+		if variable != tt.variable {
+			t.Errorf("%s: expected detection in %q, got %q", tt.name, tt.variable, variable)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"router"
+	"rules-engine/internal/entity"
+	"rules-engine/internal/openapi"
+)
+
+type OpenAPIImportParams struct {
+	Document   string
+	Host       string
+	Hostname   *string
+	UpstreamID *string
+	LogOnly    *bool
+	CreatorID  string
+}
+
+type OpenAPIImportResult struct {
+	Rule      entity.Rule       `json:"rule"`
+	Resources []entity.Resource `json:"resources"`
+}
+
+// UploadOpenAPI задает ресурсу документ openapi. если к ресурсу уже привязано openapi-правило, документ
+// заменяется в нем, и замена действует на все ресурсы с этим правилом.
+func (r *ResourceUseCase) UploadOpenAPI(resourceID, document, creatorID string) (*entity.Rule, error) {
+	if _, err := r.GetResourceByID(resourceID); err != nil {
+		return nil, err
+	}
+	spec, err := openapi.Parse(document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi: %w", err)
+	}
+
+	rules, err := r.ruleUseCase.GetRulesForResource(resourceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.AttackType == attackTypeOpenAPI {
+			return r.ruleUseCase.Update(rule.ID, RuleParams{Name: openAPIRuleName(spec), OpenAPI: &document})
+		}
+	}
+
+	rule, err := r.createOpenAPIRule(spec, document, creatorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.AttachRule(resourceID, rule.ID, nil); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ImportOpenAPI создает по ресурсу на каждую операцию документа и привязывает к ним одно openapi-правило.
+// ресурс с тем же hostname, url и методом не дублируется, а получает правило.
+func (r *ResourceUseCase) ImportOpenAPI(params OpenAPIImportParams) (*OpenAPIImportResult, error) {
+	spec, err := openapi.Parse(params.Document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi: %w", err)
+	}
+
+	hostname := ""
+	if params.Hostname != nil {
+		pattern, err := router.ParseHostPattern(*params.Hostname)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource hostname: %w", err)
+		}
+		hostname = pattern.Raw
+	}
+
+	resources, err := r.resourceRepo.GetResources()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch resources: %w", err)
+	}
+	existing := make(map[string]entity.Resource, len(resources))
+	for _, resource := range resources {
+		existing[resourceKey(resource.Hostname, resource.URL, resource.HTTPMethod)] = resource
+	}
+
+	rule, err := r.createOpenAPIRule(spec, params.Document, params.CreatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OpenAPIImportResult{Rule: *rule, Resources: []entity.Resource{}}
+	active := true
+	for _, op := range spec.Operations() {
+		resource, ok := existing[resourceKey(hostname, op.Pattern, op.Method)]
+		if !ok {
+			name := op.ID
+			if name == "" {
+				name = op.Method + " " + op.Path
+			}
+			created, err := r.Create(ResourceParams{
+				Name:       name,
+				HTTPMethod: op.Method,
+				URL:        op.Pattern,
+				Host:       params.Host,
+				Hostname:   params.Hostname,
+				UpstreamID: params.UpstreamID,
+				LogOnly:    params.LogOnly,
+				CreatorID:  params.CreatorID,
+				IsActive:   &active,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create resource for %s %s: %w", op.Method, op.Path, err)
+			}
+			resource = *created
+		}
+
+		if err := r.AttachRule(resource.ID, rule.ID, nil); err != nil {
+			return nil, fmt.Errorf("failed to attach openapi rule to resource %s: %w", resource.ID, err)
+		}
+		result.Resources = append(result.Resources, resource)
+	}
+	return result, nil
+}
+
+func (r *ResourceUseCase) createOpenAPIRule(spec *openapi.Spec, document, creatorID string) (*entity.Rule, error) {
+	active := true
+	return r.ruleUseCase.Create(RuleParams{
+		Name:       openAPIRuleName(spec),
+		AttackType: attackTypeOpenAPI,
+		ActionType: string(entity.ActionBlock),
+		OpenAPI:    &document,
+		CreatorID:  creatorID,
+		IsActive:   &active,
+	})
+}
+
+func openAPIRuleName(spec *openapi.Spec) string {
+	return strings.TrimSpace("OpenAPI " + spec.Title + " " + spec.Version)
+}
+
+func resourceKey(hostname, url, method string) string {
+	return hostname + " " + url + " " + strings.ToUpper(method)
+}
@@ -2,8 +2,10 @@ package usecase
 
 import (
 	"fmt"
+	"net/url"
 	"time"
 
+	"router"
 	"rules-engine/internal/entity"
 	"rules-engine/internal/logger"
 	"rules-engine/internal/repository"
@@ -11,27 +13,43 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	defaultRulePriority     = 100
+	defaultAnomalyThreshold = 5
+	maxParanoiaLevel        = 4
+	defaultCSRFTokenTTL     = 12 * 60 * 60
+)
+
 type ResourceUseCase struct {
 	resourceRepo       repository.ResourceRepository
 	iPListUseCase      *IPListUseCase
 	ruleUseCase        *RuleUseCase
+	upstreamUseCase    *UpstreamUseCase
 	resourceIPListRepo repository.ResourceIPListRepository
 	resourceRuleRepo   repository.ResourceRuleRepository
+	exclusionRepo      repository.RuleExclusionRepository
+	routes             *ResourceRoutes
 }
 
 func NewResourceUseCase(
 	resourceRepo repository.ResourceRepository,
 	iPListUseCase *IPListUseCase,
 	ruleUseCase *RuleUseCase,
+	upstreamUseCase *UpstreamUseCase,
 	resourceIPListRepo repository.ResourceIPListRepository,
 	resourceRuleRepo repository.ResourceRuleRepository,
+	exclusionRepo repository.RuleExclusionRepository,
+	routes *ResourceRoutes,
 ) *ResourceUseCase {
 	return &ResourceUseCase{
 		resourceRepo:       resourceRepo,
 		iPListUseCase:      iPListUseCase,
 		ruleUseCase:        ruleUseCase,
+		upstreamUseCase:    upstreamUseCase,
 		resourceIPListRepo: resourceIPListRepo,
 		resourceRuleRepo:   resourceRuleRepo,
+		exclusionRepo:      exclusionRepo,
+		routes:             routes,
 	}
 }
 
@@ -64,46 +82,328 @@ func (r *ResourceUseCase) GetResourceByID(id string) (*entity.Resource, error) {
 	} else {
 		resource.Rules = rules
 	}
+	exclusions, err := r.exclusionRepo.GetExclusionsForResource(resource.ID)
+	if err != nil {
+		logger.Logger().Info(
+			"error fetching rule exclusions for resource",
+			zap.String("resource_id", resource.ID),
+			zap.Error(err),
+		)
+	} else {
+		resource.Exclusions = exclusions
+	}
+	if resource.UpstreamID != nil {
+		upstream, err := r.upstreamUseCase.GetUpstreamByID(*resource.UpstreamID)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching upstream for resource %s: %w", resource.ID, err)
+		}
+		resource.Upstream = upstream
+	}
 
 	return resource, nil
 }
 
-func (r *ResourceUseCase) Create(name, method, url, host, creatorID string, isActive *bool) (*entity.Resource, error) {
+type ResourceParams struct {
+	Name             string
+	HTTPMethod       string
+	URL              string
+	Host             string
+	Hostname         *string
+	UpstreamID       *string
+	Policy           *entity.UpstreamPolicy
+	MaxBodyBytes     *int64
+	AllowUpgrade     *bool
+	InspectMessages  *bool
+	HTTPSRedirect    *bool
+	LogOnly          *bool
+	EvaluationMode   string
+	AnomalyThreshold *int
+	ParanoiaLevel    *int
+	CSRF             *entity.CSRFPolicy
+	CreatorID        string
+	IsActive         *bool
+}
+
+func (r *ResourceUseCase) Create(params ResourceParams) (*entity.Resource, error) {
+	if _, err := router.ParsePattern(params.URL); err != nil {
+		return nil, fmt.Errorf("invalid resource url: %w", err)
+	}
+
 	resource := &entity.Resource{
-		Name:       name,
-		HTTPMethod: method,
-		URL:        url,
-		Host:       host,
-		CreatorID:  creatorID,
-		IsActive:   isActive,
+		Name:       params.Name,
+		HTTPMethod: params.HTTPMethod,
+		URL:        params.URL,
+		Host:       params.Host,
+		CreatorID:  params.CreatorID,
+		IsActive:   params.IsActive,
 		CreatedAt:  time.Now(),
 	}
-	return r.resourceRepo.CreateResource(resource)
+
+	if params.Hostname != nil {
+		hostname, err := router.ParseHostPattern(*params.Hostname)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource hostname: %w", err)
+		}
+		resource.Hostname = hostname.Raw
+	}
+
+	if err := r.setUpstream(resource, params.UpstreamID); err != nil {
+		return nil, err
+	}
+
+	if resource.Host == "" && resource.UpstreamID == nil {
+		return nil, fmt.Errorf("resource must have either host or upstream_id")
+	}
+
+	resource.Policy = defaultUpstreamPolicy()
+	if params.Policy != nil {
+		if err := validateUpstreamPolicy(*params.Policy); err != nil {
+			return nil, err
+		}
+		resource.Policy = *params.Policy
+	}
+	if err := setMaxBodyBytes(resource, params.MaxBodyBytes); err != nil {
+		return nil, err
+	}
+	setUpgrade(resource, params.AllowUpgrade, params.InspectMessages)
+	if params.HTTPSRedirect != nil {
+		resource.HTTPSRedirect = *params.HTTPSRedirect
+	}
+	if params.LogOnly != nil {
+		resource.LogOnly = *params.LogOnly
+	}
+	resource.EvaluationMode = entity.EvaluationFirstMatch
+	resource.AnomalyThreshold = defaultAnomalyThreshold
+	resource.ParanoiaLevel = 1
+	if err := setScoring(resource, params); err != nil {
+		return nil, err
+	}
+	resource.CSRF = defaultCSRFPolicy()
+	if err := setCSRF(resource, params.CSRF); err != nil {
+		return nil, err
+	}
+
+	created, err := r.resourceRepo.CreateResource(resource)
+	if err == nil {
+		r.routes.Invalidate()
+	}
+	return created, err
 }
 
-func (r *ResourceUseCase) Update(id, name, method, url, host string, isActive *bool) (*entity.Resource, error) {
+func (r *ResourceUseCase) Update(id string, params ResourceParams) (*entity.Resource, error) {
 	resource, err := r.GetResourceByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	if name != "" {
-		resource.Name = name
+	if params.Name != "" {
+		resource.Name = params.Name
+	}
+	if params.HTTPMethod != "" {
+		resource.HTTPMethod = params.HTTPMethod
+	}
+	if params.URL != "" {
+		if _, err := router.ParsePattern(params.URL); err != nil {
+			return nil, fmt.Errorf("invalid resource url: %w", err)
+		}
+		resource.URL = params.URL
+	}
+	if params.Host != "" {
+		resource.Host = params.Host
+	}
+	if params.Hostname != nil {
+		hostname, err := router.ParseHostPattern(*params.Hostname)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource hostname: %w", err)
+		}
+		resource.Hostname = hostname.Raw
+	}
+	if params.IsActive != nil {
+		resource.IsActive = params.IsActive
+	}
+	if err := r.setUpstream(resource, params.UpstreamID); err != nil {
+		return nil, err
+	}
+	if params.Policy != nil {
+		if err := validateUpstreamPolicy(*params.Policy); err != nil {
+			return nil, err
+		}
+		resource.Policy = *params.Policy
+	}
+	if err := setMaxBodyBytes(resource, params.MaxBodyBytes); err != nil {
+		return nil, err
+	}
+	setUpgrade(resource, params.AllowUpgrade, params.InspectMessages)
+	if params.HTTPSRedirect != nil {
+		resource.HTTPSRedirect = *params.HTTPSRedirect
+	}
+	if params.LogOnly != nil {
+		resource.LogOnly = *params.LogOnly
+	}
+	if err := setScoring(resource, params); err != nil {
+		return nil, err
+	}
+	if err := setCSRF(resource, params.CSRF); err != nil {
+		return nil, err
+	}
+
+	if resource.Host == "" && resource.UpstreamID == nil {
+		return nil, fmt.Errorf("resource must have either host or upstream_id")
 	}
-	if method != "" {
-		resource.HTTPMethod = method
+
+	updated, err := r.resourceRepo.UpdateResource(resource)
+	if err == nil {
+		r.routes.Invalidate()
 	}
-	if url != "" {
-		resource.URL = url
+	return updated, err
+}
+
+func defaultUpstreamPolicy() entity.UpstreamPolicy {
+	return entity.UpstreamPolicy{
+		RetryBackoffMs:     100,
+		BreakerMinRequests: 20,
+		BreakerOpenMs:      30000,
 	}
-	if host != "" {
-		resource.Host = host
+}
+
+func validateUpstreamPolicy(policy entity.UpstreamPolicy) error {
+	if policy.ConnectTimeoutMs < 0 || policy.ReadTimeoutMs < 0 || policy.Retries < 0 || policy.RetryBackoffMs < 0 ||
+		policy.BreakerMinRequests < 0 || policy.BreakerOpenMs < 0 {
+		return fmt.Errorf("policy values must not be negative")
 	}
-	if isActive != nil {
-		resource.IsActive = isActive
+	if policy.BreakerErrorRate < 0 || policy.BreakerErrorRate > 1 {
+		return fmt.Errorf("breaker_error_rate must be between 0 and 1")
 	}
+	return nil
+}
 
-	return r.resourceRepo.UpdateResource(resource)
+func setMaxBodyBytes(resource *entity.Resource, maxBodyBytes *int64) error {
+	if maxBodyBytes == nil {
+		return nil
+	}
+	if *maxBodyBytes < 0 {
+		return fmt.Errorf("max_body_bytes must not be negative")
+	}
+	resource.MaxBodyBytes = *maxBodyBytes
+	return nil
+}
+
+func setUpgrade(resource *entity.Resource, allowUpgrade, inspectMessages *bool) {
+	if allowUpgrade != nil {
+		resource.AllowUpgrade = *allowUpgrade
+	}
+	if inspectMessages != nil {
+		resource.InspectMessages = *inspectMessages
+	}
+}
+
+// setScoring задает режим вычисления правил, порог anomaly score и уровень паранойи ресурса.
+func setScoring(resource *entity.Resource, params ResourceParams) error {
+	switch params.EvaluationMode {
+	case "":
+	case entity.EvaluationFirstMatch, entity.EvaluationAnomaly:
+		resource.EvaluationMode = params.EvaluationMode
+	default:
+		return fmt.Errorf("unknown evaluation_mode: %s", params.EvaluationMode)
+	}
+
+	if params.AnomalyThreshold != nil {
+		if *params.AnomalyThreshold <= 0 {
+			return fmt.Errorf("anomaly_threshold must be positive")
+		}
+		resource.AnomalyThreshold = *params.AnomalyThreshold
+	}
+	if params.ParanoiaLevel != nil {
+		if err := validateParanoiaLevel(*params.ParanoiaLevel); err != nil {
+			return err
+		}
+		resource.ParanoiaLevel = *params.ParanoiaLevel
+	}
+	return nil
+}
+
+func defaultCSRFPolicy() entity.CSRFPolicy {
+	return entity.CSRFPolicy{
+		CheckOrigin:     true,
+		AllowedOrigins:  []string{},
+		DoubleSubmit:    true,
+		SignedToken:     true,
+		CookieName:      "csrf_token",
+		HeaderName:      "X-CSRF-Token",
+		FieldName:       "csrf_token",
+		TokenTTLSeconds: defaultCSRFTokenTTL,
+	}
+}
+
+// setCSRF заменяет csrf-политику ресурса целиком. незаданные имена cookie, заголовка и поля и срок токена
+// берутся по умолчанию, а источники должны быть вида https://app.example.com.
+func setCSRF(resource *entity.Resource, policy *entity.CSRFPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	defaults := defaultCSRFPolicy()
+	if policy.CookieName == "" {
+		policy.CookieName = defaults.CookieName
+	}
+	if policy.HeaderName == "" {
+		policy.HeaderName = defaults.HeaderName
+	}
+	if policy.FieldName == "" {
+		policy.FieldName = defaults.FieldName
+	}
+	if policy.TokenTTLSeconds == 0 {
+		policy.TokenTTLSeconds = defaults.TokenTTLSeconds
+	}
+	if policy.TokenTTLSeconds < 0 {
+		return fmt.Errorf("csrf token_ttl_seconds must be positive")
+	}
+	// подписанный токен не привязан к сессии: любой клиент получит его у прокси, поэтому без сверки
+	// с cookie жертвы он ничего не защищает
+	if policy.SignedToken && !policy.DoubleSubmit {
+		return fmt.Errorf("csrf signed_token requires double_submit")
+	}
+	if policy.AllowedOrigins == nil {
+		policy.AllowedOrigins = []string{}
+	}
+	for _, origin := range policy.AllowedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return fmt.Errorf("invalid csrf allowed origin: %s", origin)
+		}
+	}
+
+	resource.CSRF = *policy
+	return nil
+}
+
+func validateParanoiaLevel(level int) error {
+	if level < 1 || level > maxParanoiaLevel {
+		return fmt.Errorf("paranoia_level must be between 1 and %d", maxParanoiaLevel)
+	}
+	return nil
+}
+
+// setUpstream привязывает ресурс к пулу апстримов. пустая строка отвязывает пул.
+func (r *ResourceUseCase) setUpstream(resource *entity.Resource, upstreamID *string) error {
+	if upstreamID == nil {
+		return nil
+	}
+
+	if *upstreamID == "" {
+		resource.UpstreamID = nil
+		resource.Upstream = nil
+		return nil
+	}
+
+	upstream, err := r.upstreamUseCase.GetUpstreamByID(*upstreamID)
+	if err != nil {
+		return err
+	}
+
+	resource.UpstreamID = &upstream.ID
+	resource.Upstream = upstream
+	return nil
 }
 
 // TODO: добавить флаг, при котором отправляются вместе с ресурсами правила и списки
@@ -113,7 +413,22 @@ func (r *ResourceUseCase) Get() ([]entity.Resource, error) {
 		return nil, fmt.Errorf("failed to fetch resources: %w", err)
 	}
 
+	// без пула прокси отправил бы запрос ресурса по пустому host, поэтому ошибка возвращается,
+	// и прокси остается с прежней таблицей
+	upstreams := make(map[string]*entity.Upstream)
 	for i, res := range resources {
+		if res.UpstreamID != nil {
+			upstream, ok := upstreams[*res.UpstreamID]
+			if !ok {
+				upstream, err = r.upstreamUseCase.GetUpstreamByID(*res.UpstreamID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to fetch upstream for resource %s: %w", res.ID, err)
+				}
+				upstreams[*res.UpstreamID] = upstream
+			}
+			resources[i].Upstream = upstream
+		}
+
 		ipLists, err := r.iPListUseCase.GetIPListsForResource(res.ID)
 		if err != nil {
 			logger.Logger().Info(
@@ -159,14 +474,21 @@ func (r *ResourceUseCase) DetachIPList(resourceID, ipListID string) error {
 	return r.resourceIPListRepo.DetachIPList(resourceID, ipListID)
 }
 
-func (r *ResourceUseCase) AttachRule(resourceID, ruleID string) error {
+// AttachRule привязывает правило с приоритетом: правила ресурса вычисляются по возрастанию priority.
+func (r *ResourceUseCase) AttachRule(resourceID, ruleID string, priority *int) error {
 	if _, err := r.GetResourceByID(resourceID); err != nil {
 		return err
 	}
 	if _, err := r.ruleUseCase.GetRuleByID(ruleID); err != nil {
 		return err
 	}
-	return r.resourceRuleRepo.AttachRule(resourceID, ruleID)
+	if priority == nil {
+		return r.resourceRuleRepo.AttachRule(resourceID, ruleID, defaultRulePriority)
+	}
+	if *priority < 0 {
+		return fmt.Errorf("priority must not be negative")
+	}
+	return r.resourceRuleRepo.AttachRule(resourceID, ruleID, *priority)
 }
 
 func (r *ResourceUseCase) DetachRule(resourceID, ruleID string) error {
@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"router"
+	"rules-engine/internal/entity"
+	"rules-engine/internal/logger"
+	"rules-engine/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+type ResourceMap = router.Router[entity.Resource]
+
+// ResourceRoutes хранит таблицу маршрутов активных ресурсов, чтобы анализатор не читал ресурсы и не
+// компилировал шаблоны на каждый запрос. после изменения ресурса таблица сбрасывается и строится заново
+// при следующем запросе, а Watch перечитывает ее, чтобы подхватить изменения других экземпляров.
+type ResourceRoutes struct {
+	repo     repository.ResourceRepository
+	interval time.Duration
+	current  atomic.Pointer[ResourceMap]
+
+	// generation растет при каждом сбросе: таблица, построенная до сброса, не сохраняется
+	mu         sync.Mutex
+	generation uint64
+}
+
+func NewResourceRoutes(repo repository.ResourceRepository, interval time.Duration) *ResourceRoutes {
+	return &ResourceRoutes{repo: repo, interval: interval}
+}
+
+// Load возвращает таблицу, строя ее после сброса или при первом обращении.
+func (s *ResourceRoutes) Load() (*ResourceMap, error) {
+	if routes := s.current.Load(); routes != nil {
+		return routes, nil
+	}
+	return s.refresh()
+}
+
+func (s *ResourceRoutes) Refresh() error {
+	_, err := s.refresh()
+	return err
+}
+
+// Invalidate сбрасывает таблицу после изменения ресурса.
+func (s *ResourceRoutes) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	s.current.Store(nil)
+}
+
+// Watch обновляет таблицу до отмены контекста. при ошибке остается последняя успешно загруженная таблица.
+func (s *ResourceRoutes) Watch(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil {
+				logger.Logger().Info("failed to refresh resources, keeping previous table", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (s *ResourceRoutes) refresh() (*ResourceMap, error) {
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+
+	resources, err := s.repo.GetResources()
+	if err != nil {
+		return nil, fmt.Errorf("error while loading resources")
+	}
+	routes := buildResourceMap(resources)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation == generation {
+		s.current.Store(routes)
+	}
+	return routes, nil
+}
+
+func buildResourceMap(resources []entity.Resource) *ResourceMap {
+	routes := router.New[entity.Resource]()
+	for _, res := range resources {
+		if res.IsActive != nil && !*res.IsActive {
+			continue
+		}
+		if err := routes.Add(res.Hostname, res.URL, res.HTTPMethod, res); err != nil {
+			logger.Logger().Info("skipping resource with invalid url pattern", zap.String("resource_id", res.ID), zap.Error(err))
+		}
+	}
+	return routes
+}
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"rules-engine/internal/entity"
+)
+
+func TestResourceRoutesCachesUntilInvalidated(t *testing.T) {
+	repo := &fakeResourceRepo{resources: []entity.Resource{{ID: "old", URL: "/old", HTTPMethod: "GET"}}}
+	routes := NewResourceRoutes(repo, time.Minute)
+
+	lookup := func(path string) string {
+		t.Helper()
+		table, err := routes.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resource, _, err := table.Lookup("", path, "GET")
+		if err != nil {
+			return ""
+		}
+		return resource.ID
+	}
+
+	for range 3 {
+		if id := lookup("/old"); id != "old" {
+			t.Fatalf("expected resource old, got %q", id)
+		}
+	}
+	if repo.loads != 1 {
+		t.Fatalf("expected resources to be loaded once, got %d", repo.loads)
+	}
+
+	repo.resources = []entity.Resource{{ID: "new", URL: "/new", HTTPMethod: "GET"}}
+	if id := lookup("/new"); id != "" {
+		t.Fatalf("expected cached table before invalidation, got %q", id)
+	}
+	routes.Invalidate()
+	if id := lookup("/new"); id != "new" {
+		t.Fatalf("expected resource new after invalidation, got %q", id)
+	}
+	if repo.loads != 2 {
+		t.Fatalf("expected resources to be reloaded once, got %d", repo.loads)
+	}
+}
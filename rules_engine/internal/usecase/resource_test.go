@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/repository"
+)
+
+type fakeUpstreamRepo struct {
+	repository.UpstreamRepository
+	upstreams map[string]*entity.Upstream
+}
+
+func (f fakeUpstreamRepo) GetUpstream(id string) (*entity.Upstream, error) {
+	if upstream, ok := f.upstreams[id]; ok {
+		return upstream, nil
+	}
+	return nil, errors.New("connection refused")
+}
+
+func (f *fakeResourceRepo) GetResource(id string) (*entity.Resource, error) {
+	for i := range f.resources {
+		if f.resources[i].ID == id {
+			return &f.resources[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func TestResourcesFailWithoutUpstream(t *testing.T) {
+	pool, missing := "pool", "missing"
+	resources := &fakeResourceRepo{resources: []entity.Resource{
+		{ID: "direct", URL: "/direct", HTTPMethod: "GET", Host: "http://backend"},
+		{ID: "pooled", URL: "/pooled", HTTPMethod: "GET", UpstreamID: &pool},
+		{ID: "broken", URL: "/broken", HTTPMethod: "GET", UpstreamID: &missing},
+	}}
+	r := NewResourceUseCase(
+		resources,
+		NewIPListUseCase(fakeIPListRepo{}),
+		NewRuleUseCase(&fakeRuleRepo{}),
+		NewUpstreamUseCase(fakeUpstreamRepo{upstreams: map[string]*entity.Upstream{pool: {ID: pool}}}),
+		nil,
+		nil,
+		fakeExclusionRepo{},
+		NewResourceRoutes(resources, time.Minute),
+	)
+
+	if _, err := r.Get(); err == nil {
+		t.Error("expected Get to fail when an upstream cannot be loaded")
+	}
+	if _, err := r.GetResourceByID("broken"); err == nil {
+		t.Error("expected GetResourceByID to fail when the upstream cannot be loaded")
+	}
+	resource, err := r.GetResourceByID("pooled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resource.Upstream == nil || resource.Upstream.ID != pool {
+		t.Errorf("expected upstream %s, got %+v", pool, resource.Upstream)
+	}
+
+	resources.resources = resources.resources[:2]
+	list, err := r.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list[1].Upstream == nil {
+		t.Error("expected pooled resource to carry its upstream")
+	}
+}
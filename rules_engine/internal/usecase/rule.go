@@ -2,11 +2,38 @@ package usecase
 
 import (
 	"fmt"
+	"rules-engine/internal/condition"
 	"rules-engine/internal/entity"
+	"rules-engine/internal/expr"
+	"rules-engine/internal/openapi"
 	"rules-engine/internal/repository"
+	"rules-engine/internal/seclang"
+	"rules-engine/internal/transform"
+	"strings"
 	"time"
 )
 
+const (
+	attackTypeXSS        = "xss"
+	attackTypeSQLI       = "sqli"
+	attackTypeCSRF       = "csrf"
+	attackTypeCustom     = "custom"
+	attackTypeExpression = "expression"
+	attackTypeSecLang    = "seclang"
+	attackTypeOpenAPI    = "openapi"
+	attackTypeGraphQL    = "graphql"
+
+	attackTypePathTraversal = "path_traversal"
+	attackTypeCmdInjection  = "cmd_injection"
+	attackTypeSSRF          = "ssrf"
+	attackTypeLFI           = "lfi"
+	attackTypeRFI           = "rfi"
+	attackTypeNoSQL         = "nosql"
+	attackTypeLDAP          = "ldap"
+	attackTypeXXE           = "xxe"
+	attackTypeSSTI          = "ssti"
+)
+
 type RuleUseCase struct {
 	repo repository.RuleRepository
 }
@@ -19,20 +46,58 @@ func (r *RuleUseCase) Get() ([]entity.Rule, error) {
 	return r.repo.GetRules()
 }
 
-func (r *RuleUseCase) Create(name, attackType, actionType, creatorID string, isActive *bool) (*entity.Rule, error) {
+type RuleParams struct {
+	Name            string
+	AttackType      string
+	ActionType      string
+	Conditions      []entity.Condition
+	Transformations []string
+	Expression      *string
+	SecRule         *string
+	OpenAPI         *string
+	GraphQL         *entity.GraphQLPolicy
+	Severity        string
+	ParanoiaLevel   *int
+	LogOnly         *bool
+	CreatorID       string
+	IsActive        *bool
+}
+
+func (r *RuleUseCase) Create(params RuleParams) (*entity.Rule, error) {
 	rule := &entity.Rule{
-		Name:       name,
-		AttackType: attackType,
-		ActionType: entity.Action(actionType),
-		CreatorID:  creatorID,
-		IsActive:   isActive,
-		CreatedAt:  time.Now(),
+		Name:            params.Name,
+		AttackType:      params.AttackType,
+		ActionType:      entity.Action(params.ActionType),
+		Conditions:      params.Conditions,
+		Transformations: params.Transformations,
+		Severity:        entity.SeverityCritical,
+		ParanoiaLevel:   1,
+		CreatorID:       params.CreatorID,
+		IsActive:        params.IsActive,
+		CreatedAt:       time.Now(),
+	}
+	if params.Expression != nil {
+		rule.Expression = *params.Expression
+	}
+	if params.SecRule != nil {
+		rule.SecRule = *params.SecRule
+	}
+	if params.OpenAPI != nil {
+		rule.OpenAPI = *params.OpenAPI
+	}
+	setGraphQL(rule, params.GraphQL)
+	if params.LogOnly != nil {
+		rule.LogOnly = *params.LogOnly
+	}
+	setRuleScoring(rule, params)
+	if err := validateRule(rule); err != nil {
+		return nil, err
 	}
 
 	return r.repo.CreateRule(rule)
 }
 
-func (r *RuleUseCase) Update(id, name, attackType, actionType string, isActive *bool) (*entity.Rule, error) {
+func (r *RuleUseCase) Update(id string, params RuleParams) (*entity.Rule, error) {
 	rule, err := r.repo.GetRule(id)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching rule: %w", err)
@@ -42,21 +107,234 @@ func (r *RuleUseCase) Update(id, name, attackType, actionType string, isActive *
 		return nil, fmt.Errorf("rule with id=%s not found", id)
 	}
 
-	if name != "" {
-		rule.Name = name
+	if params.Name != "" {
+		rule.Name = params.Name
+	}
+	if params.AttackType != "" {
+		rule.AttackType = params.AttackType
+	}
+	if params.ActionType != "" {
+		rule.ActionType = entity.Action(params.ActionType)
+	}
+	if params.Conditions != nil {
+		rule.Conditions = params.Conditions
+	}
+	if params.Transformations != nil {
+		rule.Transformations = params.Transformations
+	}
+	if params.Expression != nil {
+		rule.Expression = *params.Expression
+	}
+	if params.SecRule != nil {
+		rule.SecRule = *params.SecRule
 	}
-	if attackType != "" {
-		rule.AttackType = attackType
+	if params.OpenAPI != nil {
+		rule.OpenAPI = *params.OpenAPI
 	}
-	if actionType != "" {
-		rule.ActionType = entity.Action(actionType)
+	setGraphQL(rule, params.GraphQL)
+	if params.LogOnly != nil {
+		rule.LogOnly = *params.LogOnly
 	}
-	if isActive != nil {
-		rule.IsActive = isActive
+	if params.IsActive != nil {
+		rule.IsActive = params.IsActive
+	}
+	setRuleScoring(rule, params)
+	if err := validateRule(rule); err != nil {
+		return nil, err
 	}
 	return r.repo.UpdateRule(rule)
 }
 
+// validateRule проверяет, что поля и действие правила подходят его типу, и компилирует его условия.
+func validateRule(rule *entity.Rule) error {
+	if rule.AttackType != attackTypeCustom && len(rule.Conditions) > 0 {
+		return fmt.Errorf("conditions are supported only for custom rules")
+	}
+	if rule.AttackType != attackTypeExpression && rule.Expression != "" {
+		return fmt.Errorf("expression is supported only for expression rules")
+	}
+	if rule.AttackType != attackTypeSecLang && rule.SecRule != "" {
+		return fmt.Errorf("sec_rule is supported only for seclang rules")
+	}
+	if rule.AttackType != attackTypeOpenAPI && rule.OpenAPI != "" {
+		return fmt.Errorf("openapi is supported only for openapi rules")
+	}
+	if rule.AttackType != attackTypeGraphQL && rule.GraphQL != nil {
+		return fmt.Errorf("graphql is supported only for graphql rules")
+	}
+	if len(rule.Transformations) > 0 {
+		// у expression свои функции декодирования, у seclang - t:-действия в тексте правила
+		if _, ok := detectors[rule.AttackType]; !ok && rule.AttackType != attackTypeCustom {
+			return fmt.Errorf("transformations are not supported for %s rules", rule.AttackType)
+		}
+		if _, err := transform.Compile(rule.Transformations); err != nil {
+			return err
+		}
+	}
+	if _, ok := severityScores[rule.Severity]; !ok {
+		return fmt.Errorf("unknown severity: %s", rule.Severity)
+	}
+	if err := validateParanoiaLevel(rule.ParanoiaLevel); err != nil {
+		return err
+	}
+
+	if d, ok := detectors[rule.AttackType]; ok {
+		// срабатывание детектора означает найденную атаку, и allow пропустил бы ее мимо остальных правил
+		switch rule.ActionType {
+		case entity.ActionBlock:
+		case entity.ActionSanitize, entity.ActionEscape:
+			if (rule.ActionType == entity.ActionSanitize && d.sanitize == nil) || (rule.ActionType == entity.ActionEscape && d.escape == nil) {
+				return fmt.Errorf("%s action is not supported for %s rules", rule.ActionType, rule.AttackType)
+			}
+		default:
+			return fmt.Errorf("%s action is not supported for %s rules", rule.ActionType, rule.AttackType)
+		}
+		return nil
+	}
+	switch rule.AttackType {
+	case attackTypeCustom, attackTypeExpression, attackTypeSecLang:
+	case attackTypeOpenAPI:
+		// запрос, который соответствует документу, не должен пропускать остальные правила ресурса
+		if rule.ActionType != entity.ActionBlock {
+			return fmt.Errorf("openapi rule action must be block")
+		}
+		if _, err := openapi.Parse(rule.OpenAPI); err != nil {
+			return fmt.Errorf("invalid openapi: %w", err)
+		}
+		return nil
+	case attackTypeGraphQL:
+		// graphql-правило не переписывает документ запроса, поэтому может только блокировать
+		if rule.ActionType != entity.ActionBlock {
+			return fmt.Errorf("graphql rule action must be block")
+		}
+		return validateGraphQLPolicy(rule.GraphQL)
+	default:
+		return nil
+	}
+
+	if rule.ActionType != entity.ActionBlock && rule.ActionType != entity.ActionAllow {
+		return fmt.Errorf("%s rule action must be block or allow", rule.AttackType)
+	}
+
+	switch rule.AttackType {
+	case attackTypeExpression:
+		if _, err := expr.Compile(rule.Expression); err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+	case attackTypeSecLang:
+		if _, err := seclang.Compile(rule.SecRule); err != nil {
+			return fmt.Errorf("invalid sec_rule: %w", err)
+		}
+	default:
+		if _, err := condition.Compile(rule.Conditions); err != nil {
+			return fmt.Errorf("invalid custom rule: %w", err)
+		}
+	}
+	return nil
+}
+
+func setRuleScoring(rule *entity.Rule, params RuleParams) {
+	if params.Severity != "" {
+		rule.Severity = params.Severity
+	}
+	if params.ParanoiaLevel != nil {
+		rule.ParanoiaLevel = *params.ParanoiaLevel
+	}
+}
+
+type ImportResult struct {
+	Imported []entity.Rule        `json:"imported"`
+	Skipped  []seclang.ParseError `json:"skipped"`
+}
+
+// Import сохраняет правила из файла SecLang как seclang-правила. правило с уже импортированным id SecRule
+// обновляется, а не дублируется. pass-правила импортируются в режиме log_only.
+func (r *RuleUseCase) Import(content, creatorID string) (*ImportResult, error) {
+	parsed, skipped := seclang.Parse(content)
+
+	existing, err := r.secRulesByID()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Imported: []entity.Rule{}, Skipped: skipped}
+	for _, secRule := range parsed {
+		rule := &entity.Rule{
+			Name:          secRuleName(secRule),
+			AttackType:    attackTypeSecLang,
+			ActionType:    secRuleAction(secRule),
+			SecRule:       secRule.Raw,
+			Severity:      secRuleSeverity(secRule.Severity),
+			ParanoiaLevel: secRule.ParanoiaLevel(),
+			LogOnly:       secRule.Disruptive == "pass",
+			CreatorID:     creatorID,
+			CreatedAt:     time.Now(),
+		}
+
+		var saved *entity.Rule
+		if current, ok := existing[secRule.ID]; ok {
+			rule.ID = current.ID
+			rule.IsActive = current.IsActive
+			rule.LogOnly = rule.LogOnly || current.LogOnly
+			saved, err = r.repo.UpdateRule(rule)
+		} else {
+			active := true
+			rule.IsActive = &active
+			saved, err = r.repo.CreateRule(rule)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save rule %d: %w", secRule.ID, err)
+		}
+		result.Imported = append(result.Imported, *saved)
+	}
+	return result, nil
+}
+
+func (r *RuleUseCase) secRulesByID() (map[int]entity.Rule, error) {
+	rules, err := r.repo.GetRules()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rules: %w", err)
+	}
+
+	byID := make(map[int]entity.Rule)
+	for _, rule := range rules {
+		if rule.AttackType != attackTypeSecLang {
+			continue
+		}
+		if secRule, err := seclang.Compile(rule.SecRule); err == nil {
+			byID[secRule.ID] = rule
+		}
+	}
+	return byID, nil
+}
+
+func secRuleName(secRule *seclang.Rule) string {
+	if secRule.Msg == "" {
+		return fmt.Sprintf("SecRule %d", secRule.ID)
+	}
+	return fmt.Sprintf("%d: %s", secRule.ID, secRule.Msg)
+}
+
+// secRuleSeverity сводит уровни syslog из SecRule к четырем severity, как это делает CRS.
+func secRuleSeverity(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "ERROR", "3":
+		return entity.SeverityError
+	case "WARNING", "4":
+		return entity.SeverityWarning
+	case "NOTICE", "INFO", "DEBUG", "5", "6", "7":
+		return entity.SeverityNotice
+	}
+	return entity.SeverityCritical
+}
+
+func secRuleAction(secRule *seclang.Rule) entity.Action {
+	if secRule.Disruptive == "allow" {
+		return entity.ActionAllow
+	}
+	return entity.ActionBlock
+}
+
 func (r *RuleUseCase) GetRuleByID(id string) (*entity.Rule, error) {
 	rule, err := r.repo.GetRule(id)
 	if err != nil {
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"rules-engine/internal/entity"
+)
+
+type ExclusionParams struct {
+	RuleID    string
+	Target    string
+	Name      string
+	IPListIDs *[]string
+	CreatorID string
+}
+
+// CreateExclusion добавляет исключение для пары ресурс-правило. правило должно быть привязано к ресурсу:
+// при отвязке правила его исключения удаляются вместе с привязкой.
+func (r *ResourceUseCase) CreateExclusion(resourceID string, params ExclusionParams) (*entity.RuleExclusion, error) {
+	if err := r.checkRuleAttached(resourceID, params.RuleID); err != nil {
+		return nil, err
+	}
+
+	exclusion := &entity.RuleExclusion{
+		ResourceID: resourceID,
+		RuleID:     params.RuleID,
+		Target:     params.Target,
+		Name:       params.Name,
+		IPListIDs:  []string{},
+		CreatorID:  params.CreatorID,
+		CreatedAt:  time.Now(),
+	}
+	if err := r.setExclusion(exclusion, params); err != nil {
+		return nil, err
+	}
+
+	return r.exclusionRepo.CreateExclusion(exclusion)
+}
+
+// UpdateExclusion меняет поле и ограничения исключения. правило исключения не меняется.
+func (r *ResourceUseCase) UpdateExclusion(resourceID, id string, params ExclusionParams) (*entity.RuleExclusion, error) {
+	exclusion, err := r.getExclusion(resourceID, id)
+	if err != nil {
+		return nil, err
+	}
+	if params.RuleID != "" && params.RuleID != exclusion.RuleID {
+		return nil, fmt.Errorf("rule_id of an exclusion cannot be changed")
+	}
+
+	if params.Target != "" {
+		exclusion.Target = params.Target
+	}
+	if params.Name != "" {
+		exclusion.Name = params.Name
+	}
+	if err := r.setExclusion(exclusion, params); err != nil {
+		return nil, err
+	}
+
+	return r.exclusionRepo.UpdateExclusion(exclusion)
+}
+
+func (r *ResourceUseCase) DeleteExclusion(resourceID, id string) error {
+	if _, err := r.getExclusion(resourceID, id); err != nil {
+		return err
+	}
+	return r.exclusionRepo.DeleteExclusion(id)
+}
+
+func (r *ResourceUseCase) getExclusion(resourceID, id string) (*entity.RuleExclusion, error) {
+	exclusion, err := r.exclusionRepo.GetExclusion(id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rule exclusion: %w", err)
+	}
+	if exclusion == nil || exclusion.ResourceID != resourceID {
+		return nil, fmt.Errorf("rule exclusion not found: id=%s", id)
+	}
+	return exclusion, nil
+}
+
+func (r *ResourceUseCase) checkRuleAttached(resourceID, ruleID string) error {
+	if _, err := r.GetResourceByID(resourceID); err != nil {
+		return err
+	}
+	rules, err := r.ruleUseCase.GetRulesForResource(resourceID)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.ID == ruleID {
+			return nil
+		}
+	}
+	return fmt.Errorf("rule %s is not attached to resource %s", ruleID, resourceID)
+}
+
+// setExclusion проверяет поле исключения и заменяет переданные списки ip.
+func (r *ResourceUseCase) setExclusion(exclusion *entity.RuleExclusion, params ExclusionParams) error {
+	switch exclusion.Target {
+	case entity.ExclusionTargetArg, entity.ExclusionTargetHeader, entity.ExclusionTargetCookie:
+	case entity.ExclusionTargetJSON:
+		// путь пишется от корня документа, без префикса json. из имен аргументов
+		exclusion.Name = strings.TrimPrefix(exclusion.Name, "json.")
+		for _, segment := range strings.Split(exclusion.Name, ".") {
+			if segment == "" {
+				return fmt.Errorf("invalid json path: %s", exclusion.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown exclusion target: %s", exclusion.Target)
+	}
+	if strings.TrimSpace(exclusion.Name) == "" {
+		return fmt.Errorf("exclusion name must not be empty")
+	}
+
+	if params.IPListIDs != nil {
+		for _, id := range *params.IPListIDs {
+			if _, err := r.iPListUseCase.getIPListByID(id); err != nil {
+				return err
+			}
+		}
+		exclusion.IPListIDs = append([]string{}, *params.IPListIDs...)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"rules-engine/internal/entity"
+)
+
+func TestExclusions(t *testing.T) {
+	active := true
+	resources := &fakeResourceRepo{resources: []entity.Resource{{ID: "form", URL: "/form", HTTPMethod: "POST", IsActive: &active}}}
+	rules := []entity.Rule{
+		{ID: "xss", Name: "xss", AttackType: attackTypeXSS, ActionType: entity.ActionBlock, IsActive: &active},
+		{
+			ID: "header", Name: "header", AttackType: attackTypeCustom, ActionType: entity.ActionBlock, IsActive: &active,
+			Conditions: entity.Conditions{{Target: "header:X-Comment", Operator: "contains", Value: "evil"}},
+		},
+	}
+	_, office, _ := net.ParseCIDR("10.1.0.0/16")
+	lists := map[string]*entity.IPList{"office": {ID: "office", IP: *office}}
+
+	const script = "<script>alert(1)</script>"
+	tests := []struct {
+		name      string
+		exclusion entity.RuleExclusion
+		request   entity.Request
+		action    entity.Action
+	}{
+		{
+			name:      "arg",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetArg, Name: "comment"},
+			request:   entity.Request{URL: "/form?comment=" + script},
+			action:    entity.ActionAllow,
+		},
+		{
+			name:      "other arg",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetArg, Name: "comment"},
+			request:   entity.Request{URL: "/form?comment=hi&title=" + script},
+			action:    entity.ActionBlock,
+		},
+		{
+			name:      "arg of another rule",
+			exclusion: entity.RuleExclusion{RuleID: "header", Target: entity.ExclusionTargetArg, Name: "comment"},
+			request:   entity.Request{URL: "/form?comment=" + script},
+			action:    entity.ActionBlock,
+		},
+		{
+			name:      "header",
+			exclusion: entity.RuleExclusion{RuleID: "header", Target: entity.ExclusionTargetHeader, Name: "x-comment"},
+			request:   entity.Request{URL: "/form", Headers: map[string]string{"X-Comment": "evil"}},
+			action:    entity.ActionAllow,
+		},
+		{
+			name:      "cookie",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetCookie, Name: "theme"},
+			request:   entity.Request{URL: "/form", Headers: map[string]string{"Cookie": "theme=" + script}},
+			action:    entity.ActionAllow,
+		},
+		{
+			name:      "other cookie",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetCookie, Name: "theme"},
+			request:   entity.Request{URL: "/form", Headers: map[string]string{"Cookie": "theme=dark; lang=" + script}},
+			action:    entity.ActionBlock,
+		},
+		{
+			name:      "json subtree",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetJSON, Name: "post"},
+			request: entity.Request{
+				URL:     "/form",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    `{"post": {"body": {"html": "` + script + `"}}}`,
+			},
+			action: entity.ActionAllow,
+		},
+		{
+			name:      "json sibling",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetJSON, Name: "post"},
+			request: entity.Request{
+				URL:     "/form",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    `{"post": "hi", "postscript": "` + script + `"}`,
+			},
+			action: entity.ActionBlock,
+		},
+		{
+			name:      "ip in list",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetArg, Name: "comment", IPListIDs: []string{"office"}},
+			request:   entity.Request{URL: "/form?comment=" + script, IP: "10.1.2.3"},
+			action:    entity.ActionAllow,
+		},
+		{
+			name:      "ip outside list",
+			exclusion: entity.RuleExclusion{RuleID: "xss", Target: entity.ExclusionTargetArg, Name: "comment", IPListIDs: []string{"office"}},
+			request:   entity.Request{URL: "/form?comment=" + script, IP: "192.0.2.1"},
+			action:    entity.ActionBlock,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnalyzerUseCase(
+				NewResourceRoutes(resources, time.Minute),
+				&fakeRuleRepo{rules: rules},
+				fakeIPListRepo{lists: lists},
+				fakeExclusionRepo{exclusions: []entity.RuleExclusion{tt.exclusion}},
+				testSigner(),
+			)
+			request := tt.request
+			request.Method = "POST"
+			if request.IP == "" {
+				request.IP = "192.0.2.1"
+			}
+			result, err := a.AnalyzeRequest(&request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Action != tt.action {
+				t.Errorf("expected %s, got %s (%s)", tt.action, result.Action, result.Reason)
+			}
+		})
+	}
+}
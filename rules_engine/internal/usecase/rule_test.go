@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"testing"
+
+	"rules-engine/internal/entity"
+)
+
+func TestValidateRuleRejectsAllowForDetectors(t *testing.T) {
+	for attackType := range detectors {
+		rule := &entity.Rule{AttackType: attackType, ActionType: entity.ActionAllow, Severity: entity.SeverityCritical, ParanoiaLevel: 1}
+		if err := validateRule(rule); err == nil {
+			t.Errorf("%s: allow action must be rejected", attackType)
+		}
+		rule.ActionType = entity.ActionBlock
+		if err := validateRule(rule); err != nil {
+			t.Errorf("%s: block action must be accepted: %v", attackType, err)
+		}
+	}
+
+	for _, rule := range []*entity.Rule{
+		{AttackType: attackTypeExpression, Expression: `request.method == "GET"`},
+		{AttackType: attackTypeSecLang, SecRule: `SecRule REQUEST_METHOD "@streq GET" "id:1,phase:1,allow"`},
+	} {
+		rule.ActionType, rule.Severity, rule.ParanoiaLevel = entity.ActionAllow, entity.SeverityCritical, 1
+		if err := validateRule(rule); err != nil {
+			t.Errorf("%s: allow action must be accepted: %v", rule.AttackType, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"rules-engine/internal/entity"
+	"rules-engine/internal/repository"
+)
+
+const (
+	defaultHealthCheckInterval = 10
+	defaultMaxFails            = 3
+	defaultFailTimeout         = 30
+)
+
+type UpstreamUseCase struct {
+	repo repository.UpstreamRepository
+}
+
+func NewUpstreamUseCase(repo repository.UpstreamRepository) *UpstreamUseCase {
+	return &UpstreamUseCase{repo: repo}
+}
+
+type UpstreamParams struct {
+	Name                string
+	Balancer            string
+	HashKey             *string
+	Targets             []string
+	HealthCheckPath     *string
+	HealthCheckInterval int
+	MaxFails            *int
+	FailTimeout         *int
+	CreatorID           string
+}
+
+func (u *UpstreamUseCase) Get() ([]entity.Upstream, error) {
+	return u.repo.GetUpstreams()
+}
+
+func (u *UpstreamUseCase) Create(params UpstreamParams) (*entity.Upstream, error) {
+	upstream := &entity.Upstream{
+		Name:                params.Name,
+		Balancer:            entity.BalancerRoundRobin,
+		Targets:             params.Targets,
+		HealthCheckInterval: defaultHealthCheckInterval,
+		MaxFails:            defaultMaxFails,
+		FailTimeout:         defaultFailTimeout,
+		CreatorID:           params.CreatorID,
+		CreatedAt:           time.Now(),
+	}
+	applyUpstreamParams(upstream, params)
+
+	if err := validateUpstream(upstream); err != nil {
+		return nil, err
+	}
+
+	return u.repo.CreateUpstream(upstream)
+}
+
+func (u *UpstreamUseCase) Update(id string, params UpstreamParams) (*entity.Upstream, error) {
+	upstream, err := u.GetUpstreamByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Name != "" {
+		upstream.Name = params.Name
+	}
+	if len(params.Targets) > 0 {
+		upstream.Targets = params.Targets
+	}
+	applyUpstreamParams(upstream, params)
+
+	if err := validateUpstream(upstream); err != nil {
+		return nil, err
+	}
+
+	return u.repo.UpdateUpstream(upstream)
+}
+
+func (u *UpstreamUseCase) GetUpstreamByID(id string) (*entity.Upstream, error) {
+	upstream, err := u.repo.GetUpstream(id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching upstream: %w", err)
+	}
+
+	if upstream == nil {
+		return nil, fmt.Errorf("upstream not found: id=%s", id)
+	}
+
+	return upstream, nil
+}
+
+func applyUpstreamParams(upstream *entity.Upstream, params UpstreamParams) {
+	if params.Balancer != "" {
+		upstream.Balancer = params.Balancer
+	}
+	if params.HashKey != nil {
+		upstream.HashKey = *params.HashKey
+	}
+	if params.HealthCheckPath != nil {
+		upstream.HealthCheckPath = *params.HealthCheckPath
+	}
+	if params.HealthCheckInterval > 0 {
+		upstream.HealthCheckInterval = params.HealthCheckInterval
+	}
+	if params.MaxFails != nil {
+		upstream.MaxFails = *params.MaxFails
+	}
+	if params.FailTimeout != nil {
+		upstream.FailTimeout = *params.FailTimeout
+	}
+}
+
+func validateUpstream(upstream *entity.Upstream) error {
+	switch upstream.Balancer {
+	case entity.BalancerRoundRobin, entity.BalancerLeastConn:
+	case entity.BalancerConsistentHash:
+		if upstream.HashKey != "ip" && !strings.HasPrefix(upstream.HashKey, "header:") {
+			return fmt.Errorf("consistent_hash balancer requires hash_key \"ip\" or \"header:<name>\"")
+		}
+	default:
+		return fmt.Errorf("unknown balancer: %s", upstream.Balancer)
+	}
+
+	if len(upstream.Targets) == 0 {
+		return fmt.Errorf("upstream must have at least one target")
+	}
+
+	for _, target := range upstream.Targets {
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid upstream target: %s", target)
+		}
+	}
+
+	if upstream.HealthCheckPath != "" && !strings.HasPrefix(upstream.HealthCheckPath, "/") {
+		return fmt.Errorf("health_check_path must start with /")
+	}
+
+	if upstream.MaxFails < 0 || upstream.FailTimeout < 0 {
+		return fmt.Errorf("max_fails and fail_timeout must not be negative")
+	}
+
+	return nil
+}
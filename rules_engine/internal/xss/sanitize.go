@@ -0,0 +1,127 @@
+package xss
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags - теги форматирования текста и их разрешенные атрибуты. все остальное удаляется
+var allowedTags = map[string][]string{
+	"a": {"href", "title"}, "abbr": {"title"}, "b": nil, "blockquote": {"cite"}, "br": nil, "code": nil,
+	"div": nil, "em": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "hr": nil,
+	"i": nil, "img": {"src", "alt", "title", "width", "height"}, "li": nil, "ol": nil, "p": nil, "pre": nil,
+	"q": {"cite"}, "s": nil, "small": nil, "span": nil, "strong": nil, "sub": nil, "sup": nil,
+	"table": nil, "tbody": nil, "td": {"colspan", "rowspan"}, "th": {"colspan", "rowspan"}, "thead": nil,
+	"tr": nil, "u": nil, "ul": nil,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// droppedContent - теги, содержимое которых является кодом или отдельным документом: оно удаляется вместе с тегом
+var droppedContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "applet": true,
+	"noscript": true, "noembed": true, "noframes": true, "template": true, "title": true, "xmp": true,
+	"svg": true, "math": true, "frameset": true, "plaintext": true,
+}
+
+var urlAttrs = map[string]bool{"href": true, "src": true, "cite": true}
+
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Sanitize оставляет из входа только разрешенные теги и атрибуты и собирает html заново: текст экранируется,
+// ссылки допускаются только http, https, mailto и относительные, незакрытые теги закрываются.
+func Sanitize(input string) string {
+	z := html.NewTokenizer(strings.NewReader(input))
+
+	var b strings.Builder
+	var open []string
+	dropped := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// тег, оборванный в конце входа, отбрасывается
+			for i := len(open) - 1; i >= 0; i-- {
+				b.WriteString("</" + open[i] + ">")
+			}
+			return b.String()
+		case html.TextToken:
+			if dropped == 0 {
+				b.WriteString(html.EscapeString(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag := z.Token()
+			if droppedContent[tag.Data] {
+				if tt == html.StartTagToken {
+					dropped++
+				}
+				continue
+			}
+			attrs, ok := allowedTags[tag.Data]
+			if dropped > 0 || !ok {
+				continue
+			}
+			writeTag(&b, tag, attrs)
+			if tt == html.StartTagToken && !voidTags[tag.Data] {
+				open = append(open, tag.Data)
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if droppedContent[string(name)] {
+				dropped = max(dropped-1, 0)
+				continue
+			}
+			if dropped > 0 {
+				continue
+			}
+			// закрывается только открытый здесь тег: лишний </div> сломал бы разметку страницы вокруг значения
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != string(name) {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+}
+
+func writeTag(b *strings.Builder, tag html.Token, allowed []string) {
+	b.WriteString("<" + tag.Data)
+	for _, attr := range tag.Attr {
+		if !contains(allowed, attr.Key) || (urlAttrs[attr.Key] && !safeURL(attr.Val)) {
+			continue
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	b.WriteByte('>')
+}
+
+// safeURL пропускает относительные ссылки и ссылки с безопасной схемой. схема ищется так же,
+// как в dangerousScheme: браузер не замечает пробелы и управляющие символы внутри нее.
+func safeURL(value string) bool {
+	compact := strings.ToLower(strings.Map(dropControl, value))
+	scheme, _, found := strings.Cut(compact, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	return safeSchemes[scheme]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Escape экранирует значение для текста html и атрибута в кавычках.
+func Escape(input string) string {
+	return html.EscapeString(input)
+}
@@ -0,0 +1,165 @@
+// Package xss ищет xss токенизатором html из golang.org/x/net/html: вход разбирается так же, как его
+// разобрал бы браузер, и проверяются теги, обработчики событий и ссылки с опасными схемами.
+//
+// значение проверяется в нескольких контекстах страницы: как текст и как продолжение значения атрибута
+// без кавычек и в кавычках. так находится и " onmouseover="alert(1), в котором нет ни одного тега.
+package xss
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// dangerousTags выполняют код, загружают чужой документ или меняют разбор страницы сами по себе
+var dangerousTags = map[string]bool{
+	"script": true, "iframe": true, "frame": true, "frameset": true, "object": true, "embed": true,
+	"applet": true, "base": true, "meta": true, "link": true, "style": true, "import": true,
+}
+
+// dangerousSchemes выполняют код при переходе по ссылке или загрузке ресурса
+var dangerousSchemes = []string{
+	"javascript:", "vbscript:", "livescript:",
+	"data:text/html", "data:text/xml", "data:image/svg+xml", "data:application/xhtml+xml", "data:application/xml",
+}
+
+// eventHandler - onerror, onload и остальные атрибуты, значение которых браузер выполняет как код
+var eventHandler = regexp.MustCompile(`^on[a-z]{3,}$`)
+
+// контекст значения на странице. атрибут-носитель a в префиксе сам не проверяется
+type context struct {
+	prefix string
+	// breakout - символы, без которых значение не может выйти за пределы атрибута
+	breakout string
+}
+
+var contexts = []context{
+	{prefix: "", breakout: "<"},
+	{prefix: "<x a=", breakout: " \t\n\r\f/>"},
+	{prefix: `<x a="`, breakout: `"`},
+	{prefix: "<x a='", breakout: "'"},
+}
+
+// closers дописываются к тегу, оборванному в конце входа: на странице его закроет первая > или кавычка после вставки
+var closers = []string{">", `">`, "'>"}
+
+// Detect сообщает, похож ли вход на xss, и возвращает описание найденного: тег, обработчик или ссылку.
+func Detect(input string) (string, bool) {
+	if !strings.ContainsAny(input, "<=:") {
+		return "", false
+	}
+	if scheme, ok := scriptURL(input); ok {
+		return scheme + " url", true
+	}
+	for _, c := range contexts {
+		if !strings.ContainsAny(input, c.breakout) {
+			continue
+		}
+		if detail, ok := scan(c.prefix+input, c.prefix != ""); ok {
+			return detail, true
+		}
+	}
+	return "", false
+}
+
+// scan проверяет все теги входа. carrier означает, что первый атрибут первого тега - это атрибут страницы,
+// в который вставлено значение, и его содержимое уже проверено scriptURL.
+func scan(s string, carrier bool) (string, bool) {
+	z := html.NewTokenizer(strings.NewReader(s))
+	for first := true; ; first = false {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				return "", false
+			}
+			return scanUnclosed(string(z.Raw()), carrier && first)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if detail, ok := checkTag(z.Token(), carrier && first); ok {
+				return detail, true
+			}
+		}
+	}
+}
+
+// scanUnclosed проверяет тег, оборванный в конце входа, как если бы страница его закрыла.
+func scanUnclosed(tail string, carrier bool) (string, bool) {
+	if !strings.HasPrefix(tail, "<") {
+		return "", false
+	}
+	for _, closer := range closers {
+		z := html.NewTokenizer(strings.NewReader(tail + closer))
+		if tt := z.Next(); tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		if detail, ok := checkTag(z.Token(), carrier); ok {
+			return detail, true
+		}
+	}
+	return "", false
+}
+
+func checkTag(tag html.Token, carrier bool) (string, bool) {
+	if dangerousTags[tag.Data] {
+		return "<" + tag.Data + "> tag", true
+	}
+	for i, attr := range tag.Attr {
+		if carrier && i == 0 {
+			continue
+		}
+		switch {
+		case eventHandler.MatchString(attr.Key) && strings.TrimSpace(attr.Val) != "":
+			return "event handler " + attr.Key + " in <" + tag.Data + ">", true
+		case attr.Key == "srcdoc":
+			return "srcdoc in <" + tag.Data + ">", true
+		case attr.Key == "style" && dangerousStyle(attr.Val):
+			return "script in style of <" + tag.Data + ">", true
+		}
+		if scheme, ok := dangerousScheme(attr.Val); ok {
+			return scheme + " url in <" + tag.Data + " " + attr.Key + ">", true
+		}
+	}
+	return "", false
+}
+
+// scriptURL находит значение, которое само является ссылкой со скриптом, например параметр redirect.
+// в отличие от атрибута, здесь нужен еще и код после схемы: иначе "javascript: the good parts" - атака.
+func scriptURL(input string) (string, bool) {
+	value := html.UnescapeString(input)
+	scheme, ok := dangerousScheme(value)
+	if !ok {
+		return "", false
+	}
+	_, code, _ := strings.Cut(value, ":")
+	return scheme, strings.ContainsAny(code, "()`=;&%\\<")
+}
+
+// dangerousScheme проверяет ссылку так, как ее прочитает браузер: без пробелов и управляющих символов
+// в любом месте схемы и без учета регистра.
+func dangerousScheme(value string) (string, bool) {
+	compact := strings.ToLower(strings.Map(dropControl, value))
+	for _, scheme := range dangerousSchemes {
+		if strings.HasPrefix(compact, scheme) {
+			return strings.TrimSuffix(scheme, ":"), true
+		}
+	}
+	return "", false
+}
+
+func dangerousStyle(value string) bool {
+	compact := strings.ToLower(strings.Map(dropControl, value))
+	for _, marker := range []string{"expression(", "javascript:", "vbscript:", "behavior:", "-moz-binding"} {
+		if strings.Contains(compact, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func dropControl(r rune) rune {
+	if r <= ' ' || r == 0x7f {
+		return -1
+	}
+	return r
+}
@@ -0,0 +1,62 @@
+// Package xxe ищет в DTD документа xml внешние сущности, параметрические сущности и сущности,
+// раскрывающиеся в другие сущности (billion laughs), а также XInclude.
+//
+// в разобранном xml-теле проверяется только DOCTYPE (см. inspect.Request.DTD): сущности объявляются в нем,
+// а текст элементов уже не может их объявить. в строковых значениях и неразобранном теле ищется весь xml.
+package xxe
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxEntityRefs - сколько ссылок на другие сущности может содержать значение одной сущности
+const maxEntityRefs = 2
+
+var (
+	externalEntity  = regexp.MustCompile(`(?i)<!ENTITY\s+(?:%\s*)?[\w.:-]+\s+(?:SYSTEM|PUBLIC)\b`)
+	parameterEntity = regexp.MustCompile(`(?i)<!ENTITY\s+%`)
+	externalDTD     = regexp.MustCompile(`(?i)<!DOCTYPE\s+[\w.:-]+\s+(?:SYSTEM|PUBLIC)\b`)
+	// standardDTD - публичные DTD html и svg с www.w3.org, которые объявляет обычная разметка
+	standardDTD = regexp.MustCompile(`(?i)<!DOCTYPE\s+[\w.:-]+\s+PUBLIC\s+("[^"]*"|'[^']*')\s+["']https?://www\.w3\.org/`)
+	entityValue = regexp.MustCompile(`(?i)<!ENTITY\s+[\w.:-]+\s+(?:"([^"]*)"|'([^']*)')`)
+	entityRef   = regexp.MustCompile(`&[\w.:-]+;`)
+	xinclude    = regexp.MustCompile(`(?i)<\w*:?include\b[^>]*\bhref\s*=`)
+)
+
+// xincludeNamespace в разобранном xml виден как значение атрибута xmlns:xi
+const xincludeNamespace = "http://www.w3.org/2001/XInclude"
+
+// Detect сообщает, объявляет ли xml сущность, которую парсер загрузит извне или размножит, и возвращает найденное.
+// принимает и содержимое директивы DOCTYPE без <! в начале, как его отдает encoding/xml.
+func Detect(input string) (string, bool) {
+	if strings.Contains(input, xincludeNamespace) {
+		return "xinclude", true
+	}
+	if !strings.Contains(input, "<") && !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(input)), "DOCTYPE") {
+		return "", false
+	}
+	doc := input
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(input)), "DOCTYPE") {
+		doc = "<!" + strings.TrimSpace(input)
+	}
+
+	if m := externalEntity.FindString(doc); m != "" {
+		return "external entity " + m, true
+	}
+	if parameterEntity.MatchString(doc) {
+		return "parameter entity", true
+	}
+	if m := externalDTD.FindString(doc); m != "" && !standardDTD.MatchString(doc) {
+		return "external dtd " + m, true
+	}
+	for _, m := range entityValue.FindAllStringSubmatch(doc, -1) {
+		if len(entityRef.FindAllString(m[1]+m[2], -1)) > maxEntityRefs {
+			return "entity expansion", true
+		}
+	}
+	if xinclude.MatchString(doc) {
+		return "xinclude", true
+	}
+	return "", false
+}
